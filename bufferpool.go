@@ -1,5 +1,10 @@
 package jumpboot
 
+import (
+	"math/bits"
+	"sync"
+)
+
 // BufferPool manages a pool of reusable byte slices to reduce GC pressure.
 // It uses a channel-based design for thread-safe access without locks.
 //
@@ -50,3 +55,71 @@ func (bp *BufferPool) Put(buf []byte) {
 		// Pool is full, just let it be garbage collected
 	}
 }
+
+// TieredBufferPool manages reusable byte slices of varying sizes, for messages
+// too large for a BufferPool. Sizes are rounded up to a power-of-two size
+// class between minSize and maxSize, each backed by a sync.Pool, so a buffer
+// is only reused for a request of a similar size. Larger requests are
+// allocated and never pooled.
+//
+// TieredBufferPool is safe for concurrent use by multiple goroutines.
+type TieredBufferPool struct {
+	minSize int
+	maxSize int
+	classes []sync.Pool
+}
+
+// NewTieredBufferPool creates an empty pool for buffers of up to maxSize
+// bytes. minSize and maxSize are rounded up to powers of two.
+func NewTieredBufferPool(minSize, maxSize int) *TieredBufferPool {
+	if minSize < 1 {
+		minSize = 1
+	}
+	if maxSize < minSize {
+		maxSize = minSize
+	}
+	minSize = 1 << bits.Len(uint(minSize-1))
+	maxSize = 1 << bits.Len(uint(maxSize-1))
+	return &TieredBufferPool{
+		minSize: minSize,
+		maxSize: maxSize,
+		classes: make([]sync.Pool, bits.Len(uint(maxSize))-bits.Len(uint(minSize))+1),
+	}
+}
+
+// class returns the index of the smallest size class holding size bytes, or
+// -1 if size is larger than maxSize.
+func (tp *TieredBufferPool) class(size int) int {
+	if size > tp.maxSize {
+		return -1
+	}
+	if size <= tp.minSize {
+		return 0
+	}
+	return bits.Len(uint(size-1)) - bits.Len(uint(tp.minSize-1))
+}
+
+// Get returns a buffer of length size from the pool, or allocates one if there
+// is none. Its capacity is the size class, which may be larger than size.
+func (tp *TieredBufferPool) Get(size int) []byte {
+	class := tp.class(size)
+	if class < 0 {
+		return make([]byte, size)
+	}
+	if buf, ok := tp.classes[class].Get().(*[]byte); ok {
+		return (*buf)[:size]
+	}
+	return make([]byte, size, tp.minSize<<class)
+}
+
+// Put returns a buffer to the pool for reuse. Buffers whose capacity isn't
+// exactly one of the size classes are discarded, as BufferPool discards
+// buffers of the wrong size.
+func (tp *TieredBufferPool) Put(buf []byte) {
+	class := tp.class(cap(buf))
+	if class < 0 || cap(buf) != tp.minSize<<class {
+		return // Wrong size, don't put it back
+	}
+	buf = buf[:cap(buf)]
+	tp.classes[class].Put(&buf)
+}
@@ -1,8 +1,12 @@
 package jumpboot
 
 import (
+	"fmt"
+	"net"
+	"reflect"
 	"sync"
 	"testing"
+	"time"
 )
 
 // TestBufferPoolConcurrent tests that BufferPool is safe for concurrent access.
@@ -95,3 +99,185 @@ func TestBufferPoolWrongSizeBuffer(t *testing.T) {
 		t.Errorf("Expected new buffer with capacity 1024, got %d", cap(buf3))
 	}
 }
+
+// TestTieredBufferPool tests that buffers are rounded up to their size class,
+// reused within it and discarded if they don't match one, and that
+// concurrent use is safe.
+func TestTieredBufferPool(t *testing.T) {
+	pool := NewTieredBufferPool(10000, 1<<20)
+
+	for _, tc := range []struct{ size, cap int }{{1, 16384}, {16384, 16384}, {16385, 32768}, {1 << 20, 1 << 20}, {1<<20 + 1, 1<<20 + 1}} {
+		buf := pool.Get(tc.size)
+		if len(buf) != tc.size || cap(buf) != tc.cap {
+			t.Errorf("Get(%d) returned len %d cap %d, want cap %d", tc.size, len(buf), cap(buf), tc.cap)
+		}
+	}
+
+	// sync.Pool may drop buffers, so reuse is retried a few times
+	reused := false
+	for i := 0; i < 10 && !reused; i++ {
+		buf := pool.Get(20000)
+		buf[0] = 42
+		pool.Put(buf)
+		again := pool.Get(30000)
+		reused = &again[0] == &buf[0]
+	}
+	if !reused {
+		t.Error("a buffer was not reused within its size class")
+	}
+
+	// Wrong sizes are discarded
+	pool.Put(make([]byte, 20000))
+	pool.Put(make([]byte, 2<<20))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				buf := pool.Get(10000 + n*1000 + j)
+				buf[len(buf)-1] = byte(j)
+				pool.Put(buf)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestQueueProcessConcurrentCalls tests that parallel Calls each receive the
+// response to their own request, even when responses arrive out of order.
+func TestQueueProcessConcurrentCalls(t *testing.T) {
+	goSide, pySide := net.Pipe()
+	peer := NewMsgpackTransport(pySide, nopWriteCloser{pySide})
+	serializer := MsgpackSerializer{}
+
+	// Fake Python peer: echo each call's arguments back, replying from
+	// separate goroutines so responses are interleaved.
+	go func() {
+		var sendMutex sync.Mutex
+		for {
+			raw, err := peer.Receive()
+			if err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if err := serializer.Unmarshal(raw, &msg); err != nil {
+				return
+			}
+			requestID, _ := msg["request_id"].(string)
+			if requestID == "" {
+				continue
+			}
+			go func(msg map[string]interface{}) {
+				reply := map[string]interface{}{"request_id": requestID}
+				if msg["command"] == "__get_methods__" {
+					reply["result"] = map[string]interface{}{}
+				} else {
+					reply["result"] = msg["data"]
+				}
+				out, err := serializer.Marshal(reply)
+				if err != nil {
+					return
+				}
+				sendMutex.Lock()
+				defer sendMutex.Unlock()
+				peer.Send(out)
+			}(msg)
+		}
+	}()
+
+	jq, err := NewQueueProcessOverConn(goSide, nil)
+	if err != nil {
+		t.Fatalf("NewQueueProcessOverConn failed: %v", err)
+	}
+	defer jq.Close()
+
+	var wg sync.WaitGroup
+	numCalls := 200
+	for i := 0; i < numCalls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, err := jq.Call("echo", 10, map[string]interface{}{"n": i})
+			if err != nil {
+				t.Errorf("Call %d failed: %v", i, err)
+				return
+			}
+			m, ok := result.(map[string]interface{})
+			if !ok {
+				t.Errorf("Call %d returned unexpected result %v", i, result)
+				return
+			}
+			if n := reflect.ValueOf(m["n"]).Convert(reflect.TypeOf(0)).Interface(); n != i {
+				t.Errorf("Call %d received the response for call %v", i, m["n"])
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestRegisterHandlerOrdered tests that an ordered handler runs one command at
+// a time in arrival order while other handlers still run concurrently.
+func TestRegisterHandlerOrdered(t *testing.T) {
+	jq, peer := newFakePeerQueue(t, nil)
+
+	const numCommands = 50
+	var mutex sync.Mutex
+	var order []int
+	active, maxActive := 0, 0
+	done := make(chan struct{})
+
+	jq.RegisterHandlerOrdered("append", func(data interface{}, requestID string) (interface{}, error) {
+		mutex.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mutex.Unlock()
+
+		// Give later commands a chance to overtake this one if ordering is broken
+		time.Sleep(time.Millisecond)
+
+		n := reflect.ValueOf(data).Convert(reflect.TypeOf(0)).Interface().(int)
+		mutex.Lock()
+		active--
+		order = append(order, n)
+		if len(order) == numCommands {
+			close(done)
+		}
+		mutex.Unlock()
+		return nil, nil
+	})
+
+	// An unordered handler that blocks until every ordered command has run
+	// shows that ordering one command does not serialize the others
+	unblocked := make(chan struct{})
+	jq.RegisterHandler("wait", func(data interface{}, requestID string) (interface{}, error) {
+		<-done
+		close(unblocked)
+		return nil, nil
+	})
+
+	peer.send(map[string]interface{}{"command": "wait", "request_id": "py-wait"})
+	for i := 0; i < numCommands; i++ {
+		peer.send(map[string]interface{}{"command": "append", "data": i, "request_id": fmt.Sprintf("py-%d", i)})
+	}
+
+	select {
+	case <-unblocked:
+	case <-time.After(10 * time.Second):
+		t.Fatal("ordered commands did not complete")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if maxActive != 1 {
+		t.Errorf("ordered handler ran %d commands concurrently", maxActive)
+	}
+	for i, n := range order {
+		if n != i {
+			t.Fatalf("commands ran out of order: %v", order)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package jumpboot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint is a version requirement such as ">=3.10,<3.13", made of
+// comma-separated PEP 440 clauses that must all match. The operators are
+// ==, !=, <, <=, >, >= and the compatible-release operator ~=. == and !=
+// also accept a trailing ".*" to match a prefix ("==3.11.*").
+//
+// Missing components count as zero, so "3.10" and "3.10.0" are the same
+// version. Pre-releases match like any other version, except that "<V"
+// excludes pre-releases of V and ">V" excludes post-releases and local
+// builds of V, as PEP 440 requires.
+type Constraint struct {
+	clauses []constraintClause
+}
+
+// constraintClause is one comparison in a Constraint.
+type constraintClause struct {
+	op      string
+	version Version
+	prefix  bool // == or != with a trailing ".*"
+}
+
+// constraintOperators lists the operators, longest first so that "<=" is
+// not read as "<".
+var constraintOperators = []string{"~=", "==", "!=", "<=", ">=", "<", ">"}
+
+// ParseConstraint parses a comma-separated list of PEP 440 version clauses,
+// such as ">=3.10,<3.13" or "~=3.11".
+func ParseConstraint(s string) (Constraint, error) {
+	var c Constraint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: empty clause", s)
+		}
+		var clause constraintClause
+		for _, op := range constraintOperators {
+			if strings.HasPrefix(part, op) {
+				clause.op = op
+				break
+			}
+		}
+		if clause.op == "" {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %q has no operator", s, part)
+		}
+		versionStr := strings.TrimSpace(part[len(clause.op):])
+		if strings.HasSuffix(versionStr, ".*") {
+			if clause.op != "==" && clause.op != "!=" {
+				return Constraint{}, fmt.Errorf("invalid version constraint %q: .* is only allowed with == and !=", s)
+			}
+			clause.prefix = true
+			versionStr = strings.TrimSuffix(versionStr, ".*")
+		}
+		v, err := parseWholeVersion(versionStr)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: %v", s, err)
+		}
+		if clause.op == "~=" && v.Minor == -1 {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: ~= needs at least two version components", s)
+		}
+		clause.version = v
+		c.clauses = append(c.clauses, clause)
+	}
+	return c, nil
+}
+
+// parseWholeVersion parses a version that must not have ignored trailing text.
+func parseWholeVersion(s string) (Version, error) {
+	v, err := ParseVersion(s)
+	if err != nil {
+		return Version{}, err
+	}
+	if v.String() != s {
+		return Version{}, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+// Matches reports whether v satisfies every clause of the constraint.
+func (c Constraint) Matches(v Version) bool {
+	for _, clause := range c.clauses {
+		if !clause.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the constraint in its canonical form, e.g. ">=3.10,<3.13".
+func (c Constraint) String() string {
+	parts := make([]string, len(c.clauses))
+	for i, clause := range c.clauses {
+		parts[i] = clause.op + clause.version.String()
+		if clause.prefix {
+			parts[i] += ".*"
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (clause constraintClause) matches(v Version) bool {
+	spec := clause.version
+	switch clause.op {
+	case "==":
+		if clause.prefix {
+			return hasVersionPrefix(v, spec)
+		}
+		return compareZeroPadded(withoutLocal(v, spec), spec) == 0
+	case "!=":
+		if clause.prefix {
+			return !hasVersionPrefix(v, spec)
+		}
+		return compareZeroPadded(withoutLocal(v, spec), spec) != 0
+	case "<=":
+		return compareZeroPadded(withoutLocal(v, spec), spec) <= 0
+	case ">=":
+		return compareZeroPadded(withoutLocal(v, spec), spec) >= 0
+	case "<":
+		if compareZeroPadded(v, spec) >= 0 {
+			return false
+		}
+		// 3.13.0rc1 is below 3.13 but is not allowed by "<3.13"
+		return spec.Prerelease != "" || !sameRelease(v, spec) || v.Prerelease == ""
+	case ">":
+		if compareZeroPadded(v, spec) <= 0 {
+			return false
+		}
+		// 3.12.post1 and 3.12+local are above 3.12 but not allowed by ">3.12"
+		return spec.Prerelease != "" || !sameRelease(v, spec)
+	case "~=":
+		// ~=3.10.2 means >=3.10.2 and ==3.10.*
+		prefix := spec
+		prefix.Prerelease = ""
+		if prefix.Patch != -1 {
+			prefix.Patch = -1
+		} else {
+			prefix.Minor = -1
+		}
+		return compareZeroPadded(withoutLocal(v, spec), spec) >= 0 && hasVersionPrefix(v, prefix)
+	}
+	return false
+}
+
+// zeroPadded returns v with missing components set to zero.
+func zeroPadded(v Version) Version {
+	if v.Minor == -1 {
+		v.Minor = 0
+	}
+	if v.Patch == -1 {
+		v.Patch = 0
+	}
+	return v
+}
+
+// compareZeroPadded compares two versions with missing components as zero.
+func compareZeroPadded(a, b Version) int {
+	a, b = zeroPadded(a), zeroPadded(b)
+	return a.Compare(b)
+}
+
+// sameRelease reports whether a and b have the same release numbers.
+func sameRelease(a, b Version) bool {
+	a, b = zeroPadded(a), zeroPadded(b)
+	return a.Major == b.Major && a.Minor == b.Minor && a.Patch == b.Patch
+}
+
+// withoutLocal drops v's local label ("+build") unless spec has one, since
+// a clause without a local label ignores it.
+func withoutLocal(v, spec Version) Version {
+	if strings.Contains(spec.Prerelease, "+") {
+		return v
+	}
+	if i := strings.Index(v.Prerelease, "+"); i >= 0 {
+		v.Prerelease = v.Prerelease[:i]
+	}
+	return v
+}
+
+// hasVersionPrefix reports whether v starts with the components of prefix,
+// as in "==3.10.*".
+func hasVersionPrefix(v, prefix Version) bool {
+	v = zeroPadded(v)
+	if v.Major != prefix.Major {
+		return false
+	}
+	if prefix.Minor != -1 && v.Minor != prefix.Minor {
+		return false
+	}
+	if prefix.Patch != -1 && v.Patch != prefix.Patch {
+		return false
+	}
+	if prefix.Prerelease != "" {
+		return comparePrerelease(withoutLocal(v, prefix).Prerelease, prefix.Prerelease) == 0
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package jumpboot
+
+import "testing"
+
+func TestConstraintMatches(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matching   []string
+		failing    []string
+	}{
+		{">=3.10,<3.13", []string{"3.10", "3.10.0", "3.11.7", "3.12.9"}, []string{"3.9.18", "3.13.0", "3.13.0rc1", "4.0"}},
+		{">= 3.10 , != 3.11.*", []string{"3.10.4", "3.12.0"}, []string{"3.11.0", "3.11.9", "3.9"}},
+		{"~=3.10", []string{"3.10.0", "3.12.1", "3.99"}, []string{"3.9.9", "4.0.0"}},
+		{"~=3.10.2", []string{"3.10.2", "3.10.14"}, []string{"3.10.1", "3.11.0"}},
+		{"==3.11.*", []string{"3.11", "3.11.0", "3.11.7", "3.11.0rc1"}, []string{"3.10.9", "3.12.0"}},
+		{"==3.11", []string{"3.11", "3.11.0", "3.11.0+local"}, []string{"3.11.1", "3.11.0rc1"}},
+		{"!=3.11.0", []string{"3.11.1", "3.10"}, []string{"3.11", "3.11.0"}},
+		{"<=3.10", []string{"3.10.0", "3.9.2"}, []string{"3.10.1"}},
+		{">3.12", []string{"3.12.1", "3.13.0"}, []string{"3.12.0", "3.12.0.post1", "3.12+local"}},
+		{"<3.13.0rc2", []string{"3.13.0rc1", "3.12.5"}, []string{"3.13.0rc2", "3.13.0"}},
+		{">=3.12.0rc1", []string{"3.12.0rc1", "3.12.0", "3.12.0rc2"}, []string{"3.12.0b4", "3.11.9"}},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q) failed: %v", tt.constraint, err)
+			continue
+		}
+		for _, s := range tt.matching {
+			v, _ := ParseVersion(s)
+			if !c.Matches(v) {
+				t.Errorf("%q should match %s", tt.constraint, s)
+			}
+		}
+		for _, s := range tt.failing {
+			v, _ := ParseVersion(s)
+			if c.Matches(v) {
+				t.Errorf("%q should not match %s", tt.constraint, s)
+			}
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, s := range []string{"", "3.10", ">=3.10,", ">=", ">=3.10.x", "~=3", ">=3.10.*", "=>3.10"} {
+		if c, err := ParseConstraint(s); err == nil {
+			t.Errorf("ParseConstraint(%q) = %v, want an error", s, c)
+		}
+	}
+
+	c, err := ParseConstraint(" >= 3.10 ,<3.13 ")
+	if err != nil || c.String() != ">=3.10,<3.13" {
+		t.Errorf("String() = %q, %v", c.String(), err)
+	}
+}
+
+func TestPythonRequirement(t *testing.T) {
+	spec, c, err := pythonRequirement("3.10")
+	if err != nil || spec != "python=3.10" {
+		t.Fatalf("pythonRequirement(3.10) = %q, %v", spec, err)
+	}
+	for v, want := range map[string]bool{"3.10.14": true, "3.12.1": true, "3.9.18": false} {
+		if got := c.Matches(mustParseVersion(t, v)); got != want {
+			t.Errorf("3.10 matches %s = %v", v, got)
+		}
+	}
+
+	spec, c, err = pythonRequirement(">=3.10, <3.13")
+	if err != nil || spec != "python>=3.10,<3.13" {
+		t.Fatalf("pythonRequirement(range) = %q, %v", spec, err)
+	}
+	if c.Matches(mustParseVersion(t, "3.13.1")) {
+		t.Error("the range accepted a newer minor version")
+	}
+	if _, _, err := pythonRequirement(">=three"); err == nil {
+		t.Error("expected an error for an invalid constraint")
+	}
+}
+
+func mustParseVersion(t *testing.T, s string) Version {
+	t.Helper()
+	v, err := ParseVersion(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
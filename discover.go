@@ -0,0 +1,228 @@
+package jumpboot
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// unixPythonName matches the interpreter names DiscoverSystemPythons looks
+// for on Unix: python, python3 and python3.N.
+var unixPythonName = regexp.MustCompile(`^python(3(\.[0-9]+)?)?$`)
+
+// DiscoverSystemPythons returns every distinct Python interpreter it can find
+// on the system, for example to let a user choose one.
+//
+// On Unix systems, it scans the directories on PATH and common install roots
+// (/usr/bin, /usr/local/bin, Homebrew, MacPorts, the python.org framework
+// builds and pyenv) for python, python3 and python3.N. On Windows, it uses the
+// installations listed by "py -0p" and scans PATH for python.exe, excluding
+// the Microsoft Store placeholders.
+//
+// Interpreters are de-duplicated by their real path, and each result only has
+// its name ("system"), RootDir, PythonPath and PythonVersion set; use
+// CreateEnvironmentFromExacutable with the PythonPath for a complete
+// environment. Returns an error if no interpreter is found.
+func DiscoverSystemPythons() ([]PythonEnvironment, error) {
+	var envs []PythonEnvironment
+	seen := make(map[string]bool)
+	for _, candidate := range discoverPythonCandidates() {
+		if !firstRealPath(seen, candidate) {
+			continue
+		}
+		version, err := probePythonVersion(candidate)
+		if err != nil {
+			continue
+		}
+		env := PythonEnvironment{PythonVersion: version}
+		env.EnvironmentName = "system"
+		env.PythonPath = candidate
+		env.RootDir = filepath.Dir(filepath.Dir(candidate))
+		envs = append(envs, env)
+	}
+	if len(envs) == 0 {
+		return nil, fmt.Errorf("python not found")
+	}
+	return envs, nil
+}
+
+// discoverPythonCandidates returns the paths of every interpreter
+// DiscoverSystemPythons considers, which may include duplicates.
+func discoverPythonCandidates() []string {
+	if runtime.GOOS == "windows" {
+		candidates := pyLauncherPythons()
+		for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+			if strings.Contains(dir, "Microsoft\\WindowsApps") {
+				continue
+			}
+			p := filepath.Join(dir, "python.exe")
+			if isExecutableFile(p) {
+				candidates = append(candidates, p)
+			}
+		}
+		return candidates
+	}
+
+	dirs := filepath.SplitList(os.Getenv("PATH"))
+	dirs = append(dirs, "/usr/bin", "/usr/local/bin", "/opt/homebrew/bin", "/opt/local/bin")
+	roots := []string{"/Library/Frameworks/Python.framework/Versions/*/bin"}
+	if home, err := os.UserHomeDir(); err == nil {
+		roots = append(roots, filepath.Join(home, ".pyenv", "versions", "*", "bin"))
+	}
+	for _, root := range roots {
+		matches, _ := filepath.Glob(root)
+		dirs = append(dirs, matches...)
+	}
+
+	var candidates []string
+	for _, dir := range dirs {
+		candidates = append(candidates, pythonsInDir(dir)...)
+	}
+	return candidates
+}
+
+// pythonsInDir returns the executables in dir named python, python3 or
+// python3.N.
+func pythonsInDir(dir string) []string {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, entry := range entries {
+		if !unixPythonName.MatchString(entry.Name()) {
+			continue
+		}
+		p := filepath.Join(dir, entry.Name())
+		if isExecutableFile(p) {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// isExecutableFile reports whether path is a regular file (following links)
+// that can be executed.
+func isExecutableFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0111 != 0
+}
+
+// pyLauncherPythons returns the interpreters listed by the Windows Python
+// launcher, or nil if it isn't installed.
+func pyLauncherPythons() []string {
+	out, err := exec.Command("py", "-0p").Output()
+	if err != nil {
+		return nil
+	}
+	return parsePyLauncherList(string(out))
+}
+
+// parsePyLauncherList parses the output of "py -0p". Each line is a version
+// tag, an optional '*' marking the default and the path, e.g.
+// " -V:3.12 *        C:\Python312\python.exe" (or " -3.8-64 ..." from older
+// launchers).
+func parsePyLauncherList(out string) []string {
+	var paths []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "-") {
+			continue
+		}
+		fields = fields[1:]
+		if fields[0] == "*" {
+			fields = fields[1:]
+		}
+		if len(fields) > 0 {
+			paths = append(paths, strings.Join(fields, " "))
+		}
+	}
+	return paths
+}
+
+// firstRealPath records path's real path in seen and reports whether it was
+// new. python3 is usually a link to one of the versioned names.
+func firstRealPath(seen map[string]bool, path string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		resolved = path
+	}
+	if seen[resolved] {
+		return false
+	}
+	seen[resolved] = true
+	return true
+}
+
+// probePythonVersion returns the version reported by "python --version".
+func probePythonVersion(pythonPath string) (Version, error) {
+	// Python 2 prints its version to stderr
+	out, err := exec.Command(pythonPath, "--version").CombinedOutput()
+	if err != nil {
+		return Version{}, fmt.Errorf("error getting Python version: %v", err)
+	}
+	return ParsePythonVersion(strings.TrimSpace(string(out)))
+}
+
+// systemPythonCandidates returns the paths of the Python interpreters found
+// on the system, in order of preference.
+func systemPythonCandidates() []string {
+	var candidates []string
+	if runtime.GOOS == "windows" {
+		candidates = pyLauncherPythons()
+		if out, err := exec.Command("where", "python").Output(); err == nil {
+			for _, p := range strings.Split(string(out), "\n") {
+				p = strings.TrimSpace(p)
+				if p != "" && !strings.Contains(p, "Microsoft\\WindowsApps") {
+					candidates = append(candidates, p)
+				}
+			}
+		}
+		return candidates
+	}
+
+	names := []string{"python3", "python"}
+	for minor := 20; minor >= 6; minor-- {
+		names = append(names, fmt.Sprintf("python3.%d", minor))
+	}
+	for _, name := range names {
+		if p, err := exec.LookPath(name); err == nil {
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates
+}
+
+// findSystemPython returns the first candidate whose version satisfies c.
+func findSystemPython(c Constraint, candidates []string) (string, error) {
+	var found []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if !firstRealPath(seen, candidate) {
+			continue
+		}
+		version, err := probePythonVersion(candidate)
+		if err != nil {
+			continue
+		}
+		if c.Matches(version) {
+			return candidate, nil
+		}
+		found = append(found, fmt.Sprintf("%s (%s)", version.String(), candidate))
+	}
+
+	if len(found) == 0 {
+		return "", fmt.Errorf("python not found")
+	}
+	return "", fmt.Errorf("no system Python satisfies %s, found: %s", c.String(), strings.Join(found, ", "))
+}
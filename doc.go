@@ -54,7 +54,7 @@
 //
 // PythonExecProcess provides simple command execution with JSON communication:
 //
-//	exec, err := env.NewPythonExecProcess(nil, nil)
+//	exec, err := env.NewPythonExecProcess(nil, nil, nil)
 //	output, err := exec.Exec("print('hello')")
 //	exec.Close()
 //
@@ -142,6 +142,21 @@
 //	print(jumpboot.config_path)  # /path/to/config
 //	print(jumpboot.debug_mode)   # True
 //
+// jumpboot.get_kv(key, type, default) reads a value with an optional type
+// check. Values must be JSON-encodable; NewPythonProcessFromProgram returns
+// an error naming the key of one that isn't, such as a channel or a function.
+// NewREPLPythonProcess and NewPythonExecProcess take KVPairs as their first
+// parameter.
+//
+// Set KVSchema to a JSON Schema for the KVPairs object to have them validated
+// before Python is launched, so a misconfiguration is reported as a Go error
+// rather than a KeyError deep inside the program:
+//
+//	program.KVSchema = []byte(`{"type": "object", "required": ["config_path"]}`)
+//
+// The validator covers the common JSON Schema keywords (see ValidateKVPairs);
+// a schema using one it does not implement, such as $ref, is rejected.
+//
 // # Debugging Support
 //
 // Python processes can be started with debugpy for remote debugging:
@@ -0,0 +1,295 @@
+package jumpboot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PackageChange describes a package present in both specifications whose
+// version (or build string) differs.
+type PackageChange struct {
+	// Name is the package name as it appears in the first specification.
+	Name string
+
+	// OldVersion is the version in the first specification.
+	OldVersion string
+
+	// NewVersion is the version in the second specification.
+	NewVersion string
+
+	// OldSource is the package source in the first specification ("conda" or "pip").
+	OldSource string
+
+	// NewSource is the package source in the second specification ("conda" or "pip").
+	NewSource string
+}
+
+// EnvDiff describes the differences between two environment specifications,
+// as returned by DiffEnvironments. Packages are reported relative to the first
+// specification: AddedPackages are only in the second, RemovedPackages are only
+// in the first.
+type EnvDiff struct {
+	// OldName is the name of the first specification.
+	OldName string
+
+	// NewName is the name of the second specification.
+	NewName string
+
+	// AddedPackages lists packages present only in the second specification.
+	AddedPackages []PackageSpec
+
+	// RemovedPackages lists packages present only in the first specification.
+	RemovedPackages []PackageSpec
+
+	// ChangedPackages lists packages present in both with different versions.
+	ChangedPackages []PackageChange
+
+	// AddedChannels lists channels present only in the second specification.
+	AddedChannels []string
+
+	// RemovedChannels lists channels present only in the first specification.
+	RemovedChannels []string
+
+	// OldPythonVersion is the Python version of the first specification.
+	OldPythonVersion string
+
+	// NewPythonVersion is the Python version of the second specification.
+	NewPythonVersion string
+}
+
+// PythonVersionChanged reports whether the two specifications request
+// different Python versions.
+func (d *EnvDiff) PythonVersionChanged() bool {
+	return d.OldPythonVersion != d.NewPythonVersion
+}
+
+// IsEmpty reports whether the two specifications are equivalent.
+func (d *EnvDiff) IsEmpty() bool {
+	return len(d.AddedPackages) == 0 &&
+		len(d.RemovedPackages) == 0 &&
+		len(d.ChangedPackages) == 0 &&
+		len(d.AddedChannels) == 0 &&
+		len(d.RemovedChannels) == 0 &&
+		!d.PythonVersionChanged()
+}
+
+// String formats the diff in a git-style layout suitable for logs and CI output.
+// Removed entries are prefixed with "-" and added entries with "+"; a changed
+// package appears as a removal followed by an addition.
+//
+// Example:
+//
+//	--- a/myenv
+//	+++ b/myenv
+//	-python 3.9
+//	+python 3.10
+//	-numpy==1.22.0 (conda)
+//	+numpy==1.24.1 (conda)
+//	+requests==2.31.0 (pip)
+func (d *EnvDiff) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n", d.OldName)
+	fmt.Fprintf(&sb, "+++ b/%s\n", d.NewName)
+
+	if d.PythonVersionChanged() {
+		fmt.Fprintf(&sb, "-python %s\n", d.OldPythonVersion)
+		fmt.Fprintf(&sb, "+python %s\n", d.NewPythonVersion)
+	}
+
+	for _, c := range d.RemovedChannels {
+		fmt.Fprintf(&sb, "-channel %s\n", c)
+	}
+	for _, c := range d.AddedChannels {
+		fmt.Fprintf(&sb, "+channel %s\n", c)
+	}
+
+	// Interleave package lines by name so the output reads like a sorted diff
+	type line struct {
+		key  string
+		text string
+	}
+	var lines []line
+	for _, p := range d.RemovedPackages {
+		lines = append(lines, line{normalizePackageName(p.Name), "-" + formatDiffPackage(p.Name, packageVersionWithBuild(p), p.Source)})
+	}
+	for _, p := range d.AddedPackages {
+		lines = append(lines, line{normalizePackageName(p.Name), "+" + formatDiffPackage(p.Name, packageVersionWithBuild(p), p.Source)})
+	}
+	for _, c := range d.ChangedPackages {
+		text := "-" + formatDiffPackage(c.Name, c.OldVersion, c.OldSource) + "\n" +
+			"+" + formatDiffPackage(c.Name, c.NewVersion, c.NewSource)
+		lines = append(lines, line{normalizePackageName(c.Name), text})
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].key < lines[j].key
+	})
+	for _, l := range lines {
+		sb.WriteString(l.text)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// formatDiffPackage renders a single package line for EnvDiff.String.
+func formatDiffPackage(name, version, source string) string {
+	s := name
+	if version != "" {
+		s += "==" + version
+	}
+	if source != "" {
+		s += " (" + source + ")"
+	}
+	return s
+}
+
+// DiffEnvironments compares two environment specifications and reports the
+// differences in packages, channels, and Python version.
+//
+// Packages are collected from both the unified Packages list and the legacy
+// CondaPackages/PipPackages fields. Package names are compared case-insensitively,
+// and a conda build string is treated as part of the version ("1.22.0=py39h1234").
+func DiffEnvironments(a, b EnvironmentSpec) EnvDiff {
+	diff := EnvDiff{
+		OldName:          a.Name,
+		NewName:          b.Name,
+		OldPythonVersion: a.PythonVersion,
+		NewPythonVersion: b.PythonVersion,
+	}
+
+	oldPackages := collectSpecPackages(a)
+	newPackages := collectSpecPackages(b)
+
+	for key, oldPkg := range oldPackages {
+		newPkg, ok := newPackages[key]
+		if !ok {
+			diff.RemovedPackages = append(diff.RemovedPackages, oldPkg)
+			continue
+		}
+		oldVersion := packageVersionWithBuild(oldPkg)
+		newVersion := packageVersionWithBuild(newPkg)
+		if oldVersion != newVersion {
+			diff.ChangedPackages = append(diff.ChangedPackages, PackageChange{
+				Name:       oldPkg.Name,
+				OldVersion: oldVersion,
+				NewVersion: newVersion,
+				OldSource:  oldPkg.Source,
+				NewSource:  newPkg.Source,
+			})
+		}
+	}
+	for key, newPkg := range newPackages {
+		if _, ok := oldPackages[key]; !ok {
+			diff.AddedPackages = append(diff.AddedPackages, newPkg)
+		}
+	}
+
+	sort.Slice(diff.AddedPackages, func(i, j int) bool {
+		return normalizePackageName(diff.AddedPackages[i].Name) < normalizePackageName(diff.AddedPackages[j].Name)
+	})
+	sort.Slice(diff.RemovedPackages, func(i, j int) bool {
+		return normalizePackageName(diff.RemovedPackages[i].Name) < normalizePackageName(diff.RemovedPackages[j].Name)
+	})
+	sort.Slice(diff.ChangedPackages, func(i, j int) bool {
+		return normalizePackageName(diff.ChangedPackages[i].Name) < normalizePackageName(diff.ChangedPackages[j].Name)
+	})
+
+	diff.AddedChannels = stringSetDifference(b.Channels, a.Channels)
+	diff.RemovedChannels = stringSetDifference(a.Channels, b.Channels)
+
+	return diff
+}
+
+// collectSpecPackages gathers every package referenced by a specification into a
+// map keyed by normalized package name. Entries in the unified Packages list take
+// precedence over the legacy string lists.
+func collectSpecPackages(spec EnvironmentSpec) map[string]PackageSpec {
+	packages := make(map[string]PackageSpec)
+
+	for _, s := range spec.CondaPackages {
+		if pkg, ok := parseCondaPackageString(s); ok {
+			packages[normalizePackageName(pkg.Name)] = pkg
+		}
+	}
+	for _, s := range spec.PipPackages {
+		if pkg, ok := parsePipPackageString(s); ok {
+			packages[normalizePackageName(pkg.Name)] = pkg
+		}
+	}
+	for _, pkg := range spec.Packages {
+		if pkg.Name == "" {
+			continue
+		}
+		packages[normalizePackageName(pkg.Name)] = pkg
+	}
+
+	return packages
+}
+
+// parseCondaPackageString parses a legacy "name=version=build" conda package string.
+func parseCondaPackageString(s string) (PackageSpec, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return PackageSpec{}, false
+	}
+	parts := strings.SplitN(s, "=", 3)
+	pkg := PackageSpec{Name: parts[0], Source: "conda"}
+	if len(parts) > 1 {
+		pkg.Version = parts[1]
+	}
+	if len(parts) > 2 {
+		pkg.Build = parts[2]
+	}
+	return pkg, true
+}
+
+// parsePipPackageString parses a legacy "name==version" pip requirement string.
+// Requirements without a pinned version (e.g., "pkg @ git+https://...") are
+// returned with an empty version.
+func parsePipPackageString(s string) (PackageSpec, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "-") {
+		return PackageSpec{}, false
+	}
+	pkg := PackageSpec{Source: "pip"}
+	if name, version, ok := strings.Cut(s, "=="); ok {
+		pkg.Name = strings.TrimSpace(name)
+		pkg.Version = strings.TrimLeft(strings.TrimSpace(version), "=")
+	} else if name, _, ok := strings.Cut(s, " @ "); ok {
+		pkg.Name = strings.TrimSpace(name)
+	} else {
+		pkg.Name = s
+	}
+	return pkg, pkg.Name != ""
+}
+
+// packageVersionWithBuild returns the version of a package including its build
+// string, if present.
+func packageVersionWithBuild(pkg PackageSpec) string {
+	if pkg.Build != "" {
+		return pkg.Version + "=" + pkg.Build
+	}
+	return pkg.Version
+}
+
+// normalizePackageName lowercases a package name for case-insensitive comparison.
+func normalizePackageName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// stringSetDifference returns the elements of a that are not in b, preserving order.
+func stringSetDifference(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, s := range b {
+		seen[s] = true
+	}
+	var retv []string
+	for _, s := range a {
+		if !seen[s] {
+			retv = append(retv, s)
+			seen[s] = true
+		}
+	}
+	return retv
+}
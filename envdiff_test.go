@@ -0,0 +1,69 @@
+package jumpboot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffEnvironments_LegacyAndUnified(t *testing.T) {
+	a := EnvironmentSpec{
+		Name:          "old",
+		Channels:      []string{"defaults"},
+		PythonVersion: "3.9",
+		CondaPackages: []string{"numpy=1.22.0=py39h1234", "zlib=1.2.13=h0"},
+		PipPackages:   []string{"Requests==2.28.0"},
+	}
+	b := EnvironmentSpec{
+		Name:          "new",
+		Channels:      []string{"conda-forge"},
+		PythonVersion: "3.10",
+		Packages: []PackageSpec{
+			{Name: "numpy", Version: "1.24.1", Build: "py310h5678", Source: "conda"},
+			{Name: "requests", Version: "2.28.0", Source: "pip"},
+			{Name: "pendulum", Version: "3.0.0", Source: "pip"},
+		},
+	}
+
+	diff := DiffEnvironments(a, b)
+
+	if !diff.PythonVersionChanged() {
+		t.Error("Expected Python version change to be reported")
+	}
+	if len(diff.AddedPackages) != 1 || diff.AddedPackages[0].Name != "pendulum" {
+		t.Errorf("Expected pendulum to be added, got %v", diff.AddedPackages)
+	}
+	if len(diff.RemovedPackages) != 1 || diff.RemovedPackages[0].Name != "zlib" {
+		t.Errorf("Expected zlib to be removed, got %v", diff.RemovedPackages)
+	}
+	if len(diff.ChangedPackages) != 1 || diff.ChangedPackages[0].Name != "numpy" {
+		t.Fatalf("Expected numpy to be changed, got %v", diff.ChangedPackages)
+	}
+	if diff.ChangedPackages[0].OldVersion != "1.22.0=py39h1234" || diff.ChangedPackages[0].NewVersion != "1.24.1=py310h5678" {
+		t.Errorf("Unexpected numpy versions: %+v", diff.ChangedPackages[0])
+	}
+	if len(diff.AddedChannels) != 1 || diff.AddedChannels[0] != "conda-forge" {
+		t.Errorf("Expected conda-forge channel to be added, got %v", diff.AddedChannels)
+	}
+	if len(diff.RemovedChannels) != 1 || diff.RemovedChannels[0] != "defaults" {
+		t.Errorf("Expected defaults channel to be removed, got %v", diff.RemovedChannels)
+	}
+
+	out := diff.String()
+	for _, want := range []string{"--- a/old", "+++ b/new", "-python 3.9", "+python 3.10", "+pendulum==3.0.0 (pip)", "-zlib==1.2.13=h0 (conda)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected diff output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestDiffEnvironments_Identical(t *testing.T) {
+	spec := EnvironmentSpec{
+		Name:          "same",
+		PythonVersion: "3.11",
+		PipPackages:   []string{"requests==2.31.0"},
+	}
+	diff := DiffEnvironments(spec, spec)
+	if !diff.IsEmpty() {
+		t.Errorf("Expected no differences, got:\n%s", diff.String())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -119,6 +120,384 @@ func (env *PythonEnvironment) Freeze(filePath string) error {
 	return env.FreezeToFile(filePath)
 }
 
+// Remove deletes the environment from disk.
+//
+// Micromamba environments are removed with "micromamba env remove" so that
+// micromamba's metadata stays consistent. Virtual environments are removed by
+// deleting their directory tree. A system Python is never removed: EnvPath
+// must hold a conda-meta directory (micromamba) or a pyvenv.cfg file (venv).
+//
+// Remove refuses to delete an environment while a Python process launched from
+// it is still running. On success, the environment's paths are cleared so that
+// accidental reuse fails immediately.
+func (env *PythonEnvironment) Remove() error {
+	if env.EnvPath == "" && env.MicromambaPath == "" && env.PythonPath != "" {
+		return fmt.Errorf("refusing to remove %s: it is a system Python, not a micromamba environment or virtual environment", env.PythonPath)
+	}
+	if env.EnvPath == "" {
+		return fmt.Errorf("environment %s has no path; it may already have been removed", env.EnvironmentName)
+	}
+
+	// Only remove directories that carry an environment marker
+	marker := filepath.Join(env.EnvPath, "pyvenv.cfg")
+	if env.MicromambaPath != "" {
+		marker = filepath.Join(env.EnvPath, "conda-meta")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		return fmt.Errorf("refusing to remove %s: not a micromamba environment or virtual environment", env.EnvPath)
+	}
+
+	if environmentInUse(env.EnvPath) {
+		return fmt.Errorf("environment %s is in use by a running Python process", env.EnvironmentName)
+	}
+	defer env.lock()()
+
+	if env.MicromambaPath != "" {
+		cmd := exec.Command(env.MicromambaPath, "env", "remove", "-n", env.EnvironmentName, "-y")
+		cmd.Env = append(os.Environ(), "MAMBA_ROOT_PREFIX="+env.RootDir)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("error removing environment: %v - %s", err, string(output))
+		}
+	} else {
+		if err := os.RemoveAll(env.EnvPath); err != nil {
+			return fmt.Errorf("error removing environment directory: %v", err)
+		}
+	}
+
+	// Clear the paths so the removed environment can't be used by mistake
+	env.EnvPath = ""
+	env.EnvBinPath = ""
+	env.EnvLibPath = ""
+	env.PythonPath = ""
+	env.PythonLibPath = ""
+	env.PipPath = ""
+	env.PythonHeadersPath = ""
+	env.SitePackagesPath = ""
+
+	return nil
+}
+
+// Relocate moves the environment to newPath and rewrites the absolute paths
+// that a plain move would leave pointing at the old location.
+//
+// Text files in the environment's bin (or Scripts) directory, such as pip
+// entry-point shebangs and activation scripts, the venv's pyvenv.cfg, and a
+// conda environment's conda-meta and etc/conda directories have the old path
+// replaced with the new one wherever it appears as a whole path or path
+// prefix, so relocating /envs/foo leaves /envs/foobar alone. Binary files and
+// symlinks are left untouched.
+//
+// Other files that embed the old prefix are not rewritten. In conda
+// environments these include compiled libraries and binaries, and text files
+// such as lib/pkgconfig/*.pc or the sysconfig data of Python itself; tools
+// that read them may still see the old path.
+//
+// Micromamba environments must stay inside RootDir/envs, because micromamba
+// looks them up by name; relocating one there renames it to the base name of
+// newPath. Virtual environments may be moved anywhere. A system Python is
+// never relocated.
+//
+// Relocate refuses to move an environment while a Python process launched from
+// it is still running. The move uses os.Rename, so newPath must be on the same
+// filesystem and must not already exist.
+func (env *PythonEnvironment) Relocate(newPath string) error {
+	if env.EnvPath == "" {
+		return fmt.Errorf("environment %s has no path; it may have been removed", env.EnvironmentName)
+	}
+
+	newPath, err := filepath.Abs(newPath)
+	if err != nil {
+		return fmt.Errorf("error resolving new path: %v", err)
+	}
+	oldPath := env.EnvPath
+	if newPath == oldPath {
+		return nil
+	}
+
+	if env.MicromambaPath != "" {
+		if filepath.Dir(newPath) != filepath.Join(env.RootDir, "envs") {
+			return fmt.Errorf("micromamba environments must remain in %s", filepath.Join(env.RootDir, "envs"))
+		}
+	} else if _, err := os.Stat(filepath.Join(oldPath, "pyvenv.cfg")); err != nil {
+		return fmt.Errorf("refusing to relocate %s: not a micromamba environment or virtual environment", oldPath)
+	}
+
+	if environmentInUse(oldPath) {
+		return fmt.Errorf("environment %s is in use by a running Python process", env.EnvironmentName)
+	}
+	defer lockEnvironmentPath(oldPath)()
+
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("destination already exists: %s", newPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("error creating directory: %v", err)
+	}
+
+	// 1. Move the environment directory.
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return fmt.Errorf("error moving environment: %v", err)
+	}
+
+	// 2. Update the environment's paths.
+	relocate := func(p string) string {
+		if p == oldPath || strings.HasPrefix(p, oldPath+string(filepath.Separator)) {
+			return newPath + p[len(oldPath):]
+		}
+		return p
+	}
+	env.EnvPath = newPath
+	env.EnvBinPath = relocate(env.EnvBinPath)
+	env.EnvLibPath = relocate(env.EnvLibPath)
+	env.PythonPath = relocate(env.PythonPath)
+	env.PythonLibPath = relocate(env.PythonLibPath)
+	env.PipPath = relocate(env.PipPath)
+	env.PythonHeadersPath = relocate(env.PythonHeadersPath)
+	env.SitePackagesPath = relocate(env.SitePackagesPath)
+	env.EnvironmentName = filepath.Base(newPath)
+	if env.MicromambaPath == "" {
+		env.RootDir = newPath
+	}
+
+	// 3. Rewrite path references in scripts and conda metadata.
+	files := []string{filepath.Join(newPath, "pyvenv.cfg")}
+	if entries, err := os.ReadDir(env.EnvBinPath); err == nil {
+		for _, entry := range entries {
+			files = append(files, filepath.Join(env.EnvBinPath, entry.Name()))
+		}
+	}
+	for _, dir := range []string{filepath.Join(newPath, "conda-meta"), filepath.Join(newPath, "etc", "conda")} {
+		filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				files = append(files, p)
+			}
+			return nil
+		})
+	}
+	for _, file := range files {
+		if err := rewritePathReferences(file, oldPath, newPath); err != nil {
+			return fmt.Errorf("error rewriting paths in %s: %v", file, err)
+		}
+	}
+
+	return nil
+}
+
+// rewritePathReferences replaces oldPath with newPath in a text file, where
+// replacePathPrefix finds it. Missing files, directories, symlinks, and binary
+// files are skipped.
+func rewritePathReferences(file string, oldPath string, newPath string) error {
+	info, err := os.Lstat(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	// Treat files with NUL bytes near the start as binary
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	if bytes.IndexByte(head, 0) != -1 {
+		return nil
+	}
+
+	data, changed := replacePathPrefix(data, oldPath, newPath)
+	if !changed {
+		return nil
+	}
+	return os.WriteFile(file, data, info.Mode().Perm())
+}
+
+// replacePathPrefix replaces the occurrences of oldPath in data that are
+// followed by a path separator, a quote, whitespace or the end of the data,
+// and reports whether it replaced any. Other occurrences, such as the start
+// of /envs/foobar when oldPath is /envs/foo, are names of other paths.
+func replacePathPrefix(data []byte, oldPath string, newPath string) ([]byte, bool) {
+	old := []byte(oldPath)
+	var out []byte
+	changed := false
+	rest := data
+	for {
+		i := bytes.Index(rest, old)
+		if i < 0 {
+			break
+		}
+		end := i + len(old)
+		if end < len(rest) && !strings.ContainsRune("/\\\"' \t\r\n", rune(rest[end])) {
+			out = append(out, rest[:end]...)
+			rest = rest[end:]
+			continue
+		}
+		out = append(out, rest[:i]...)
+		out = append(out, newPath...)
+		rest = rest[end:]
+		changed = true
+	}
+	if !changed {
+		return data, false
+	}
+	return append(out, rest...), true
+}
+
+// RepairVenv repairs a virtual environment whose base Python has moved or been
+// removed, so that it runs with newBasePython instead.
+//
+// Dangling interpreter symlinks in the venv's bin directory (python, python3,
+// python3.X) are repointed at newBasePython, and the home and executable keys
+// of pyvenv.cfg are updated. If the interpreter still does not run afterwards,
+// RepairVenv falls back to "python -m venv --upgrade" with newBasePython.
+//
+// newBasePython may be a launcher such as a pyenv shim, in which case the
+// interpreter it runs is used. It must have the same major and minor version
+// the venv was created with, because installed packages live in a
+// version-specific site-packages directory. RepairVenv refuses to run while a Python process
+// launched from the environment is still running.
+func (env *PythonEnvironment) RepairVenv(newBasePython string) error {
+	if env.EnvPath == "" {
+		return fmt.Errorf("environment %s has no path; it may have been removed", env.EnvironmentName)
+	}
+	cfgPath := filepath.Join(env.EnvPath, "pyvenv.cfg")
+	if _, err := os.Stat(cfgPath); err != nil {
+		return fmt.Errorf("%s is not a virtual environment", env.EnvPath)
+	}
+	if environmentInUse(env.EnvPath) {
+		return fmt.Errorf("environment %s is in use by a running Python process", env.EnvironmentName)
+	}
+	defer env.lock()()
+
+	newBasePython, err := filepath.Abs(newBasePython)
+	if err != nil {
+		return fmt.Errorf("error resolving base Python path: %v", err)
+	}
+	if info, err := os.Stat(newBasePython); err != nil || info.IsDir() {
+		return fmt.Errorf("base Python not found: %s", newBasePython)
+	}
+
+	// Resolve launchers such as pyenv shims to the interpreter they run; a venv
+	// linked to a shim script would not find its pyvenv.cfg.
+	if out, err := exec.Command(newBasePython, "-c", "import sys; print(sys.executable)").Output(); err == nil {
+		if exe := strings.TrimSpace(string(out)); exe != "" {
+			newBasePython = exe
+		}
+	}
+
+	// 1. Check that the new base Python matches the venv's version.
+	versionOutput, err := exec.Command(newBasePython, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("error getting base Python version: %v", err)
+	}
+	baseVersion, err := ParsePythonVersion(strings.TrimSpace(string(versionOutput)))
+	if err != nil {
+		return fmt.Errorf("error parsing base Python version: %v", err)
+	}
+	if env.PythonVersion.Major != 0 && (baseVersion.Major != env.PythonVersion.Major || baseVersion.Minor != env.PythonVersion.Minor) {
+		return fmt.Errorf("base Python %s is version %d.%d but the environment was created with %d.%d",
+			newBasePython, baseVersion.Major, baseVersion.Minor, env.PythonVersion.Major, env.PythonVersion.Minor)
+	}
+
+	// 2. Repoint dangling interpreter symlinks. Links to a sibling (such as
+	// python3 -> python) are left alone when repairing their target fixes them.
+	dangling := func(path string) bool {
+		info, err := os.Lstat(path)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return false
+		}
+		_, err = os.Stat(path)
+		return err != nil
+	}
+	repoint := func(path string) error {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		return os.Symlink(newBasePython, path)
+	}
+
+	entries, err := os.ReadDir(env.EnvBinPath)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", env.EnvBinPath, err)
+	}
+	var siblings []string
+	for _, entry := range entries {
+		path := filepath.Join(env.EnvBinPath, entry.Name())
+		if !strings.HasPrefix(entry.Name(), "python") || !dangling(path) {
+			continue
+		}
+		if target, err := os.Readlink(path); err == nil && !filepath.IsAbs(target) && !strings.ContainsRune(target, filepath.Separator) {
+			siblings = append(siblings, path)
+			continue
+		}
+		if err := repoint(path); err != nil {
+			return fmt.Errorf("error repairing %s: %v", path, err)
+		}
+	}
+	for _, path := range siblings {
+		if dangling(path) {
+			if err := repoint(path); err != nil {
+				return fmt.Errorf("error repairing %s: %v", path, err)
+			}
+		}
+	}
+
+	// 3. Point pyvenv.cfg at the new base Python.
+	if err := updateVenvConfig(cfgPath, map[string]string{
+		"home":       filepath.Dir(newBasePython),
+		"executable": newBasePython,
+	}); err != nil {
+		return fmt.Errorf("error updating %s: %v", cfgPath, err)
+	}
+
+	// 4. Fall back to letting venv rebuild the environment if it still fails to run.
+	if err := exec.Command(env.PythonPath, "-c", "import sys").Run(); err != nil {
+		upgradeCmd := exec.Command(newBasePython, "-m", "venv", "--upgrade", env.EnvPath)
+		if output, err := upgradeCmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("error upgrading virtual environment: %v\n%s", err, output)
+		}
+		if err := exec.Command(env.PythonPath, "-c", "import sys").Run(); err != nil {
+			return fmt.Errorf("virtual environment is still broken after repair: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// updateVenvConfig sets existing keys in a pyvenv.cfg file, leaving other
+// lines untouched. Keys not present in the file are not added.
+func updateVenvConfig(cfgPath string, values map[string]string) error {
+	info, err := os.Stat(cfgPath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		key, _, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		if value, ok := values[strings.TrimSpace(key)]; ok {
+			lines[i] = strings.TrimSpace(key) + " = " + value
+		}
+	}
+
+	return os.WriteFile(cfgPath, []byte(strings.Join(lines, "\n")), info.Mode().Perm())
+}
+
 // VenvOptions configures the creation of a Python virtual environment.
 // These options correspond to the flags available in Python's venv module.
 type VenvOptions struct {
@@ -165,6 +544,11 @@ type PackageSpec struct {
 
 	// Source indicates where the package came from ("conda" or "pip").
 	Source string `json:"source,omitempty"`
+
+	// Channel is the conda channel the package was installed from (conda
+	// packages only). Restoring pins the package to this channel, so a spec
+	// with mixed channels reproduces each package from its original source.
+	Channel string `json:"channel,omitempty"`
 }
 
 // EnvironmentSpec represents a complete environment specification that can be
@@ -204,6 +588,18 @@ type RestoreOptions struct {
 	// Strict fails the restore if any package with a checksum fails verification,
 	// or if VerifyChecksums is true and a package lacks a checksum.
 	Strict bool
+
+	// MaxParallel is the maximum number of conda packages downloaded
+	// concurrently. Conda packages are installed in a single micromamba
+	// transaction (concurrent transactions on one prefix would serialize on its
+	// lock or undo each other), so parallelism applies to the downloads.
+	// Values less than or equal to 1 keep micromamba's default.
+	// Pip packages are always installed in a single batched pip invocation.
+	MaxParallel int
+
+	// DryRun resolves the specification without installing anything; see
+	// RestoreEnvironmentFromJSONFile, which returns the resulting plan.
+	DryRun bool
 }
 
 // CreateEnvironmentOptions specifies feedback verbosity during environment creation.
@@ -211,7 +607,10 @@ type CreateEnvironmentOptions int
 
 // ProgressCallback is called during long-running operations to report progress.
 // The message describes the current operation, current is the progress value,
-// and total is the expected total (-1 if unknown).
+// and total is the expected total (-1 if unknown). While packages download,
+// current and total are byte counts; otherwise current counts output lines.
+// For pip, the message names the package being collected, built or installed
+// when pip's output says which it is.
 type ProgressCallback func(message string, current, total int64)
 
 const (
@@ -226,12 +625,15 @@ const (
 )
 
 // CreateEnvironmentMamba creates a new Python environment using micromamba.
-// If micromamba is not present in the rootDir/bin directory, it will be downloaded automatically.
+// If micromamba is not present in the rootDir/bin directory, it will be downloaded automatically,
+// retrying transient failures as configured with SetDownloadOptions. Output
+// verbosity and download concurrency can be set with SetMicromambaOptions.
 //
 // Parameters:
 //   - envName: Name for the new environment (e.g., "myenv")
 //   - rootDir: Root directory for micromamba and environments
-//   - pythonVersion: Python version to install (e.g., "3.10"); defaults to "3.10" if empty
+//   - pythonVersion: Python version to install (e.g., "3.10"), or a version constraint
+//     such as ">=3.10,<3.13" (see ParseConstraint); defaults to "3.10" if empty
 //   - channel: Conda channel to use (e.g., "conda-forge"); uses default if empty
 //   - progressCallback: Optional callback for progress updates; may be nil
 //
@@ -245,7 +647,7 @@ func CreateEnvironmentMamba(envName string, rootDir string, pythonVersion string
 		pythonVersion = "3.10"
 	}
 
-	requestedVersion, err := ParseVersion(pythonVersion)
+	pythonSpec, requirement, err := pythonRequirement(pythonVersion)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing requested python version: %v", err)
 	}
@@ -326,34 +728,34 @@ func CreateEnvironmentMamba(envName string, rootDir string, pythonVersion string
 		env.IsNew = true
 
 		// Create a new Python environment with micromamba
-		cmdargs := []string{"--root-prefix", env.RootDir, "create", "-n", env.EnvironmentName, "python=" + pythonVersion, "-y"}
+		cmdargs := []string{"--root-prefix", env.RootDir, "create", "-n", env.EnvironmentName, pythonSpec, "-y"}
 		if channel != "" {
 			cmdargs = append(cmdargs, "-c", channel)
 		}
+		network := getNetworkConfig()
+		mambaOpts := getMicromambaOptions()
+		cmdargs = append(cmdargs, network.micromambaArgs()...)
+		cmdargs = append(cmdargs, mambaOpts.args()...)
 
 		createEnvCmd := exec.Command(env.MicromambaPath, cmdargs...)
-		createEnvCmd.Env = append(os.Environ(), "MAMBA_ROOT_PREFIX="+env.RootDir)
+		createEnvCmd.Env = append(mambaOpts.env(network.micromambaEnv()), "MAMBA_ROOT_PREFIX="+env.RootDir)
 
-		stdout, err := createEnvCmd.StdoutPipe()
-		if err != nil {
-			return nil, err
+		// Report download sizes from micromamba's output, and line counts
+		// for the rest of it
+		var progress *lineWriter
+		if progressCallback != nil {
+			progress = &lineWriter{onLine: (&micromambaProgress{callback: progressCallback, description: "Creating Python environment..."}).line}
+			createEnvCmd.Stdout = progress
 		}
-		defer stdout.Close()
 
 		if err := createEnvCmd.Start(); err != nil {
 			return nil, err
 		}
-
-		scanner := bufio.NewScanner(stdout)
-		lineCount := 0
-		for scanner.Scan() {
-			lineCount++
-			if progressCallback != nil {
-				progressCallback("Creating Python environment...", int64(lineCount), -1)
-			}
+		err := createEnvCmd.Wait()
+		if progress != nil {
+			progress.Flush()
 		}
-
-		if err := createEnvCmd.Wait(); err != nil {
+		if err != nil {
 			return nil, fmt.Errorf("error creating environment: %v", err)
 		}
 
@@ -374,31 +776,33 @@ func CreateEnvironmentMamba(envName string, rootDir string, pythonVersion string
 		env.PipPath = filepath.Join(env.EnvBinPath, "pip")
 	}
 
-	env.SitePackagesPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "lib", "python"+requestedVersion.MinorString(), "site-packages")
+	// Check if the Python executable exists and get its version
+	pver, err := RunReadStdout(env.PythonPath, "--version")
+	if err != nil {
+		return nil, fmt.Errorf("error running python --version: %v", err)
+	}
+	env.PythonVersion, err = ParsePythonVersion(pver)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Python version: %v", err)
+	}
+
+	// The paths depend on the installed version, which a constraint leaves open
+	env.SitePackagesPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "lib", "python"+env.PythonVersion.MinorString(), "site-packages")
 
 	// find the python lib path
 	env.EnvLibPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "lib")
 	env.PythonLibPath = env.EnvLibPath
 	if platform == "windows" {
-		env.PythonLibPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "python"+requestedVersion.MinorStringCompact()+".dll")
+		env.PythonLibPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "python"+env.PythonVersion.MinorStringCompact()+".dll")
 	} else if platform == "darwin" {
-		env.PythonLibPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "lib", "libpython"+requestedVersion.MinorString()+".dylib")
+		env.PythonLibPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "lib", "libpython"+env.PythonVersion.MinorString()+".dylib")
 	} else {
-		env.PythonLibPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "lib", "libpython"+requestedVersion.MinorString()+".so")
+		env.PythonLibPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "lib", "libpython"+env.PythonVersion.MinorString()+".so")
 	}
 
 	// find the python headers path
-	env.PythonHeadersPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "include", "python"+requestedVersion.MinorString())
+	env.PythonHeadersPath = filepath.Join(env.RootDir, "envs", env.EnvironmentName, "include", "python"+env.PythonVersion.MinorString())
 
-	// Check if the Python executable exists and get its version
-	pver, err := RunReadStdout(env.PythonPath, "--version")
-	if err != nil {
-		return nil, fmt.Errorf("error running python --version: %v", err)
-	}
-	env.PythonVersion, err = ParsePythonVersion(pver)
-	if err != nil {
-		return nil, fmt.Errorf("error parsing Python version: %v", err)
-	}
 	// Check if the Python lib exists
 	if _, err := os.Stat(env.PythonLibPath); os.IsNotExist(err) {
 		env.PythonLibPath = ""
@@ -414,14 +818,35 @@ func CreateEnvironmentMamba(envName string, rootDir string, pythonVersion string
 		return nil, fmt.Errorf("error parsing pip version: %v", err)
 	}
 
-	// ensure the python version is equal or greater than the requested version
-	if env.PythonVersion.Compare(requestedVersion) < 0 {
-		return nil, fmt.Errorf("requested python version %s is not available, found %s", requestedVersion.String(), env.PythonVersion.String())
+	// ensure the python version satisfies the requested version
+	if !requirement.Matches(env.PythonVersion) {
+		return nil, fmt.Errorf("requested python version %s is not available, found %s", pythonVersion, env.PythonVersion.String())
 	}
 
 	return env, nil
 }
 
+// pythonRequirement interprets the pythonVersion argument of
+// CreateEnvironmentMamba. A version such as "3.10" asks micromamba for
+// python=3.10 and accepts that version or newer; a constraint such as
+// ">=3.10,<3.13" is passed on as a match spec and the installed version must
+// satisfy it.
+func pythonRequirement(pythonVersion string) (string, Constraint, error) {
+	pythonVersion = strings.TrimSpace(pythonVersion)
+	if pythonVersion != "" && strings.ContainsAny(pythonVersion[:1], "<>=!~") {
+		constraint, err := ParseConstraint(pythonVersion)
+		if err != nil {
+			return "", Constraint{}, err
+		}
+		return "python" + constraint.String(), constraint, nil
+	}
+	version, err := ParseVersion(pythonVersion)
+	if err != nil {
+		return "", Constraint{}, err
+	}
+	return "python=" + pythonVersion, Constraint{clauses: []constraintClause{{op: ">=", version: version}}}, nil
+}
+
 // CreateEnvironmentFromExacutable creates a PythonEnvironment from an existing Python executable.
 // This is useful when you have a specific Python installation you want to use.
 //
@@ -593,6 +1018,29 @@ func CreateEnvironmentFromSystem() (*PythonEnvironment, error) {
 	return CreateEnvironmentFromExacutable(pythonPath)
 }
 
+// CreateEnvironmentFromSystemWithConstraint creates a PythonEnvironment using
+// the first system Python installation whose version satisfies constraint,
+// such as ">=3.11" (see ParseConstraint).
+//
+// On Unix systems, it tries "python3" and "python", then versioned names from
+// "python3.20" down to "python3.6". On Windows, it tries the installations
+// listed by "py -0p", then "python" (excluding the Microsoft Store
+// placeholder).
+//
+// If no installation satisfies the constraint, the error lists the versions
+// that were found.
+func CreateEnvironmentFromSystemWithConstraint(constraint string) (*PythonEnvironment, error) {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing version constraint: %v", err)
+	}
+	pythonPath, err := findSystemPython(c, systemPythonCandidates())
+	if err != nil {
+		return nil, err
+	}
+	return CreateEnvironmentFromExacutable(pythonPath)
+}
+
 // CreateVenvEnvironment creates a Python virtual environment using the venv module.
 //
 // Parameters:
@@ -622,6 +1070,7 @@ func CreateVenvEnvironment(baseEnv *PythonEnvironment, venvPath string, options
 		BaseEnvironment: BaseEnvironment{
 			EnvironmentName: filepath.Base(venvPath),
 			RootDir:         venvPath,
+			EnvPath:         venvPath,
 			IsNew:           !envExists || options.Clear, // Set IsNew if the env doesn't exist or if clear is true
 		},
 	}
@@ -659,7 +1108,10 @@ func CreateVenvEnvironment(baseEnv *PythonEnvironment, venvPath string, options
 	var stderr bytes.Buffer
 	venvCmd := exec.Command(baseEnv.PythonPath, args...)
 	venvCmd.Stderr = &stderr // Capture stderr output
-	if err := venvCmd.Run(); err != nil {
+	unlock := lockEnvironmentPath(venvPath)
+	err := venvCmd.Run()
+	unlock()
+	if err != nil {
 		// Include stderr in the error message
 		return nil, fmt.Errorf("failed to create/update virtual environment: %v, stderr: %s", err, stderr.String())
 	}
@@ -783,7 +1235,9 @@ func CreateVenvEnvironment(baseEnv *PythonEnvironment, venvPath string, options
 //   - Pip packages with versions
 //   - Conda channels used
 //
-// Packages installed via pip are not duplicated in the conda package list.
+// Conda packages are also recorded in the unified Packages list together with
+// the channel each was installed from. Packages installed via pip are not
+// duplicated in the conda package list.
 // The resulting JSON file can be used with CreateEnvironmentFromJSONFile to
 // recreate an identical environment.
 //
@@ -888,6 +1342,13 @@ func (env *PythonEnvironment) FreezeToFile(filePath string) error {
 				packageString = fmt.Sprintf("%s=%s", name, version)
 			}
 			spec.CondaPackages = append(spec.CondaPackages, packageString)
+			spec.Packages = append(spec.Packages, PackageSpec{
+				Name:    name,
+				Version: version,
+				Build:   buildString,
+				Source:  "conda",
+				Channel: channel,
+			})
 
 			if channelOk {
 				found := false
@@ -918,15 +1379,164 @@ func (env *PythonEnvironment) FreezeToFile(filePath string) error {
 	return nil
 }
 
+// FreezeAndVerify freezes the environment to filePath and checks that the
+// resulting specification reproduces the environment.
+//
+// The specification is restored into a temporary root directory: micromamba
+// environments with CreateEnvironmentFromJSONFile, and virtual environments
+// and system Pythons as a venv of the same base Python with the pip packages
+// installed. The restored environment is frozen again and compared with
+// DiffEnvironments, considering only the package sources (conda or pip) the
+// original specification recorded. The temporary root is removed afterwards.
+// The frozen file at filePath is kept either way.
+//
+// Returns an error describing the discrepancies if the round trip does not
+// reproduce the environment, or if freezing or restoring fails.
+func (env *PythonEnvironment) FreezeAndVerify(filePath string) error {
+	// 1. Freeze the original environment.
+	if err := env.FreezeToFile(filePath); err != nil {
+		return fmt.Errorf("error freezing environment: %v", err)
+	}
+	originalSpec, err := readEnvironmentSpec(filePath)
+	if err != nil {
+		return err
+	}
+
+	// 2. Restore into a temporary root, the same way the environment was made.
+	tempRoot, err := os.MkdirTemp("", "jumpboot-verify-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempRoot)
+
+	var restored *PythonEnvironment
+	if env.MicromambaPath != "" {
+		restored, err = CreateEnvironmentFromJSONFile(filePath, tempRoot, nil)
+	} else {
+		restored, err = env.restoreAsVenv(originalSpec, filepath.Join(tempRoot, originalSpec.Name))
+	}
+	if err != nil {
+		return fmt.Errorf("error restoring frozen environment: %v", err)
+	}
+
+	// 3. Freeze the restored environment and compare.
+	restoredFile := filepath.Join(tempRoot, "restored.json")
+	if err := restored.FreezeToFile(restoredFile); err != nil {
+		return fmt.Errorf("error freezing restored environment: %v", err)
+	}
+	restoredSpec, err := readEnvironmentSpec(restoredFile)
+	if err != nil {
+		return err
+	}
+
+	return verifyRestoredSpec(originalSpec, restoredSpec)
+}
+
+// restoreAsVenv recreates a pip-only specification as a virtual environment
+// at venvPath, using the base Python of env (or env itself for a system Python).
+func (env *PythonEnvironment) restoreAsVenv(spec EnvironmentSpec, venvPath string) (*PythonEnvironment, error) {
+	basePython := env.PythonPath
+	if cfg, err := readVenvConfig(filepath.Join(env.EnvPath, "pyvenv.cfg")); err == nil {
+		if executable := cfg["executable"]; executable != "" {
+			basePython = executable
+		} else if home := cfg["home"]; home != "" {
+			basePython = filepath.Join(home, filepath.Base(env.PythonPath))
+		}
+	}
+
+	baseEnv, err := CreateEnvironmentFromExacutable(basePython)
+	if err != nil {
+		return nil, fmt.Errorf("error finding base Python %s: %v", basePython, err)
+	}
+	venv, err := CreateVenvEnvironment(baseEnv, venvPath, VenvOptions{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(spec.PipPackages) > 0 {
+		if err := venv.PipInstallPackages(spec.PipPackages, "https://pypi.org/simple", "", true, nil); err != nil {
+			return nil, fmt.Errorf("error installing pip packages: %v", err)
+		}
+	}
+	return venv, nil
+}
+
+// verifyRestoredSpec compares a restored specification with the original,
+// ignoring package sources and channels the original did not record (a
+// pip-only venv spec restored with micromamba, for example, would otherwise
+// report the base conda packages as added).
+func verifyRestoredSpec(original, restored EnvironmentSpec) error {
+	recordsConda := len(original.CondaPackages) > 0
+	recordsPip := len(original.PipPackages) > 0
+	for _, pkg := range original.Packages {
+		switch pkg.Source {
+		case "conda":
+			recordsConda = true
+		case "pip":
+			recordsPip = true
+		}
+	}
+
+	if !recordsConda {
+		restored.CondaPackages = nil
+		restored.Channels = nil
+	}
+	if !recordsPip {
+		restored.PipPackages = nil
+	}
+	var packages []PackageSpec
+	for _, pkg := range restored.Packages {
+		if (pkg.Source == "conda" && !recordsConda) || (pkg.Source == "pip" && !recordsPip) {
+			continue
+		}
+		packages = append(packages, pkg)
+	}
+	restored.Packages = packages
+
+	diff := DiffEnvironments(original, restored)
+	if !diff.IsEmpty() {
+		return fmt.Errorf("restored environment does not match frozen environment:\n%s", diff.String())
+	}
+	return nil
+}
+
+// readVenvConfig reads the key = value pairs of a pyvenv.cfg file.
+func readVenvConfig(cfgPath string) (map[string]string, error) {
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, value, found := strings.Cut(line, "="); found {
+			values[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return values, nil
+}
+
+func readEnvironmentSpec(filePath string) (EnvironmentSpec, error) {
+	var spec EnvironmentSpec
+	jsonData, err := os.ReadFile(filePath)
+	if err != nil {
+		return spec, fmt.Errorf("error reading JSON file: %v", err)
+	}
+	if err := json.Unmarshal(jsonData, &spec); err != nil {
+		return spec, fmt.Errorf("error unmarshaling JSON: %v", err)
+	}
+	return spec, nil
+}
+
 // CreateEnvironmentFromJSONFile creates a new environment from a JSON specification file.
 //
 // The JSON file should match the EnvironmentSpec format, typically created by FreezeToFile.
 // This function:
 //  1. Creates a base micromamba environment with the specified Python version
-//  2. Installs all conda packages from the spec
+//  2. Installs all conda packages from the spec in a single micromamba call
 //  3. Installs all pip packages from the spec
 //
-// If no channels are specified in the JSON, "conda-forge" is used as the default.
+// If no channels are specified in the JSON, "conda-forge" is used as the
+// default. With several channels, the conda packages are installed from the
+// first channel that provides all of them.
 // The environment is created at rootDir/envs/<name> where name comes from the spec.
 func CreateEnvironmentFromJSONFile(filePath string, rootDir string, progressCallback ProgressCallback) (*PythonEnvironment, error) {
 	// 1. Read the JSON file.
@@ -953,24 +1563,9 @@ func CreateEnvironmentFromJSONFile(filePath string, rootDir string, progressCall
 		channels = []string{"conda-forge"} // Default channel
 	}
 
-	// 4. Install conda packages.
-	for _, pkg := range spec.CondaPackages {
-		// Install using the specified channels
-		var installErr error
-		for _, channel := range channels {
-			if err := env.MicromambaInstallPackage(pkg, channel); err == nil {
-				installErr = nil // Success on at least one channel
-				break            // Exit the inner loop (try next channel)
-			} else {
-				installErr = err // Keep track of the last error
-			}
-		}
-		if installErr != nil {
-			return nil, fmt.Errorf("error installing conda package %s: %v", pkg, installErr) // Report the final error
-		}
-		if progressCallback != nil {
-			progressCallback(fmt.Sprintf("Installing conda package %s...", pkg), 50, 100)
-		}
+	// 4. Install conda packages, solved together in one transaction.
+	if err := env.installCondaPackagesBatched(spec.CondaPackages, channels, 0, progressCallback); err != nil {
+		return nil, err
 	}
 
 	// 5. Install pip packages.
@@ -1001,100 +1596,155 @@ func CreateEnvironmentFromJSONFile(filePath string, rootDir string, progressCall
 // If opts.VerifyChecksums is true, packages with SHA256 checksums will be verified.
 // If opts.Strict is also true, the function will fail if any package lacks a checksum.
 func CreateEnvironmentFromJSONFileWithOptions(filePath string, rootDir string, opts RestoreOptions, progressCallback ProgressCallback) (*PythonEnvironment, error) {
+	if opts.DryRun {
+		return nil, fmt.Errorf("a dry run returns a plan rather than an environment: use RestoreEnvironmentFromJSONFile")
+	}
+
+	// 1-3. Read and check the specification.
+	spec, err := readRestoreSpec(filePath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Create the base environment.
+	env, err := CreateEnvironmentMamba(spec.Name, rootDir, spec.PythonVersion, "", progressCallback)
+	if err != nil {
+		return nil, fmt.Errorf("error creating base environment: %v", err)
+	}
+
+	// Determine the channels to use.
+	channels := spec.Channels
+	if len(channels) == 0 {
+		channels = []string{"conda-forge"}
+	}
+
+	// 5. Install packages from the unified Packages list, falling back to the
+	// legacy lists. Conda and pip packages are each installed in one batch.
+	condaSpecs, pipSpecs := restorePackageSpecs(spec)
+
+	if err := env.installRestorePackages(condaSpecs, pipSpecs, channels, opts.MaxParallel, progressCallback); err != nil {
+		return nil, err
+	}
+
+	if progressCallback != nil {
+		progressCallback("Finished creating environment from JSON file", 100, 100)
+	}
+	return env, nil
+}
+
+// readRestoreSpec reads a JSON specification file and, in strict mode with
+// VerifyChecksums, checks that every package has a checksum.
+func readRestoreSpec(filePath string, opts RestoreOptions) (EnvironmentSpec, error) {
 	// 1. Read the JSON file.
 	jsonData, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error reading JSON file: %v", err)
+		return EnvironmentSpec{}, fmt.Errorf("error reading JSON file: %v", err)
 	}
 
 	// 2. Unmarshal the JSON data into an EnvironmentSpec.
 	var spec EnvironmentSpec
 	if err := json.Unmarshal(jsonData, &spec); err != nil {
-		return nil, fmt.Errorf("error unmarshaling JSON: %v", err)
+		return EnvironmentSpec{}, fmt.Errorf("error unmarshaling JSON: %v", err)
 	}
 
 	// 3. If Strict mode and VerifyChecksums enabled, check that all packages have checksums.
 	if opts.Strict && opts.VerifyChecksums {
 		for _, pkg := range spec.Packages {
 			if pkg.SHA256 == "" {
-				return nil, fmt.Errorf("strict mode: package %s lacks SHA256 checksum", pkg.Name)
+				return EnvironmentSpec{}, fmt.Errorf("strict mode: package %s lacks SHA256 checksum", pkg.Name)
 			}
 		}
 	}
+	return spec, nil
+}
 
-	// 4. Create the base environment.
-	env, err := CreateEnvironmentMamba(spec.Name, rootDir, spec.PythonVersion, "", progressCallback)
-	if err != nil {
-		return nil, fmt.Errorf("error creating base environment: %v", err)
-	}
-
-	// Determine the channels to use.
-	channels := spec.Channels
-	if len(channels) == 0 {
-		channels = []string{"conda-forge"}
-	}
-
-	// 5. Install packages from the unified Packages list if present.
+// restorePackageSpecs returns the conda and pip install specs for a
+// specification. The unified Packages list is used for each source it covers
+// and the legacy CondaPackages or PipPackages list otherwise. Conda packages
+// with a recorded channel are pinned to it ("channel::name=version=build").
+func restorePackageSpecs(spec EnvironmentSpec) (condaSpecs []string, pipSpecs []string) {
 	for _, pkg := range spec.Packages {
 		if pkg.Source == "conda" {
-			// Install conda package
 			pkgSpec := pkg.Name + "=" + pkg.Version
 			if pkg.Build != "" {
 				pkgSpec += "=" + pkg.Build
 			}
-			var installErr error
-			for _, channel := range channels {
-				if err := env.MicromambaInstallPackage(pkgSpec, channel); err == nil {
-					installErr = nil
-					break
-				} else {
-					installErr = err
-				}
-			}
-			if installErr != nil {
-				return nil, fmt.Errorf("error installing conda package %s: %v", pkg.Name, installErr)
+			if pkg.Channel != "" {
+				pkgSpec = pkg.Channel + "::" + pkgSpec
 			}
+			condaSpecs = append(condaSpecs, pkgSpec)
 		} else if pkg.Source == "pip" {
-			// Install pip package
-			pkgSpec := pkg.Name + "==" + pkg.Version
-			if err := env.PipInstallPackage(pkgSpec, "https://pypi.org/simple", "", true, progressCallback); err != nil {
-				return nil, fmt.Errorf("error installing pip package %s: %v", pkg.Name, err)
-			}
+			pipSpecs = append(pipSpecs, pkg.Name+"=="+pkg.Version)
 		}
+	}
 
-		if progressCallback != nil {
-			progressCallback(fmt.Sprintf("Installing package %s...", pkg.Name), 50, 100)
-		}
+	if len(condaSpecs) == 0 {
+		condaSpecs = spec.CondaPackages
+	}
+	if len(pipSpecs) == 0 {
+		pipSpecs = spec.PipPackages
 	}
+	return condaSpecs, pipSpecs
+}
 
-	// 6. Fall back to legacy format if Packages list is empty.
-	if len(spec.Packages) == 0 {
-		// Install conda packages from legacy format.
-		for _, pkg := range spec.CondaPackages {
-			var installErr error
-			for _, channel := range channels {
-				if err := env.MicromambaInstallPackage(pkg, channel); err == nil {
-					installErr = nil
-					break
-				} else {
-					installErr = err
-				}
-			}
-			if installErr != nil {
-				return nil, fmt.Errorf("error installing conda package %s: %v", pkg, installErr)
-			}
+// installRestorePackages installs the conda and pip packages of a restored
+// environment, each in a single batch.
+func (env *PythonEnvironment) installRestorePackages(condaSpecs []string, pipSpecs []string, channels []string, maxParallel int, progressCallback ProgressCallback) error {
+	if err := env.installCondaPackagesBatched(condaSpecs, channels, maxParallel, progressCallback); err != nil {
+		return err
+	}
+
+	if len(pipSpecs) > 0 {
+		if err := env.PipInstallPackages(pipSpecs, "https://pypi.org/simple", "", true, progressCallback); err != nil {
+			return fmt.Errorf("error installing pip packages: %v", err)
 		}
+	}
+	return nil
+}
+
+// installCondaPackagesBatched installs conda packages in one micromamba
+// transaction, trying each channel in order until one succeeds. If every
+// channel fails, the errors from all channels are returned together. If
+// every spec is pinned to a channel ("channel::name"), another channel can't
+// change the result, so only the first is tried.
+// maxParallel, if greater than 1, sets micromamba's download threads.
+func (env *PythonEnvironment) installCondaPackagesBatched(pkgSpecs []string, channels []string, maxParallel int, progressCallback ProgressCallback) error {
+	if len(pkgSpecs) == 0 {
+		return nil
+	}
+	if len(channels) > 1 && allChannelPinned(pkgSpecs) {
+		channels = channels[:1]
+	}
+
+	mambaOpts := getMicromambaOptions()
+	if maxParallel > 1 {
+		mambaOpts.DownloadThreads = maxParallel
+	}
 
-		// Install pip packages from legacy format.
-		if len(spec.PipPackages) > 0 {
-			if err := env.PipInstallPackages(spec.PipPackages, "https://pypi.org/simple", "", true, progressCallback); err != nil {
-				return nil, fmt.Errorf("error installing pip packages: %v", err)
+	if progressCallback != nil {
+		progressCallback(fmt.Sprintf("Installing %d conda packages...", len(pkgSpecs)), 0, 100)
+	}
+
+	var errs []error
+	for _, channel := range channels {
+		err := env.micromambaInstall(pkgSpecs, channel, mambaOpts, progressCallback)
+		if err == nil {
+			if progressCallback != nil {
+				progressCallback(fmt.Sprintf("Installed %d conda packages", len(pkgSpecs)), 100, 100)
 			}
+			return nil
 		}
+		errs = append(errs, fmt.Errorf("error installing conda packages from channel %s: %v", channel, err))
 	}
+	return errors.Join(errs...)
+}
 
-	if progressCallback != nil {
-		progressCallback("Finished creating environment from JSON file", 100, 100)
+// allChannelPinned reports whether every conda spec names its channel.
+func allChannelPinned(pkgSpecs []string) bool {
+	for _, spec := range pkgSpecs {
+		if !strings.Contains(spec, "::") {
+			return false
+		}
 	}
-	return env, nil
+	return true
 }
@@ -1,15 +1,24 @@
 package jumpboot
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 // --------------------------------------------------------------------------------
@@ -136,6 +145,222 @@ func TestCreateEnvironmentFromSystem(t *testing.T) {
 	}
 }
 
+func TestCreateEnvironmentFromSystemWithConstraint(t *testing.T) {
+	env, err := CreateEnvironmentFromSystemWithConstraint(">=3")
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	isValidEnvironment(t, env)
+
+	if _, err := CreateEnvironmentFromSystemWithConstraint(">=99"); err == nil || !strings.Contains(err.Error(), "no system Python satisfies >=99") {
+		t.Errorf("expected an unsatisfiable constraint error, got %v", err)
+	}
+	if _, err := CreateEnvironmentFromSystemWithConstraint("3.11"); err == nil {
+		t.Error("expected an error for a constraint without an operator")
+	}
+}
+
+func TestFindSystemPython(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake interpreters are shell scripts")
+	}
+	dir := createTestDir(t)
+	defer cleanupTestDir(t, dir)
+
+	python3, _ := writeFakeTool(t, dir, "python3", "echo Python 3.8.10")
+	python311, _ := writeFakeTool(t, dir, "python3.11", "echo Python 3.11.4")
+	python2, _ := writeFakeTool(t, dir, "python", "echo Python 2.7.18 >&2")
+	broken, _ := writeFakeTool(t, dir, "python3.10", "exit 1")
+	candidates := []string{python3, python2, broken, python311}
+
+	c, _ := ParseConstraint(">=3.11")
+	got, err := findSystemPython(c, candidates)
+	if err != nil || got != python311 {
+		t.Errorf("findSystemPython(>=3.11) = %q, %v; want %q", got, err, python311)
+	}
+
+	c, _ = ParseConstraint("<3")
+	got, err = findSystemPython(c, candidates)
+	if err != nil || got != python2 {
+		t.Errorf("findSystemPython(<3) = %q, %v; want %q", got, err, python2)
+	}
+
+	c, _ = ParseConstraint(">=3.12")
+	_, err = findSystemPython(c, candidates)
+	if err == nil {
+		t.Fatal("expected an error when no interpreter satisfies the constraint")
+	}
+	for _, want := range []string{">=3.12", "3.8.10 (" + python3 + ")", "3.11.4", "2.7.18"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	if _, err := findSystemPython(c, nil); err == nil || err.Error() != "python not found" {
+		t.Errorf("expected python not found, got %v", err)
+	}
+}
+
+func TestDiscoverSystemPythons(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake interpreters are shell scripts")
+	}
+	dir := createTestDir(t)
+	defer cleanupTestDir(t, dir)
+
+	python311, _ := writeFakeTool(t, dir, "python3.11", "echo Python 3.11.4")
+	python39, _ := writeFakeTool(t, dir, "python3.9", "echo Python 3.9.18")
+	writeFakeTool(t, dir, "python3-config", "echo Python 3.0.0")
+	if err := os.Symlink(python311, filepath.Join(dir, "python3")); err != nil {
+		t.Fatalf("Failed to link python3: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	envs, err := DiscoverSystemPythons()
+	if err != nil {
+		t.Fatalf("DiscoverSystemPythons failed: %v", err)
+	}
+	found := make(map[string]int)
+	for _, env := range envs {
+		if filepath.Dir(env.PythonPath) != dir {
+			continue
+		}
+		found[env.PythonVersion.String()]++
+		if env.Name() != "system" {
+			t.Errorf("expected name system, got %q", env.Name())
+		}
+	}
+	if found["3.11.4"] != 1 || found["3.9.18"] != 1 || len(found) != 2 {
+		t.Errorf("expected 3.11.4 and 3.9.18 once each from %s, got %v", dir, found)
+	}
+
+	paths := make(map[string]bool)
+	for _, env := range envs {
+		if paths[env.PythonPath] {
+			t.Errorf("%s listed twice", env.PythonPath)
+		}
+		paths[env.PythonPath] = true
+	}
+	if !paths[python39] {
+		t.Errorf("%s not listed", python39)
+	}
+}
+
+func TestParsePyLauncherList(t *testing.T) {
+	out := "  -V:3.12 *        C:\\Program Files\\Python312\\python.exe\r\n" +
+		"  -V:3.11          C:\\Python311\\python.exe\r\n" +
+		" -3.8-64           C:\\Python38\\python.exe\r\n" +
+		"\r\n"
+	got := parsePyLauncherList(out)
+	want := []string{"C:\\Program Files\\Python312\\python.exe", "C:\\Python311\\python.exe", "C:\\Python38\\python.exe"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("parsePyLauncherList = %q, want %q", got, want)
+	}
+}
+
+func TestRunPythonReadStdout(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	dir := createTestDir(t)
+	defer cleanupTestDir(t, dir)
+
+	script := filepath.Join(dir, "fail.py")
+	body := "import sys\nprint('partial')\nprint('x' * 100000)\nsys.stderr.write('went wrong')\nsys.exit(3)\n"
+	if err := os.WriteFile(script, []byte(body), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	out, err := env.RunPythonReadStdout(script)
+	if err == nil {
+		t.Fatal("expected an error for a script exiting with code 3")
+	}
+	if !strings.Contains(err.Error(), "went wrong") {
+		t.Errorf("error %q does not include stderr", err)
+	}
+	if want := "partial\n" + strings.Repeat("x", 100000) + "\n"; out != want {
+		t.Errorf("expected the printed output (%d bytes), got %d bytes", len(want), len(out))
+	}
+
+	script = filepath.Join(dir, "ok.py")
+	if err := os.WriteFile(script, []byte("import sys\nprint(sys.argv[1])\n"), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	out, err = env.RunPythonReadStdout(script, "hello")
+	if err != nil || out != "hello\n" {
+		t.Errorf("RunPythonReadStdout = %q, %v; want \"hello\\n\", nil", out, err)
+	}
+}
+
+func TestRunPythonWithInput(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	dir := createTestDir(t)
+	defer cleanupTestDir(t, dir)
+
+	script := filepath.Join(dir, "echo.py")
+	if err := os.WriteFile(script, []byte("import sys\nsys.stdout.write(sys.stdin.read())\n"), 0644); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	input := "first line\nsecond line\n"
+	out, err := env.RunPythonWithInput([]byte(input), script)
+	if err != nil || out != input {
+		t.Errorf("RunPythonWithInput = %q, %v; want %q, nil", out, err, input)
+	}
+
+	// A reader that isn't a file reaches EOF as well
+	result, err := env.RunPython(RunOptions{Args: []string{script}, Stdin: io.MultiReader(strings.NewReader("a"), strings.NewReader("b"))})
+	if err != nil || result.Stdout != "ab" {
+		t.Errorf("RunPython with Stdin = %q, %v; want \"ab\", nil", result.Stdout, err)
+	}
+}
+
+func TestRunPython(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	dir := createTestDir(t)
+	defer cleanupTestDir(t, dir)
+
+	code := "import os, sys\n" +
+		"print(sys.stdin.read().upper() + ' ' + os.environ['RUN_TEST'] + ' ' + os.path.basename(os.getcwd()))\n" +
+		"sys.stderr.write('on stderr')\n" +
+		"sys.exit(int(sys.argv[1]))\n"
+	result, err := env.RunPython(RunOptions{
+		Args:  []string{"-c", code, "4"},
+		Env:   map[string]string{"RUN_TEST": "value"},
+		Dir:   dir,
+		Stdin: strings.NewReader("input"),
+	})
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("expected an *exec.ExitError, got %v", err)
+	}
+	if want := "INPUT value " + filepath.Base(dir) + "\n"; strings.ReplaceAll(result.Stdout, "\r\n", "\n") != want {
+		t.Errorf("Stdout = %q, want %q", result.Stdout, want)
+	}
+	if result.Stderr != "on stderr" {
+		t.Errorf("Stderr = %q, want %q", result.Stderr, "on stderr")
+	}
+	if result.ExitCode != 4 {
+		t.Errorf("ExitCode = %d, want 4", result.ExitCode)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("Duration = %v, want > 0", result.Duration)
+	}
+
+	result, err = env.RunPython(RunOptions{Args: []string{"-c", "import time; time.sleep(30)"}, Timeout: 200 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+	if result.ExitCode != -1 || result.Duration > 10*time.Second {
+		t.Errorf("expected a killed process, got exit code %d after %v", result.ExitCode, result.Duration)
+	}
+}
+
 func TestCreateEnvironmentFromExecutable_NotFound(t *testing.T) {
 	_, err := CreateEnvironmentFromExacutable("/path/to/nonexistent/python")
 	if err == nil {
@@ -225,11 +450,11 @@ func TestExpectMicromamba_DownloadError(t *testing.T) {
 	defer server.Close()
 
 	// Override the download URL to point to our mock server.
-	originalBaseURL := micromambaBaseURL // Store the original URL
-	micromambaBaseURL = server.URL       // Set to the mock server
+	originalBaseURL := getMicromambaBaseURL() // Store the original URL
+	SetMicromambaBaseURL(server.URL)          // Set to the mock server
 
 	// Restore the original URL *after* the test completes, even if it fails.
-	defer func() { micromambaBaseURL = originalBaseURL }()
+	defer SetMicromambaBaseURL(originalBaseURL)
 
 	// Create a temporary directory for the download.
 	testDir := createTestDir(t)
@@ -247,6 +472,214 @@ func TestExpectMicromamba_DownloadError(t *testing.T) {
 	}
 }
 
+func TestExpectMicromamba_RetryAfterServerError(t *testing.T) {
+	// Fail the first request with a 503, then serve the binary.
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			sum := sha256.Sum256([]byte("micromamba-binary"))
+			w.Write([]byte(hex.EncodeToString(sum[:]) + "  micromamba\n"))
+			return
+		}
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("micromamba-binary"))
+	}))
+	defer server.Close()
+
+	originalBaseURL := getMicromambaBaseURL()
+	SetMicromambaBaseURL(server.URL)
+	defer SetMicromambaBaseURL(originalBaseURL)
+
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	var messages []string
+	binpath, err := ExpectMicromambaWithOptions(testDir, DownloadOptions{MaxRetries: 2, RetryBackoff: time.Millisecond}, func(message string, current, total int64) {
+		messages = append(messages, message)
+	})
+	if err != nil {
+		t.Fatalf("Expected download to succeed after retry, got: %v", err)
+	}
+
+	data, err := os.ReadFile(binpath)
+	if err != nil || string(data) != "micromamba-binary" {
+		t.Errorf("Unexpected downloaded content: %q, %v", data, err)
+	}
+	if _, err := os.Stat(binpath + ".part"); !os.IsNotExist(err) {
+		t.Error("Expected partial download file to be removed")
+	}
+
+	found := false
+	for _, m := range messages {
+		if m == "retrying download (2/3)" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected retry progress message, got: %v", messages)
+	}
+}
+
+// failingTransport fails every request with err and counts the attempts.
+type failingTransport struct {
+	err      error
+	attempts int
+}
+
+func (f *failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	f.attempts++
+	return nil, f.err
+}
+
+func TestExpectMicromamba_RetriesOnlyTransientErrors(t *testing.T) {
+	defer SetMicromambaHTTPClient(nil)
+	opts := DownloadOptions{MaxRetries: 2, RetryBackoff: time.Millisecond}
+
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"unknown host", &net.DNSError{Err: "no such host", Name: "mirror.invalid", IsNotFound: true}, 1},
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, 3},
+	}
+	for _, tt := range tests {
+		transport := &failingTransport{err: tt.err}
+		SetMicromambaHTTPClient(&http.Client{Transport: transport})
+
+		_, err := ExpectMicromambaWithOptions(t.TempDir(), opts, nil)
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if transport.attempts != tt.attempts {
+			t.Errorf("%s: made %d attempts, want %d", tt.name, transport.attempts, tt.attempts)
+		}
+	}
+}
+
+func TestExpectMicromamba_ChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered-binary"))
+	}))
+	defer server.Close()
+
+	originalBaseURL := getMicromambaBaseURL()
+	SetMicromambaBaseURL(server.URL)
+	defer SetMicromambaBaseURL(originalBaseURL)
+
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	sum := sha256.Sum256([]byte("micromamba-binary"))
+	_, err := ExpectMicromambaWithOptions(testDir, DownloadOptions{Version: "2.2.0-0", SHA256: hex.EncodeToString(sum[:])}, nil)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Expected checksum mismatch error, got: %v", err)
+	}
+
+	entries, _ := os.ReadDir(testDir)
+	if len(entries) != 0 {
+		t.Errorf("Expected mismatched binary to be deleted, found %d files", len(entries))
+	}
+}
+
+func TestExpectMicromamba_MirrorWithoutChecksum(t *testing.T) {
+	// A mirror that serves the binary but no ".sha256" file.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("micromamba-binary"))
+	}))
+	defer server.Close()
+
+	originalBaseURL := getMicromambaBaseURL()
+	SetMicromambaBaseURL(server.URL)
+	defer SetMicromambaBaseURL(originalBaseURL)
+
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	_, err := ExpectMicromambaWithOptions(testDir, DownloadOptions{Version: "2.2.0-0"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("Expected checksum fetch error, got: %v", err)
+	}
+	if entries, _ := os.ReadDir(testDir); len(entries) != 0 {
+		t.Errorf("Expected no unverified binary to be left behind, found %d files", len(entries))
+	}
+
+	// Pinning the checksum makes the mirror usable.
+	sum := sha256.Sum256([]byte("micromamba-binary"))
+	binpath, err := ExpectMicromambaWithOptions(testDir, DownloadOptions{Version: "2.2.0-0", SHA256: hex.EncodeToString(sum[:])}, nil)
+	if err != nil {
+		t.Fatalf("Expected download with pinned checksum to succeed, got: %v", err)
+	}
+	if _, err := os.Stat(binpath); err != nil {
+		t.Errorf("Expected verified binary at %s: %v", binpath, err)
+	}
+	if _, err := os.Stat(binpath + ".part"); !os.IsNotExist(err) {
+		t.Error("Expected partial download file to be renamed into place")
+	}
+}
+
+func TestSetMicromambaBaseURL_Mirror(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, ".sha256") {
+			sum := sha256.Sum256([]byte("micromamba-binary"))
+			w.Write([]byte(hex.EncodeToString(sum[:])))
+			return
+		}
+		w.Write([]byte("micromamba-binary"))
+	}))
+	defer server.Close()
+
+	originalBaseURL := getMicromambaBaseURL()
+	// The trailing slash must not produce a double slash in the request path.
+	SetMicromambaBaseURL(server.URL + "/mirror/releases/")
+	defer SetMicromambaBaseURL(originalBaseURL)
+
+	platform := map[string]string{"darwin": "osx", "windows": "win"}[runtime.GOOS]
+	if platform == "" {
+		platform = runtime.GOOS
+	}
+	arch := map[string]string{"amd64": "64", "arm64": "arm64"}[runtime.GOARCH]
+	if runtime.GOARCH == "arm64" && platform == "linux" {
+		arch = "aarch64"
+	} else if runtime.GOARCH == "arm64" && platform == "win" {
+		arch = "64"
+	}
+	if arch == "" {
+		t.Skipf("micromamba is not published for %s", runtime.GOARCH)
+	}
+	asset := "micromamba-" + platform + "-" + arch
+
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	if _, err := ExpectMicromambaWithOptions(testDir, DownloadOptions{Version: "2.2.0-0"}, nil); err != nil {
+		t.Fatalf("ExpectMicromambaWithOptions failed: %v", err)
+	}
+	want := []string{
+		"/mirror/releases/download/2.2.0-0/" + asset,
+		"/mirror/releases/download/2.2.0-0/" + asset + ".sha256",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Requested paths = %v, want %v", paths, want)
+	}
+
+	// An empty url restores the public releases location.
+	SetMicromambaBaseURL("")
+	if got := getMicromambaBaseURL(); got != defaultMicromambaBaseURL {
+		t.Errorf("getMicromambaBaseURL() = %q after reset, want %q", got, defaultMicromambaBaseURL)
+	}
+}
+
 func TestExpectMicromamba_FileCreationError(t *testing.T) {
 	// Use a read-only directory to simulate a file creation error.
 	readOnlyDir := "/tmp/readonly" // Use /tmp and create a subdirectory
@@ -613,3 +1046,718 @@ func TestCreateEnvironmentFromJSONFile(t *testing.T) {
 		}
 	}
 }
+
+// writeFakeTool writes a shell script that appends its arguments to a log
+// file, one invocation per line, and then runs body. It returns the script
+// path and the log path.
+func writeFakeTool(t *testing.T, dir string, name string, body string) (string, string) {
+	t.Helper()
+	logPath := filepath.Join(dir, name+".log")
+	script := "#!/bin/sh\necho \"$@\" >> " + logPath + "\n" + body + "\n"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake %s: %v", name, err)
+	}
+	return path, logPath
+}
+
+// readInvocations returns the logged invocations of a fake tool.
+func readInvocations(t *testing.T, logPath string) []string {
+	t.Helper()
+	data, err := os.ReadFile(logPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", logPath, err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestInstallRestorePackages_Batched(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	// micromamba fails for the "bad" channel, pip always succeeds
+	mamba, mambaLog := writeFakeTool(t, testDir, "micromamba", `case "$*" in *"-c bad"*) exit 1;; esac`)
+	pip, pipLog := writeFakeTool(t, testDir, "pip", "")
+	env := &PythonEnvironment{PipPath: pip}
+	env.MicromambaPath = mamba
+	env.EnvPath = filepath.Join(testDir, "env")
+
+	condaSpecs := []string{"numpy=1.26.4", "scipy=1.11.4=py311", "openssl=3.2.1"}
+	pipSpecs := []string{"requests==2.31.0", "rich==13.7.0"}
+	if err := env.installRestorePackages(condaSpecs, pipSpecs, []string{"bad", "conda-forge"}, 8, nil); err != nil {
+		t.Fatalf("installRestorePackages failed: %v", err)
+	}
+
+	// One conda transaction per channel tried, each with every spec
+	calls := readInvocations(t, mambaLog)
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 micromamba invocations, got %d: %v", len(calls), calls)
+	}
+	for i, channel := range []string{"bad", "conda-forge"} {
+		if !strings.Contains(calls[i], "-c "+channel) || !strings.Contains(calls[i], strings.Join(condaSpecs, " ")) {
+			t.Errorf("micromamba invocation %d = %q, want channel %s and all specs", i, calls[i], channel)
+		}
+	}
+
+	// One pip invocation with every spec
+	calls = readInvocations(t, pipLog)
+	if len(calls) != 1 || !strings.Contains(calls[0], strings.Join(pipSpecs, " ")) {
+		t.Errorf("expected one pip invocation with all specs, got %v", calls)
+	}
+}
+
+func TestMicromambaInstallPackages_SingleCall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	mamba, mambaLog := writeFakeTool(t, testDir, "micromamba", "")
+	env := &PythonEnvironment{}
+	env.MicromambaPath = mamba
+	env.EnvPath = filepath.Join(testDir, "env")
+
+	specs := []string{"numpy=1.26.4", "scipy", "pandas>=2"}
+	if err := env.MicromambaInstallPackages(specs, "conda-forge", func(string, int64, int64) {}); err != nil {
+		t.Fatalf("MicromambaInstallPackages failed: %v", err)
+	}
+	calls := readInvocations(t, mambaLog)
+	if len(calls) != 1 || !strings.Contains(calls[0], "-c conda-forge") || !strings.Contains(calls[0], strings.Join(specs, " ")) {
+		t.Errorf("expected one micromamba invocation with every spec, got %v", calls)
+	}
+}
+
+func TestMicromambaRemovePackages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	// numpy and scipy are installed; removing "locked" fails
+	mamba, mambaLog := writeFakeTool(t, testDir, "micromamba", `case "$1" in
+list) echo '[{"name":"numpy","version":"1.26.4"},{"name":"scipy","version":"1.11.4"},{"name":"locked","version":"1.0"}]';;
+remove) case "$*" in *locked*) echo "critical libmamba: prefix is locked" >&2; exit 1;; esac;;
+esac`)
+	env := &PythonEnvironment{}
+	env.EnvironmentName = "myenv"
+	env.MicromambaPath = mamba
+	env.RootDir = testDir
+
+	var messages []string
+	progress := func(message string, current, total int64) { messages = append(messages, message) }
+	if err := env.MicromambaRemovePackages([]string{"numpy=1.26", "missing", "conda-forge::scipy"}, progress); err != nil {
+		t.Fatalf("MicromambaRemovePackages failed: %v", err)
+	}
+	calls := readInvocations(t, mambaLog)
+	if len(calls) != 2 || !strings.HasPrefix(calls[1], "remove --no-rc -n myenv -y numpy=1.26 conda-forge::scipy") {
+		t.Fatalf("expected a list and one remove of the installed packages, got %v", calls)
+	}
+	if len(messages) != 2 {
+		t.Errorf("progress messages = %v", messages)
+	}
+
+	// Strict mode removes nothing if a package is missing
+	os.Remove(mambaLog)
+	err := env.MicromambaRemovePackagesWithOptions([]string{"numpy", "missing"}, RemoveOptions{Strict: true}, nil)
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected an error naming the missing package, got %v", err)
+	}
+	if calls := readInvocations(t, mambaLog); len(calls) != 1 {
+		t.Errorf("expected only micromamba list in strict mode, got %v", calls)
+	}
+
+	// A failed removal reports micromamba's stderr
+	err = env.MicromambaRemovePackages([]string{"locked"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "prefix is locked") {
+		t.Errorf("expected micromamba's stderr in the error, got %v", err)
+	}
+}
+
+func TestConcurrentInstallsSerialized(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	// Both tools note when they start while another install is running
+	busy := filepath.Join(testDir, "busy")
+	overlap := filepath.Join(testDir, "overlap")
+	body := "if [ -e " + busy + " ]; then touch " + overlap + "; fi\ntouch " + busy + "\nsleep 0.1\nrm -f " + busy
+	pip, _ := writeFakeTool(t, testDir, "pip", body)
+	mamba, _ := writeFakeTool(t, testDir, "micromamba", body)
+	env := &PythonEnvironment{PipPath: pip}
+	env.MicromambaPath = mamba
+	env.EnvPath = filepath.Join(testDir, "env")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 6)
+	for i := 0; i < 3; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- env.PipInstallPackage("requests", "", "", false, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- env.MicromambaInstallPackage("numpy", "conda-forge")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("install failed: %v", err)
+		}
+	}
+	if _, err := os.Stat(overlap); err == nil {
+		t.Error("installs into the same environment ran at the same time")
+	}
+}
+
+func TestRestoreEnvironmentFromJSONFile_DryRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	// micromamba can't resolve anything from the "bad" channel
+	if err := os.MkdirAll(filepath.Join(testDir, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, mambaLog := writeFakeTool(t, filepath.Join(testDir, "bin"), "micromamba", `case "$*" in
+*"-c bad"*) echo "critical libmamba: could not solve" >&2; exit 1;;
+esac
+echo '{"actions":{"LINK":[{"name":"python","version":"3.11.8","build_string":"hab00c5b_0","channel":"conda-forge"},{"name":"numpy","version":"1.26.4","build_string":"py311","channel":"conda-forge"}]},"dry_run":true,"success":true}'`)
+
+	spec := EnvironmentSpec{
+		Name:          "planned",
+		PythonVersion: "3.11",
+		Channels:      []string{"bad", "conda-forge"},
+		CondaPackages: []string{"numpy=1.26.4"},
+	}
+	specPath := filepath.Join(testDir, "env.json")
+	data, _ := json.Marshal(spec)
+	if err := os.WriteFile(specPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env, plan, err := RestoreEnvironmentFromJSONFile(specPath, testDir, RestoreOptions{DryRun: true}, nil)
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if env != nil {
+		t.Error("a dry run returned an environment")
+	}
+	want := []PackageSpec{
+		{Name: "python", Version: "3.11.8", Build: "hab00c5b_0", Source: "conda", Channel: "conda-forge"},
+		{Name: "numpy", Version: "1.26.4", Build: "py311", Source: "conda", Channel: "conda-forge"},
+	}
+	if plan.Name != "planned" || plan.Channel != "conda-forge" || !reflect.DeepEqual(plan.CondaPackages, want) {
+		t.Errorf("plan = %+v", plan)
+	}
+	calls := readInvocations(t, mambaLog)
+	if len(calls) != 2 || !strings.Contains(calls[1], "create --no-rc --dry-run --json") || !strings.Contains(calls[1], "python=3.11 numpy=1.26.4") {
+		t.Errorf("micromamba invocations = %v", calls)
+	}
+	if _, err := os.Stat(filepath.Join(testDir, "envs", "planned")); !os.IsNotExist(err) {
+		t.Error("a dry run created the environment")
+	}
+
+	// The environment-returning function refuses a dry run
+	if _, err := CreateEnvironmentFromJSONFileWithOptions(specPath, testDir, RestoreOptions{DryRun: true}, nil); err == nil {
+		t.Error("CreateEnvironmentFromJSONFileWithOptions accepted DryRun")
+	}
+}
+
+func TestPlanPipPackages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	pip, pipLog := writeFakeTool(t, testDir, "pip", `echo '{"version":"1","install":[{"metadata":{"name":"requests","version":"2.31.0"},"requested":true},{"metadata":{"name":"idna","version":"3.6"},"requested":false}]}'`)
+	packages, err := planPipPackages(pip, []string{"requests==2.31.0"}, "3.12")
+	if err != nil {
+		t.Fatalf("planPipPackages failed: %v", err)
+	}
+	want := []PackageSpec{{Name: "requests", Version: "2.31.0", Source: "pip"}, {Name: "idna", Version: "3.6", Source: "pip"}}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("packages = %v, want %v", packages, want)
+	}
+	calls := readInvocations(t, pipLog)
+	if len(calls) != 1 || !strings.Contains(calls[0], "install --dry-run --ignore-installed --quiet --report -") || !strings.Contains(calls[0], "--python-version 3.12 --only-binary=:all:") {
+		t.Errorf("pip invocations = %v", calls)
+	}
+}
+
+func TestInstallRestorePackages_CollectsChannelErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	mamba, _ := writeFakeTool(t, testDir, "micromamba", "exit 1")
+	pip, pipLog := writeFakeTool(t, testDir, "pip", "")
+	env := &PythonEnvironment{PipPath: pip}
+	env.MicromambaPath = mamba
+	env.EnvPath = filepath.Join(testDir, "env")
+
+	err := env.installRestorePackages([]string{"numpy=1.26.4"}, []string{"requests==2.31.0"}, []string{"first", "second"}, 0, nil)
+	if err == nil {
+		t.Fatal("expected an error when every channel fails")
+	}
+	for _, channel := range []string{"first", "second"} {
+		if !strings.Contains(err.Error(), "channel "+channel) {
+			t.Errorf("error %q does not mention channel %s", err, channel)
+		}
+	}
+
+	// Pip is not attempted after the conda packages fail
+	if calls := readInvocations(t, pipLog); len(calls) != 0 {
+		t.Errorf("pip was invoked after conda failure: %v", calls)
+	}
+}
+
+func TestInstallRestorePackages_PinnedSpecsTryOneChannel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	mamba, mambaLog := writeFakeTool(t, testDir, "micromamba", "exit 1")
+	env := &PythonEnvironment{}
+	env.MicromambaPath = mamba
+	env.EnvPath = filepath.Join(testDir, "env")
+
+	pinned := []string{"conda-forge::numpy=1.26.4", "bioconda::samtools=1.19"}
+	if err := env.installCondaPackagesBatched(pinned, []string{"conda-forge", "bioconda"}, 0, nil); err == nil {
+		t.Fatal("expected an error from the failing micromamba")
+	}
+	if calls := readInvocations(t, mambaLog); len(calls) != 1 {
+		t.Errorf("expected 1 micromamba invocation for pinned specs, got %d: %v", len(calls), calls)
+	}
+
+	// A single unpinned spec brings back the channel fallback
+	os.Remove(mambaLog)
+	mixed := append(pinned, "openssl=3.2.1")
+	env.installCondaPackagesBatched(mixed, []string{"conda-forge", "bioconda"}, 0, nil)
+	if calls := readInvocations(t, mambaLog); len(calls) != 2 {
+		t.Errorf("expected 2 micromamba invocations for mixed specs, got %d: %v", len(calls), calls)
+	}
+}
+
+func TestVerifyRestoredSpec_IgnoresUnrecordedSources(t *testing.T) {
+	// A pip-only venv spec
+	original := EnvironmentSpec{
+		Name:          "myvenv",
+		PythonVersion: "3.11",
+		CondaPackages: []string{},
+		PipPackages:   []string{"requests==2.31.0", "rich==13.7.0"},
+	}
+
+	// The same pip packages restored into an environment that also reports
+	// its base conda packages and channel
+	restored := EnvironmentSpec{
+		Name:          "myvenv",
+		PythonVersion: "3.11",
+		CondaPackages: []string{"python=3.11.7=hab00c5b_0", "openssl=3.2.1=hd590300_0", "pip=24.0=pyhd8ed1ab_0"},
+		PipPackages:   []string{"rich==13.7.0", "requests==2.31.0"},
+		Channels:      []string{"conda-forge"},
+	}
+	if err := verifyRestoredSpec(original, restored); err != nil {
+		t.Errorf("verifyRestoredSpec failed for a matching round trip: %v", err)
+	}
+
+	// A changed pip version is still reported
+	restored.PipPackages = []string{"rich==13.7.0", "requests==2.32.0"}
+	if err := verifyRestoredSpec(original, restored); err == nil {
+		t.Error("expected a mismatch for a changed pip package version")
+	}
+}
+
+func TestFreezeAndVerify_Venv(t *testing.T) {
+	baseEnv, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("No system Python available: %v", err)
+	}
+
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	venv, err := CreateVenvEnvironment(baseEnv, filepath.Join(testDir, "roundtrip"), VenvOptions{}, nil)
+	if err != nil {
+		t.Skipf("Could not create a venv from the system Python: %v", err)
+	}
+
+	if err := venv.FreezeAndVerify(filepath.Join(testDir, "roundtrip.json")); err != nil {
+		t.Errorf("FreezeAndVerify failed for a venv: %v", err)
+	}
+}
+
+func TestReplacePathPrefix(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"#!/envs/foo/bin/python\n", "#!/envs/new/bin/python\n"},
+		{`VIRTUAL_ENV="/envs/foo"`, `VIRTUAL_ENV="/envs/new"`},
+		{"VIRTUAL_ENV='/envs/foo'", "VIRTUAL_ENV='/envs/new'"},
+		{"command = python -m venv /envs/foo", "command = python -m venv /envs/new"},
+		{"/envs/foo /envs/foo\t/envs/foo\r\n", "/envs/new /envs/new\t/envs/new\r\n"},
+		{"/envs/foobar/bin /envs/foo-old /envs/foo.bak", "/envs/foobar/bin /envs/foo-old /envs/foo.bak"},
+		{"/envs/foobar:/envs/foo/lib", "/envs/foobar:/envs/new/lib"},
+	}
+	for _, tt := range tests {
+		got, changed := replacePathPrefix([]byte(tt.in), "/envs/foo", "/envs/new")
+		if string(got) != tt.want || changed != (tt.in != tt.want) {
+			t.Errorf("replacePathPrefix(%q) = %q, %v; want %q", tt.in, got, changed, tt.want)
+		}
+	}
+
+	// Windows paths continue with a backslash
+	if got, _ := replacePathPrefix([]byte(`C:\envs\foo\Scripts C:\envs\foobar`), `C:\envs\foo`, `D:\foo`); string(got) != `D:\foo\Scripts C:\envs\foobar` {
+		t.Errorf("replacePathPrefix with Windows paths = %q", got)
+	}
+}
+
+func TestRelocate_Venv(t *testing.T) {
+	baseEnv, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("No system Python available: %v", err)
+	}
+
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	oldPath := filepath.Join(testDir, "venv")
+	venv, err := CreateVenvEnvironment(baseEnv, oldPath, VenvOptions{}, nil)
+	if err != nil {
+		t.Skipf("Could not create a venv from the system Python: %v", err)
+	}
+	if venv.PipPath == "" {
+		t.Skip("the venv has no pip entry point")
+	}
+
+	// A neighbouring path that starts with the old one must not be rewritten
+	neighbour := filepath.Join(venv.EnvBinPath, "neighbour.txt")
+	neighbourText := "see " + oldPath + "-old/bin and " + oldPath + "bar\n"
+	if err := os.WriteFile(neighbour, []byte(neighbourText), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	newPath := filepath.Join(testDir, "moved", "venv")
+	if err := venv.Relocate(newPath); err != nil {
+		t.Fatalf("Relocate failed: %v", err)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("the old path still exists: %v", err)
+	}
+	if !strings.HasPrefix(venv.PipPath, newPath) || !strings.HasPrefix(venv.PythonPath, newPath) {
+		t.Errorf("paths were not updated: pip %s, python %s", venv.PipPath, venv.PythonPath)
+	}
+
+	// The pip entry point's shebang now names the moved interpreter
+	out, err := exec.Command(venv.PipPath, "--version").CombinedOutput()
+	if err != nil {
+		t.Fatalf("running the relocated pip failed: %v: %s", err, out)
+	}
+	if !strings.Contains(string(out), newPath) {
+		t.Errorf("pip --version = %q, want it to run from %s", out, newPath)
+	}
+
+	data, err := os.ReadFile(filepath.Join(venv.EnvBinPath, "neighbour.txt"))
+	if err != nil || string(data) != neighbourText {
+		t.Errorf("neighbouring paths were rewritten: %q, %v", data, err)
+	}
+}
+
+func TestRemove_SystemPythonWithoutEnvPath(t *testing.T) {
+	env := &PythonEnvironment{PythonPath: "/usr/bin/python3"}
+	err := env.Remove()
+	if err == nil || !strings.Contains(err.Error(), "system Python") {
+		t.Errorf("expected a system Python refusal, got %v", err)
+	}
+}
+
+func TestRemove_RequiresEnvironmentMarker(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	// For venvs RootDir is the same path as EnvPath, so only a marker can tell
+	// an environment from an arbitrary directory.
+	plain := filepath.Join(testDir, "plain")
+	if err := os.MkdirAll(filepath.Join(plain, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env := &PythonEnvironment{PythonPath: filepath.Join(plain, "bin", "python")}
+	env.EnvPath = plain
+	env.RootDir = plain
+	if err := env.Remove(); err == nil || !strings.Contains(err.Error(), "refusing to remove") {
+		t.Errorf("expected a directory without pyvenv.cfg to be refused, got %v", err)
+	}
+	if _, err := os.Stat(plain); err != nil {
+		t.Errorf("the refused directory was removed: %v", err)
+	}
+
+	// A micromamba environment needs conda-meta; micromamba must not be run otherwise
+	mamba, mambaLog := writeFakeTool(t, testDir, "micromamba", "")
+	env.MicromambaPath = mamba
+	if err := env.Remove(); err == nil || !strings.Contains(err.Error(), "refusing to remove") {
+		t.Errorf("expected a directory without conda-meta to be refused, got %v", err)
+	}
+	if calls := readInvocations(t, mambaLog); len(calls) != 0 {
+		t.Errorf("micromamba ran for a refused directory: %v", calls)
+	}
+
+	venvPath := filepath.Join(testDir, "venv")
+	if err := os.MkdirAll(venvPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(venvPath, "pyvenv.cfg"), []byte("home = /usr/bin\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	venv := &PythonEnvironment{PythonPath: filepath.Join(venvPath, "bin", "python")}
+	venv.EnvPath = venvPath
+	venv.RootDir = venvPath
+	if err := venv.Remove(); err != nil {
+		t.Fatalf("Remove failed for a venv: %v", err)
+	}
+	if _, err := os.Stat(venvPath); !os.IsNotExist(err) {
+		t.Errorf("the venv still exists: %v", err)
+	}
+}
+
+func TestRunningProcessesPrunedOnExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	envPath := createTestDir(t)
+	defer cleanupTestDir(t, envPath)
+
+	// A process reaped through PythonProcess.Wait is removed immediately
+	cmd := exec.Command("sh", "-c", "exit 0")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	registerRunningProcess(envPath, cmd.Process)
+	pp := &PythonProcess{Cmd: cmd}
+	if err := pp.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+
+	runningProcessesMutex.Lock()
+	_, tracked := runningProcesses[envPath]
+	runningProcessesMutex.Unlock()
+	if tracked {
+		t.Error("process is still tracked after Wait")
+	}
+
+	// A process reaped elsewhere is pruned on the next registration
+	other := exec.Command("sh", "-c", "exit 0")
+	if err := other.Start(); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	registerRunningProcess(envPath, other.Process)
+	other.Wait()
+	live := exec.Command("sh", "-c", "sleep 5")
+	if err := live.Start(); err != nil {
+		t.Fatalf("Failed to start process: %v", err)
+	}
+	defer func() {
+		live.Process.Kill()
+		(&PythonProcess{Cmd: live}).Wait()
+	}()
+	registerRunningProcess(envPath+"-other", live.Process)
+
+	runningProcessesMutex.Lock()
+	_, tracked = runningProcesses[envPath]
+	runningProcessesMutex.Unlock()
+	if tracked {
+		t.Error("exited process was not pruned when another process was registered")
+	}
+}
+
+func TestFreezeToFile_RecordsPackageChannels(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	mamba, _ := writeFakeTool(t, testDir, "micromamba", `cat <<'JSON'
+[
+  {"name": "numpy", "version": "1.26.4", "build_string": "py311h64a7726_0", "channel": "conda-forge"},
+  {"name": "mkl", "version": "2023.1.0", "build_string": "h213fc3f_46344", "channel": "defaults"},
+  {"name": "requests", "version": "2.31.0", "build_string": "pyhd8ed1ab_0", "channel": "conda-forge"}
+]
+JSON`)
+	pip, _ := writeFakeTool(t, testDir, "pip", `echo "requests==2.31.0"`)
+	env := &PythonEnvironment{PipPath: pip}
+	env.EnvironmentName = "mixed"
+	env.MicromambaPath = mamba
+
+	specPath := filepath.Join(testDir, "environment.json")
+	if err := env.FreezeToFile(specPath); err != nil {
+		t.Fatalf("FreezeToFile failed: %v", err)
+	}
+	spec, err := readEnvironmentSpec(specPath)
+	if err != nil {
+		t.Fatalf("readEnvironmentSpec failed: %v", err)
+	}
+
+	// Conda packages carry their channel; pip packages are not duplicated
+	want := []PackageSpec{
+		{Name: "numpy", Version: "1.26.4", Build: "py311h64a7726_0", Source: "conda", Channel: "conda-forge"},
+		{Name: "mkl", Version: "2023.1.0", Build: "h213fc3f_46344", Source: "conda", Channel: "defaults"},
+	}
+	if !reflect.DeepEqual(spec.Packages, want) {
+		t.Errorf("Packages = %+v, want %+v", spec.Packages, want)
+	}
+
+	// Restoring pins each conda package to its channel and keeps the legacy pip list
+	condaSpecs, pipSpecs := restorePackageSpecs(spec)
+	wantConda := []string{"conda-forge::numpy=1.26.4=py311h64a7726_0", "defaults::mkl=2023.1.0=h213fc3f_46344"}
+	if !reflect.DeepEqual(condaSpecs, wantConda) {
+		t.Errorf("conda specs = %v, want %v", condaSpecs, wantConda)
+	}
+	if !reflect.DeepEqual(pipSpecs, []string{"requests==2.31.0"}) {
+		t.Errorf("pip specs = %v, want [requests==2.31.0]", pipSpecs)
+	}
+}
+
+func TestRepairVenv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("venvs on Windows copy the interpreter instead of linking it")
+	}
+	baseEnv, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("No system Python available: %v", err)
+	}
+
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	venv, err := CreateVenvEnvironment(baseEnv, filepath.Join(testDir, "venv"), VenvOptions{}, nil)
+	if err != nil {
+		t.Skipf("Could not create a venv from the system Python: %v", err)
+	}
+
+	// Break the interpreter: remove bin/python and leave the other links dangling
+	// as they would be if the base Python had moved.
+	python := filepath.Join(venv.EnvBinPath, "python")
+	if err := os.Remove(python); err != nil {
+		t.Fatalf("removing %s: %v", python, err)
+	}
+	entries, _ := os.ReadDir(venv.EnvBinPath)
+	for _, entry := range entries {
+		path := filepath.Join(venv.EnvBinPath, entry.Name())
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 && strings.HasPrefix(entry.Name(), "python") {
+			os.Remove(path)
+			os.Symlink(filepath.Join(testDir, "gone", "python3"), path)
+		}
+	}
+	if err := exec.Command(venv.PythonPath, "-c", "import sys").Run(); err == nil {
+		t.Fatal("the interpreter still runs after breaking it")
+	}
+
+	if err := venv.RepairVenv(baseEnv.PythonPath); err != nil {
+		t.Fatalf("RepairVenv failed: %v", err)
+	}
+
+	out, err := exec.Command(python, "-c", "import sys; print(sys.prefix)").CombinedOutput()
+	if err != nil {
+		t.Fatalf("running the repaired interpreter failed: %v: %s", err, out)
+	}
+	if got := strings.TrimSpace(string(out)); got != venv.EnvPath {
+		t.Errorf("sys.prefix = %q, want %q", got, venv.EnvPath)
+	}
+}
+
+func TestNetworkConfig_ToolArguments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	proxy, _ := url.Parse("http://proxy.example.com:8080")
+	caFile := filepath.Join(testDir, "ca.pem")
+	SetNetworkConfig(NetworkConfig{Proxy: proxy, CACertFile: caFile, Timeout: 2500 * time.Millisecond})
+	defer SetNetworkConfig(NetworkConfig{})
+
+	pip, pipLog := writeFakeTool(t, testDir, "pip", "")
+	// micromamba has no proxy flag, so record the proxy it is given in its environment
+	mamba, mambaLog := writeFakeTool(t, testDir, "micromamba", `echo "HTTPS_PROXY=$HTTPS_PROXY" >> "$0.log"`)
+	env := &PythonEnvironment{PipPath: pip}
+	env.MicromambaPath = mamba
+	env.EnvPath = filepath.Join(testDir, "env")
+
+	if err := env.PipInstallPackages([]string{"requests"}, "", "", false, nil); err != nil {
+		t.Fatalf("PipInstallPackages failed: %v", err)
+	}
+	calls := readInvocations(t, pipLog)
+	if len(calls) != 1 || !strings.Contains(calls[0], "--proxy http://proxy.example.com:8080 --cert "+caFile+" --timeout 2.5") {
+		t.Errorf("expected pip to get the proxy, cert and timeout flags, got %v", calls)
+	}
+
+	if err := env.MicromambaInstallPackages([]string{"numpy"}, "conda-forge", nil); err != nil {
+		t.Fatalf("MicromambaInstallPackages failed: %v", err)
+	}
+	calls = readInvocations(t, mambaLog)
+	if len(calls) != 2 || !strings.Contains(calls[0], "--cacert-path "+caFile) || calls[1] != "HTTPS_PROXY=http://proxy.example.com:8080" {
+		t.Errorf("expected micromamba to get --cacert-path and the proxy environment, got %v", calls)
+	}
+}
+
+func TestEnsurePackages_InstallsOnlyMissing(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := createTestDir(t)
+	defer cleanupTestDir(t, testDir)
+
+	pip, pipLog := writeFakeTool(t, testDir, "pip", `case "$1" in list) echo '[{"name": "requests", "version": "2.31.0"}, {"name": "numpy", "version": "1.24.0"}]';; esac`)
+	env := &PythonEnvironment{PipPath: pip, PythonPath: filepath.Join(testDir, "python")}
+	defer env.invalidatePackageCache()
+
+	err := env.EnsurePackages([]PackageSpec{
+		{Name: "Requests"},                            // installed at any version
+		{Name: "numpy", Version: "1.26.0"},            // installed at another version
+		{Name: "typing_extensions", Version: "4.9.0"}, // missing
+	})
+	if err != nil {
+		t.Fatalf("EnsurePackages failed: %v", err)
+	}
+
+	var installs []string
+	for _, call := range readInvocations(t, pipLog) {
+		if strings.HasPrefix(call, "install ") {
+			installs = append(installs, call)
+		}
+	}
+	if len(installs) != 1 || !strings.HasSuffix(installs[0], " numpy==1.26.0 typing_extensions==4.9.0") {
+		t.Errorf("expected one pip install of the missing packages, got %v", installs)
+	}
+
+	// Conda specs need a micromamba environment
+	err = env.EnsurePackages([]PackageSpec{{Name: "libzlib", Source: "conda"}})
+	if err == nil || !strings.Contains(err.Error(), "not managed by micromamba") {
+		t.Errorf("expected an error for conda specs without micromamba, got %v", err)
+	}
+}
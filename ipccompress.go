@@ -0,0 +1,111 @@
+package jumpboot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Compression flags stored in the first byte of each frame sent through a
+// CompressingTransport.
+const (
+	compressionNone byte = 0
+	compressionGzip byte = 1
+)
+
+// DefaultCompressMinSize is the smallest frame, in bytes, that
+// CompressingTransport compresses when no threshold is given.
+const DefaultCompressMinSize = 1024
+
+// queueCompressionEnvVar tells the Python queue server to wrap its transport
+// in a CompressingTransport with the given minimum size.
+const queueCompressionEnvVar = "JUMPBOOT_QUEUE_COMPRESSION"
+
+// CompressingTransport wraps a Transport and gzip-compresses frames that are
+// at least MinSize bytes long. Each frame carries a one-byte header inside the
+// length-prefixed payload:
+//
+//	[4-byte length (big-endian)][flag][message, compressed if flag is 1]
+//
+// Small frames, and frames that do not shrink, are sent uncompressed with flag
+// 0. Both peers must use a CompressingTransport; the Python queue server does
+// so automatically when QueueOptions.CompressMinSize is set.
+type CompressingTransport struct {
+	inner   Transport
+	minSize int
+}
+
+// NewCompressingTransport returns a CompressingTransport over inner. Frames
+// shorter than minSize are not compressed; a minSize of zero or less uses
+// DefaultCompressMinSize.
+func NewCompressingTransport(inner Transport, minSize int) *CompressingTransport {
+	if minSize <= 0 {
+		minSize = DefaultCompressMinSize
+	}
+	return &CompressingTransport{inner: inner, minSize: minSize}
+}
+
+// Send compresses the frame if it is large enough and sends it.
+func (ct *CompressingTransport) Send(data []byte) error {
+	if len(data) >= ct.minSize {
+		var buf bytes.Buffer
+		buf.WriteByte(compressionGzip)
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return fmt.Errorf("error compressing frame: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("error compressing frame: %v", err)
+		}
+		if buf.Len() < len(data)+1 {
+			return ct.inner.Send(buf.Bytes())
+		}
+	}
+
+	frame := make([]byte, len(data)+1)
+	frame[0] = compressionNone
+	copy(frame[1:], data)
+	return ct.inner.Send(frame)
+}
+
+// Receive reads a frame and decompresses it if it was compressed.
+func (ct *CompressingTransport) Receive() ([]byte, error) {
+	frame, err := ct.inner.Receive()
+	if err != nil {
+		return nil, err
+	}
+	if len(frame) == 0 {
+		return nil, fmt.Errorf("error reading compressed frame: missing header")
+	}
+
+	switch frame[0] {
+	case compressionNone:
+		return frame[1:], nil
+	case compressionGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(frame[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing frame: %v", err)
+		}
+		defer zr.Close()
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing frame: %v", err)
+		}
+		// The compressed frame is no longer needed
+		releaseFrame(ct.inner, frame)
+		return data, nil
+	default:
+		return nil, fmt.Errorf("error reading compressed frame: unknown compression flag %d", frame[0])
+	}
+}
+
+// Close closes the wrapped transport.
+func (ct *CompressingTransport) Close() error {
+	return ct.inner.Close()
+}
+
+// Flush flushes the wrapped transport.
+func (ct *CompressingTransport) Flush() error {
+	return ct.inner.Flush()
+}
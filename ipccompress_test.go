@@ -0,0 +1,86 @@
+package jumpboot
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+// TestCompressingTransportRoundTrip tests that frames below and above the
+// threshold survive CompressingTransport, and that only large frames are
+// compressed on the wire.
+func TestCompressingTransportRoundTrip(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe failed: %v", err)
+	}
+	inner := NewMsgpackTransport(reader, writer)
+	transport := NewCompressingTransport(inner, 64)
+	defer transport.Close()
+
+	tests := []struct {
+		name     string
+		data     []byte
+		wantFlag byte
+	}{
+		{"small", []byte("hello"), compressionNone},
+		{"large", bytes.Repeat([]byte("jumpboot "), 1000), compressionGzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Send from a goroutine so a large frame can't fill the pipe buffer
+			sendErr := make(chan error, 1)
+			go func() {
+				sendErr <- transport.Send(tt.data)
+			}()
+
+			frame, err := inner.Receive()
+			if err != nil {
+				t.Fatalf("Receive failed: %v", err)
+			}
+			if err := <-sendErr; err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+			if frame[0] != tt.wantFlag {
+				t.Errorf("flag = %d, want %d", frame[0], tt.wantFlag)
+			}
+			if tt.wantFlag == compressionGzip && len(frame) >= len(tt.data) {
+				t.Errorf("compressed frame is %d bytes, original %d", len(frame), len(tt.data))
+			}
+
+			// Feed the raw frame back through the compressing side
+			go func() {
+				sendErr <- inner.Send(frame)
+			}()
+			received, err := transport.Receive()
+			if err != nil {
+				t.Fatalf("Receive failed: %v", err)
+			}
+			if err := <-sendErr; err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+			if !bytes.Equal(received, tt.data) {
+				t.Errorf("round trip mismatch: got %d bytes, want %d", len(received), len(tt.data))
+			}
+		})
+	}
+}
+
+// TestCompressingTransportUnknownFlag tests that a frame with an unknown
+// compression flag is rejected.
+func TestCompressingTransportUnknownFlag(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe failed: %v", err)
+	}
+	inner := NewMsgpackTransport(reader, writer)
+	transport := NewCompressingTransport(inner, 0)
+	defer transport.Close()
+
+	go inner.Send([]byte{9, 'x'})
+	if _, err := transport.Receive(); err == nil || err == io.EOF {
+		t.Errorf("expected an unknown flag error, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package jumpboot
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONSerializer implements Serializer using JSON encoding. It is slower and
+// larger on the wire than MessagePack but human-readable, which makes traffic
+// easy to inspect when debugging. Binary data is carried as base64 strings and
+// all numbers decode as float64.
+//
+// When a QueueProcess is created with this serializer, the Python
+// MessagePackQueueServer selects its matching JSON serializer automatically.
+type JSONSerializer struct{}
+
+// Marshal encodes a Go value to JSON bytes.
+func (js JSONSerializer) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON bytes into a Go value.
+func (js JSONSerializer) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// JSONTransport implements Transport for JSON-encoded messages. It uses the
+// same framing as MsgpackTransport (a 4-byte big-endian length followed by the
+// message bytes), which is what the Python queue server expects regardless of
+// the serializer.
+type JSONTransport struct {
+	*MsgpackTransport
+}
+
+// NewJSONTransport creates a new JSONTransport using the provided reader and writer.
+func NewJSONTransport(reader io.ReadCloser, writer io.WriteCloser) *JSONTransport {
+	return &JSONTransport{NewMsgpackTransport(reader, writer)}
+}
@@ -0,0 +1,55 @@
+package jumpboot
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestJSONTransportRoundTrip tests that a nested map survives JSONSerializer
+// encoding and JSONTransport framing over a pipe.
+func TestJSONTransportRoundTrip(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe failed: %v", err)
+	}
+	transport := NewJSONTransport(reader, writer)
+	defer transport.Close()
+
+	serializer := JSONSerializer{}
+	message := map[string]interface{}{
+		"command":    "process",
+		"request_id": "req-1",
+		"data": map[string]interface{}{
+			"items":  []interface{}{1.0, "two", map[string]interface{}{"three": true}},
+			"nested": map[string]interface{}{"empty": nil, "name": "value"},
+		},
+	}
+
+	data, err := serializer.Marshal(message)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	// Send from a goroutine so a large frame can't fill the pipe buffer
+	sendErr := make(chan error, 1)
+	go func() {
+		sendErr <- transport.Send(data)
+	}()
+
+	received, err := transport.Receive()
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if err := <-sendErr; err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := serializer.Unmarshal(received, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, message) {
+		t.Errorf("round trip mismatch:\n got %v\nwant %v", decoded, message)
+	}
+}
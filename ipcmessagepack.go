@@ -2,6 +2,8 @@ package jumpboot
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/vmihailenco/msgpack/v5"
@@ -22,64 +24,108 @@ func (ms MsgpackSerializer) Unmarshal(data []byte, v interface{}) error {
 	return msgpack.Unmarshal(data, v)
 }
 
+// DefaultMaxMessageSize is the largest message a new MsgpackTransport
+// receives.
+const DefaultMaxMessageSize = 256 << 20
+
+// ErrMessageTooLarge is returned by MsgpackTransport.Receive when a length
+// prefix exceeds MaxMessageSize. The message is not read, so the stream can't
+// be used any further.
+var ErrMessageTooLarge = errors.New("message too large")
+
 // MsgpackTransport implements Transport using length-prefixed binary framing.
 // Each message is sent as a 4-byte big-endian length followed by the message bytes.
 // This matches the protocol used by the Python jumpboot.msgpackqueue module.
 type MsgpackTransport struct {
+	// MaxMessageSize is the largest message Receive accepts, checked before
+	// the message is allocated so a corrupt length prefix can't exhaust
+	// memory. Zero or less means no limit.
+	MaxMessageSize int
+
 	reader     io.ReadCloser
 	writer     io.WriteCloser
 	bufferPool *BufferPool
+	largePool  *TieredBufferPool
+}
+
+// MsgpackTransportOptions tunes the buffers a MsgpackTransport reuses. Zero
+// fields use the defaults.
+type MsgpackTransportOptions struct {
+	// BufferSize is the size of the small-message buffers, 8KB (matching
+	// Python) by default. Smaller messages are read into one of them and
+	// copied out.
+	BufferSize int
+
+	// BufferCount is the number of small-message buffers kept, 10 by default.
+	BufferCount int
+
+	// MaxPooledSize is the largest message whose buffer is reused once it is
+	// handed back with Release, 64MB by default. Larger messages are
+	// allocated every time.
+	MaxPooledSize int
 }
 
 // NewMsgpackTransport creates a new MsgpackTransport using the provided reader and writer.
-// A buffer pool with 8KB buffers (matching Python) is created for efficient memory usage.
+// A buffer pool with 8KB buffers (matching Python) is created for efficient memory usage,
+// and MaxMessageSize is set to DefaultMaxMessageSize.
 func NewMsgpackTransport(reader io.ReadCloser, writer io.WriteCloser) *MsgpackTransport {
+	return NewMsgpackTransportWithOptions(reader, writer, MsgpackTransportOptions{})
+}
+
+// NewMsgpackTransportWithOptions creates a new MsgpackTransport like
+// NewMsgpackTransport, with buffers sized by opts.
+func NewMsgpackTransportWithOptions(reader io.ReadCloser, writer io.WriteCloser, opts MsgpackTransportOptions) *MsgpackTransport {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 8192 // Same size as Python side
+	}
+	if opts.BufferCount <= 0 {
+		opts.BufferCount = 10
+	}
+	if opts.MaxPooledSize <= 0 {
+		opts.MaxPooledSize = 64 << 20
+	}
 	return &MsgpackTransport{reader: reader,
-		writer:     writer,
-		bufferPool: NewBufferPool(8192, 10), // Same size as Python side
+		writer:         writer,
+		bufferPool:     NewBufferPool(opts.BufferSize, opts.BufferCount),
+		largePool:      NewTieredBufferPool(opts.BufferSize+1, opts.MaxPooledSize),
+		MaxMessageSize: DefaultMaxMessageSize,
 	}
 }
 
 // Send transmits a message with a 4-byte length prefix.
 // The length is encoded as big-endian uint32.
+//
+// The pipes to Python are *os.File values, which are unbuffered: each Write
+// reaches the pipe immediately and there is nothing to flush. A writer that
+// buffers (one with a Flush() error method, such as a *bufio.Writer) is
+// flushed once the whole message has been written.
 func (mt *MsgpackTransport) Send(data []byte) error {
 	// Get length and convert to 4-byte array
 	lengthBytes := mt.bufferPool.Get()[:4]
 	binary.BigEndian.PutUint32(lengthBytes, uint32(len(data)))
 
 	// Send length
-	if _, err := mt.writer.Write(lengthBytes); err != nil {
-		mt.bufferPool.Put(lengthBytes)
-		return err
-	}
-
-	// Flush after length to ensure it's received immediately
-	if flusher, ok := mt.writer.(interface{ Flush() error }); ok {
-		if err := flusher.Flush(); err != nil {
-			mt.bufferPool.Put(lengthBytes)
-			return err
-		}
-	}
+	_, err := mt.writer.Write(lengthBytes)
 
 	// Put length buffer back in the pool
 	mt.bufferPool.Put(lengthBytes)
-
-	// Send data
-	_, err := mt.writer.Write(data)
 	if err != nil {
 		return err
 	}
 
-	// Flush after data
-	if flusher, ok := mt.writer.(interface{ Flush() error }); ok {
-		return flusher.Flush()
+	// Send data
+	if _, err := mt.writer.Write(data); err != nil {
+		return err
 	}
 
-	return nil
+	return mt.Flush()
 }
 
 // Receive reads a length-prefixed message from the transport.
-// Small messages (<=8KB) use the buffer pool; larger messages allocate new buffers.
+// Small messages (<=8KB) use the buffer pool and are returned as copies; larger
+// messages are read into a buffer from a pool of size classes, which is reused
+// if the caller hands the message back with Release.
+// A message longer than MaxMessageSize returns an error wrapping ErrMessageTooLarge.
 func (mt *MsgpackTransport) Receive() ([]byte, error) {
 	// Get buffer for length
 	lengthBuf := mt.bufferPool.Get()[:4]
@@ -93,6 +139,10 @@ func (mt *MsgpackTransport) Receive() ([]byte, error) {
 	length := binary.BigEndian.Uint32(lengthBuf)
 	mt.bufferPool.Put(lengthBuf)
 
+	if mt.MaxMessageSize > 0 && uint64(length) > uint64(mt.MaxMessageSize) {
+		return nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrMessageTooLarge, length, mt.MaxMessageSize)
+	}
+
 	// For small messages, use buffer pool
 	if length <= uint32(mt.bufferPool.bufSize) {
 		buf := mt.bufferPool.Get()[:length]
@@ -109,10 +159,36 @@ func (mt *MsgpackTransport) Receive() ([]byte, error) {
 		return result, nil
 	}
 
-	// For large messages, allocate a new buffer
-	data := make([]byte, length)
-	_, err := io.ReadFull(mt.reader, data)
-	return data, err
+	// For large messages, use a buffer from the size-class pool
+	data := mt.largePool.Get(int(length))
+	if _, err := io.ReadFull(mt.reader, data); err != nil {
+		mt.largePool.Put(data)
+		return nil, err
+	}
+	return data, nil
+}
+
+// Release hands a message returned by Receive back to the transport so its
+// buffer can be reused for a later message. The caller must not use data, or
+// anything sharing its memory, afterwards. Calling Release is optional: a
+// message that isn't released is garbage collected as usual.
+func (mt *MsgpackTransport) Release(data []byte) {
+	if cap(data) > mt.bufferPool.bufSize {
+		mt.largePool.Put(data)
+	}
+}
+
+// releaser is implemented by transports that can reuse the buffers of
+// received messages.
+type releaser interface {
+	Release(data []byte)
+}
+
+// releaseFrame hands a received frame back to t if it supports Release.
+func releaseFrame(t Transport, frame []byte) {
+	if r, ok := t.(releaser); ok {
+		r.Release(frame)
+	}
 }
 
 // Close closes both the reader and writer.
@@ -123,12 +199,11 @@ func (mt *MsgpackTransport) Close() error {
 	return mt.writer.Close()
 }
 
-// Flush flushes the writer if it supports the Flush method.
+// Flush flushes the writer if it supports the Flush method, returning its
+// error.
 func (mt *MsgpackTransport) Flush() error {
 	if flusher, ok := mt.writer.(interface{ Flush() error }); ok {
-		if err := flusher.Flush(); err != nil {
-			return nil
-		}
+		return flusher.Flush()
 	}
 	return nil
 }
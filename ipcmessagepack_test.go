@@ -0,0 +1,179 @@
+package jumpboot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// frame returns a length-prefixed frame declaring length bytes followed by
+// payload.
+func frame(length uint32, payload []byte) []byte {
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, length)
+	return append(header, payload...)
+}
+
+// TestMsgpackTransportMaxMessageSize tests that an oversized length prefix is
+// rejected before the message is allocated.
+func TestMsgpackTransportMaxMessageSize(t *testing.T) {
+	newTransport := func(data []byte) *MsgpackTransport {
+		return NewMsgpackTransport(io.NopCloser(bytes.NewReader(data)), nopWriteCloser{io.Discard})
+	}
+
+	transport := newTransport(frame(0xFFFFFFFF, nil))
+	if transport.MaxMessageSize != DefaultMaxMessageSize {
+		t.Errorf("MaxMessageSize = %d, want %d", transport.MaxMessageSize, DefaultMaxMessageSize)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := transport.Receive()
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Receive error = %v, want ErrMessageTooLarge", err)
+	}
+	if grown := after.TotalAlloc - before.TotalAlloc; grown > 1<<20 {
+		t.Errorf("Receive allocated %d bytes for a rejected message", grown)
+	}
+
+	// The limit itself is allowed
+	payload := bytes.Repeat([]byte("x"), 10000)
+	transport = newTransport(frame(10000, payload))
+	transport.MaxMessageSize = 10000
+	if data, err := transport.Receive(); err != nil || !bytes.Equal(data, payload) {
+		t.Errorf("Receive at the limit = %d bytes, %v", len(data), err)
+	}
+	transport = newTransport(frame(10001, append(payload, 'x')))
+	transport.MaxMessageSize = 10000
+	if _, err := transport.Receive(); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Receive over the limit error = %v, want ErrMessageTooLarge", err)
+	}
+
+	// Zero disables the limit
+	transport = newTransport(frame(10001, append(payload, 'x')))
+	transport.MaxMessageSize = 0
+	if data, err := transport.Receive(); err != nil || len(data) != 10001 {
+		t.Errorf("Receive without a limit = %d bytes, %v", len(data), err)
+	}
+}
+
+// flushWriter is a buffering writer whose Flush fails with err.
+type flushWriter struct {
+	bytes.Buffer
+	flushes int
+	err     error
+}
+
+func (w *flushWriter) Flush() error {
+	w.flushes++
+	return w.err
+}
+
+func (w *flushWriter) Close() error {
+	return nil
+}
+
+// TestMsgpackTransportFlushError tests that flush errors reach the caller of
+// Flush and Send, and that Send flushes a buffering writer once per message.
+func TestMsgpackTransportFlushError(t *testing.T) {
+	writer := &flushWriter{}
+	transport := NewMsgpackTransport(io.NopCloser(bytes.NewReader(nil)), writer)
+	if err := transport.Send([]byte("hello")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if writer.flushes != 1 {
+		t.Errorf("Send flushed %d times, want 1", writer.flushes)
+	}
+	if !bytes.Equal(writer.Bytes(), frame(5, []byte("hello"))) {
+		t.Errorf("Send wrote %q", writer.Bytes())
+	}
+
+	writer.err = errors.New("broken pipe")
+	if err := transport.Flush(); err != writer.err {
+		t.Errorf("Flush error = %v, want %v", err, writer.err)
+	}
+	if err := transport.Send([]byte("hello")); err != writer.err {
+		t.Errorf("Send error = %v, want %v", err, writer.err)
+	}
+}
+
+// loopReader returns data over and over.
+type loopReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *loopReader) Read(p []byte) (int, error) {
+	if r.pos == len(r.data) {
+		r.pos = 0
+	}
+	n := copy(p, r.data[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+// mixedFrames returns three small frames followed by a large one.
+func mixedFrames() []byte {
+	var stream []byte
+	for i := 0; i < 3; i++ {
+		stream = append(stream, frame(100, bytes.Repeat([]byte{byte(i)}, 100))...)
+	}
+	return append(stream, frame(1<<20, bytes.Repeat([]byte{9}, 1<<20))...)
+}
+
+// TestMsgpackTransportRelease tests that a released large message's buffer is
+// reused for the next one and that messages read correctly either way.
+func TestMsgpackTransportRelease(t *testing.T) {
+	transport := NewMsgpackTransportWithOptions(io.NopCloser(&loopReader{data: mixedFrames()}), nopWriteCloser{io.Discard},
+		MsgpackTransportOptions{BufferSize: 1024, BufferCount: 2})
+
+	reused := false
+	var previous []byte
+	for i := 0; i < 40; i++ {
+		data, err := transport.Receive()
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		want := bytes.Repeat([]byte{byte(i % 4)}, 100)
+		if i%4 == 3 {
+			want = bytes.Repeat([]byte{9}, 1<<20)
+			// sync.Pool may drop buffers, so any reuse will do
+			reused = reused || (previous != nil && &data[0] == &previous[0])
+			previous = data
+		}
+		if !bytes.Equal(data, want) {
+			t.Fatalf("message %d has the wrong contents", i)
+		}
+		transport.Release(data)
+	}
+	if !reused {
+		t.Error("released large buffers were never reused")
+	}
+}
+
+// BenchmarkMsgpackTransportReceive receives a mix of small and large
+// messages, with and without handing them back with Release.
+func BenchmarkMsgpackTransportReceive(b *testing.B) {
+	for _, release := range []bool{false, true} {
+		name := "NoRelease"
+		if release {
+			name = "Release"
+		}
+		b.Run(name, func(b *testing.B) {
+			transport := NewMsgpackTransport(io.NopCloser(&loopReader{data: mixedFrames()}), nopWriteCloser{io.Discard})
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				data, err := transport.Receive()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if release {
+					transport.Release(data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package jumpboot
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Raw frames carry a binary payload without passing it through the
+// serializer. They share the transport's length-prefixed framing with
+// serialized messages and are told apart by their first byte, 0xc1, which is
+// never produced by MessagePack or JSON:
+//
+//	[0xc1][kind][2-byte id length][id][2-byte name length][name][payload]
+//
+// Lengths are big-endian. The name is the method for requests and empty for
+// responses; an error response carries the error message as its payload.
+const rawFrameMarker byte = 0xc1
+
+// Raw frame kinds.
+const (
+	rawKindRequest  byte = 1
+	rawKindResponse byte = 2
+	rawKindError    byte = 3
+)
+
+// isRawFrame reports whether a frame is a raw frame rather than a serialized message.
+func isRawFrame(frame []byte) bool {
+	return len(frame) > 0 && frame[0] == rawFrameMarker
+}
+
+// encodeRawFrame builds a raw frame.
+func encodeRawFrame(kind byte, requestID, name string, payload []byte) ([]byte, error) {
+	if len(requestID) > 0xffff || len(name) > 0xffff {
+		return nil, errors.New("raw frame request ID or name is too long")
+	}
+	frame := make([]byte, 0, 6+len(requestID)+len(name)+len(payload))
+	frame = append(frame, rawFrameMarker, kind)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(requestID)))
+	frame = append(frame, requestID...)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(name)))
+	frame = append(frame, name...)
+	return append(frame, payload...), nil
+}
+
+// decodeRawFrame splits a raw frame into its parts. The payload shares memory
+// with frame.
+func decodeRawFrame(frame []byte) (kind byte, requestID, name string, payload []byte, err error) {
+	if len(frame) < 4 || frame[0] != rawFrameMarker {
+		return 0, "", "", nil, errors.New("invalid raw frame header")
+	}
+	kind = frame[1]
+	rest := frame[2:]
+
+	readString := func() (string, bool) {
+		if len(rest) < 2 {
+			return "", false
+		}
+		n := int(binary.BigEndian.Uint16(rest))
+		if len(rest) < 2+n {
+			return "", false
+		}
+		s := string(rest[2 : 2+n])
+		rest = rest[2+n:]
+		return s, true
+	}
+
+	var ok bool
+	if requestID, ok = readString(); !ok {
+		return 0, "", "", nil, errors.New("truncated raw frame request ID")
+	}
+	if name, ok = readString(); !ok {
+		return 0, "", "", nil, errors.New("truncated raw frame name")
+	}
+	return kind, requestID, name, rest, nil
+}
+
+// handleRawFrame routes a raw frame received from Python. Raw responses are
+// delivered to the waiting CallRaw like any other response.
+func (jq *QueueProcess) handleRawFrame(frame []byte) {
+	kind, requestID, _, payload, err := decodeRawFrame(frame)
+	if err != nil {
+		jq.logger.get().Errorf("Error decoding raw frame: %v", err)
+		return
+	}
+
+	var message map[string]interface{}
+	switch kind {
+	case rawKindResponse:
+		message = map[string]interface{}{"request_id": requestID, "raw": payload}
+	case rawKindError:
+		message = map[string]interface{}{"request_id": requestID, "error": string(payload)}
+	case rawKindRequest:
+		// Python can only answer raw calls, not make them
+		reply, err := encodeRawFrame(rawKindError, requestID, "", []byte("raw requests to Go are not supported"))
+		if err == nil {
+			err = jq.sendFrame(reply)
+		}
+		if err != nil {
+			jq.logger.get().Errorf("Error rejecting raw request from Python: %v", err)
+		}
+		return
+	default:
+		jq.logger.get().Warnf("Unknown raw frame kind %d", kind)
+		return
+	}
+
+	jq.mutex.Lock()
+	if ch, exists := jq.responseMap[requestID]; exists {
+		ch <- message
+		delete(jq.responseMap, requestID)
+	}
+	jq.mutex.Unlock()
+}
+
+// CallRaw invokes a Python method with a binary payload and returns the
+// binary result, bypassing the serializer in both directions. It is intended
+// for bulk data such as images or tensors, where encoding the bytes inside a
+// message map adds copies and overhead.
+//
+// The Python method receives the payload as bytes for its first argument and
+// must return bytes, bytearray or memoryview (None returns an empty result).
+// A timeout of zero or less waits indefinitely. Requests are correlated with
+// the same request IDs as Call, and a timed-out call is cancelled the same way.
+func (jq *QueueProcess) CallRaw(methodName string, payload []byte, timeout time.Duration) ([]byte, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	requestID := jq.generateRequestID()
+	frame, err := encodeRawFrame(rawKindRequest, requestID, methodName, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	responseChan := make(chan map[string]interface{}, 1)
+	jq.mutex.Lock()
+	jq.responseMap[requestID] = responseChan
+	jq.mutex.Unlock()
+
+	if err := jq.sendFrame(frame); err != nil {
+		jq.mutex.Lock()
+		delete(jq.responseMap, requestID)
+		jq.mutex.Unlock()
+		return nil, err
+	}
+
+	response, err := jq.waitForResponse(ctx, methodName, requestID, responseChan)
+	if err != nil {
+		return nil, err
+	}
+	if errMsg, ok := response["error"].(string); ok {
+		return nil, fmt.Errorf("python error: %s", errMsg)
+	}
+	result, ok := response["raw"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected non-raw response to raw call: %s", methodName)
+	}
+	return result, nil
+}
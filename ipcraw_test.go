@@ -0,0 +1,160 @@
+package jumpboot
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRawFrameRoundTrip tests that raw frames decode to the parts they were
+// built from and are told apart from serialized messages.
+func TestRawFrameRoundTrip(t *testing.T) {
+	payload := []byte{0xc1, 0x00, 0xff, 'x'}
+	frame, err := encodeRawFrame(rawKindRequest, "req-7", "process_image", payload)
+	if err != nil {
+		t.Fatalf("encodeRawFrame failed: %v", err)
+	}
+	if !isRawFrame(frame) {
+		t.Fatal("encoded frame is not recognized as raw")
+	}
+
+	kind, requestID, name, decoded, err := decodeRawFrame(frame)
+	if err != nil {
+		t.Fatalf("decodeRawFrame failed: %v", err)
+	}
+	if kind != rawKindRequest || requestID != "req-7" || name != "process_image" || !bytes.Equal(decoded, payload) {
+		t.Errorf("got kind=%d id=%q name=%q payload=%v", kind, requestID, name, decoded)
+	}
+
+	// Truncated frames are rejected
+	if _, _, _, _, err := decodeRawFrame(frame[:6]); err == nil {
+		t.Error("expected an error for a truncated frame")
+	}
+
+	// Serialized messages are never mistaken for raw frames
+	for _, serializer := range []Serializer{MsgpackSerializer{}, JSONSerializer{}} {
+		data, err := serializer.Marshal(map[string]interface{}{"command": "x", "request_id": "req-1"})
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if isRawFrame(data) {
+			t.Errorf("%T message recognized as a raw frame", serializer)
+		}
+	}
+}
+
+// TestCallRaw tests CallRaw against a fake Python peer over net.Pipe: a raw
+// response is returned, a raw error becomes a Go error, and a timed-out call
+// is removed from the response map and cancelled.
+func TestCallRaw(t *testing.T) {
+	goSide, pySide := net.Pipe()
+	peer := NewMsgpackTransport(pySide, nopWriteCloser{pySide})
+	serializer := MsgpackSerializer{}
+
+	cancelled := make(chan string, 1)
+	slowID := make(chan string, 1)
+	var sendMutex sync.Mutex
+	send := func(frame []byte) {
+		sendMutex.Lock()
+		defer sendMutex.Unlock()
+		peer.Send(frame)
+	}
+
+	go func() {
+		for {
+			frame, err := peer.Receive()
+			if err != nil {
+				return
+			}
+			if isRawFrame(frame) {
+				_, requestID, name, payload, err := decodeRawFrame(frame)
+				if err != nil {
+					return
+				}
+				switch name {
+				case "reverse":
+					out := make([]byte, len(payload))
+					for i, b := range payload {
+						out[len(payload)-1-i] = b
+					}
+					reply, _ := encodeRawFrame(rawKindResponse, requestID, "", out)
+					send(reply)
+				case "fail":
+					reply, _ := encodeRawFrame(rawKindError, requestID, "", []byte("bad image"))
+					send(reply)
+				case "slow":
+					// Never answer in time
+					slowID <- requestID
+				}
+				continue
+			}
+
+			var msg map[string]interface{}
+			if err := serializer.Unmarshal(frame, &msg); err != nil {
+				return
+			}
+			requestID, _ := msg["request_id"].(string)
+			switch msg["command"] {
+			case "__get_methods__":
+				out, _ := serializer.Marshal(map[string]interface{}{"request_id": requestID, "methods": map[string]interface{}{}})
+				send(out)
+			case "__cancel__":
+				data, _ := msg["data"].(map[string]interface{})
+				target, _ := data["request_id"].(string)
+				cancelled <- target
+			}
+		}
+	}()
+
+	jq, err := NewQueueProcessOverConn(goSide, nil)
+	if err != nil {
+		t.Fatalf("NewQueueProcessOverConn failed: %v", err)
+	}
+	defer jq.Close()
+
+	// 1. Response kind
+	result, err := jq.CallRaw("reverse", []byte{1, 2, 3, rawFrameMarker}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("CallRaw failed: %v", err)
+	}
+	if !bytes.Equal(result, []byte{rawFrameMarker, 3, 2, 1}) {
+		t.Errorf("CallRaw returned %v", result)
+	}
+
+	// 2. Error kind
+	if _, err := jq.CallRaw("fail", []byte("x"), 5*time.Second); err == nil || !strings.Contains(err.Error(), "bad image") {
+		t.Errorf("expected the Python error, got %v", err)
+	}
+
+	// 3. Timeout and cancellation
+	if _, err := jq.CallRaw("slow", []byte("x"), 100*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	requestID := <-slowID
+	select {
+	case target := <-cancelled:
+		if target != requestID {
+			t.Errorf("cancelled %q, want %q", target, requestID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no __cancel__ was sent for the timed-out call")
+	}
+
+	jq.mutex.Lock()
+	_, pending := jq.responseMap[requestID]
+	jq.mutex.Unlock()
+	if pending {
+		t.Error("timed-out request is still in the response map")
+	}
+
+	// A late response is dropped and later calls still work
+	late, _ := encodeRawFrame(rawKindResponse, requestID, "", []byte("late"))
+	send(late)
+	result, err = jq.CallRaw("reverse", []byte("ab"), 5*time.Second)
+	if err != nil || string(result) != "ba" {
+		t.Errorf("CallRaw after a late response returned %q, %v", result, err)
+	}
+}
@@ -0,0 +1,183 @@
+package jumpboot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// recordedFrame is one line of a transport recording. Recordings are JSON
+// lines, one per frame, in the order the frames crossed the transport.
+type recordedFrame struct {
+	// Dir is "send" for frames Go sent and "recv" for frames Go received.
+	Dir string `json:"dir"`
+
+	// Time is when the frame crossed the transport.
+	Time time.Time `json:"time"`
+
+	// Frame is the serialized message (base64 encoded in the JSON).
+	Frame []byte `json:"frame"`
+}
+
+// recordingTransport wraps a Transport and writes every frame it sends or
+// receives to a recording.
+type recordingTransport struct {
+	inner Transport
+	mutex sync.Mutex
+	enc   *json.Encoder
+}
+
+// NewRecordingTransport returns a Transport that behaves like inner and also
+// writes every frame sent and received to w, for later replay with
+// NewReplayTransport. QueueOptions.RecordTo applies this automatically.
+func NewRecordingTransport(inner Transport, w io.Writer) Transport {
+	return &recordingTransport{inner: inner, enc: json.NewEncoder(w)}
+}
+
+func (rt *recordingTransport) record(dir string, frame []byte) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	// Recording is best-effort; a failing writer must not break the connection
+	rt.enc.Encode(recordedFrame{Dir: dir, Time: time.Now(), Frame: frame})
+}
+
+// Send transmits the frame and records it.
+func (rt *recordingTransport) Send(data []byte) error {
+	if err := rt.inner.Send(data); err != nil {
+		return err
+	}
+	rt.record("send", data)
+	return nil
+}
+
+// Receive reads a frame and records it.
+func (rt *recordingTransport) Receive() ([]byte, error) {
+	data, err := rt.inner.Receive()
+	if err != nil {
+		return nil, err
+	}
+	rt.record("recv", data)
+	return data, nil
+}
+
+// Release hands a received frame back to the wrapped transport. The frame has
+// already been written to the recording.
+func (rt *recordingTransport) Release(data []byte) {
+	releaseFrame(rt.inner, data)
+}
+
+// Close closes the wrapped transport.
+func (rt *recordingTransport) Close() error {
+	return rt.inner.Close()
+}
+
+// Flush flushes the wrapped transport.
+func (rt *recordingTransport) Flush() error {
+	return rt.inner.Flush()
+}
+
+// ReplayTransport is a Transport that plays back the Python side of a
+// recording made with QueueOptions.RecordTo or NewRecordingTransport, so that
+// Go RPC code can be tested deterministically without a Python process.
+//
+// Received frames are returned in recorded order, and each one is held back
+// until Go has sent as many frames as it had when the frame was originally
+// received. Frames sent by Go are counted but not compared with the recording.
+// Because request IDs are generated sequentially, the Go code must issue its
+// requests in the same order as when the recording was made.
+type ReplayTransport struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	frames []replayFrame
+	next   int
+	sent   int
+	closed bool
+}
+
+// replayFrame is a recorded received frame and the number of frames Go had
+// sent before it arrived.
+type replayFrame struct {
+	data       []byte
+	sentBefore int
+}
+
+// NewReplayTransport reads a recording from r and returns a transport that
+// replays it. Use it with NewQueueProcessOverTransport.
+func NewReplayTransport(r io.Reader) (*ReplayTransport, error) {
+	rt := &ReplayTransport{}
+	rt.cond = sync.NewCond(&rt.mutex)
+
+	sent := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var frame recordedFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("error parsing recording line %d: %v", line, err)
+		}
+		switch frame.Dir {
+		case "send":
+			sent++
+		case "recv":
+			rt.frames = append(rt.frames, replayFrame{data: frame.Frame, sentBefore: sent})
+		default:
+			return nil, fmt.Errorf("error parsing recording line %d: unknown direction %q", line, frame.Dir)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading recording: %v", err)
+	}
+
+	return rt, nil
+}
+
+// Send counts a frame sent by Go, which may release the next recorded frame.
+func (rt *ReplayTransport) Send(data []byte) error {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	if rt.closed {
+		return io.ErrClosedPipe
+	}
+	rt.sent++
+	rt.cond.Broadcast()
+	return nil
+}
+
+// Receive returns the next recorded frame once Go has sent the frames that
+// preceded it. It returns io.EOF when the recording is exhausted or the
+// transport is closed.
+func (rt *ReplayTransport) Receive() ([]byte, error) {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	for {
+		if rt.closed || rt.next >= len(rt.frames) {
+			return nil, io.EOF
+		}
+		frame := rt.frames[rt.next]
+		if rt.sent >= frame.sentBefore {
+			rt.next++
+			return frame.data, nil
+		}
+		rt.cond.Wait()
+	}
+}
+
+// Close stops the replay; pending and future Receive calls return io.EOF.
+func (rt *ReplayTransport) Close() error {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	rt.closed = true
+	rt.cond.Broadcast()
+	return nil
+}
+
+// Flush does nothing; replayed frames are not buffered.
+func (rt *ReplayTransport) Flush() error {
+	return nil
+}
@@ -0,0 +1,83 @@
+package jumpboot
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// TestReplayTransport tests that a recorded session replays the same results
+// without the original peer.
+func TestReplayTransport(t *testing.T) {
+	goSide, pySide := net.Pipe()
+	peer := NewMsgpackTransport(pySide, nopWriteCloser{pySide})
+	serializer := MsgpackSerializer{}
+
+	// Fake Python peer that doubles its argument
+	go func() {
+		for {
+			raw, err := peer.Receive()
+			if err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if err := serializer.Unmarshal(raw, &msg); err != nil {
+				return
+			}
+			reply := map[string]interface{}{"request_id": msg["request_id"]}
+			if msg["command"] == "__get_methods__" {
+				reply["result"] = map[string]interface{}{}
+			} else if n, ok := msg["data"].(int8); ok {
+				reply["result"] = int(n) * 2
+			} else {
+				continue
+			}
+			out, err := serializer.Marshal(reply)
+			if err != nil {
+				return
+			}
+			peer.Send(out)
+		}
+	}()
+
+	var recording bytes.Buffer
+	transport := NewRecordingTransport(NewMsgpackTransport(goSide, nopWriteCloser{goSide}), &recording)
+	jq, err := NewQueueProcessOverTransport(transport, nil)
+	if err != nil {
+		t.Fatalf("NewQueueProcessOverTransport failed: %v", err)
+	}
+
+	var want []int
+	for i := 1; i <= 3; i++ {
+		n, err := CallTyped[int](jq, "double", 0, i)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if n != i*2 {
+			t.Fatalf("Call %d returned %d, expected %d", i, n, i*2)
+		}
+		want = append(want, n)
+	}
+	jq.Close()
+	<-jq.loopDone
+
+	replay, err := NewReplayTransport(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayTransport failed: %v", err)
+	}
+	jq, err = NewQueueProcessOverTransport(replay, nil)
+	if err != nil {
+		t.Fatalf("NewQueueProcessOverTransport failed: %v", err)
+	}
+	defer jq.Close()
+
+	for i := 1; i <= 3; i++ {
+		n, err := CallTyped[int](jq, "double", 0, i)
+		if err != nil {
+			t.Fatalf("replayed Call failed: %v", err)
+		}
+		if n != want[i-1] {
+			t.Errorf("replayed Call %d returned %d, expected %d", i, n, want[i-1])
+		}
+	}
+}
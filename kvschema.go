@@ -0,0 +1,390 @@
+package jumpboot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateKVPairs checks that every KVPairs value can be encoded as JSON,
+// then checks the KVPairs against the program's KVSchema, if it has one, and
+// returns an error describing every violation. NewPythonProcessFromProgram
+// calls it before launching Python.
+//
+// KVSchema is a JSON Schema for the object formed by KVPairs. The supported
+// keywords are type, enum, const, properties, required, additionalProperties,
+// items, minItems, maxItems, minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, minLength, maxLength, pattern, allOf, anyOf and oneOf,
+// along with the annotations $schema, $id, $comment, title, description,
+// default and examples. A schema using any other keyword (such as $ref) is
+// rejected rather than partially checked. KVPairs values are compared after
+// JSON encoding, exactly as Python receives them.
+func (program *PythonProgram) ValidateKVPairs() error {
+	if err := checkKVPairsEncodable(program.KVPairs); err != nil {
+		return err
+	}
+	if len(program.KVSchema) == 0 {
+		return nil
+	}
+
+	var schema interface{}
+	if err := json.Unmarshal(program.KVSchema, &schema); err != nil {
+		return fmt.Errorf("error parsing KVSchema: %v", err)
+	}
+	if err := checkSchemaKeywords(schema, "#"); err != nil {
+		return fmt.Errorf("unsupported KVSchema: %v", err)
+	}
+
+	// Validate the values as Python will see them
+	kvpairs := program.KVPairs
+	if kvpairs == nil {
+		kvpairs = map[string]interface{}{}
+	}
+	data, err := json.Marshal(kvpairs)
+	if err != nil {
+		return fmt.Errorf("error encoding KVPairs: %v", err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("error encoding KVPairs: %v", err)
+	}
+
+	violations := validateSchema(schema, value, "jumpboot")
+	if len(violations) > 0 {
+		return fmt.Errorf("KVPairs do not match KVSchema: %s", strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// checkKVPairsEncodable reports the first KVPairs value, in key order, that
+// encoding/json can't encode, such as a channel, a function, a complex number
+// or a NaN, so the error names the key rather than failing as a whole.
+func checkKVPairsEncodable(kvpairs map[string]interface{}) error {
+	keys := make([]string, 0, len(kvpairs))
+	for key := range kvpairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := json.Marshal(kvpairs[key]); err != nil {
+			return fmt.Errorf("KVPairs[%q] can't be sent to Python: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// supportedSchemaKeywords lists the keywords validateSchema implements, and
+// the annotations that have no effect on validation.
+var supportedSchemaKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"allOf": true, "anyOf": true, "oneOf": true,
+	"$schema": true, "$id": true, "$comment": true,
+	"title": true, "description": true, "default": true, "examples": true,
+}
+
+// checkSchemaKeywords returns an error naming the first keyword in schema or
+// its subschemas that validateSchema does not implement, so that a schema is
+// never silently checked only in part. path is a JSON pointer to schema.
+func checkSchemaKeywords(schema interface{}, path string) error {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		// Boolean schemas have no keywords; anything else is reported when validating
+		return nil
+	}
+
+	keywords := make([]string, 0, len(s))
+	for keyword := range s {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	for _, keyword := range keywords {
+		if !supportedSchemaKeywords[keyword] {
+			return fmt.Errorf("keyword %q at %s is not supported", keyword, path)
+		}
+	}
+
+	// Descend into subschemas
+	if properties, ok := s["properties"].(map[string]interface{}); ok {
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := checkSchemaKeywords(properties[name], path+"/properties/"+name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"additionalProperties", "items"} {
+		if sub, ok := s[keyword]; ok {
+			if _, isArray := sub.([]interface{}); isArray {
+				return fmt.Errorf("array form of %q at %s is not supported", keyword, path)
+			}
+			if err := checkSchemaKeywords(sub, path+"/"+keyword); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		subs, _ := s[keyword].([]interface{})
+		for i, sub := range subs {
+			if err := checkSchemaKeywords(sub, fmt.Sprintf("%s/%s/%d", path, keyword, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateSchema returns the violations of schema by value, each prefixed with
+// the path to the offending value.
+func validateSchema(schema interface{}, value interface{}, path string) []string {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			return []string{fmt.Sprintf("%s: not allowed", path)}
+		}
+		return nil
+	case map[string]interface{}:
+		return validateSchemaObject(s, value, path)
+	default:
+		return []string{fmt.Sprintf("%s: invalid schema", path)}
+	}
+}
+
+func validateSchemaObject(schema map[string]interface{}, value interface{}, path string) []string {
+	var violations []string
+	fail := func(format string, args ...interface{}) {
+		violations = append(violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	// 1. Type, enum and const. A type mismatch makes the remaining checks moot.
+	if t, ok := schema["type"]; ok {
+		var types []string
+		switch t := t.(type) {
+		case string:
+			types = []string{t}
+		case []interface{}:
+			for _, name := range t {
+				if name, ok := name.(string); ok {
+					types = append(types, name)
+				}
+			}
+		}
+		matched := false
+		for _, name := range types {
+			if schemaTypeMatches(name, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("expected %s, got %s", strings.Join(types, " or "), schemaTypeName(value))
+			return violations
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if schemaValuesEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("value %s is not one of %s", schemaFormat(value), schemaFormat(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !schemaValuesEqual(constant, value) {
+		fail("value %s is not %s", schemaFormat(value), schemaFormat(constant))
+	}
+
+	// 2. Keywords for the value's type.
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if name, ok := name.(string); ok {
+					if _, present := v[name]; !present {
+						fail("missing required key %q", name)
+					}
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if propSchema, ok := properties[key]; ok {
+				violations = append(violations, validateSchema(propSchema, v[key], path+"."+key)...)
+			} else if additional, ok := schema["additionalProperties"]; ok {
+				if allowed, ok := additional.(bool); ok && !allowed {
+					fail("unexpected key %q", key)
+				} else {
+					violations = append(violations, validateSchema(additional, v[key], path+"."+key)...)
+				}
+			}
+		}
+
+	case []interface{}:
+		if min, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < min {
+			fail("expected at least %v items, got %d", min, len(v))
+		}
+		if max, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > max {
+			fail("expected at most %v items, got %d", max, len(v))
+		}
+		if items, ok := schema["items"]; ok {
+			for i, item := range v {
+				violations = append(violations, validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if min, ok := schemaNumber(schema, "minLength"); ok && length < min {
+			fail("expected at least %v characters, got %v", min, length)
+		}
+		if max, ok := schemaNumber(schema, "maxLength"); ok && length > max {
+			fail("expected at most %v characters, got %v", max, length)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fail("invalid pattern %q in schema: %v", pattern, err)
+			} else if !re.MatchString(v) {
+				fail("value %q does not match pattern %q", v, pattern)
+			}
+		}
+
+	case float64:
+		if min, ok := schemaNumber(schema, "minimum"); ok && v < min {
+			fail("value %v is less than minimum %v", v, min)
+		}
+		if max, ok := schemaNumber(schema, "maximum"); ok && v > max {
+			fail("value %v is greater than maximum %v", v, max)
+		}
+		if min, ok := schemaNumber(schema, "exclusiveMinimum"); ok && v <= min {
+			fail("value %v must be greater than %v", v, min)
+		}
+		if max, ok := schemaNumber(schema, "exclusiveMaximum"); ok && v >= max {
+			fail("value %v must be less than %v", v, max)
+		}
+	}
+
+	// 3. Combinators.
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			violations = append(violations, validateSchema(sub, value, path)...)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if len(validateSchema(sub, value, path)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("value does not match any schema in anyOf")
+		}
+	}
+	if one, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, sub := range one {
+			if len(validateSchema(sub, value, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			fail("value matches %d schemas in oneOf, expected exactly 1", matches)
+		}
+	}
+
+	return violations
+}
+
+// schemaTypeMatches reports whether a decoded JSON value has the named JSON
+// Schema type.
+func schemaTypeMatches(name string, value interface{}) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return false
+}
+
+// schemaTypeName returns the JSON Schema type name of a decoded JSON value.
+func schemaTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// schemaNumber returns a numeric schema keyword.
+func schemaNumber(schema map[string]interface{}, keyword string) (float64, bool) {
+	n, ok := schema[keyword].(float64)
+	return n, ok
+}
+
+// schemaValuesEqual compares two decoded JSON values.
+func schemaValuesEqual(a, b interface{}) bool {
+	return schemaFormat(a) == schemaFormat(b)
+}
+
+// schemaFormat renders a decoded JSON value as JSON for messages and
+// comparisons. Map keys are sorted by encoding/json, so equal values format
+// identically.
+func schemaFormat(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,117 @@
+package jumpboot
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// TestValidateKVPairs tests KVPairs validation against a JSON Schema.
+func TestValidateKVPairs(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"required": ["port", "mode"],
+		"additionalProperties": false,
+		"properties": {
+			"port": {"type": "integer", "minimum": 1, "maximum": 65535},
+			"mode": {"enum": ["fast", "safe"]},
+			"tags": {"type": "array", "items": {"type": "string", "minLength": 1}}
+		}
+	}`
+
+	tests := []struct {
+		name    string
+		kvpairs map[string]interface{}
+		wantErr string
+	}{
+		{"valid", map[string]interface{}{"port": 8080, "mode": "fast", "tags": []string{"a"}}, ""},
+		{"missing key", map[string]interface{}{"port": 8080}, `missing required key "mode"`},
+		{"wrong type", map[string]interface{}{"port": "8080", "mode": "fast"}, "jumpboot.port: expected integer, got string"},
+		{"out of range", map[string]interface{}{"port": 70000, "mode": "fast"}, "greater than maximum"},
+		{"not in enum", map[string]interface{}{"port": 80, "mode": "slow"}, `jumpboot.mode: value "slow" is not one of`},
+		{"bad item", map[string]interface{}{"port": 80, "mode": "safe", "tags": []string{""}}, "jumpboot.tags[0]: expected at least 1 characters"},
+		{"extra key", map[string]interface{}{"port": 80, "mode": "safe", "debug": true}, `unexpected key "debug"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := &PythonProgram{KVPairs: tt.kvpairs, KVSchema: []byte(schema)}
+			err := program.ValidateKVPairs()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A program without a schema is not validated
+	program := &PythonProgram{KVPairs: map[string]interface{}{"anything": 1}}
+	if err := program.ValidateKVPairs(); err != nil {
+		t.Errorf("unexpected error without a schema: %v", err)
+	}
+}
+
+// TestValidateKVPairs_NotEncodable tests that a value JSON can't encode is
+// reported by key, with or without a schema.
+func TestValidateKVPairs_NotEncodable(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"channel", make(chan int)},
+		{"function", func() {}},
+		{"complex", complex(1, 2)},
+		{"nan", math.NaN()},
+		{"nested", map[string]interface{}{"inner": []interface{}{1, make(chan int)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := &PythonProgram{KVPairs: map[string]interface{}{"ok": 1, "bad": tt.value}}
+			err := program.ValidateKVPairs()
+			if err == nil || !strings.Contains(err.Error(), `KVPairs["bad"] can't be sent to Python`) {
+				t.Errorf("error = %v, want it to name the bad key", err)
+			}
+		})
+	}
+}
+
+// TestValidateKVPairs_UnsupportedKeywords tests that schemas using keywords
+// the validator does not implement are rejected instead of partly applied.
+func TestValidateKVPairs_UnsupportedKeywords(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{"ref", `{"$defs": {"port": {"type": "integer"}}, "properties": {"port": {"$ref": "#/$defs/port"}}}`, `keyword "$defs" at # is not supported`},
+		{"nested ref", `{"properties": {"port": {"$ref": "#/definitions/port"}}}`, `keyword "$ref" at #/properties/port is not supported`},
+		{"in combinator", `{"anyOf": [{"type": "object"}, {"not": {}}]}`, `keyword "not" at #/anyOf/1 is not supported`},
+		{"in items", `{"properties": {"tags": {"items": {"uniqueItems": true}}}}`, `keyword "uniqueItems" at #/properties/tags/items`},
+		{"tuple items", `{"properties": {"pair": {"items": [{"type": "string"}]}}}`, `array form of "items"`},
+		{"conditional", `{"if": {"required": ["a"]}, "then": {"required": ["b"]}}`, `keyword "if"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := &PythonProgram{KVPairs: map[string]interface{}{"port": 80}, KVSchema: []byte(tt.schema)}
+			err := program.ValidateKVPairs()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Annotations are accepted
+	program := &PythonProgram{
+		KVPairs:  map[string]interface{}{"port": 80},
+		KVSchema: []byte(`{"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "Config", "properties": {"port": {"description": "Listen port", "default": 80, "type": "integer"}}}`),
+	}
+	if err := program.ValidateKVPairs(); err != nil {
+		t.Errorf("unexpected error with annotations: %v", err)
+	}
+}
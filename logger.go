@@ -0,0 +1,108 @@
+package jumpboot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Logger receives the package's diagnostic messages, such as undecodable
+// messages from Python or failures to send a response. By default they are
+// discarded; use SetLogger or a process's SetLogger to see them.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// nopLogger discards every message.
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Warnf(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+
+var (
+	packageLogger      Logger = nopLogger{}
+	packageLoggerMutex sync.RWMutex
+)
+
+// SetLogger sets the Logger used by processes without a logger of their own
+// and by functions that aren't tied to a process. Nil discards all messages,
+// which is the default.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	packageLoggerMutex.Lock()
+	defer packageLoggerMutex.Unlock()
+	packageLogger = l
+}
+
+// getPackageLogger returns the Logger set with SetLogger.
+func getPackageLogger() Logger {
+	packageLoggerMutex.RLock()
+	defer packageLoggerMutex.RUnlock()
+	return packageLogger
+}
+
+// loggerRef holds a process's Logger. It is safe to change while the
+// process's goroutines are logging, and falls back to the package logger
+// when unset.
+type loggerRef struct {
+	l atomic.Pointer[Logger]
+}
+
+func (r *loggerRef) set(l Logger) {
+	if l == nil {
+		r.l.Store(nil)
+		return
+	}
+	r.l.Store(&l)
+}
+
+func (r *loggerRef) get() Logger {
+	if r != nil {
+		if l := r.l.Load(); l != nil {
+			return *l
+		}
+	}
+	return getPackageLogger()
+}
+
+// slogLogger adapts a *slog.Logger to Logger.
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger returns a Logger that writes to l at the matching slog level.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+func (s slogLogger) logf(level slog.Level, format string, args []interface{}) {
+	ctx := context.Background()
+	if s.l.Enabled(ctx, level) {
+		s.l.Log(ctx, level, fmt.Sprintf(format, args...))
+	}
+}
+
+func (s slogLogger) Debugf(format string, args ...interface{}) {
+	s.logf(slog.LevelDebug, format, args)
+}
+
+func (s slogLogger) Infof(format string, args ...interface{}) {
+	s.logf(slog.LevelInfo, format, args)
+}
+
+func (s slogLogger) Warnf(format string, args ...interface{}) {
+	s.logf(slog.LevelWarn, format, args)
+}
+
+func (s slogLogger) Errorf(format string, args ...interface{}) {
+	s.logf(slog.LevelError, format, args)
+}
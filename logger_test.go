@@ -0,0 +1,143 @@
+package jumpboot
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger collects messages as "LEVEL message" lines.
+type recordingLogger struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (r *recordingLogger) add(level, format string, args []interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.messages = append(r.messages, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (r *recordingLogger) Debugf(format string, args ...interface{}) { r.add("DEBUG", format, args) }
+func (r *recordingLogger) Infof(format string, args ...interface{})  { r.add("INFO", format, args) }
+func (r *recordingLogger) Warnf(format string, args ...interface{})  { r.add("WARN", format, args) }
+func (r *recordingLogger) Errorf(format string, args ...interface{}) { r.add("ERROR", format, args) }
+
+func (r *recordingLogger) has(prefix string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, m := range r.messages {
+		if strings.HasPrefix(m, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestQueueProcessLogger tests that queue messages go to the queue's logger
+// rather than the package logger, and that Close writes nothing to stdout.
+func TestQueueProcessLogger(t *testing.T) {
+	packageLog := &recordingLogger{}
+	SetLogger(packageLog)
+	defer SetLogger(nil)
+
+	goSide, pySide := net.Pipe()
+	peer := NewMsgpackTransport(pySide, nopWriteCloser{pySide})
+	serializer := MsgpackSerializer{}
+	go func() {
+		for {
+			raw, err := peer.Receive()
+			if err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if err := serializer.Unmarshal(raw, &msg); err != nil {
+				return
+			}
+			if msg["command"] == "__get_methods__" {
+				out, _ := serializer.Marshal(map[string]interface{}{"request_id": msg["request_id"], "result": map[string]interface{}{}})
+				peer.Send(out)
+				// An array where a message map is expected
+				peer.Send([]byte{0x93, 1, 2, 3})
+			}
+		}
+	}()
+
+	jq, err := NewQueueProcessOverConn(goSide, nil)
+	if err != nil {
+		t.Fatalf("NewQueueProcessOverConn failed: %v", err)
+	}
+	queueLog := &recordingLogger{}
+	jq.SetLogger(queueLog)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !queueLog.has("ERROR Error decoding message") && !packageLog.has("ERROR Error decoding message") {
+		if time.Now().After(deadline) {
+			t.Fatal("the undecodable frame was not logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	jq.Close()
+	os.Stdout = stdout
+	w.Close()
+	printed, _ := io.ReadAll(r)
+	if len(printed) != 0 {
+		t.Errorf("Close printed %q", printed)
+	}
+	if !queueLog.has("DEBUG Sending exit command") {
+		t.Errorf("the queue logger got %q", queueLog.messages)
+	}
+	if packageLog.has("DEBUG") {
+		t.Errorf("the package logger got the queue's messages: %q", packageLog.messages)
+	}
+}
+
+// TestRunPythonScriptFromFileOutput tests that a script's own output is still
+// printed to stdout rather than being routed through the diagnostic logger.
+func TestRunPythonScriptFromFileOutput(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	packageLog := &recordingLogger{}
+	SetLogger(packageLog)
+	defer SetLogger(nil)
+
+	script := filepath.Join(t.TempDir(), "script.py")
+	if err := os.WriteFile(script, []byte("import sys\nprint('from the script', file=sys.stderr)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	err = env.RunPythonScriptFromFile(script)
+	os.Stdout = stdout
+	w.Close()
+	printed, _ := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("RunPythonScriptFromFile failed: %v", err)
+	}
+	if !strings.Contains(string(printed), "Python script output: from the script") {
+		t.Errorf("stdout got %q", printed)
+	}
+	if packageLog.has("INFO Python script output") {
+		t.Errorf("the script output went to the logger: %q", packageLog.messages)
+	}
+}
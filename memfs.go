@@ -0,0 +1,126 @@
+package jumpboot
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memFS is a read-only, in-memory fs.FS holding regular files keyed by their
+// slash-separated path. Directories are implied by the file paths. It backs
+// packages loaded from archives without extracting them to disk.
+type memFS map[string][]byte
+
+// Open implements fs.FS.
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := m[name]; ok {
+		return &memFile{info: memFileInfo{name: path.Base(name), size: int64(len(data))}, Reader: bytes.NewReader(data)}, nil
+	}
+	entries, err := m.ReadDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memDir{info: memFileInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+// ReadDir implements fs.ReadDirFS, returning the entries of a directory
+// sorted by name.
+func (m memFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+
+	children := map[string]memFileInfo{}
+	for filePath, data := range m {
+		if !strings.HasPrefix(filePath, prefix) {
+			continue
+		}
+		rest := filePath[len(prefix):]
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			children[rest[:i]] = memFileInfo{name: rest[:i], dir: true}
+		} else {
+			children[rest] = memFileInfo{name: rest, size: int64(len(data))}
+		}
+	}
+	if len(children) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, info := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// memFileInfo describes a memFS file or directory.
+type memFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return fi.size }
+func (fi memFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi memFileInfo) IsDir() bool        { return fi.dir }
+func (fi memFileInfo) Sys() interface{}   { return nil }
+
+func (fi memFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// memFile is an open memFS file.
+type memFile struct {
+	*bytes.Reader
+	info memFileInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *memFile) Close() error               { return nil }
+
+// memDir is an open memFS directory.
+type memDir struct {
+	info    memFileInfo
+	entries []fs.DirEntry
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
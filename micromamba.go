@@ -1,18 +1,192 @@
 package jumpboot
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
-// micromambaBaseURL is the base URL for downloading micromamba binaries.
-// This can be overridden for testing or to use a custom mirror.
-var micromambaBaseURL = "https://github.com/mamba-org/micromamba-releases/releases"
+// defaultMicromambaBaseURL is the public GitHub releases location for micromamba.
+const defaultMicromambaBaseURL = "https://github.com/mamba-org/micromamba-releases/releases"
+
+// micromambaBaseURL is the base URL for downloading micromamba binaries, and
+// micromambaHTTPClient the client used to fetch them (nil uses the NetworkConfig).
+// Both are guarded by micromambaDownloadMutex.
+var (
+	micromambaBaseURL       = defaultMicromambaBaseURL
+	micromambaHTTPClient    *http.Client
+	micromambaDownloadMutex sync.Mutex
+)
+
+// SetMicromambaBaseURL points micromamba downloads at a mirror instead of the
+// public GitHub releases. The platform/arch suffix is appended as usual, so the
+// mirror must reproduce the GitHub releases layout:
+//
+//	<baseURL>/download/<version>/micromamba-<platform>-<arch>
+//	<baseURL>/download/<version>/micromamba-<platform>-<arch>.sha256
+//	<baseURL>/latest/download/micromamba-<platform>-<arch>
+//
+// For example, "https://artifacts.example.com/micromamba-releases/releases".
+// Every download is checksum-verified, so a mirror that does not serve the
+// ".sha256" files fails each install unless DownloadOptions.SHA256 is set
+// with SetDownloadOptions.
+// Call this before creating environments; an empty url restores the default.
+func SetMicromambaBaseURL(url string) {
+	if url == "" {
+		url = defaultMicromambaBaseURL
+	}
+	micromambaDownloadMutex.Lock()
+	defer micromambaDownloadMutex.Unlock()
+	micromambaBaseURL = strings.TrimRight(url, "/")
+}
+
+// getMicromambaBaseURL returns the base URL set with SetMicromambaBaseURL.
+func getMicromambaBaseURL() string {
+	micromambaDownloadMutex.Lock()
+	defer micromambaDownloadMutex.Unlock()
+	return micromambaBaseURL
+}
+
+// SetMicromambaHTTPClient sets the HTTP client used to download micromamba,
+// for example one configured with an HTTP proxy:
+//
+//	proxyURL, _ := url.Parse("http://proxy.example.com:8080")
+//	jumpboot.SetMicromambaHTTPClient(&http.Client{
+//		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+//	})
+//
+// Call this before creating environments; nil restores the default client,
+// which honors SetNetworkConfig.
+func SetMicromambaHTTPClient(client *http.Client) {
+	micromambaDownloadMutex.Lock()
+	defer micromambaDownloadMutex.Unlock()
+	micromambaHTTPClient = client
+}
+
+// getMicromambaHTTPClient returns the client used for micromamba downloads:
+// the client set with SetMicromambaHTTPClient, or one built from the NetworkConfig.
+func getMicromambaHTTPClient() *http.Client {
+	micromambaDownloadMutex.Lock()
+	client := micromambaHTTPClient
+	micromambaDownloadMutex.Unlock()
+	if client != nil {
+		return client
+	}
+	return getNetworkConfig().httpClient()
+}
+
+// DownloadOptions configures how the micromamba binary is downloaded.
+type DownloadOptions struct {
+	// MaxRetries is the number of additional attempts made after a failed download.
+	// Only transient failures are retried. Zero disables retrying.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry. The delay doubles after
+	// each subsequent failure.
+	RetryBackoff time.Duration
+
+	// Version pins the micromamba release to download (e.g., "2.2.0-0").
+	// Empty uses the default release; "latest" downloads the newest release,
+	// in which case the checksum changes between releases.
+	Version string
+
+	// SHA256 is the expected hex-encoded SHA256 of the binary. If empty, the
+	// checksum is fetched from the release's "<binary>.sha256" file.
+	SHA256 string
+}
+
+// defaultMicromambaVersion is the micromamba release downloaded when no version is pinned.
+const defaultMicromambaVersion = "2.2.0-0"
+
+// defaultDownloadOptions are used by ExpectMicromamba and CreateEnvironmentMamba.
+var (
+	defaultDownloadOptions      = DownloadOptions{MaxRetries: 3, RetryBackoff: time.Second}
+	defaultDownloadOptionsMutex sync.Mutex
+)
+
+// SetDownloadOptions sets the retry behavior used when micromamba has to be
+// downloaded by ExpectMicromamba or CreateEnvironmentMamba.
+// The default is 3 retries starting with a 1 second backoff.
+func SetDownloadOptions(opts DownloadOptions) {
+	defaultDownloadOptionsMutex.Lock()
+	defer defaultDownloadOptionsMutex.Unlock()
+	defaultDownloadOptions = opts
+}
+
+// getDownloadOptions returns the current package-level download options.
+func getDownloadOptions() DownloadOptions {
+	defaultDownloadOptionsMutex.Lock()
+	defer defaultDownloadOptionsMutex.Unlock()
+	return defaultDownloadOptions
+}
+
+// MicromambaOptions configures how micromamba is run when creating
+// environments and installing conda packages.
+type MicromambaOptions struct {
+	// Quiet passes --quiet so micromamba prints less output, leaving the
+	// progress callback as the only progress reporting. Errors are still shown.
+	Quiet bool
+
+	// DownloadThreads limits the number of parallel package downloads.
+	// Zero uses micromamba's default.
+	DownloadThreads int
+
+	// ExtractThreads limits the number of parallel package extractions.
+	// Zero uses micromamba's default.
+	ExtractThreads int
+}
+
+var (
+	micromambaOptions      MicromambaOptions
+	micromambaOptionsMutex sync.Mutex
+)
+
+// SetMicromambaOptions sets the options used for all subsequent micromamba
+// invocations by CreateEnvironmentMamba and MicromambaInstallPackage.
+func SetMicromambaOptions(opts MicromambaOptions) {
+	micromambaOptionsMutex.Lock()
+	defer micromambaOptionsMutex.Unlock()
+	micromambaOptions = opts
+}
+
+// getMicromambaOptions returns the current micromamba options.
+func getMicromambaOptions() MicromambaOptions {
+	micromambaOptionsMutex.Lock()
+	defer micromambaOptionsMutex.Unlock()
+	return micromambaOptions
+}
+
+// args returns the micromamba command-line flags for the options.
+func (mo MicromambaOptions) args() []string {
+	var args []string
+	if mo.Quiet {
+		args = append(args, "--quiet")
+	}
+	return args
+}
+
+// env extends a micromamba environment with the thread settings, which
+// micromamba reads from its MAMBA_* configuration variables.
+func (mo MicromambaOptions) env(env []string) []string {
+	if mo.DownloadThreads > 0 {
+		env = append(env, fmt.Sprintf("MAMBA_DOWNLOAD_THREADS=%d", mo.DownloadThreads))
+	}
+	if mo.ExtractThreads > 0 {
+		env = append(env, fmt.Sprintf("MAMBA_EXTRACT_THREADS=%d", mo.ExtractThreads))
+	}
+	return env
+}
 
 // ExpectMicromamba ensures micromamba is available in the specified folder.
 // If not present, it downloads the appropriate binary for the current platform.
@@ -22,9 +196,25 @@ var micromambaBaseURL = "https://github.com/mamba-org/micromamba-releases/releas
 //   - macOS: amd64, arm64
 //   - Windows: amd64 (arm64 uses amd64 emulation)
 //
-// The binary is downloaded from GitHub releases and made executable on Unix systems.
+// The binary is downloaded from GitHub releases, verified against its published
+// SHA256 checksum, and made executable on Unix systems. Transient failures are retried according to the options set with SetDownloadOptions.
 // Returns the full path to the micromamba binary.
 func ExpectMicromamba(binFolder string, progressCallback ProgressCallback) (string, error) {
+	return ExpectMicromambaWithOptions(binFolder, getDownloadOptions(), progressCallback)
+}
+
+// ExpectMicromambaWithOptions is like ExpectMicromamba but uses the given
+// download options instead of the package-level defaults.
+//
+// Network errors and 5xx responses are retried with exponential backoff, and
+// each retry is reported through progressCallback (e.g., "retrying download (2/4)").
+// Other failures, such as an unknown host, a 404 response or an unwritable
+// folder, fail immediately.
+// The binary is downloaded to a ".part" file next to the target and is renamed
+// into place only after its SHA256 matches opts.SHA256 (or the release's published
+// checksum), so an incomplete or unverified binary is never left at the returned
+// path. On a mismatch the partial file is deleted and an error is returned.
+func ExpectMicromambaWithOptions(binFolder string, opts DownloadOptions, progressCallback ProgressCallback) (string, error) {
 	// Detect platform and architecture
 	platform := runtime.GOOS
 	arch := runtime.GOARCH
@@ -55,13 +245,15 @@ func ExpectMicromamba(binFolder string, progressCallback ProgressCallback) (stri
 
 	// Construct the download URL
 	var downloadURL string
-	version := "2.2.0-0" // Use this to specify a version, or leave empty for latest
+	baseURL := getMicromambaBaseURL()
+	version := opts.Version
 	if version == "" {
-		// Use the variable here!
-		downloadURL = fmt.Sprintf("%s/latest/download/%s-%s-%s", micromambaBaseURL, executableName, platform, arch)
+		version = defaultMicromambaVersion
+	}
+	if version == "latest" {
+		downloadURL = fmt.Sprintf("%s/latest/download/%s-%s-%s", baseURL, executableName, platform, arch)
 	} else {
-		// Use the variable here!
-		downloadURL = fmt.Sprintf("%s/download/%s/%s-%s-%s", micromambaBaseURL, version, executableName, platform, arch)
+		downloadURL = fmt.Sprintf("%s/download/%s/%s-%s-%s", baseURL, version, executableName, platform, arch)
 	}
 
 	// Ensure the target bin directory exists
@@ -74,26 +266,93 @@ func ExpectMicromamba(binFolder string, progressCallback ProgressCallback) (stri
 		executableName += ".exe"
 	}
 	binpath := filepath.Join(binFolder, executableName)
+	partPath := binpath + ".part"
+
+	attempts := opts.MaxRetries + 1
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := opts.RetryBackoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			if progressCallback != nil {
+				progressCallback(fmt.Sprintf("retrying download (%d/%d)", attempt, attempts), 0, -1)
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var retryable bool
+		retryable, err = downloadFile(downloadURL, partPath, progressCallback)
+		if err == nil || !retryable {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Verify the download before it is moved to binpath
+	expected := strings.ToLower(strings.TrimSpace(opts.SHA256))
+	if expected == "" {
+		expected, err = fetchSHA256(downloadURL + ".sha256")
+		if err != nil {
+			os.Remove(partPath)
+			return "", fmt.Errorf("error fetching micromamba checksum: %v", err)
+		}
+	}
+	actual, err := fileSHA256(partPath)
+	if err != nil {
+		os.Remove(partPath)
+		return "", fmt.Errorf("error computing micromamba checksum: %v", err)
+	}
+	if actual != expected {
+		os.Remove(partPath)
+		return "", fmt.Errorf("micromamba checksum mismatch for %s: expected %s, got %s", downloadURL, expected, actual)
+	}
+
+	// Change file permissions to make it executable (not applicable for Windows)
+	if platform != "win" {
+		if err := os.Chmod(partPath, 0755); err != nil {
+			os.Remove(partPath)
+			return "", fmt.Errorf("error setting file permissions: %v", err)
+		}
+	}
+
+	if err := os.Rename(partPath, binpath); err != nil {
+		os.Remove(partPath)
+		return "", fmt.Errorf("error moving downloaded file into place: %v", err)
+	}
 
-	req, err := http.NewRequest("GET", downloadURL, nil)
+	return binpath, nil
+}
+
+// downloadFile downloads url to destPath in a single attempt. destPath is
+// removed if the download fails or is incomplete. The returned bool reports
+// whether the failure is transient and worth retrying.
+func downloadFile(url string, destPath string, progressCallback ProgressCallback) (bool, error) {
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %v", err)
+		return false, fmt.Errorf("error creating request: %v", err)
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := getMicromambaHTTPClient().Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error downloading file: %v", err)
+		// A host that does not resolve won't start resolving between retries
+		var dnsErr *net.DNSError
+		return !errors.As(err, &dnsErr) || !dnsErr.IsNotFound, fmt.Errorf("error downloading file: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return resp.StatusCode >= 500, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	f, err := os.OpenFile(binpath, os.O_CREATE|os.O_WRONLY, 0755)
+	f, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
-		return "", fmt.Errorf("error creating file: %v", err)
+		return false, fmt.Errorf("error creating file: %v", err)
 	}
-	defer f.Close()
 
 	var written int64
 	if progressCallback != nil {
@@ -128,18 +387,61 @@ func ExpectMicromamba(binFolder string, progressCallback ProgressCallback) (stri
 		}
 	}
 
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && resp.ContentLength > 0 && written != resp.ContentLength {
+		err = io.ErrUnexpectedEOF
+	}
 	if err != nil {
-		return "", fmt.Errorf("error downloading micromamba: %v", err)
+		os.Remove(destPath)
+		return true, fmt.Errorf("error downloading micromamba: %v", err)
 	}
 
-	// Change file permissions to make it executable (not applicable for Windows)
-	if platform != "win" {
-		if err := os.Chmod(binpath, 0755); err != nil {
-			return "", fmt.Errorf("error setting file permissions: %v", err)
-		}
+	return false, nil
+}
+
+// fetchSHA256 downloads a checksum file and returns the hex digest it contains.
+// The file may be in "sha256sum" format ("<digest>  <filename>").
+func fetchSHA256(url string) (string, error) {
+	resp, err := getMicromambaHTTPClient().Get(url)
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
 
-	return binpath, nil
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file")
+	}
+	digest := strings.ToLower(fields[0])
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid checksum: %q", fields[0])
+	}
+	return digest, nil
+}
+
+// fileSHA256 returns the hex-encoded SHA256 digest of a file.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 // MicromambaInstallPackage installs a conda package using micromamba.
@@ -151,18 +453,139 @@ func ExpectMicromamba(binFolder string, progressCallback ProgressCallback) (stri
 // The installation is performed with --no-rc to avoid configuration conflicts
 // and uses the environment's prefix directly.
 func (env *PythonEnvironment) MicromambaInstallPackage(packageToInstall string, channel string) error {
-	var installCmd *exec.Cmd
+	return env.MicromambaInstallPackages([]string{packageToInstall}, channel, nil)
+}
+
+// MicromambaInstallPackages installs several conda packages in a single
+// micromamba transaction, so the solver runs once and picks versions that
+// work together, and the environment's prefix is locked only once. If
+// progressCallback is set, download progress is reported in bytes.
+func (env *PythonEnvironment) MicromambaInstallPackages(packages []string, channel string, progressCallback ProgressCallback) error {
+	return env.micromambaInstall(packages, channel, getMicromambaOptions(), progressCallback)
+}
+
+// RemoveOptions configures MicromambaRemovePackagesWithOptions.
+type RemoveOptions struct {
+	// Strict makes removing a package that isn't installed an error. By
+	// default such packages are skipped.
+	Strict bool
+}
+
+// MicromambaRemovePackages removes conda packages from a micromamba
+// environment in a single micromamba call. Packages may be given by name or
+// with a version spec ("numpy=1.26"); those that aren't installed are
+// skipped. Like "conda remove", micromamba also removes installed packages
+// that depend on the removed ones.
+func (env *PythonEnvironment) MicromambaRemovePackages(packages []string, progressCallback ProgressCallback) error {
+	return env.MicromambaRemovePackagesWithOptions(packages, RemoveOptions{}, progressCallback)
+}
+
+// MicromambaRemovePackagesWithOptions is like MicromambaRemovePackages but
+// uses the given options. With opts.Strict, nothing is removed if any of the
+// packages isn't installed.
+func (env *PythonEnvironment) MicromambaRemovePackagesWithOptions(packages []string, opts RemoveOptions, progressCallback ProgressCallback) error {
+	if len(packages) == 0 {
+		return nil
+	}
+	if env.MicromambaPath == "" {
+		return fmt.Errorf("cannot remove conda packages %v: environment is not managed by micromamba", packages)
+	}
+	defer env.lock()()
+
+	installed, err := env.listCondaPackages()
+	if err != nil {
+		return err
+	}
+	installedNames := make(map[string]bool, len(installed))
+	for _, pkg := range installed {
+		installedNames[canonicalPackageName(pkg.Name)] = true
+	}
+	var toRemove, missing []string
+	for _, pkg := range packages {
+		if installedNames[canonicalPackageName(condaSpecName(pkg))] {
+			toRemove = append(toRemove, pkg)
+		} else {
+			missing = append(missing, pkg)
+		}
+	}
+	if opts.Strict && len(missing) > 0 {
+		return fmt.Errorf("cannot remove conda packages %v: not installed", missing)
+	}
+	if len(toRemove) == 0 {
+		return nil
+	}
+
+	if progressCallback != nil {
+		progressCallback(fmt.Sprintf("Removing %d conda packages...", len(toRemove)), 0, 100)
+	}
+
+	args := append([]string{"remove", "--no-rc", "-n", env.EnvironmentName, "-y"}, toRemove...)
+	removeCmd := exec.Command(env.MicromambaPath, append(args, getMicromambaOptions().args()...)...)
+	removeCmd.Env = append(os.Environ(), "MAMBA_ROOT_PREFIX="+env.RootDir)
+	defer env.invalidatePackageCache()
+
+	// Show micromamba's output as usual, keeping the end of stderr for the error
+	stderr := &tailBuffer{max: StderrTailSize}
+	removeCmd.Stdout = os.Stdout
+	removeCmd.Stderr = io.MultiWriter(os.Stderr, stderr)
+	if err := removeCmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("error removing packages: %v - %s", err, msg)
+		}
+		return fmt.Errorf("error removing packages: %v", err)
+	}
+
+	if progressCallback != nil {
+		progressCallback(fmt.Sprintf("Removed %d conda packages", len(toRemove)), 100, 100)
+	}
+	return nil
+}
+
+// condaSpecName returns the package name of a conda match spec such as
+// "numpy", "numpy=1.26" or "scipy>=1.11".
+func condaSpecName(spec string) string {
+	spec = strings.TrimSpace(spec)
+	if i := strings.IndexAny(spec, "=<>!~ ["); i >= 0 {
+		spec = spec[:i]
+	}
+	// a channel prefix, as in "conda-forge::numpy"
+	if i := strings.LastIndex(spec, "::"); i >= 0 {
+		spec = spec[i+2:]
+	}
+	return spec
+}
+
+// micromambaInstall runs "micromamba install" for packages with the given
+// options. If progressCallback is set, download progress is reported in bytes.
+func (env *PythonEnvironment) micromambaInstall(packages []string, channel string, mambaOpts MicromambaOptions, progressCallback ProgressCallback) error {
+	if len(packages) == 0 {
+		return nil
+	}
+	network := getNetworkConfig()
+	extraArgs := append(network.micromambaArgs(), mambaOpts.args()...)
+
+	/*
+		../../bin/micromamba install --no-rc -c conda-forge -y --prefix /Users/richardinsley/Projects/comfycli/jumpboot/tests/mlx/micromamba/envs/myenv3.10 mlx
+	*/
+	args := []string{"install", "--no-rc"}
 	if channel != "" {
-		/*
-			../../bin/micromamba install --no-rc -c conda-forge -y --prefix /Users/richardinsley/Projects/comfycli/jumpboot/tests/mlx/micromamba/envs/myenv3.10 mlx
-		*/
-		installCmd = exec.Command(env.MicromambaPath, "install", "--no-rc", "-c", channel, "--prefix", env.EnvPath, "-y", packageToInstall)
-	} else {
-		installCmd = exec.Command(env.MicromambaPath, "install", "--no-rc", "--prefix", env.EnvPath, "-y", packageToInstall)
+		args = append(args, "-c", channel)
 	}
+	args = append(args, "--prefix", env.EnvPath, "-y")
+	args = append(args, packages...)
+	installCmd := exec.Command(env.MicromambaPath, append(args, extraArgs...)...)
+	installCmd.Env = mambaOpts.env(network.micromambaEnv())
+	defer env.lock()()
+	defer env.invalidatePackageCache()
 
 	installCmd.Stdout = os.Stdout
 	installCmd.Stderr = os.Stderr
+	if progressCallback != nil {
+		// Keep showing micromamba's output while parsing it for download sizes
+		progress := &lineWriter{onLine: (&micromambaProgress{callback: progressCallback}).line}
+		defer progress.Flush()
+		installCmd.Stdout = io.MultiWriter(os.Stdout, progress)
+	}
 	if err := installCmd.Run(); err != nil {
 		return fmt.Errorf("error installing package: %v", err)
 	}
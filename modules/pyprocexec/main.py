@@ -1,7 +1,39 @@
 import jumpboot
+import json
 import sys
+import traceback
 from io import StringIO
 
+class TeeWriter:
+    """Writes to a stream's own buffer and to a buffer shared with the other stream"""
+    def __init__(self, own, combined):
+        self.own = own
+        self.combined = combined
+
+    def write(self, s):
+        self.own.write(s)
+        self.combined.write(s)
+        return len(s)
+
+    def flush(self):
+        pass
+
+def exception_info(e):
+    """Describe an exception the way the Go PythonException expects"""
+    info = {
+        "exception": type(e).__name__,
+        "message": str(e),
+        "traceback": "".join(traceback.format_exception(type(e), e, e.__traceback__)),
+    }
+    if e.__cause__ is not None:
+        info["cause"] = exception_info(e.__cause__)
+    try:
+        json.dumps(e.args)
+        info["args"] = list(e.args)
+    except (TypeError, ValueError):
+        pass
+    return info
+
 def main():
     print("Waiting for message...")
     json_queue = jumpboot.JSONQueue(jumpboot.Pipe_in, jumpboot.Pipe_out)
@@ -13,21 +45,43 @@ def main():
                 break
             elif message['type'] == "exec":
                 # capture the output of the python exec() function
+                stdout = StringIO()
+                stderr = StringIO()
+                combined = StringIO()
                 try:
                     old_stdout = sys.stdout
-                    sys.stdout = StringIO()
-                    exec(message['code'])
-                    output = sys.stdout.getvalue()
+                    old_stderr = sys.stderr
+                    old_argv = sys.argv
+                    sys.stdout = TeeWriter(stdout, combined)
+                    sys.stderr = TeeWriter(stderr, combined)
+                    if message.get('argv') is not None:
+                        sys.argv = message['argv']
+                    if message.get('filename'):
+                        # run a script file as __main__ in its own namespace
+                        filename = message['filename']
+                        script_globals = {"__name__": "__main__", "__file__": filename, "__builtins__": __builtins__}
+                        exec(compile(message['code'], filename, "exec"), script_globals)
+                    else:
+                        exec(message['code'])
                     # create the json response
-                    response = {"type": "output", "output": output}
-                    json_queue.put(response)
+                    response = {"type": "output", "output": combined.getvalue()}
+                except SystemExit as e:
+                    # sys.exit() and argparse end the code, not the process
+                    if e.code is None or e.code == 0:
+                        response = {"type": "output", "output": combined.getvalue()}
+                    else:
+                        response = {"type": "error", "output": "exited with status %s" % e.code, "exception": exception_info(e)}
                 except Exception as e:
                     output = str(e)
                     # create the json response
-                    response = {"type": "error", "output": output}
-                    json_queue.put(response)
+                    response = {"type": "error", "output": output, "exception": exception_info(e)}
                 finally:
                     sys.stdout = old_stdout
+                    sys.stderr = old_stderr
+                    sys.argv = old_argv
+                response["stdout"] = stdout.getvalue()
+                response["stderr"] = stderr.getvalue()
+                json_queue.put(response)
         except EOFError:
             break
         except Exception as e:
@@ -37,4 +91,3 @@ def main():
 
 if __name__ == "__main__":
     main()
-
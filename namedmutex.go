@@ -0,0 +1,191 @@
+package jumpboot
+
+import "fmt"
+
+// NamedMutex is a cross-process mutex: a named semaphore with a count of 1.
+// Python opens the same mutex with jumpboot.NamedMutex.
+//
+// A process that exits while holding the mutex leaves it locked until the
+// mutex is created again.
+type NamedMutex struct {
+	sem Semaphore
+}
+
+// CreateNamedMutex creates an unlocked named mutex. Like CreateSharedMemory,
+// it replaces an existing one of the same name, so only one process should
+// create it and the others open it.
+func CreateNamedMutex(name string) (*NamedMutex, error) {
+	RemoveSemaphore(name)
+	sem, err := CreateSemaphore(name, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &NamedMutex{sem: sem}, nil
+}
+
+// OpenNamedMutex opens a named mutex created by another process.
+func OpenNamedMutex(name string) (*NamedMutex, error) {
+	sem, err := OpenSemaphore(name)
+	if err != nil {
+		return nil, err
+	}
+	return &NamedMutex{sem: sem}, nil
+}
+
+// Lock blocks until the mutex is acquired.
+func (m *NamedMutex) Lock() error {
+	return m.sem.Acquire()
+}
+
+// TryLock acquires the mutex if it is free and reports whether it did.
+func (m *NamedMutex) TryLock() (bool, error) {
+	return m.sem.TryAcquire()
+}
+
+// Unlock releases the mutex. Unlocking a mutex that is not locked lets two
+// holders in at once, as with any semaphore.
+func (m *NamedMutex) Unlock() error {
+	return m.sem.Release()
+}
+
+// Close releases this process's handle to the mutex.
+func (m *NamedMutex) Close() error {
+	return m.sem.Close()
+}
+
+// NamedRWMutex is a cross-process reader/writer lock: any number of readers
+// or a single writer. It is built from two named semaphores and a reader count
+// in shared memory, all derived from one name:
+//
+//	<name>_turnstile  semaphore (1): taken by a writer for the whole write and
+//	                  by each reader while it enters
+//	<name>_room       semaphore (1): held by the writer, or by the readers as
+//	                  a group while the reader count is above zero
+//	<name>_readers    8 bytes of shared memory: the reader count
+//
+// The lock prefers writers: once a writer is waiting, readers that arrive after
+// it wait until it has finished, so a steady stream of readers can't starve a
+// writer. Among several waiting writers and readers, the order is the order in
+// which the operating system wakes semaphore waiters.
+//
+// Readers change the count with atomic adds, so every participant must update
+// it atomically (see SharedMemory.AtomicAddUint64). Pure Python has no atomic
+// add on shared memory, so Python code should use a NamedMutex instead.
+type NamedRWMutex struct {
+	turnstile Semaphore
+	room      Semaphore
+	readers   *SharedMemory
+}
+
+// CreateNamedRWMutex creates an unlocked reader/writer lock, replacing an
+// existing one of the same name. The creator's Close removes the reader count,
+// so it should outlive the other processes.
+func CreateNamedRWMutex(name string) (*NamedRWMutex, error) {
+	RemoveSemaphore(name + "_turnstile")
+	RemoveSemaphore(name + "_room")
+	turnstile, err := CreateSemaphore(name+"_turnstile", 1)
+	if err != nil {
+		return nil, err
+	}
+	room, err := CreateSemaphore(name+"_room", 1)
+	if err != nil {
+		turnstile.Close()
+		return nil, err
+	}
+	readers, err := CreateSharedMemory(name+"_readers", 8)
+	if err != nil {
+		turnstile.Close()
+		room.Close()
+		return nil, fmt.Errorf("error creating reader count: %v", err)
+	}
+	if err := readers.AtomicStoreUint64(0, 0); err != nil {
+		turnstile.Close()
+		room.Close()
+		readers.Close()
+		return nil, err
+	}
+	return &NamedRWMutex{turnstile: turnstile, room: room, readers: readers}, nil
+}
+
+// OpenNamedRWMutex opens a reader/writer lock created by another process.
+func OpenNamedRWMutex(name string) (*NamedRWMutex, error) {
+	turnstile, err := OpenSemaphore(name + "_turnstile")
+	if err != nil {
+		return nil, err
+	}
+	room, err := OpenSemaphore(name + "_room")
+	if err != nil {
+		turnstile.Close()
+		return nil, err
+	}
+	readers, err := OpenSharedMemory(name+"_readers", 8)
+	if err != nil {
+		turnstile.Close()
+		room.Close()
+		return nil, fmt.Errorf("error opening reader count: %v", err)
+	}
+	return &NamedRWMutex{turnstile: turnstile, room: room, readers: readers}, nil
+}
+
+// RLock blocks until the lock is held for reading.
+func (rw *NamedRWMutex) RLock() error {
+	if err := rw.turnstile.Acquire(); err != nil {
+		return err
+	}
+	n, err := rw.readers.AtomicAddUint64(0, 1)
+	if err == nil && n == 1 {
+		// The first reader in waits for the writer to leave
+		if err = rw.room.Acquire(); err != nil {
+			rw.readers.AtomicAddUint64(0, ^uint64(0))
+		}
+	}
+	if releaseErr := rw.turnstile.Release(); err == nil {
+		err = releaseErr
+	}
+	return err
+}
+
+// RUnlock releases a read lock.
+func (rw *NamedRWMutex) RUnlock() error {
+	n, err := rw.readers.AtomicAddUint64(0, ^uint64(0))
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// The last reader out lets a writer in
+		return rw.room.Release()
+	}
+	return nil
+}
+
+// Lock blocks until the lock is held for writing.
+func (rw *NamedRWMutex) Lock() error {
+	if err := rw.turnstile.Acquire(); err != nil {
+		return err
+	}
+	if err := rw.room.Acquire(); err != nil {
+		rw.turnstile.Release()
+		return err
+	}
+	return nil
+}
+
+// Unlock releases a write lock.
+func (rw *NamedRWMutex) Unlock() error {
+	if err := rw.room.Release(); err != nil {
+		return err
+	}
+	return rw.turnstile.Release()
+}
+
+// Close releases this process's handles to the lock.
+func (rw *NamedRWMutex) Close() error {
+	err := rw.turnstile.Close()
+	if roomErr := rw.room.Close(); err == nil {
+		err = roomErr
+	}
+	if shmErr := rw.readers.Close(); err == nil {
+		err = shmErr
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package jumpboot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestNamedMutex tests that a mutex held through one handle excludes another
+// handle and a Python process.
+func TestNamedMutex(t *testing.T) {
+	name := fmt.Sprintf("/jumpboot_mutex_%d", os.Getpid())
+	m, err := CreateNamedMutex(name)
+	if errors.Is(err, ErrSemaphoreNotAvailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("CreateNamedMutex failed: %v", err)
+	}
+	defer RemoveSemaphore(name)
+	defer m.Close()
+	other, err := OpenNamedMutex(name)
+	if err != nil {
+		t.Fatalf("OpenNamedMutex failed: %v", err)
+	}
+	defer other.Close()
+
+	if err := m.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := other.TryLock(); ok || err != nil {
+		t.Fatalf("TryLock while locked = %v, %v", ok, err)
+	}
+	m.Unlock()
+	if ok, err := other.TryLock(); !ok || err != nil {
+		t.Fatalf("TryLock after Unlock = %v, %v", ok, err)
+	}
+
+	// Python waits for the lock and releases it when done
+	p := newTestExecProcess(t)
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.ExecWithTimeout(fmt.Sprintf("import jumpboot\nwith jumpboot.NamedMutex(%q):\n    pass", name), 10*time.Second)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("Python took a held mutex: %v", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+	other.Unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("Python lock failed: %v", err)
+	}
+	if ok, _ := m.TryLock(); !ok {
+		t.Error("Python did not unlock the mutex")
+	}
+}
+
+// TestNamedRWMutex tests that readers share the lock, that a writer excludes
+// them, and that readers arriving while a writer waits queue behind it.
+func TestNamedRWMutex(t *testing.T) {
+	name := fmt.Sprintf("/jumpboot_rw_%d", os.Getpid())
+	rw, err := CreateNamedRWMutex(name)
+	if errors.Is(err, ErrSemaphoreNotAvailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("CreateNamedRWMutex failed: %v", err)
+	}
+	defer RemoveSemaphore(name + "_turnstile")
+	defer RemoveSemaphore(name + "_room")
+	defer rw.Close()
+	other, err := OpenNamedRWMutex(name)
+	if err != nil {
+		t.Fatalf("OpenNamedRWMutex failed: %v", err)
+	}
+	defer other.Close()
+
+	// waitFor reports whether ch is closed within d
+	waitFor := func(ch <-chan struct{}, d time.Duration) bool {
+		select {
+		case <-ch:
+			return true
+		case <-time.After(d):
+			return false
+		}
+	}
+	run := func(f func() error) <-chan struct{} {
+		ch := make(chan struct{})
+		go func() {
+			if err := f(); err != nil {
+				t.Error(err)
+			}
+			close(ch)
+		}()
+		return ch
+	}
+
+	// Two readers hold the lock at once
+	if err := rw.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	if !waitFor(run(other.RLock), 2*time.Second) {
+		t.Fatal("a second reader was blocked")
+	}
+
+	// A writer waits for both readers
+	writer := run(other.Lock)
+	if waitFor(writer, 200*time.Millisecond) {
+		t.Fatal("the writer got in while readers held the lock")
+	}
+
+	// A reader arriving now queues behind the waiting writer
+	lateReader := run(rw.RLock)
+	if waitFor(lateReader, 200*time.Millisecond) {
+		t.Fatal("a new reader got in ahead of a waiting writer")
+	}
+
+	rw.RUnlock()
+	if waitFor(writer, 200*time.Millisecond) {
+		t.Fatal("the writer got in while a reader held the lock")
+	}
+	other.RUnlock()
+	if !waitFor(writer, 2*time.Second) {
+		t.Fatal("the writer was not let in after the readers left")
+	}
+	if waitFor(lateReader, 200*time.Millisecond) {
+		t.Fatal("a reader got in while the writer held the lock")
+	}
+	other.Unlock()
+	if !waitFor(lateReader, 2*time.Second) {
+		t.Fatal("the queued reader was not let in after the writer")
+	}
+	rw.RUnlock()
+
+	if n, _ := rw.readers.AtomicLoadUint64(0); n != 0 {
+		t.Errorf("reader count = %d after everyone left", n)
+	}
+}
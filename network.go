@@ -0,0 +1,110 @@
+package jumpboot
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// NetworkConfig configures the network access used when creating environments
+// and installing packages. It applies to the micromamba download as well as
+// the pip and micromamba subprocesses.
+//
+// Use SetNetworkConfig to apply a configuration. The zero value uses the
+// default behavior (proxy and certificates from the environment).
+type NetworkConfig struct {
+	// Proxy is the HTTP(S) proxy for all requests. Passed to pip as --proxy and
+	// to micromamba through the HTTP_PROXY/HTTPS_PROXY environment variables.
+	Proxy *url.URL
+
+	// Timeout limits each HTTP request made by the micromamba downloader and is
+	// passed to pip as --timeout. Zero means no timeout.
+	Timeout time.Duration
+
+	// CACertPool is the set of root CAs trusted by the micromamba downloader,
+	// for example to trust a TLS-inspecting proxy. Nil uses the system roots.
+	CACertPool *x509.CertPool
+
+	// CACertFile is a PEM bundle of trusted root CAs for the pip and micromamba
+	// subprocesses, which cannot use CACertPool directly. Passed to pip as --cert
+	// and to micromamba as --cacert-path.
+	CACertFile string
+}
+
+var (
+	networkConfig      NetworkConfig
+	networkConfigMutex sync.Mutex
+)
+
+// SetNetworkConfig sets the network configuration used for all subsequent
+// downloads and package installs. An HTTP client set with SetMicromambaHTTPClient
+// takes precedence over this configuration for the micromamba download.
+func SetNetworkConfig(config NetworkConfig) {
+	networkConfigMutex.Lock()
+	defer networkConfigMutex.Unlock()
+	networkConfig = config
+}
+
+// getNetworkConfig returns the current network configuration.
+func getNetworkConfig() NetworkConfig {
+	networkConfigMutex.Lock()
+	defer networkConfigMutex.Unlock()
+	return networkConfig
+}
+
+// httpClient returns an HTTP client that honors the configuration.
+// The zero configuration returns http.DefaultClient.
+func (nc NetworkConfig) httpClient() *http.Client {
+	if nc.Proxy == nil && nc.Timeout == 0 && nc.CACertPool == nil {
+		return http.DefaultClient
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if nc.Proxy != nil {
+		transport.Proxy = http.ProxyURL(nc.Proxy)
+	}
+	if nc.CACertPool != nil {
+		transport.TLSClientConfig = &tls.Config{RootCAs: nc.CACertPool}
+	}
+	return &http.Client{Transport: transport, Timeout: nc.Timeout}
+}
+
+// pipArgs returns the pip command-line flags for the configuration.
+func (nc NetworkConfig) pipArgs() []string {
+	var args []string
+	if nc.Proxy != nil {
+		args = append(args, "--proxy", nc.Proxy.String())
+	}
+	if nc.CACertFile != "" {
+		args = append(args, "--cert", nc.CACertFile)
+	}
+	if nc.Timeout > 0 {
+		args = append(args, "--timeout", fmt.Sprintf("%g", nc.Timeout.Seconds()))
+	}
+	return args
+}
+
+// micromambaArgs returns the micromamba command-line flags for the configuration.
+func (nc NetworkConfig) micromambaArgs() []string {
+	var args []string
+	if nc.CACertFile != "" {
+		args = append(args, "--cacert-path", nc.CACertFile)
+	}
+	return args
+}
+
+// micromambaEnv returns os.Environ() extended with the configured proxy, since
+// micromamba has no proxy flag.
+func (nc NetworkConfig) micromambaEnv() []string {
+	env := os.Environ()
+	if nc.Proxy != nil {
+		proxy := nc.Proxy.String()
+		env = append(env, "HTTP_PROXY="+proxy, "HTTPS_PROXY="+proxy, "http_proxy="+proxy, "https_proxy="+proxy)
+	}
+	return env
+}
@@ -0,0 +1,328 @@
+package jumpboot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// installedPackagesCache caches ListPackages results, keyed by Python executable
+// path. Entries are invalidated whenever packages are installed through jumpboot.
+var (
+	installedPackagesMutex sync.Mutex
+	installedPackagesCache = make(map[string][]PackageSpec)
+)
+
+// invalidatePackageCache discards the cached package list for the environment.
+func (env *PythonEnvironment) invalidatePackageCache() {
+	installedPackagesMutex.Lock()
+	defer installedPackagesMutex.Unlock()
+	delete(installedPackagesCache, env.PythonPath)
+}
+
+// environmentLocks serializes the operations that change an environment, such
+// as installing or removing packages, keyed by environment path. pip and
+// micromamba corrupt each other's metadata when run on the same environment
+// at the same time.
+var (
+	environmentLocksMutex sync.Mutex
+	environmentLocks      = make(map[string]*sync.Mutex)
+)
+
+// lockEnvironmentPath locks the environment at path against other changes
+// made through jumpboot and returns the function that unlocks it.
+func lockEnvironmentPath(path string) func() {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	environmentLocksMutex.Lock()
+	lock, ok := environmentLocks[path]
+	if !ok {
+		lock = &sync.Mutex{}
+		environmentLocks[path] = lock
+	}
+	environmentLocksMutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// lock locks the environment against other changes. A system Python, which
+// has no EnvPath, is locked by its interpreter path.
+func (env *PythonEnvironment) lock() func() {
+	if env.EnvPath == "" {
+		return lockEnvironmentPath(env.PythonPath)
+	}
+	return lockEnvironmentPath(env.EnvPath)
+}
+
+// ListPackages returns the packages installed in the environment.
+//
+// Pip packages are listed with "pip list". For micromamba environments, conda
+// packages that pip does not report (such as native libraries) are included
+// with Source "conda" and the Channel they were installed from. The result is cached until a package is installed
+// through this environment; call RefreshPackages to pick up changes made
+// outside jumpboot.
+//
+// Listing doesn't change the environment, so it may run at the same time as
+// other calls. Installs, removals and repairs of the same environment wait
+// for each other instead.
+func (env *PythonEnvironment) ListPackages() ([]PackageSpec, error) {
+	installedPackagesMutex.Lock()
+	cached, ok := installedPackagesCache[env.PythonPath]
+	installedPackagesMutex.Unlock()
+	if ok {
+		return append([]PackageSpec(nil), cached...), nil
+	}
+
+	var packages []PackageSpec
+	seen := make(map[string]bool)
+
+	// 1. Get pip packages.
+	if env.PipPath != "" {
+		output, err := exec.Command(env.PipPath, "list", "--format=json", "--disable-pip-version-check").Output()
+		if err != nil {
+			return nil, fmt.Errorf("error running pip list: %v", err)
+		}
+
+		var pipPackages []struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if err := json.Unmarshal(output, &pipPackages); err != nil {
+			return nil, fmt.Errorf("error parsing pip list JSON output: %v", err)
+		}
+		for _, pkg := range pipPackages {
+			packages = append(packages, PackageSpec{Name: pkg.Name, Version: pkg.Version, Source: "pip"})
+			seen[canonicalPackageName(pkg.Name)] = true
+		}
+	}
+
+	// 2. Get conda packages not already reported by pip.
+	if env.MicromambaPath != "" {
+		condaPackages, err := env.listCondaPackages()
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range condaPackages {
+			if seen[canonicalPackageName(pkg.Name)] {
+				continue
+			}
+			packages = append(packages, PackageSpec{Name: pkg.Name, Version: pkg.Version, Build: pkg.BuildString, Source: "conda", Channel: pkg.Channel})
+		}
+	}
+
+	installedPackagesMutex.Lock()
+	installedPackagesCache[env.PythonPath] = packages
+	installedPackagesMutex.Unlock()
+
+	return append([]PackageSpec(nil), packages...), nil
+}
+
+// condaPackage is a package as reported by "micromamba list --json".
+type condaPackage struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	BuildString string `json:"build_string"`
+	Channel     string `json:"channel"`
+}
+
+// listCondaPackages returns the conda packages installed in a micromamba
+// environment, bypassing the ListPackages cache.
+func (env *PythonEnvironment) listCondaPackages() ([]condaPackage, error) {
+	cmd := exec.Command(env.MicromambaPath, "list", "-n", env.EnvironmentName, "--json")
+	cmd.Env = append(os.Environ(), "MAMBA_ROOT_PREFIX="+env.RootDir)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running micromamba list: %v", err)
+	}
+
+	var condaPackages []condaPackage
+	if err := json.Unmarshal(output, &condaPackages); err != nil {
+		return nil, fmt.Errorf("error parsing micromamba list JSON output: %v", err)
+	}
+	return condaPackages, nil
+}
+
+// RefreshPackages discards the cached package list and lists the installed
+// packages again.
+func (env *PythonEnvironment) RefreshPackages() ([]PackageSpec, error) {
+	env.invalidatePackageCache()
+	return env.ListPackages()
+}
+
+// EnsurePackages installs only the packages in specs that are missing or
+// installed at a different version, so provisioning code can be rerun cheaply.
+//
+// A spec with an empty Version is satisfied by any installed version; a conda
+// spec with a Build is only satisfied by that build. Specs with Source "conda"
+// are installed with micromamba from conda-forge; all others are installed
+// with pip in a single batch.
+func (env *PythonEnvironment) EnsurePackages(specs []PackageSpec) error {
+	installed, err := env.ListPackages()
+	if err != nil {
+		return err
+	}
+
+	installedByName := make(map[string]PackageSpec, len(installed))
+	for _, pkg := range installed {
+		installedByName[canonicalPackageName(pkg.Name)] = pkg
+	}
+
+	var pipSpecs []string
+	var condaSpecs []string
+	for _, spec := range specs {
+		if current, ok := installedByName[canonicalPackageName(spec.Name)]; ok {
+			if (spec.Version == "" || spec.Version == current.Version) && (spec.Build == "" || spec.Build == current.Build) {
+				continue
+			}
+		}
+
+		if spec.Source == "conda" {
+			pkgSpec := spec.Name
+			if spec.Version != "" {
+				pkgSpec += "=" + spec.Version
+				if spec.Build != "" {
+					pkgSpec += "=" + spec.Build
+				}
+			}
+			condaSpecs = append(condaSpecs, pkgSpec)
+		} else {
+			pkgSpec := spec.Name
+			if spec.Version != "" {
+				pkgSpec += "==" + spec.Version
+			}
+			pipSpecs = append(pipSpecs, pkgSpec)
+		}
+	}
+
+	if len(condaSpecs) > 0 {
+		if env.MicromambaPath == "" {
+			return fmt.Errorf("cannot install conda packages %v: environment is not managed by micromamba", condaSpecs)
+		}
+		if err := env.MicromambaInstallPackages(condaSpecs, "conda-forge", nil); err != nil {
+			return fmt.Errorf("error installing conda packages %v: %v", condaSpecs, err)
+		}
+	}
+
+	if len(pipSpecs) > 0 {
+		if err := env.PipInstallPackages(pipSpecs, "", "", false, nil); err != nil {
+			return fmt.Errorf("error installing pip packages: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// canonicalPackageName normalizes a package name as described in PEP 503, so
+// that "Typing_Extensions" and "typing-extensions" compare equal.
+func canonicalPackageName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer("_", "-", ".", "-").Replace(name)
+}
+
+// CondaPackageRecord is the metadata conda and micromamba record for an
+// installed package in the environment's conda-meta directory. It identifies
+// exactly which artifact was installed and where it came from.
+type CondaPackageRecord struct {
+	// Name is the package name.
+	Name string `json:"name"`
+
+	// Version is the package version.
+	Version string `json:"version"`
+
+	// Build is the build string.
+	Build string `json:"build"`
+
+	// BuildNumber is the build number.
+	BuildNumber int `json:"build_number"`
+
+	// Channel is the channel the package was installed from.
+	Channel string `json:"channel"`
+
+	// Subdir is the platform subdirectory of the channel (e.g. "linux-64" or "noarch").
+	Subdir string `json:"subdir"`
+
+	// Filename is the name of the downloaded package archive.
+	Filename string `json:"fn"`
+
+	// URL is the download URL of the package archive.
+	URL string `json:"url"`
+
+	// MD5 is the MD5 checksum of the package archive.
+	MD5 string `json:"md5"`
+
+	// SHA256 is the SHA256 checksum of the package archive.
+	SHA256 string `json:"sha256"`
+
+	// Size is the size of the package archive in bytes.
+	Size int64 `json:"size"`
+
+	// License is the package license.
+	License string `json:"license"`
+
+	// Timestamp is the build time in milliseconds since the Unix epoch.
+	Timestamp int64 `json:"timestamp"`
+
+	// Depends lists the package's run dependencies as match specs.
+	Depends []string `json:"depends"`
+
+	// Constrains lists the optional constraints on other packages.
+	Constrains []string `json:"constrains"`
+
+	// RequestedSpec is the spec the user asked for, if the package was requested
+	// explicitly rather than pulled in as a dependency.
+	RequestedSpec string `json:"requested_spec"`
+
+	// Files lists the files the package installed, relative to the environment.
+	Files []string `json:"files"`
+}
+
+// CondaMeta returns the records in the environment's conda-meta directory,
+// sorted by package name. Unlike ListPackages, the records include the
+// download URL, checksums and dependencies of each installed package, which
+// makes them suitable for building a complete bill of materials.
+//
+// Environments without a conda-meta directory (such as virtual environments)
+// return an error.
+func (env *PythonEnvironment) CondaMeta() ([]CondaPackageRecord, error) {
+	metaDir := filepath.Join(env.EnvPath, "conda-meta")
+	entries, err := os.ReadDir(metaDir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading conda-meta directory: %v", err)
+	}
+
+	var records []CondaPackageRecord
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(metaDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", entry.Name(), err)
+		}
+
+		var record CondaPackageRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", entry.Name(), err)
+		}
+
+		// The history file and other non-package JSON files have no name
+		if record.Name == "" {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Name < records[j].Name
+	})
+
+	return records, nil
+}
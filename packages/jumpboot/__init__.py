@@ -58,4 +58,61 @@ except ImportError:
 from .bufferpool import BufferPool
 from .jsonqueue import JSONQueue, JSONQueueServer, exposed
 from .msgpackqueue import MessagePackTransport, MessagePackQueueServer
-from .namedsemaphore import NamedSemaphore
\ No newline at end of file
+from .namedsemaphore import NamedSemaphore
+from .ringbuffer import RingBuffer
+from .sharedmemory import SharedMemory
+from .namedmutex import NamedMutex
+# KVPairs passed from Go, filled in by the bootstrap
+_kvpairs = {}
+
+_MISSING = object()
+
+def get_kv(key, type=None, default=_MISSING):
+    """
+    Return the KVPairs value for `key`.
+
+    If `type` is given the value must be an instance of it, otherwise
+    TypeError is raised. Go encodes whole floats such as 2.0 as 2, so an int
+    is accepted (and converted) when `type` is float; a bool is never
+    accepted as an int or float. If the key is missing, `default` is returned
+    when given and KeyError is raised when not.
+    """
+    if key not in _kvpairs:
+        if default is _MISSING:
+            raise KeyError("jumpboot KVPairs has no key %r" % (key,))
+        return default
+    value = _kvpairs[key]
+    if type is None:
+        return value
+    if type is float and isinstance(value, int) and not isinstance(value, bool):
+        return float(value)
+    if isinstance(value, bool) and type in (int, float):
+        raise TypeError("jumpboot KVPairs[%r] is a bool, not %s" % (key, type.__name__))
+    if not isinstance(value, type):
+        raise TypeError("jumpboot KVPairs[%r] is %s, not %s" % (key, value.__class__.__name__, type.__name__))
+    return value
+
+def open_extra_file(index, mode='r', **kwargs):
+    """
+    Open the file Go passed at position `index` of the extrafiles argument
+    and return it like os.fdopen(fd, mode, **kwargs).
+
+    sys.extra_file_descriptors holds the numbers Go passed: file descriptors
+    on Unix, but Windows handles on Windows, which os.fdopen can't use
+    directly. This converts a handle with msvcrt.open_osfhandle first, so the
+    same code works on both.
+    """
+    import sys
+    fd = sys.extra_file_descriptors[index]
+    if sys.platform.startswith('win'):
+        import msvcrt
+        if '+' in mode:
+            flags = os.O_RDWR
+        elif 'r' in mode:
+            flags = os.O_RDONLY
+        elif 'a' in mode:
+            flags = os.O_WRONLY | os.O_APPEND
+        else:
+            flags = os.O_WRONLY
+        fd = msvcrt.open_osfhandle(fd, flags)
+    return os.fdopen(fd, mode, **kwargs)
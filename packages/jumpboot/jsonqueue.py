@@ -167,13 +167,19 @@ class JSONQueueServer:
             params = sig.parameters
             
             # Extract method arguments from the data
+            args = []
             kwargs = {}
             
             if data is not None:
                 if isinstance(data, dict):
-                    # If data is a dict, use it for keyword arguments
+                    # Positional arguments (methodCall.Args in Go) arrive as
+                    # a list under "__args__" and are passed as *args
+                    args = data.get("__args__") or []
+                    if not isinstance(args, (list, tuple)):
+                        raise TypeError("__args__ must be a list, not %s" % type(args).__name__)
+                    # The other keys of a dict are keyword arguments
                     for param_name in params:
-                        if param_name in data:
+                        if param_name in data and param_name != "__args__":
                             kwargs[param_name] = data[param_name]
                 else:
                     # If data is not a dict, pass it as the first argument
@@ -182,7 +188,7 @@ class JSONQueueServer:
                         kwargs[param_names[0]] = data
             
             # Call the method with the extracted arguments
-            result = method(**kwargs)
+            result = method(*args, **kwargs)
             
             # If the result is a coroutine, await it
             if inspect.iscoroutine(result):
@@ -216,9 +222,12 @@ class JSONQueueServer:
                 if param_name == 'self':
                     continue
                     
+                # *args and **kwargs never need a value
+                variadic = param.kind in (inspect.Parameter.VAR_POSITIONAL, inspect.Parameter.VAR_KEYWORD)
                 param_info = {
                     "name": param_name,
-                    "required": param.default is inspect.Parameter.empty
+                    "required": param.default is inspect.Parameter.empty and not variadic,
+                    "kind": param.kind.name
                 }
                 
                 # Add type information if available
@@ -7,9 +7,11 @@ import threading
 import sys
 import os
 import inspect
-import time
+import json
+import gzip
 import traceback
 import concurrent.futures
+import contextvars
 import select
 from typing import Any, Dict, Callable, Optional, Union, List, Tuple, IO
 
@@ -17,6 +19,45 @@ def debug_out(msg, file=sys.stderr):
     # print(f"DEBUG MessagePackQueue: {msg}", file=file, flush=True)
     pass
 
+# Metadata sent by Go with the command currently being handled
+_call_meta = contextvars.ContextVar('jumpboot_call_meta', default=None)
+
+def current_meta():
+    """
+    Return the metadata sent by Go with the command being handled, or None.
+    """
+    return _call_meta.get()
+
+def time_remaining():
+    """
+    Return the seconds left before Go stops waiting for the command being
+    handled, or None if Go set no timeout. Long-running handlers can check
+    this periodically and abort once it reaches zero.
+    """
+    meta = _call_meta.get()
+    if not meta or meta.get("deadline") is None:
+        return None
+    return max(0.0, meta["deadline"] - time.time())
+
+# Set when Go cancels the command currently being handled
+_call_cancel_event = contextvars.ContextVar('jumpboot_call_cancel_event', default=None)
+
+def cancelled():
+    """
+    Return True if Go has cancelled the command being handled (for example
+    because its context was cancelled). Async handlers are also interrupted
+    at their next await.
+    """
+    event = _call_cancel_event.get()
+    return event is not None and event.is_set()
+
+def deadline_exceeded():
+    """
+    Return True if Go has already given up waiting for the command being handled.
+    """
+    remaining = time_remaining()
+    return remaining is not None and remaining <= 0
+
 class MessagePackTransport:
     def __init__(self, read_pipe, write_pipe, buffer_size=8192, pool_size=10):
         # Make read pipe non-blocking on Windows
@@ -158,13 +199,115 @@ class MessagePackTransport:
         self.read_pipe.close()
         self.write_pipe.close()
 
+class CompressingTransport:
+    """
+    Transport wrapper matching jumpboot.CompressingTransport in Go. Frames of at
+    least min_size bytes are gzip-compressed, and every frame starts with a flag
+    byte (0 for raw, 1 for gzip) inside the length-prefixed payload.
+    """
+
+    FLAG_NONE = 0
+    FLAG_GZIP = 1
+
+    def __init__(self, inner, min_size=1024):
+        self.inner = inner
+        self.min_size = min_size if min_size > 0 else 1024
+
+    def _encode(self, data):
+        data = bytes(data)
+        if len(data) >= self.min_size:
+            compressed = gzip.compress(data)
+            if len(compressed) < len(data):
+                return bytes([self.FLAG_GZIP]) + compressed
+        return bytes([self.FLAG_NONE]) + data
+
+    def _decode(self, frame):
+        if not frame:
+            raise ValueError("Compressed frame is missing its header")
+        flag = frame[0]
+        if flag == self.FLAG_NONE:
+            return frame[1:]
+        if flag == self.FLAG_GZIP:
+            return gzip.decompress(frame[1:])
+        raise ValueError(f"Unknown compression flag {flag}")
+
+    def send(self, data):
+        self.inner.send(self._encode(data))
+
+    def send_with_timeout(self, data, timeout=5.0):
+        return self.inner.send_with_timeout(self._encode(data), timeout)
+
+    def receive(self):
+        return self._decode(self.inner.receive())
+
+    def receive_with_timeout(self, timeout=5.0):
+        return self._decode(self.inner.receive_with_timeout(timeout))
+
+    def close(self):
+        self.inner.close()
+
+class JSONSerializer:
+    """
+    Serializer matching jumpboot.JSONSerializer in Go. Any object with packb and
+    unpackb methods (such as the msgpack module, the default) can be used as a
+    serializer.
+    """
+
+    def packb(self, obj):
+        return json.dumps(obj).encode('utf-8')
+
+    def unpackb(self, data):
+        return json.loads(data)
+
+def default_serializer():
+    """Return the serializer Go selected with QueueOptions.Serializer."""
+    if os.environ.get('JUMPBOOT_QUEUE_SERIALIZER') == 'json':
+        return JSONSerializer()
+    return msgpack
+
+# Raw frames carry binary payloads for QueueProcess.CallRaw without going
+# through the serializer. They start with 0xc1, which msgpack never produces:
+# [0xc1][kind][2-byte id length][id][2-byte name length][name][payload]
+RAW_FRAME_MARKER = 0xc1
+RAW_KIND_REQUEST = 1
+RAW_KIND_RESPONSE = 2
+RAW_KIND_ERROR = 3
+
+def encode_raw_frame(kind, request_id, name, payload):
+    request_id = (request_id or "").encode('utf-8')
+    name = (name or "").encode('utf-8')
+    header = struct.pack(">BBH", RAW_FRAME_MARKER, kind, len(request_id)) + request_id
+    header += struct.pack(">H", len(name)) + name
+    return header + bytes(payload)
+
+def decode_raw_frame(frame):
+    """Return (kind, request_id, name, payload) for a raw frame."""
+    _, kind, id_len = struct.unpack_from(">BBH", frame, 0)
+    offset = 4
+    request_id = bytes(frame[offset:offset + id_len]).decode('utf-8')
+    offset += id_len
+    name_len, = struct.unpack_from(">H", frame, offset)
+    offset += 2
+    name = bytes(frame[offset:offset + name_len]).decode('utf-8')
+    offset += name_len
+    return kind, request_id, name, bytes(frame[offset:])
+
+def default_transport(read_pipe, write_pipe):
+    """Return the transport Go selected with QueueOptions.CompressMinSize."""
+    transport = MessagePackTransport(read_pipe, write_pipe)
+    min_size = os.environ.get('JUMPBOOT_QUEUE_COMPRESSION')
+    if min_size:
+        transport = CompressingTransport(transport, int(min_size))
+    return transport
+
 class MessagePackQueue:
-    def __init__(self, read_pipe, write_pipe):
-        self.transport = MessagePackTransport(read_pipe, write_pipe)
+    def __init__(self, read_pipe, write_pipe, serializer=None):
+        self.transport = default_transport(read_pipe, write_pipe)
+        self.serializer = serializer if serializer is not None else default_serializer()
 
     def put(self, obj, block=True, timeout=0):
         try:
-            serialized = msgpack.packb(obj)
+            serialized = self.serializer.packb(obj)
             if block:
                 self._write_with_timeout(serialized, timeout)
             else:
@@ -184,11 +327,21 @@ class MessagePackQueue:
     def _write_non_blocking(self, data):
         self.transport.send(data)
 
+    def put_raw(self, kind, request_id, name, payload):
+        """Send a raw frame, bypassing the serializer."""
+        self.transport.send(encode_raw_frame(kind, request_id, name, payload))
+
+    def _decode(self, frame):
+        if frame and frame[0] == RAW_FRAME_MARKER:
+            kind, request_id, name, payload = decode_raw_frame(frame)
+            return {"command": name, "data": payload, "request_id": request_id, "raw": kind}
+        return self.serializer.unpackb(frame)
+
     def _read_with_timeout(self, timeout):
-        return msgpack.unpackb(self.transport.receive_with_timeout(timeout))
+        return self._decode(self.transport.receive_with_timeout(timeout))
 
     def _read_non_blocking(self):
-        return msgpack.unpackb(self.transport.receive())
+        return self._decode(self.transport.receive())
 
     def close(self):
         self.transport.close()
@@ -198,7 +351,7 @@ class MessagePackQueueServer:
     A server that handles MessagePack-based communication with a Go process using the existing MessagePackQueue.
     """
     
-    def __init__(self, pipe_in=None, pipe_out=None, auto_start=True, expose_methods=True):
+    def __init__(self, pipe_in=None, pipe_out=None, auto_start=True, expose_methods=True, serializer=None):
         """
         Initialize the server with customizable pipes.
         
@@ -207,6 +360,8 @@ class MessagePackQueueServer:
             pipe_out: Output pipe (defaults to jumpboot.Pipe_out)
             auto_start: Whether to automatically start the server
             expose_methods: Whether to automatically expose public methods
+            serializer: Object with packb/unpackb matching the Go serializer
+                (defaults to msgpack, or JSON when Go uses JSONSerializer)
         """
         import jumpboot
         
@@ -214,7 +369,7 @@ class MessagePackQueueServer:
         self.pipe_out = pipe_out if pipe_out is not None else jumpboot.Pipe_out
         
         # Create a JSONQueue instance for communication
-        self.queue = MessagePackQueue(self.pipe_in, self.pipe_out)
+        self.queue = MessagePackQueue(self.pipe_in, self.pipe_out, serializer)
         
         # Set up asyncio event loop for non-blocking behavior
         self.loop = asyncio.new_event_loop()
@@ -225,6 +380,10 @@ class MessagePackQueueServer:
         self.default_handler = None
         self._response_futures = {}
         self._next_request_id = 0
+
+        # Tasks and cancel flags of commands in progress, keyed by request ID
+        self._active_tasks = {}
+        self._cancel_events = {}
         
         # For thread safety when accessing shared resources
         self._lock = threading.Lock()
@@ -266,13 +425,19 @@ class MessagePackQueueServer:
             params = sig.parameters
             
             # Extract method arguments from the data
+            args = []
             kwargs = {}
             
             if data is not None:
                 if isinstance(data, dict):
-                    # If data is a dict, use it for keyword arguments
+                    # Positional arguments (methodCall.Args in Go) arrive as
+                    # a list under "__args__" and are passed as *args
+                    args = data.get("__args__") or []
+                    if not isinstance(args, (list, tuple)):
+                        raise TypeError("__args__ must be a list, not %s" % type(args).__name__)
+                    # The other keys of a dict are keyword arguments
                     for param_name in params:
-                        if param_name in data:
+                        if param_name in data and param_name != "__args__":
                             kwargs[param_name] = data[param_name]
                 else:
                     # If data is not a dict, pass it as the first argument
@@ -281,7 +446,7 @@ class MessagePackQueueServer:
                         kwargs[param_names[0]] = data
             
             # Call the method with the extracted arguments
-            result = method(**kwargs)
+            result = method(*args, **kwargs)
             
             # If the result is a coroutine, await it
             if inspect.iscoroutine(result):
@@ -298,6 +463,26 @@ class MessagePackQueueServer:
 
         # Add a special handler for method inspection (useful for Go)
         self.register_handler("__get_methods__", self._handle_get_methods)
+        self.register_handler("__cancel__", self._handle_cancel)
+        self.register_handler("__ping__", self._handle_ping)
+
+    def _handle_ping(self, data, request_id):
+        """Answer a liveness probe from Go."""
+        return {"pong": True}
+
+    def _handle_cancel(self, data, request_id):
+        """Cancel a command that Go has stopped waiting for."""
+        target = data.get("request_id") if isinstance(data, dict) else None
+        if target is None:
+            return None
+        event = self._cancel_events.get(target)
+        if event is not None:
+            event.set()
+        task = self._active_tasks.get(target)
+        if task is not None:
+            task.cancel()
+        # No response; Go sends cancellations without waiting
+        return None
     
     async def _handle_get_methods(self, data, request_id):
         """Return information about exposed methods for Go discovery."""
@@ -315,9 +500,12 @@ class MessagePackQueueServer:
                 if param_name == 'self':
                     continue
                     
+                # *args and **kwargs never need a value
+                variadic = param.kind in (inspect.Parameter.VAR_POSITIONAL, inspect.Parameter.VAR_KEYWORD)
                 param_info = {
                     "name": param_name,
-                    "required": param.default is inspect.Parameter.empty
+                    "required": param.default is inspect.Parameter.empty and not variadic,
+                    "kind": param.kind.name
                 }
                 
                 # Add type information if available
@@ -433,6 +621,12 @@ class MessagePackQueueServer:
                             command = message.get("command")
                             data = message.get("data")
                             request_id = message.get("request_id")
+                            meta = message.get("meta")
+                            raw = message.get("raw") == RAW_KIND_REQUEST
+
+                            # Convert the time budget into a local deadline so clock skew doesn't matter
+                            if isinstance(meta, dict) and meta.get("budget_ms") is not None:
+                                meta["deadline"] = time.time() + meta["budget_ms"] / 1000.0
                             
                             # Check if this is a response to a pending request
                             if request_id and request_id.startswith("py-"):
@@ -445,7 +639,12 @@ class MessagePackQueueServer:
                                 continue
                             
                             # Process the command in a separate task
-                            asyncio.create_task(self._process_command(command, data, request_id))
+                            if request_id:
+                                self._cancel_events[request_id] = threading.Event()
+                            task = asyncio.create_task(self._process_command(command, data, request_id, meta, raw))
+                            if request_id:
+                                self._active_tasks[request_id] = task
+                                task.add_done_callback(lambda t, rid=request_id: self._forget_request(rid))
                             
                         except Exception as e:
                             debug_out(f"Error processing future: {e}", file=sys.stderr)
@@ -507,7 +706,7 @@ class MessagePackQueueServer:
     async def _process_message(self, msg, queue):
         """Process a line read from the pipe."""
         try:
-            message = msgpack.unpackb(msg)
+            message = self.queue.serializer.unpackb(msg)
 
             # Put the message into the queue
             await queue.put(message)
@@ -515,10 +714,18 @@ class MessagePackQueueServer:
         except Exception as e:
             debug_out(f"Error processing line: {e}", file=sys.stderr)
 
-    async def _process_command(self, command: str, data: Any, request_id: Optional[str]):
+    def _forget_request(self, request_id):
+        self._active_tasks.pop(request_id, None)
+        self._cancel_events.pop(request_id, None)
+
+    async def _process_command(self, command: str, data: Any, request_id: Optional[str], meta: Optional[Dict] = None, raw: bool = False):
         """
-        Process a command and send a response if needed.
+        Process a command and send a response if needed. Raw commands (from
+        QueueProcess.CallRaw) receive bytes and always get a raw response.
         """
+        # Each task runs in its own context, so this only affects the current command
+        _call_meta.set(meta)
+        _call_cancel_event.set(self._cancel_events.get(request_id))
         debug_out(f"Starting to process command: {command} with request ID: {request_id}", file=sys.stderr)
         response = None
         try:
@@ -535,8 +742,11 @@ class MessagePackQueueServer:
                 debug_out(f"No handler found for command: {command}", file=sys.stderr)
                 response = {"error": f"Unknown command: {command}"}
             
+            # Raw calls always get a response, since Go is waiting for the bytes
+            if raw:
+                self._send_raw_response(response, request_id)
             # Send a response if one was returned and there's a request_id
-            if response is not None and request_id is not None:
+            elif response is not None and request_id is not None:
                 debug_out(f"Sending response for request ID: {request_id}", file=sys.stderr)
                 self.send_response(response, request_id)
                 debug_out(f"Response sent for request ID: {request_id}", file=sys.stderr)
@@ -545,9 +755,27 @@ class MessagePackQueueServer:
             debug_out(f"Error processing command {command}: {e}", file=sys.stderr)
             traceback.print_exc(file=sys.stderr)
             # Send an error response if there's a request_id
-            if request_id is not None:
+            if raw:
+                self._send_raw_response({"error": str(e)}, request_id)
+            elif request_id is not None:
                 error_response = {"error": str(e), "traceback": traceback.format_exc()}
                 self.send_response(error_response, request_id)
+
+    def _send_raw_response(self, response: Any, request_id: str):
+        """Send the result of a raw call, which must be bytes-like or None."""
+        try:
+            if response is None:
+                self.queue.put_raw(RAW_KIND_RESPONSE, request_id, "", b"")
+            elif isinstance(response, (bytes, bytearray, memoryview)):
+                self.queue.put_raw(RAW_KIND_RESPONSE, request_id, "", response)
+            elif isinstance(response, dict) and "error" in response:
+                self.queue.put_raw(RAW_KIND_ERROR, request_id, "", str(response["error"]).encode('utf-8'))
+            else:
+                message = f"raw handler returned {type(response).__name__}, expected bytes"
+                self.queue.put_raw(RAW_KIND_ERROR, request_id, "", message.encode('utf-8'))
+        except Exception as e:
+            debug_out(f"Error sending raw response: {e}", file=sys.stderr)
+            traceback.print_exc(file=sys.stderr)
     
     def send_response(self, response: Any, request_id: Optional[str] = None):
         """
@@ -569,6 +797,17 @@ class MessagePackQueueServer:
             debug_out(f"Error sending response: {e}", file=sys.stderr)
             traceback.print_exc(file=sys.stderr)
     
+    def emit(self, topic: str, data: Any = None):
+        """
+        Send a one-way event to the Go process. Go receives the data on the
+        channels returned by QueueProcess.Subscribe(topic); no response is
+        expected and events with no subscriber are dropped.
+        """
+        try:
+            self.queue.put({"event": topic, "data": data})
+        except Exception as e:
+            debug_out(f"Error sending event {topic}: {e}", file=sys.stderr)
+
     def _handle_exit(self, data, request_id):
         """Handle the built-in 'exit' command - terminate immediately."""
         debug_out("Received exit command, terminating process...", file=sys.stderr)
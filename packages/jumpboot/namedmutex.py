@@ -0,0 +1,32 @@
+from .namedsemaphore import NamedSemaphore
+
+class NamedMutex:
+    def __init__(self, name):
+        """
+        Open a cross-process mutex created in Go with CreateNamedMutex.
+        It is a named semaphore with a count of 1, and can be used as a
+        context manager.
+
+        Args:
+            name: The name used on the Go side, e.g. "/my_lock"
+        """
+        self.name = name
+        self.sem = NamedSemaphore(name)
+
+    def lock(self):
+        """Block until the mutex is acquired"""
+        self.sem.acquire()
+
+    def unlock(self):
+        """Release the mutex"""
+        self.sem.release()
+
+    def close(self):
+        self.sem.close()
+
+    def __enter__(self):
+        self.lock()
+        return self
+
+    def __exit__(self, exc_type, exc_value, tb):
+        self.unlock()
@@ -0,0 +1,128 @@
+import struct
+
+HEADER_SIZE = 64
+MAGIC = b"JBRB"
+VERSION = 1
+
+# Offsets of the 8-byte indices in the header
+_WRITE_SLOT = 2
+_READ_SLOT = 3
+
+class RingBuffer:
+    def __init__(self, buffer, semaphore=None, create=False):
+        """
+        A single-producer, single-consumer queue of byte messages in shared
+        memory, compatible with the Go jumpboot.RingBuffer.
+
+        Args:
+            buffer: A writable buffer over the whole region, such as
+                multiprocessing.shared_memory.SharedMemory(name).buf
+            semaphore: Optional NamedSemaphore released once per pushed message,
+                so pop(block=True) can wait for messages
+            create: Format the region as an empty ring buffer instead of
+                attaching to one the other side created
+        """
+        self.buffer = memoryview(buffer).cast("B")
+        if len(self.buffer) <= HEADER_SIZE + 4:
+            raise ValueError("buffer of %d bytes is too small for a ring buffer" % len(self.buffer))
+        self.capacity = len(self.buffer) - HEADER_SIZE
+        self.data = self.buffer[HEADER_SIZE:]
+        self.semaphore = semaphore
+
+        if create:
+            self.buffer[:HEADER_SIZE] = bytes(HEADER_SIZE)
+            struct.pack_into("<4sIQ", self.buffer, 0, MAGIC, VERSION, self.capacity)
+        else:
+            magic, version, capacity = struct.unpack_from("<4sIQ", self.buffer, 0)
+            if magic != MAGIC:
+                raise ValueError("buffer does not hold a ring buffer")
+            if version != VERSION:
+                raise ValueError("unsupported ring buffer version %d" % version)
+            if capacity != self.capacity:
+                raise ValueError("ring buffer capacity %d does not match the buffer size %d" % (capacity, len(self.buffer)))
+
+        # Each index is updated with a single aligned 8-byte store
+        self._indices = self.buffer[:32].cast("Q")
+
+    def __len__(self):
+        """Number of bytes queued, including the 4-byte length prefixes"""
+        return self._indices[_WRITE_SLOT] - self._indices[_READ_SLOT]
+
+    def push(self, data):
+        """
+        Append a message. Must only be called by the producer.
+
+        Returns:
+            False without changing the buffer if there is not enough free space
+
+        Raises:
+            ValueError: If the message can never fit
+        """
+        need = 4 + len(data)
+        if need > self.capacity:
+            raise ValueError("message of %d bytes is larger than the ring buffer" % len(data))
+
+        write = self._indices[_WRITE_SLOT]
+        if need > self.capacity - (write - self._indices[_READ_SLOT]):
+            return False
+
+        self._copy_in(write, struct.pack("<I", len(data)))
+        self._copy_in(write + 4, data)
+
+        # Publish the message only once it is complete
+        self._indices[_WRITE_SLOT] = write + need
+
+        if self.semaphore is not None:
+            self.semaphore.release()
+        return True
+
+    def pop(self, block=False):
+        """
+        Remove and return the oldest message. Must only be called by the consumer.
+
+        Args:
+            block: Wait on the semaphore until a message arrives
+
+        Returns:
+            The message as bytes, or None if the buffer is empty and block is False
+        """
+        if block and self.semaphore is None:
+            raise ValueError("a blocking pop requires a ring buffer semaphore")
+        while True:
+            read = self._indices[_READ_SLOT]
+            available = self._indices[_WRITE_SLOT] - read
+            if available > 0:
+                break
+            if not block:
+                return None
+            # The semaphore may count messages a non-blocking pop already took;
+            # those wake us early and we wait again
+            self.semaphore.acquire()
+
+        if available < 4 or available > self.capacity:
+            raise ValueError("corrupt ring buffer: %d bytes queued" % available)
+        size = struct.unpack("<I", self._copy_out(read, 4))[0]
+        if 4 + size > available:
+            raise ValueError("corrupt ring buffer: message of %d bytes with %d bytes queued" % (size, available))
+        data = self._copy_out(read + 4, size)
+
+        # Free the space only once the message has been copied out
+        self._indices[_READ_SLOT] = read + 4 + size
+        return data
+
+    def close(self):
+        """Release the views of the buffer so the shared memory can be closed"""
+        self._indices.release()
+        self.data.release()
+        self.buffer.release()
+
+    def _copy_in(self, index, data):
+        start = index % self.capacity
+        first = min(len(data), self.capacity - start)
+        self.data[start:start + first] = data[:first]
+        self.data[:len(data) - first] = data[first:]
+
+    def _copy_out(self, index, size):
+        start = index % self.capacity
+        first = min(size, self.capacity - start)
+        return bytes(self.data[start:start + first]) + bytes(self.data[:size - first])
@@ -0,0 +1,195 @@
+import mmap
+import os
+import struct
+import sys
+import tempfile
+
+try:
+    from multiprocessing import shared_memory
+except ImportError:
+    # Python 3.7 has no multiprocessing.shared_memory
+    shared_memory = None
+
+ARRAY_HEADER_MAGIC = b"JBNA"
+ARRAY_DATA_ALIGNMENT = 64
+
+# magic, rank, dtype name, data offset
+_ARRAY_HEADER = struct.Struct("<4sI16sQ")
+
+# The dtypes Go's GetDTypeSize understands
+_DTYPE_SIZES = {
+    "float32": 4, "float64": 8,
+    "int8": 1, "int16": 2, "int32": 4, "int64": 8,
+    "uint8": 1, "uint16": 2, "uint32": 4, "uint64": 8,
+    "complex64": 8, "complex128": 16, "bool": 1,
+}
+
+class SharedMemory:
+    def __init__(self, name, size=0, create=False):
+        """
+        Open (or create) a shared memory region shared with Go's
+        jumpboot.SharedMemory.
+
+        Args:
+            name: The name used on the Go side, e.g. "/my_data"
+            size: The size of the region in bytes; 0 uses the region's own size
+            create: Create the region instead of opening an existing one
+        """
+        if shared_memory is None:
+            raise RuntimeError("jumpboot.SharedMemory requires Python 3.8 or later")
+        self.name = name
+        self.pos = 0
+        # Python adds the leading slash of POSIX names itself
+        shm_name = name.lstrip("/") if os.name == "posix" else name
+        if create:
+            self._shm = shared_memory.SharedMemory(name=shm_name, create=True, size=size)
+        else:
+            self._shm = _attach(shm_name)
+        self.size = size if size > 0 else self._shm.size
+        if self.size > self._shm.size:
+            self._shm.close()
+            raise ValueError("shared memory %s is smaller than %d bytes" % (name, size))
+        self.buffer = self._shm.buf[:self.size]
+
+    def read(self, size=-1):
+        """Read up to size bytes from the current position (all remaining bytes if negative)"""
+        end = self.size if size < 0 else min(self.size, self.pos + size)
+        data = bytes(self.buffer[self.pos:end])
+        self.pos = max(self.pos, end)
+        return data
+
+    def write(self, data):
+        """Write data at the current position, returning the number of bytes written"""
+        end = self.pos + len(data)
+        if end > self.size:
+            raise ValueError("write of %d bytes at %d exceeds the shared memory size %d" % (len(data), self.pos, self.size))
+        self.buffer[self.pos:end] = data
+        self.pos = end
+        return len(data)
+
+    def seek(self, offset, whence=os.SEEK_SET):
+        """Move the current position, returning the new position"""
+        if whence == os.SEEK_CUR:
+            offset += self.pos
+        elif whence == os.SEEK_END:
+            offset += self.size
+        if offset < 0 or offset > self.size:
+            raise ValueError("seek position %d is outside the shared memory" % offset)
+        self.pos = offset
+        return self.pos
+
+    def tell(self):
+        return self.pos
+
+    def write_array_header(self, dtype, shape):
+        """
+        Describe an array in the header at the start of the region, in the
+        same format as Go's WriteArrayHeader.
+
+        Returns:
+            The offset at which the array data starts
+        """
+        dtype = str(dtype)
+        if dtype not in _DTYPE_SIZES:
+            raise ValueError("unsupported dtype: %s" % dtype)
+        shape = [int(dim) for dim in shape]
+        count = 1
+        for dim in shape:
+            count *= dim
+        offset = _array_data_offset(len(shape))
+        if offset + count * _DTYPE_SIZES[dtype] > self.size:
+            raise ValueError("%s array of shape %s does not fit in %d bytes of shared memory" % (dtype, shape, self.size))
+        _ARRAY_HEADER.pack_into(self.buffer, 0, ARRAY_HEADER_MAGIC, len(shape), dtype.encode(), offset)
+        struct.pack_into("<%dQ" % len(shape), self.buffer, _ARRAY_HEADER.size, *shape)
+        return offset
+
+    def read_array_header(self):
+        """
+        Read the header written by Go's WriteArrayHeader.
+
+        Returns:
+            A (dtype, shape, data_offset) tuple
+        """
+        if self.size < _ARRAY_HEADER.size:
+            raise ValueError("shared memory does not hold an array header")
+        magic, rank, dtype, offset = _ARRAY_HEADER.unpack_from(self.buffer, 0)
+        if magic != ARRAY_HEADER_MAGIC:
+            raise ValueError("shared memory does not hold an array header")
+        dtype = dtype.rstrip(b"\0").decode()
+        if dtype not in _DTYPE_SIZES:
+            raise ValueError("unsupported dtype in array header: %r" % dtype)
+        if _ARRAY_HEADER.size + 8 * rank > self.size:
+            raise ValueError("invalid array rank %d for %d bytes of shared memory" % (rank, self.size))
+        shape = struct.unpack_from("<%dQ" % rank, self.buffer, _ARRAY_HEADER.size)
+        count = 1
+        for dim in shape:
+            count *= dim
+        if offset < _ARRAY_HEADER.size + 8 * rank or offset + count * _DTYPE_SIZES[dtype] > self.size:
+            raise ValueError("invalid array data offset %d for %d bytes of shared memory" % (offset, self.size))
+        return dtype, tuple(shape), offset
+
+    def as_numpy(self):
+        """
+        Return a NumPy array viewing the data described by the array header.
+        Writes to the array are visible to Go immediately.
+        """
+        import numpy as np
+        dtype, shape, offset = self.read_array_header()
+        return np.ndarray(shape, dtype=np.dtype(dtype).newbyteorder("<"), buffer=self.buffer, offset=offset)
+
+    def close(self):
+        """
+        Unmap the region from this process. NumPy arrays from as_numpy() must
+        be deleted first.
+        """
+        self.buffer.release()
+        self._shm.close()
+
+    def unlink(self):
+        """Destroy the region once every process has closed it"""
+        self._shm.unlink()
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, exc_type, exc_value, tb):
+        self.close()
+
+def _array_data_offset(rank):
+    end = _ARRAY_HEADER.size + 8 * rank
+    return (end + ARRAY_DATA_ALIGNMENT - 1) // ARRAY_DATA_ALIGNMENT * ARRAY_DATA_ALIGNMENT
+
+class _FileMapping:
+    """A mapped file with the parts of multiprocessing's SharedMemory we use"""
+    def __init__(self, path):
+        with open(path, "r+b") as f:
+            self._mmap = mmap.mmap(f.fileno(), 0)
+        self.buf = memoryview(self._mmap)
+        self.size = len(self._mmap)
+        self._path = path
+
+    def close(self):
+        self.buf.release()
+        self._mmap.close()
+
+    def unlink(self):
+        os.remove(self._path)
+
+def _attach(name):
+    """Open an existing region without letting Python's resource tracker destroy it at exit"""
+    try:
+        try:
+            return shared_memory.SharedMemory(name=name, track=False)
+        except TypeError:
+            # Python < 3.13 always tracks the region it opens
+            shm = shared_memory.SharedMemory(name=name)
+            if os.name == "posix":
+                from multiprocessing import resource_tracker
+                resource_tracker.unregister(shm._name, "shared_memory")
+            return shm
+    except FileNotFoundError:
+        # Go built without CGO on macOS maps a file in the temp directory
+        path = os.path.join(tempfile.gettempdir(), "jumpboot-shm-" + name)
+        if sys.platform != "darwin" or not os.path.exists(path):
+            raise
+        return _FileMapping(path)
@@ -1,12 +1,24 @@
 package jumpboot
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 )
 
+// PipInstallOptions controls optional pip install flags.
+type PipInstallOptions struct {
+	// NoDeps passes --no-deps so that package dependencies are not installed.
+	NoDeps bool
+
+	// PreferBinary passes --prefer-binary so that wheels are preferred over
+	// newer source distributions.
+	PreferBinary bool
+}
+
 // PipInstallPackages installs one or more Python packages using pip.
 //
 // Parameters:
@@ -16,8 +28,67 @@ import (
 //   - no_cache: If true, disables pip's cache (useful for CI/CD)
 //   - progressCallback: Optional progress callback; may be nil
 //
-// Returns an error if pip fails, including stderr output for debugging.
+// Besides index names, packages may be VCS URLs ("git+https://..."), archive
+// URLs ("https://.../pkg.tar.gz"), or local wheel/sdist paths ("./dist/pkg.whl").
+// These are handed to pip as-is; local paths are made absolute first.
+//
+// If pip fails, the error is a *PipError holding pip's exit code and stderr,
+// with a Kind that tells, for example, network failures worth retrying from
+// version conflicts.
 func (env *PythonEnvironment) PipInstallPackages(packages []string, index_url string, extra_index_url string, no_cache bool, progressCallback ProgressCallback) error {
+	return env.PipInstallPackagesWithOptions(packages, index_url, extra_index_url, no_cache, PipInstallOptions{}, progressCallback)
+}
+
+// PipInstallPackagesWithOptions installs packages like PipInstallPackages with
+// additional pip flags controlled by opts.
+func (env *PythonEnvironment) PipInstallPackagesWithOptions(packages []string, index_url string, extra_index_url string, no_cache bool, opts PipInstallOptions, progressCallback ProgressCallback) error {
+	args, err := pipInstallArgs(packages, index_url, extra_index_url, no_cache, opts)
+	if err != nil {
+		return err
+	}
+
+	installCmd := exec.Command(env.PipPath, append(args, pipProgressArgs(env.PipVersion)...)...)
+	defer env.lock()()
+	defer env.invalidatePackageCache()
+
+	bardesc := "Installing pip packages..."
+	if len(packages) == 1 {
+		bardesc = fmt.Sprintf("Installing pip package %s...", packages[0])
+	}
+	if err := runPip(installCmd, "error installing package", bardesc, progressCallback); err != nil {
+		return err
+	}
+
+	if progressCallback != nil {
+		progressCallback("Pip packages installed successfully", 100, 100)
+	}
+
+	return nil
+}
+
+// runPip runs a pip command, reporting each line of its stdout as progress
+// and keeping its stderr for the error. Both are read while pip runs, so
+// neither pipe can fill up and block pip, however much it writes. If pip
+// fails, the error is a *PipError whose action describes what failed.
+func runPip(cmd *exec.Cmd, action string, description string, progressCallback ProgressCallback) error {
+	progress := &lineWriter{onLine: (&pipProgress{description: description, callback: progressCallback}).line}
+	var stderrBuf bytes.Buffer
+	cmd.Stdout = progress
+	cmd.Stderr = &stderrBuf
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting pip install: %v", err)
+	}
+	err := cmd.Wait()
+	progress.Flush()
+	if err != nil {
+		return newPipError(action, err, stderrBuf.String())
+	}
+	return nil
+}
+
+// pipInstallArgs builds the argument list for "pip install".
+func pipInstallArgs(packages []string, index_url string, extra_index_url string, no_cache bool, opts PipInstallOptions) ([]string, error) {
 	args := []string{
 		"install",
 		"--no-warn-script-location",
@@ -26,8 +97,22 @@ func (env *PythonEnvironment) PipInstallPackages(packages []string, index_url st
 	if no_cache {
 		args = append(args, "--no-cache-dir")
 	}
+	if opts.NoDeps {
+		args = append(args, "--no-deps")
+	}
+	if opts.PreferBinary {
+		args = append(args, "--prefer-binary")
+	}
+	args = append(args, getNetworkConfig().pipArgs()...)
+
+	for _, pkg := range packages {
+		spec, err := pipPackageArg(pkg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, spec)
+	}
 
-	args = append(args, packages...)
 	if index_url != "" {
 		args = append(args, "--index-url", index_url)
 	}
@@ -35,82 +120,115 @@ func (env *PythonEnvironment) PipInstallPackages(packages []string, index_url st
 		args = append(args, "--extra-index-url", extra_index_url)
 	}
 
-	installCmd := exec.Command(env.PipPath, args...)
-
-	// Capture both stdout AND stderr
-	var stdoutBuf, stderrBuf bytes.Buffer
-	installCmd.Stdout = &stdoutBuf
-	installCmd.Stderr = &stderrBuf
+	return args, nil
+}
 
-	if err := installCmd.Start(); err != nil {
-		return fmt.Errorf("error starting pip install: %v", err)
+// pipPackageArg prepares a single package specifier for pip. VCS and archive
+// URLs are returned untouched, and local wheel/sdist paths are made absolute so
+// they do not depend on pip's working directory.
+func pipPackageArg(pkg string) (string, error) {
+	if isPipURLSpec(pkg) {
+		return pkg, nil
+	}
+	if strings.HasSuffix(pkg, ".whl") || strings.HasSuffix(pkg, ".tar.gz") {
+		absPath, err := filepath.Abs(pkg)
+		if err != nil {
+			return "", fmt.Errorf("error resolving package path %s: %v", pkg, err)
+		}
+		return absPath, nil
 	}
+	return pkg, nil
+}
 
-	scanner := bufio.NewScanner(&stdoutBuf)
-	lineCount := int64(0)
-	for scanner.Scan() {
-		lineCount++
-		if progressCallback != nil {
-			bardesc := "Installing pip packages..."
-			if len(packages) == 1 {
-				bardesc = fmt.Sprintf("Installing pip package %s...", packages[0])
-			}
-			progressCallback(bardesc, lineCount, -1)
+// isPipURLSpec reports whether a package specifier is a VCS or archive URL.
+func isPipURLSpec(pkg string) bool {
+	for _, prefix := range []string{"git+", "http://", "https://"} {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
 		}
 	}
+	return false
+}
 
-	// Get the error (if any) *and* the stderr output.
-	if err := installCmd.Wait(); err != nil {
-		return fmt.Errorf("error installing package: %v, stderr: %s", err, stderrBuf.String())
+// PipInstallRequirements installs packages from a requirements.txt file.
+// The file should contain one package specifier per line in pip format.
+// If pip fails, the error is a *PipError.
+func (env *PythonEnvironment) PipInstallRequirements(requirementsPath string, progressCallback ProgressCallback) error {
+	args := append([]string{"install", "--no-warn-script-location"}, getNetworkConfig().pipArgs()...)
+	args = append(args, pipProgressArgs(env.PipVersion)...)
+	installCmd := exec.Command(env.PipPath, append(args, "-r", requirementsPath)...)
+	defer env.lock()()
+	defer env.invalidatePackageCache()
+
+	if err := runPip(installCmd, "error installing requirements", "Installing pip requirements...", progressCallback); err != nil {
+		return err
 	}
 
 	if progressCallback != nil {
-		progressCallback("Pip packages installed successfully", 100, 100)
+		progressCallback("Pip requirements installed successfully", 100, 100)
 	}
 
 	return nil
 }
 
-// PipInstallRequirements installs packages from a requirements.txt file.
-// The file should contain one package specifier per line in pip format.
-func (env *PythonEnvironment) PipInstallRequirements(requirementsPath string, progressCallback ProgressCallback) error {
-	installCmd := exec.Command(env.PipPath, "install", "--no-warn-script-location", "-r", requirementsPath)
+// PipInstallPackage installs a single Python package using pip.
+// This is a convenience wrapper around PipInstallPackages for single packages.
+func (env *PythonEnvironment) PipInstallPackage(packageToInstall string, index_url string, extra_index_url string, no_cache bool, progressCallback ProgressCallback) error {
+	packages := []string{
+		packageToInstall,
+	}
+	return env.PipInstallPackages(packages, index_url, extra_index_url, no_cache, progressCallback)
+}
 
-	stdout, err := installCmd.StdoutPipe()
+// PipInstallEditable installs a local Python project in editable (development) mode
+// using "pip install -e". Changes to the project's source are picked up without
+// reinstalling.
+//
+// Parameters:
+//   - projectPath: Directory containing setup.py or pyproject.toml
+//   - extras: Optional extras to install (e.g., []string{"dev"} for "-e ./mypkg[dev]")
+//   - progressCallback: Optional progress callback; may be nil
+//
+// Returns an error if projectPath is not a Python project or pip fails.
+func (env *PythonEnvironment) PipInstallEditable(projectPath string, extras []string, progressCallback ProgressCallback) error {
+	info, err := os.Stat(projectPath)
 	if err != nil {
-		return fmt.Errorf("error creating stdout pipe: %v", err)
+		return fmt.Errorf("error accessing project path: %v", err)
 	}
-	defer stdout.Close()
-
-	if err := installCmd.Start(); err != nil {
-		return fmt.Errorf("error starting pip install: %v", err)
+	if !info.IsDir() {
+		return fmt.Errorf("project path is not a directory: %s", projectPath)
 	}
 
-	scanner := bufio.NewScanner(stdout)
-	lineCount := int64(0)
-	for scanner.Scan() {
-		lineCount++
-		if progressCallback != nil {
-			progressCallback("Installing pip requirements...", lineCount, -1)
+	hasProjectFile := false
+	for _, name := range []string{"pyproject.toml", "setup.py"} {
+		if _, err := os.Stat(filepath.Join(projectPath, name)); err == nil {
+			hasProjectFile = true
+			break
 		}
 	}
+	if !hasProjectFile {
+		return fmt.Errorf("project path %s does not contain a setup.py or pyproject.toml", projectPath)
+	}
 
-	if err := installCmd.Wait(); err != nil {
-		return fmt.Errorf("error installing requirements: %v", err)
+	target := projectPath
+	if len(extras) > 0 {
+		target += "[" + strings.Join(extras, ",") + "]"
+	}
+
+	args := append([]string{"install", "--no-warn-script-location"}, getNetworkConfig().pipArgs()...)
+	args = append(args, pipProgressArgs(env.PipVersion)...)
+	installCmd := exec.Command(env.PipPath, append(args, "-e", target)...)
+	defer env.lock()()
+	defer env.invalidatePackageCache()
+
+	bardesc := fmt.Sprintf("Installing editable package %s...", filepath.Base(projectPath))
+	if err := runPip(installCmd, "error installing editable package", bardesc, progressCallback); err != nil {
+		return err
 	}
 
 	if progressCallback != nil {
-		progressCallback("Pip requirements installed successfully", 100, 100)
+		progressCallback("Editable package installed successfully", 100, 100)
 	}
 
 	return nil
 }
-
-// PipInstallPackage installs a single Python package using pip.
-// This is a convenience wrapper around PipInstallPackages for single packages.
-func (env *PythonEnvironment) PipInstallPackage(packageToInstall string, index_url string, extra_index_url string, no_cache bool, progressCallback ProgressCallback) error {
-	packages := []string{
-		packageToInstall,
-	}
-	return env.PipInstallPackages(packages, index_url, extra_index_url, no_cache, progressCallback)
-}
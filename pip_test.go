@@ -0,0 +1,149 @@
+package jumpboot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipInstallArgs_PassThroughSpecs(t *testing.T) {
+	gitSpec := "git+https://github.com/psf/requests.git@v2.31.0#egg=requests"
+	urlSpec := "https://example.com/pkg-1.0.tar.gz"
+	wheel := filepath.Join("dist", "pkg-1.0-py3-none-any.whl")
+
+	args, err := pipInstallArgs([]string{gitSpec, urlSpec, wheel, "numpy==1.26.0"}, "", "", false, PipInstallOptions{NoDeps: true, PreferBinary: true})
+	if err != nil {
+		t.Fatalf("pipInstallArgs failed: %v", err)
+	}
+
+	absWheel, _ := filepath.Abs(wheel)
+	expected := []string{"install", "--no-warn-script-location", "--no-deps", "--prefer-binary", gitSpec, urlSpec, absWheel, "numpy==1.26.0"}
+	if len(args) != len(expected) {
+		t.Fatalf("Expected args %v, got %v", expected, args)
+	}
+	for i := range expected {
+		if args[i] != expected[i] {
+			t.Errorf("Arg %d: expected %q, got %q", i, expected[i], args[i])
+		}
+	}
+}
+
+func TestClassifyPipError(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   PipErrorKind
+	}{
+		{"ERROR: Could not find a version that satisfies the requirement nosuchpkg (from versions: none)\nERROR: No matching distribution found for nosuchpkg", PipErrorNotFound},
+		{"WARNING: Retrying (Retry(total=4, connect=None, read=None, redirect=None, status=None)) after connection broken by 'NewConnectionError(...: Failed to establish a new connection: [Errno 111] Connection refused')'\nERROR: No matching distribution found for requests", PipErrorNetwork},
+		{"ERROR: Cannot install a==1.0 and b==2.0 because these package versions have conflicting dependencies.\nERROR: ResolutionImpossible: for help visit https://pip.pypa.io", PipErrorVersionConflict},
+		{"ERROR: Could not install packages due to an OSError: [Errno 13] Permission denied: '/usr/lib/python3/site-packages/x'\nConsider using the `--user` option or check the permissions.", PipErrorPermissionDenied},
+		{"ERROR: Exception: something else went wrong", PipErrorUnknown},
+		{"", PipErrorUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyPipError(tt.stderr); got != tt.want {
+			t.Errorf("classifyPipError(%q) = %v, want %v", tt.stderr, got, tt.want)
+		}
+	}
+}
+
+func TestPipInstallPackages_PipError(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := t.TempDir()
+	pip, _ := writeFakeTool(t, testDir, "pip", `echo "ERROR: No matching distribution found for nosuchpkg" >&2; exit 1`)
+	env := &PythonEnvironment{PipPath: pip}
+
+	err := env.PipInstallPackages([]string{"nosuchpkg"}, "", "", false, nil)
+	var pipErr *PipError
+	if !errors.As(err, &pipErr) {
+		t.Fatalf("expected a *PipError, got %v", err)
+	}
+	if pipErr.Kind != PipErrorNotFound || pipErr.ExitCode != 1 || !strings.Contains(pipErr.Stderr, "nosuchpkg") {
+		t.Errorf("PipError = %+v", pipErr)
+	}
+
+	// Requirements files fail the same way
+	requirements := filepath.Join(testDir, "requirements.txt")
+	if err := os.WriteFile(requirements, []byte("nosuchpkg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = env.PipInstallRequirements(requirements, nil)
+	if !errors.As(err, &pipErr) || pipErr.Kind != PipErrorNotFound {
+		t.Errorf("expected a not found *PipError from PipInstallRequirements, got %v", err)
+	}
+}
+
+func TestPipInstallPackages_LargeOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	// A megabyte of warnings on stderr and a stdout line longer than a
+	// bufio.Scanner accepts, both far past a pipe's buffer
+	testDir := t.TempDir()
+	pip, _ := writeFakeTool(t, testDir, "pip", `head -c 1048576 /dev/zero | tr '\0' 'w' >&2
+head -c 200000 /dev/zero | tr '\0' 'x'; echo
+echo "Collecting last-package"`)
+	env := &PythonEnvironment{PipPath: pip}
+
+	var messages []string
+	done := make(chan error, 1)
+	go func() {
+		done <- env.PipInstallPackages([]string{"requests"}, "", "", false, func(message string, current, total int64) {
+			messages = append(messages, message)
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("PipInstallPackages failed: %v", err)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("PipInstallPackages hung on large output")
+	}
+	found := false
+	for _, message := range messages {
+		found = found || message == "Collecting last-package..."
+	}
+	if !found {
+		t.Errorf("output after the long line was not reported: %d messages", len(messages))
+	}
+}
+
+func TestPipInstallEditable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	testDir := t.TempDir()
+	pip, pipLog := writeFakeTool(t, testDir, "pip", "")
+	env := &PythonEnvironment{PipPath: pip}
+
+	project := filepath.Join(testDir, "mypkg")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory that is not a Python project is rejected before pip runs
+	if err := env.PipInstallEditable(project, nil, nil); err == nil || !strings.Contains(err.Error(), "pyproject.toml") {
+		t.Errorf("expected an error for a directory without a project file, got %v", err)
+	}
+	if calls := readInvocations(t, pipLog); len(calls) != 0 {
+		t.Errorf("pip ran for an invalid project: %v", calls)
+	}
+
+	if err := os.WriteFile(filepath.Join(project, "pyproject.toml"), []byte("[project]\nname = \"mypkg\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.PipInstallEditable(project, []string{"dev", "test"}, nil); err != nil {
+		t.Fatalf("PipInstallEditable failed: %v", err)
+	}
+	calls := readInvocations(t, pipLog)
+	if len(calls) != 1 || !strings.HasPrefix(calls[0], "install ") || !strings.HasSuffix(calls[0], " -e "+project+"[dev,test]") {
+		t.Errorf("expected one pip install ending in -e %s[dev,test], got %v", project, calls)
+	}
+}
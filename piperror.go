@@ -0,0 +1,140 @@
+package jumpboot
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// PipErrorKind is a best-effort classification of why pip failed, made from
+// the messages pip writes to stderr.
+type PipErrorKind int
+
+const (
+	// PipErrorUnknown is a failure that matched none of the other kinds.
+	PipErrorUnknown PipErrorKind = iota
+
+	// PipErrorNotFound means no distribution matched a requirement, for
+	// example because the package or version doesn't exist on the index.
+	PipErrorNotFound
+
+	// PipErrorVersionConflict means the requirements, or the packages already
+	// installed, can't be satisfied together.
+	PipErrorVersionConflict
+
+	// PipErrorNetwork means the index couldn't be reached, for example
+	// because of a DNS, connection, timeout, proxy or TLS failure. These
+	// failures are often worth retrying.
+	PipErrorNetwork
+
+	// PipErrorPermissionDenied means pip couldn't write to the environment.
+	PipErrorPermissionDenied
+)
+
+func (k PipErrorKind) String() string {
+	switch k {
+	case PipErrorNotFound:
+		return "not found"
+	case PipErrorVersionConflict:
+		return "version conflict"
+	case PipErrorNetwork:
+		return "network"
+	case PipErrorPermissionDenied:
+		return "permission denied"
+	}
+	return "unknown"
+}
+
+// PipError is returned when pip runs but fails, by PipInstallPackages,
+// PipInstallRequirements and the other pip install methods.
+type PipError struct {
+	// Kind classifies the failure from pip's stderr.
+	Kind PipErrorKind
+
+	// ExitCode is pip's exit code, or -1 if it was killed by a signal.
+	ExitCode int
+
+	// Stderr is everything pip wrote to standard error.
+	Stderr string
+
+	// action describes what failed, such as "error installing package"
+	action string
+
+	// err is the error from running pip
+	err error
+}
+
+func (e *PipError) Error() string {
+	return fmt.Sprintf("%s: %v, stderr: %s", e.action, e.err, e.Stderr)
+}
+
+// Unwrap returns the error from running pip, usually an *exec.ExitError.
+func (e *PipError) Unwrap() error {
+	return e.err
+}
+
+// newPipError returns a *PipError for a pip command that failed with err.
+func newPipError(action string, err error, stderr string) *PipError {
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+	return &PipError{
+		Kind:     classifyPipError(stderr),
+		ExitCode: exitCode,
+		Stderr:   stderr,
+		action:   action,
+		err:      err,
+	}
+}
+
+// pipErrorPatterns are the stderr messages that identify each kind of
+// failure, checked in order. Network failures come first because pip reports
+// an unreachable index as "No matching distribution found" as well.
+var pipErrorPatterns = []struct {
+	kind     PipErrorKind
+	patterns []string
+}{
+	{PipErrorNetwork, []string{
+		"Failed to establish a new connection",
+		"NewConnectionError",
+		"ConnectTimeoutError",
+		"Read timed out",
+		"Temporary failure in name resolution",
+		"Name or service not known",
+		"nodename nor servname provided",
+		"Network is unreachable",
+		"Connection refused",
+		"Connection reset by peer",
+		"ProxyError",
+		"SSLError",
+		"Max retries exceeded",
+	}},
+	{PipErrorVersionConflict, []string{
+		"ResolutionImpossible",
+		"conflicting dependencies",
+	}},
+	{PipErrorPermissionDenied, []string{
+		"Permission denied",
+		"[Errno 13]",
+		"Consider using the `--user` option",
+	}},
+	{PipErrorNotFound, []string{
+		"No matching distribution found",
+		"Could not find a version that satisfies the requirement",
+	}},
+}
+
+// classifyPipError returns the kind of failure pip's stderr describes.
+func classifyPipError(stderr string) PipErrorKind {
+	for _, kind := range pipErrorPatterns {
+		for _, pattern := range kind.patterns {
+			if strings.Contains(stderr, pattern) {
+				return kind.kind
+			}
+		}
+	}
+	return PipErrorUnknown
+}
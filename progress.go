@@ -0,0 +1,216 @@
+package jumpboot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Install progress is reported in bytes when pip or micromamba print download
+// sizes, so callers can draw a real progress bar for large downloads. Lines
+// that carry no download information are reported with the line count as
+// current and a total of -1; for pip, the message names the phase and the
+// package it is working on where pip's output says so.
+
+var (
+	// pipDownloadRegex matches pip's "Downloading numpy-1.26.4-...whl (18.3 MB)".
+	pipDownloadRegex = regexp.MustCompile(`^\s*Downloading (\S+) \(([\d.]+ ?(?:bytes|[kKMGT]?i?B))\)`)
+
+	// pipRawProgressRegex matches the lines printed with --progress-bar raw.
+	pipRawProgressRegex = regexp.MustCompile(`^\s*Progress (\d+) of (\d+)\s*$`)
+
+	// pipPhases match the other pip lines that name the package being worked
+	// on, with the message reported for each.
+	pipPhases = []struct {
+		regex  *regexp.Regexp
+		format string
+	}{
+		{regexp.MustCompile(`^\s*Collecting (\S+)`), "Collecting %s..."},
+		{regexp.MustCompile(`^\s*Using cached (\S+)`), "Using cached %s"},
+		{regexp.MustCompile(`^\s*Requirement already satisfied: (\S+)`), "Requirement already satisfied: %s"},
+		{regexp.MustCompile(`^\s*Building wheel for (\S+)`), "Building wheel for %s..."},
+		{regexp.MustCompile(`^\s*Installing collected packages: (.+?)\s*$`), "Installing %s..."},
+		{regexp.MustCompile(`^\s*Successfully installed (.+?)\s*$`), "Installed %s"},
+	}
+
+	// micromambaTotalRegex matches the transaction summary's "Total download: 1.2GB".
+	micromambaTotalRegex = regexp.MustCompile(`Total download:\s*([\d.]+\s*[kKMGT]?i?B)`)
+
+	// micromambaDownloadedRegex matches a finished download such as
+	// "numpy      7.6MB @  10.2MB/s  0.7s".
+	micromambaDownloadedRegex = regexp.MustCompile(`^\s*(\S+)\s+([\d.]+\s*[kKMGT]?i?B)\s+@\s+`)
+)
+
+// byteUnits maps the size units pip and micromamba print to their size in bytes.
+var byteUnits = map[string]float64{
+	"b": 1, "bytes": 1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+}
+
+// parseByteSize parses a human-readable size such as "18.3 MB", "7.6MB" or
+// "512 bytes".
+func parseByteSize(s string) (int64, bool) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i <= 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, false
+	}
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, false
+	}
+	return int64(n * unit), true
+}
+
+// pipProgressArgs returns the pip flags that make pip print machine-readable
+// download progress. "--progress-bar raw" requires pip 24.1 or later; older
+// versions still report each download's size.
+func pipProgressArgs(pipVersion Version) []string {
+	if pipVersion.Compare(Version{Major: 24, Minor: 1, Patch: -1}) >= 0 {
+		return []string{"--progress-bar", "raw"}
+	}
+	return nil
+}
+
+// pipProgress turns pip's output into progress callbacks. Pip does not know
+// the total download size in advance, so the total grows as each download
+// starts.
+type pipProgress struct {
+	description string
+	callback    ProgressCallback
+
+	lines     int64
+	file      string
+	completed int64 // bytes of the downloads before the current one
+	fileSize  int64
+	fileDone  int64
+}
+
+// line handles one line of pip output.
+func (p *pipProgress) line(text string) {
+	if p.callback == nil {
+		return
+	}
+	p.lines++
+
+	if match := pipDownloadRegex.FindStringSubmatch(text); match != nil {
+		size, ok := parseByteSize(match[2])
+		if ok {
+			p.completed += p.fileSize
+			p.file, p.fileSize, p.fileDone = match[1], size, 0
+			p.callback(fmt.Sprintf("Downloading %s...", p.file), p.completed, p.completed+p.fileSize)
+			return
+		}
+	}
+	if match := pipRawProgressRegex.FindStringSubmatch(text); match != nil && p.file != "" {
+		done, _ := strconv.ParseInt(match[1], 10, 64)
+		size, _ := strconv.ParseInt(match[2], 10, 64)
+		if size > 0 {
+			// The exact size replaces the rounded one from the Downloading line
+			p.fileSize = size
+		}
+		p.fileDone = done
+		p.callback(fmt.Sprintf("Downloading %s...", p.file), p.completed+p.fileDone, p.completed+p.fileSize)
+		return
+	}
+	for _, phase := range pipPhases {
+		if match := phase.regex.FindStringSubmatch(text); match != nil {
+			p.callback(fmt.Sprintf(phase.format, match[1]), p.lines, -1)
+			return
+		}
+	}
+
+	p.callback(p.description, p.lines, -1)
+}
+
+// micromambaProgress turns micromamba's output into progress callbacks. The
+// total comes from the transaction summary and each finished package download
+// adds its size to the current value. Micromamba's --json output is a single
+// document printed at the end, so the text output is the only progress
+// stream it has.
+//
+// If description is set, lines without download information, such as the
+// solver's, are reported with it too: with the line count as current and a
+// total of -1 until the download size is known, and with the bytes
+// downloaded so far after that, so a progress bar doesn't jump back.
+type micromambaProgress struct {
+	callback    ProgressCallback
+	description string
+
+	lines int64
+	total int64
+	done  int64
+}
+
+// line handles one line of micromamba output.
+func (p *micromambaProgress) line(text string) {
+	p.lines++
+	if p.reportDownload(text) || p.description == "" {
+		return
+	}
+	if p.total > 0 {
+		p.callback(p.description, p.done, p.total)
+	} else {
+		p.callback(p.description, p.lines, -1)
+	}
+}
+
+// reportDownload reports the download summary or a finished download, and
+// returns false for any other line.
+func (p *micromambaProgress) reportDownload(text string) bool {
+	if match := micromambaTotalRegex.FindStringSubmatch(text); match != nil {
+		if total, ok := parseByteSize(match[1]); ok {
+			p.total = total
+			p.callback("Downloading conda packages...", p.done, p.total)
+			return true
+		}
+		return false
+	}
+	if p.total == 0 {
+		// Download lines are only expected after the summary
+		return false
+	}
+	if match := micromambaDownloadedRegex.FindStringSubmatch(text); match != nil {
+		if size, ok := parseByteSize(match[2]); ok {
+			p.done += size
+			if p.done > p.total {
+				p.done = p.total
+			}
+			p.callback(fmt.Sprintf("Downloaded %s", match[1]), p.done, p.total)
+			return true
+		}
+	}
+	return false
+}
+
+// lineWriter is an io.Writer that calls onLine for each line written to it.
+// Carriage returns also end a line, since progress output redraws lines with them.
+type lineWriter struct {
+	onLine func(line string)
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b == '\n' || b == '\r' {
+			w.Flush()
+			continue
+		}
+		w.buf = append(w.buf, b)
+	}
+	return len(p), nil
+}
+
+// Flush passes a final unterminated line to onLine.
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.onLine(string(w.buf))
+		w.buf = w.buf[:0]
+	}
+}
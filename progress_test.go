@@ -0,0 +1,141 @@
+package jumpboot
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type progressEvent struct {
+	message        string
+	current, total int64
+}
+
+func recordProgress(events *[]progressEvent) ProgressCallback {
+	return func(message string, current, total int64) {
+		*events = append(*events, progressEvent{message, current, total})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"18.3 MB", 18300000, true},
+		{"7.6MB", 7600000, true},
+		{"512 bytes", 512, true},
+		{"1 KiB", 1024, true},
+		{"2 kB", 2000, true},
+		{"1.5 GB", 1500000000, true},
+		{"MB", 0, false},
+		{"12 parsecs", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseByteSize(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPipProgressArgs(t *testing.T) {
+	if args := pipProgressArgs(Version{Major: 23, Minor: 2, Patch: 1}); args != nil {
+		t.Errorf("pip 23.2.1: got %v, want no flags", args)
+	}
+	want := []string{"--progress-bar", "raw"}
+	for _, v := range []Version{{Major: 24, Minor: 1, Patch: -1}, {Major: 24, Minor: 2, Patch: 0}, {Major: 25, Minor: 0, Patch: 1}} {
+		if args := pipProgressArgs(v); !reflect.DeepEqual(args, want) {
+			t.Errorf("pip %v: got %v, want %v", v, args, want)
+		}
+	}
+}
+
+func TestPipProgress(t *testing.T) {
+	var events []progressEvent
+	p := &pipProgress{description: "Installing", callback: recordProgress(&events)}
+	for _, line := range []string{
+		"Collecting numpy",
+		"  Downloading numpy-1.26.4-cp311-cp311-manylinux_x86_64.whl (18.3 MB)",
+		"Progress 9000000 of 18312345",
+		"Progress 18312345 of 18312345",
+		"Collecting six",
+		"  Downloading six-1.16.0-py2.py3-none-any.whl (11 kB)",
+		"Collecting requests>=2 (from -r requirements.txt (line 1))",
+		"  Using cached requests-2.31.0-py3-none-any.whl (62 kB)",
+		"Requirement already satisfied: idna<4,>=2.5 in ./lib/python3.11/site-packages (from requests>=2) (3.6)",
+		"Building wheel for pyyaml (pyproject.toml): started",
+		"Installing collected packages: six, numpy",
+		"Successfully installed numpy-1.26.4 six-1.16.0",
+		"  Attempting uninstall: six",
+	} {
+		p.line(line)
+	}
+
+	want := []progressEvent{
+		{"Collecting numpy...", 1, -1},
+		{"Downloading numpy-1.26.4-cp311-cp311-manylinux_x86_64.whl...", 0, 18300000},
+		{"Downloading numpy-1.26.4-cp311-cp311-manylinux_x86_64.whl...", 9000000, 18312345},
+		{"Downloading numpy-1.26.4-cp311-cp311-manylinux_x86_64.whl...", 18312345, 18312345},
+		{"Collecting six...", 5, -1},
+		{"Downloading six-1.16.0-py2.py3-none-any.whl...", 18312345, 18312345 + 11000},
+		{"Collecting requests>=2...", 7, -1},
+		{"Using cached requests-2.31.0-py3-none-any.whl", 8, -1},
+		{"Requirement already satisfied: idna<4,>=2.5", 9, -1},
+		{"Building wheel for pyyaml...", 10, -1},
+		{"Installing six, numpy...", 11, -1},
+		{"Installed numpy-1.26.4 six-1.16.0", 12, -1},
+		{"Installing", 13, -1},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events:\n got %v\nwant %v", events, want)
+	}
+}
+
+func TestMicromambaProgress(t *testing.T) {
+	var events []progressEvent
+	lw := &lineWriter{onLine: (&micromambaProgress{callback: recordProgress(&events)}).line}
+
+	// Micromamba redraws its progress lines with carriage returns
+	output := "  numpy  7.6MB @  1.0MB/s  0.7s\n" + // before the summary: ignored
+		"  Total download: 10MB\n\n" +
+		"[+] 0.1s\r" +
+		"numpy      7.6MB @  10.2MB/s  0.7s\r\n" +
+		"python     3MB @  5.0MB/s  0.6s"
+	fmt.Fprint(lw, output)
+	lw.Flush()
+
+	want := []progressEvent{
+		{"Downloading conda packages...", 0, 10000000},
+		{"Downloaded numpy", 7600000, 10000000},
+		{"Downloaded python", 10000000, 10000000}, // clamped to the total
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events:\n got %v\nwant %v", events, want)
+	}
+}
+
+func TestMicromambaProgress_Fallback(t *testing.T) {
+	var events []progressEvent
+	p := &micromambaProgress{callback: recordProgress(&events), description: "Creating Python environment..."}
+	for _, line := range []string{
+		"Transaction",
+		"  Total download: 10MB",
+		"python     10MB @  5.0MB/s  2.0s",
+		"Linking python-3.11.8",
+	} {
+		p.line(line)
+	}
+
+	want := []progressEvent{
+		{"Creating Python environment...", 1, -1},
+		{"Downloading conda packages...", 0, 10000000},
+		{"Downloaded python", 10000000, 10000000},
+		{"Creating Python environment...", 10000000, 10000000},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events:\n got %v\nwant %v", events, want)
+	}
+}
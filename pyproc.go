@@ -1,20 +1,25 @@
 package jumpboot
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"embed"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path"
+	"regexp"
 	"strconv"
-	"syscall"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"text/template"
 	"time"
 )
@@ -28,6 +33,25 @@ var secondaryBootstrapScriptTemplate string
 //go:embed packages/jumpboot/* packages/jumpboot/**/*
 var jumpboot_package embed.FS
 
+// MinPythonVersion is the oldest Python the embedded jumpboot package and the
+// bootstrap and REPL scripts support (they use asyncio.create_task, added in
+// 3.7). Raise it whenever those files start using newer syntax or stdlib
+// features, so that launching on an older interpreter fails with a clear error
+// instead of a SyntaxError during the bootstrap.
+var MinPythonVersion = Version{Major: 3, Minor: 7, Patch: -1}
+
+// checkMinPythonVersion returns an error if the environment's Python is older
+// than MinPythonVersion. Environments whose version is unknown are not checked.
+func (env *PythonEnvironment) checkMinPythonVersion() error {
+	if env.PythonVersion.Major == 0 {
+		return nil
+	}
+	if env.PythonVersion.Compare(MinPythonVersion) < 0 {
+		return fmt.Errorf("jumpboot requires Python >= %s, but %s is Python %s", MinPythonVersion.String(), env.PythonPath, env.PythonVersion.String())
+	}
+	return nil
+}
+
 // ProcOnException is a callback function invoked when a Python exception occurs.
 type ProcOnException func(ex PythonException)
 
@@ -54,7 +78,8 @@ type PythonProcess struct {
 	// Stdout is the read end of the process's standard output.
 	Stdout io.ReadCloser
 
-	// Stderr is the read end of the process's standard error.
+	// Stderr is the read end of the process's standard error. Its last
+	// StderrTailSize bytes are also kept for StderrTail.
 	Stderr io.ReadCloser
 
 	// PipeIn is for reading data sent from the Python process.
@@ -71,6 +96,219 @@ type PythonProcess struct {
 
 	// StatusChan receives status messages (e.g., "exit") from Python.
 	StatusChan chan map[string]interface{}
+
+	// startup records bootstrap phase timings reported by Python
+	startup *startupRecorder
+
+	// logger receives the process's diagnostic messages (see SetLogger)
+	logger *loggerRef
+
+	// suspended is set while the process is stopped by Suspend
+	suspended atomic.Bool
+
+	// outputForwarded is set when Stdout and Stderr are already drained by
+	// another reader, as QueueProcess does, so WaitForOutput must not read them
+	outputForwarded bool
+
+	// outputDone is closed when the goroutines started by ForwardOutput finish
+	outputDone chan struct{}
+
+	// stderrTail keeps the end of the process's stderr (see StderrTail)
+	stderrTail *tailBuffer
+
+	// exitMutex protects exitCode and exited, set once Wait sees the exit
+	exitMutex sync.Mutex
+	exitCode  int
+	exited    bool
+}
+
+// ErrNoLocalProcess is returned by PythonProcess methods called on a
+// QueueProcess that talks to Python over a connection or transport (see
+// NewQueueProcessOverConn) and so has no local process to control.
+var ErrNoLocalProcess = errors.New("no local process")
+
+// runningProcesses tracks the processes launched from each environment, keyed by
+// environment path, so that an environment can refuse removal while in use.
+var (
+	runningProcessesMutex sync.Mutex
+	runningProcesses      = make(map[string][]*os.Process)
+)
+
+// registerRunningProcess records a process launched from the environment at
+// envPath. Processes that have exited are pruned from every environment first,
+// so the registry stays bounded by the number of live processes even when
+// callers reap their processes without PythonProcess.Wait.
+func registerRunningProcess(envPath string, process *os.Process) {
+	if envPath == "" || process == nil {
+		return
+	}
+	runningProcessesMutex.Lock()
+	defer runningProcessesMutex.Unlock()
+
+	for path, processes := range runningProcesses {
+		alive := processes[:0]
+		for _, p := range processes {
+			if processAlive(p) {
+				alive = append(alive, p)
+			}
+		}
+		if len(alive) == 0 {
+			delete(runningProcesses, path)
+		} else {
+			runningProcesses[path] = alive
+		}
+	}
+	runningProcesses[envPath] = append(runningProcesses[envPath], process)
+}
+
+// unregisterRunningProcess forgets a process once it has exited.
+func unregisterRunningProcess(process *os.Process) {
+	if process == nil {
+		return
+	}
+	runningProcessesMutex.Lock()
+	defer runningProcessesMutex.Unlock()
+
+	for path, processes := range runningProcesses {
+		for i, p := range processes {
+			if p == process {
+				processes = append(processes[:i], processes[i+1:]...)
+				break
+			}
+		}
+		if len(processes) == 0 {
+			delete(runningProcesses, path)
+		} else {
+			runningProcesses[path] = processes
+		}
+	}
+}
+
+// environmentInUse reports whether any process launched from the environment at
+// envPath is still running. Processes that have exited are forgotten.
+func environmentInUse(envPath string) bool {
+	runningProcessesMutex.Lock()
+	defer runningProcessesMutex.Unlock()
+
+	var alive []*os.Process
+	for _, process := range runningProcesses[envPath] {
+		if processAlive(process) {
+			alive = append(alive, process)
+		}
+	}
+	if len(alive) == 0 {
+		delete(runningProcesses, envPath)
+		return false
+	}
+	runningProcesses[envPath] = alive
+	return true
+}
+
+// StartupTimings breaks down how long each phase of the Python bootstrap took.
+// Python reports its phase timestamps on the status pipe just before the main
+// program starts; until then only Launch is populated and Complete is false.
+type StartupTimings struct {
+	// Complete indicates Python has reported its bootstrap timings.
+	Complete bool
+
+	// Launch is the wall-clock time at which Go started the Python process.
+	Launch time.Time
+
+	// Interpreter is the time from launch until the primary bootstrap began executing.
+	// This is dominated by Python interpreter startup and site imports.
+	Interpreter time.Duration
+
+	// PrimaryBootstrap is the time from the primary bootstrap starting until the
+	// secondary bootstrap began executing.
+	PrimaryBootstrap time.Duration
+
+	// SecondaryBootstrap is the time spent reading program data and opening pipes.
+	SecondaryBootstrap time.Duration
+
+	// ModuleLoading is the time spent loading embedded packages and modules.
+	ModuleLoading time.Duration
+
+	// MainStart is the time from modules being loaded until the main program started.
+	MainStart time.Duration
+
+	// Total is the time from launch until the main program started.
+	Total time.Duration
+}
+
+// startupRecorder collects StartupTimings from the status pipe goroutine.
+type startupRecorder struct {
+	mutex   sync.Mutex
+	timings StartupTimings
+}
+
+// markLaunch records the time at which the Python process was started.
+func (sr *startupRecorder) markLaunch() {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	sr.timings.Launch = time.Now()
+}
+
+// record converts the "timing" status message sent by the secondary bootstrap
+// into phase durations relative to the launch time.
+func (sr *startupRecorder) record(status map[string]interface{}, logger Logger) {
+	stamp := func(key string) (time.Time, bool) {
+		f, ok := status[key].(float64)
+		if !ok {
+			return time.Time{}, false
+		}
+		return time.Unix(0, int64(f*float64(time.Second))), true
+	}
+	primary, ok1 := stamp("primary_start")
+	secondary, ok2 := stamp("secondary_start")
+	programLoaded, ok3 := stamp("program_loaded")
+	modulesLoaded, ok4 := stamp("modules_loaded")
+	mainStart, ok5 := stamp("main_start")
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 {
+		logger.Warnf("Incomplete startup timing status: %v", status)
+		return
+	}
+
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	launch := sr.timings.Launch
+	sr.timings.Interpreter = nonNegativeDuration(primary.Sub(launch))
+	sr.timings.PrimaryBootstrap = nonNegativeDuration(secondary.Sub(primary))
+	sr.timings.SecondaryBootstrap = nonNegativeDuration(programLoaded.Sub(secondary))
+	sr.timings.ModuleLoading = nonNegativeDuration(modulesLoaded.Sub(programLoaded))
+	sr.timings.MainStart = nonNegativeDuration(mainStart.Sub(modulesLoaded))
+	sr.timings.Total = nonNegativeDuration(mainStart.Sub(launch))
+	sr.timings.Complete = true
+}
+
+// nonNegativeDuration clamps small negative durations caused by clock granularity.
+func nonNegativeDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// StartupTimings returns the bootstrap phase timings for this process.
+// The result is only complete (Complete == true) once Python has finished
+// bootstrapping and is about to run the main program. Processes started with
+// NewPythonProcessFromString do not run the secondary bootstrap and only report Launch.
+func (pp *PythonProcess) StartupTimings() StartupTimings {
+	if pp == nil || pp.startup == nil {
+		return StartupTimings{}
+	}
+	pp.startup.mutex.Lock()
+	defer pp.startup.mutex.Unlock()
+	return pp.startup.timings
+}
+
+// SetLogger sets the Logger for this process's diagnostic messages, such as
+// exceptions reported on the status pipe. Nil reverts to the package logger
+// set with SetLogger.
+func (pp *PythonProcess) SetLogger(l Logger) {
+	if pp.logger == nil {
+		pp.logger = &loggerRef{}
+	}
+	pp.logger.set(l)
 }
 
 // Module represents a Python module that can be embedded in a Go binary.
@@ -101,6 +339,28 @@ type Package struct {
 
 	// Packages contains nested subpackages.
 	Packages []Package
+
+	// Hidden lists fully-qualified module names that the importer refuses to
+	// import from code outside this package. Modules within the package can
+	// still import them. Set by NewPackageFromFSWithOptions.
+	Hidden []string
+}
+
+// PackageOptions controls which modules of an embedded package are importable
+// from code outside the package (e.g., the main program or a REPL session).
+//
+// Module paths are dotted Python names relative to the package root, such as
+// "utils" or "internal.helpers". A path also covers every module below it, so
+// "internal" matches "internal.helpers".
+type PackageOptions struct {
+	// Include, if non-empty, lists the only module paths that may be imported from
+	// outside the package. The package itself and the parents of included modules
+	// always remain importable.
+	Include []string
+
+	// Exclude lists module paths that may not be imported from outside the
+	// package. Exclude takes precedence over Include.
+	Exclude []string
 }
 
 // PythonProgram defines a complete Python program to be executed, including
@@ -115,9 +375,14 @@ type PythonProgram struct {
 	// Path is the base path for resolving relative imports.
 	Path string
 
-	// Program is the main module (__main__) to execute.
+	// Program is the main module to execute.
 	Program Module
 
+	// MainModuleName is the module name the program runs under. Empty means
+	// "__main__" (run as a script); any other name imports the program as that
+	// module, so `if __name__ == "__main__"` guards do not fire.
+	MainModuleName string
+
 	// Packages contains Python packages available for import.
 	Packages []Package
 
@@ -139,8 +404,75 @@ type PythonProgram struct {
 	// BreakOnStart, if true with DebugPort set, breaks at the first line of code.
 	BreakOnStart bool
 
-	// KVPairs contains key-value data accessible in Python as jumpboot.<key>.
+	// KVPairs contains key-value data accessible in Python as jumpboot.<key>
+	// or with jumpboot.get_kv(key). Values are sent as JSON, so they must be
+	// JSON-encodable: nil, bools, numbers, strings, and slices, maps and
+	// structs of them. Python receives None, bool, int or float, str, list
+	// and dict; whole floats such as 2.0 arrive as int.
 	KVPairs map[string]interface{}
+
+	// KVSchema, if set, is a JSON Schema that KVPairs must satisfy. The process
+	// is not launched if validation fails; see ValidateKVPairs.
+	KVSchema json.RawMessage `json:"-"`
+
+	// WorkingDir is the directory Python runs in, so relative paths in the
+	// program resolve against it. Empty means the Go process's working
+	// directory. The process is not launched if it isn't a directory.
+	WorkingDir string `json:"-"`
+
+	// InterpreterFlags are extra options for the Python interpreter, such as
+	// "-O", "-X", "utf8" or "-W", "error", placed before the bootstrap's own
+	// "-u -c <script>". Options that would replace or interfere with the
+	// bootstrap, such as -c, -m, -i or -h, are rejected.
+	InterpreterFlags []string `json:"-"`
+}
+
+// interpreterFlagLetters are the short interpreter options that may be used
+// with the bootstrap, alone or combined as in "-OO" or "-Es". Value says
+// whether the option takes a value, either attached ("-Xutf8") or as the
+// next flag.
+var interpreterFlagLetters = map[byte]struct{ value bool }{
+	'b': {}, 'B': {}, 'd': {}, 'E': {}, 'I': {}, 'O': {}, 'P': {}, 'q': {},
+	's': {}, 'S': {}, 'u': {}, 'v': {}, 'R': {},
+	'W': {value: true}, 'X': {value: true},
+}
+
+// checkInterpreterFlags returns an error if flags has an option that isn't
+// in interpreterFlagLetters or a value without its option.
+func checkInterpreterFlags(flags []string) error {
+	for i := 0; i < len(flags); i++ {
+		flag := flags[i]
+		if flag == "--check-hash-based-pycs" {
+			if i+1 == len(flags) {
+				return fmt.Errorf("interpreter flag %q needs a value", flag)
+			}
+			i++
+			continue
+		}
+		if strings.HasPrefix(flag, "--check-hash-based-pycs=") {
+			continue
+		}
+		if len(flag) < 2 || flag[0] != '-' || flag[1] == '-' {
+			return fmt.Errorf("unsupported interpreter flag %q", flag)
+		}
+		for j := 1; j < len(flag); j++ {
+			letter, ok := interpreterFlagLetters[flag[j]]
+			if !ok {
+				return fmt.Errorf("unsupported interpreter flag %q: -%c can't be used with the bootstrap", flag, flag[j])
+			}
+			if letter.value {
+				// the rest of the flag, or the next one, is the value
+				if j+1 == len(flag) {
+					if i+1 == len(flags) {
+						return fmt.Errorf("interpreter flag %q needs a value", flag)
+					}
+					i++
+				}
+				break
+			}
+		}
+	}
+	return nil
 }
 
 // TemplateData holds data for rendering the bootstrap script templates.
@@ -222,13 +554,13 @@ func fsDirHasInitPy(fs embed.FS, path string) bool {
 	return false
 }
 
-func newPackageFromFS(name string, sourcepath string, rootpath string, fs embed.FS) (*Package, error) {
+func newPackageFromFS(name string, sourcepath string, rootpath string, fsys fs.FS) (*Package, error) {
 	retv := &Package{
 		Name: name,
 		Path: rootpath,
 	}
 
-	entries, err := fs.ReadDir(rootpath)
+	entries, err := fs.ReadDir(fsys, rootpath)
 	if err != nil {
 		return nil, err
 	}
@@ -236,14 +568,14 @@ func newPackageFromFS(name string, sourcepath string, rootpath string, fs embed.
 	for _, entry := range entries {
 		fpath := path.Join(rootpath, entry.Name())
 		if entry.IsDir() {
-			subpackage, err := newPackageFromFS(entry.Name(), sourcepath, fpath, fs)
+			subpackage, err := newPackageFromFS(entry.Name(), sourcepath, fpath, fsys)
 			if err != nil {
 				continue
 			}
 			retv.Packages = append(retv.Packages, *subpackage)
 		} else {
 			// Use the fpath directly, which now uses forward slashes
-			file, err := fs.Open(fpath)
+			file, err := fsys.Open(fpath)
 			if err != nil {
 				return nil, err
 			}
@@ -287,18 +619,201 @@ func NewPackageFromFS(name string, sourcepath string, rootpath string, fs embed.
 	return newPackageFromFS(name, sourcepath, rootpath, fs)
 }
 
+// Size limits applied by NewPackageFromTarGz to the uncompressed archive
+// contents, so a corrupt or malicious archive cannot exhaust memory.
+const (
+	// MaxTarGzEntrySize is the largest file NewPackageFromTarGz accepts.
+	MaxTarGzEntrySize = 16 << 20
+
+	// MaxTarGzTotalSize is the largest total size of all files NewPackageFromTarGz accepts.
+	MaxTarGzTotalSize = 256 << 20
+)
+
+// NewPackageFromTarGz creates a Package from a gzipped tarball of Python sources,
+// such as a versioned program bundle fetched from a release artifact. The
+// archive is read entirely into memory and nothing is extracted to disk.
+//
+// If every entry in the archive lives under a single top-level directory
+// (e.g., "mypackage/__init__.py"), that directory is treated as the package
+// root. Modules are collected the same way as NewPackageFromFS: .py files become
+// modules and subdirectories become subpackages. Archives with a file larger
+// than MaxTarGzEntrySize, or more than MaxTarGzTotalSize of files in total, are
+// rejected.
+//
+// Example:
+//
+//	resp, _ := http.Get("https://example.com/releases/mypackage-1.2.0.tar.gz")
+//	defer resp.Body.Close()
+//	pkg, err := jumpboot.NewPackageFromTarGz("mypackage", resp.Body)
+func NewPackageFromTarGz(name string, r io.Reader) (*Package, error) {
+	return newPackageFromTarGz(name, r, MaxTarGzEntrySize, MaxTarGzTotalSize)
+}
+
+func newPackageFromTarGz(name string, r io.Reader, maxEntrySize, maxTotalSize int64) (*Package, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error opening gzip stream: %v", err)
+	}
+	defer gz.Close()
+
+	files := memFS{}
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading tar archive: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Normalize the entry name and reject paths that escape the archive root
+		entryName := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if !fs.ValidPath(entryName) || entryName == "." {
+			return nil, fmt.Errorf("invalid path in tar archive: %s", hdr.Name)
+		}
+
+		// Check the sizes before reading so oversized entries are never buffered
+		if hdr.Size > maxEntrySize {
+			return nil, fmt.Errorf("%s in tar archive is %d bytes, exceeding the %d byte limit", hdr.Name, hdr.Size, maxEntrySize)
+		}
+		total += hdr.Size
+		if total > maxTotalSize {
+			return nil, fmt.Errorf("tar archive contents exceed the %d byte limit", maxTotalSize)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from tar archive: %v", hdr.Name, err)
+		}
+		files[entryName] = data
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("tar archive contains no files")
+	}
+
+	// Use a single top-level directory as the package root
+	rootpath := "."
+	entries, err := files.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		rootpath = entries[0].Name()
+	}
+
+	pkg, err := newPackageFromFS(name, name, rootpath, files)
+	if err != nil {
+		return nil, err
+	}
+	pkg.Path = name
+	return pkg, nil
+}
+
+// NewPackageFromFSWithOptions creates a Package like NewPackageFromFS, but limits
+// which of its modules can be imported from outside the package.
+//
+// Every module is still embedded so the package's own code can import its
+// internal helpers; the Python importer refuses imports of excluded modules
+// made from any other module. This keeps user code from importing bundled
+// internals by name, but it is not a sandbox: objects the package exposes can
+// still reach them.
+//
+// Example:
+//
+//	pkg, err := jumpboot.NewPackageFromFSWithOptions("mypackage", "mypackage", "packages/mypackage", myPackageFS,
+//		jumpboot.PackageOptions{Exclude: []string{"_internal"}})
+func NewPackageFromFSWithOptions(name string, sourcepath string, rootpath string, fs embed.FS, opts PackageOptions) (*Package, error) {
+	pkg, err := newPackageFromFS(name, sourcepath, rootpath, fs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Include) == 0 && len(opts.Exclude) == 0 {
+		return pkg, nil
+	}
+
+	pkg.Hidden = collectHiddenModules(pkg, pkg.Name, "", opts, nil)
+	return pkg, nil
+}
+
+// collectHiddenModules appends the fully-qualified names of the modules and
+// subpackages of pkg that opts hides. qualname is the import name of pkg and
+// relpath its dotted path relative to the root package ("" for the root itself).
+// A hidden subpackage hides everything below it, so its contents are not listed.
+func collectHiddenModules(pkg *Package, qualname string, relpath string, opts PackageOptions, hidden []string) []string {
+	for _, module := range pkg.Modules {
+		base := strings.TrimSuffix(module.Name, ".py")
+		if base == "__init__" {
+			continue
+		}
+		if !moduleVisible(joinModulePath(relpath, base), opts) {
+			hidden = append(hidden, qualname+"."+base)
+		}
+	}
+	for i := range pkg.Packages {
+		sub := &pkg.Packages[i]
+		rel := joinModulePath(relpath, sub.Name)
+		if !moduleVisible(rel, opts) {
+			hidden = append(hidden, qualname+"."+sub.Name)
+			continue
+		}
+		hidden = collectHiddenModules(sub, qualname+"."+sub.Name, rel, opts, hidden)
+	}
+	return hidden
+}
+
+// moduleVisible reports whether the module at the relative dotted path rel may
+// be imported from outside its package.
+func moduleVisible(rel string, opts PackageOptions) bool {
+	for _, ex := range opts.Exclude {
+		if modulePathCovers(ex, rel) {
+			return false
+		}
+	}
+	if len(opts.Include) == 0 {
+		return true
+	}
+	for _, in := range opts.Include {
+		// rel is included itself, lies below an included path, or is a parent of one
+		if modulePathCovers(in, rel) || modulePathCovers(rel, in) {
+			return true
+		}
+	}
+	return false
+}
+
+// modulePathCovers reports whether rel equals parent or lies below it.
+func modulePathCovers(parent string, rel string) bool {
+	parent = strings.TrimSpace(parent)
+	return rel == parent || strings.HasPrefix(rel, parent+".")
+}
+
+// joinModulePath appends name to a dotted module path.
+func joinModulePath(relpath, name string) string {
+	if relpath == "" {
+		return name
+	}
+	return relpath + "." + name
+}
+
 func procTemplate(templateStr string, data interface{}) string {
 	// Parse the template
 	tmpl, err := template.New("pythonTemplate").Parse(templateStr)
 	if err != nil {
-		log.Fatalf("Error parsing template: %v", err)
+		panic(fmt.Sprintf("error parsing template: %v", err))
 	}
 
 	// Execute the template with the data
 	var result bytes.Buffer
 	err = tmpl.Execute(&result, data)
 	if err != nil {
-		log.Fatalf("Error executing template: %v", err)
+		panic(fmt.Sprintf("error executing template: %v", err))
 	}
 
 	return result.String()
@@ -313,6 +828,11 @@ func procTemplate(templateStr string, data interface{}) string {
 //  3. Starts Python with the primary bootstrap script
 //  4. Sends the secondary bootstrap and program data
 //  5. Sets up signal handling for clean shutdown
+//  6. Waits for the bootstrap to start the main program
+//
+// If Python exits before the main program starts, for example because an
+// embedded package fails to import, the error is a *BootstrapError holding
+// the exit code and the end of Python's stderr.
 //
 // Parameters:
 //   - program: The PythonProgram to execute
@@ -323,6 +843,33 @@ func procTemplate(templateStr string, data interface{}) string {
 //
 // Returns the PythonProcess, the JSON-encoded program data, and any error.
 func (env *PythonEnvironment) NewPythonProcessFromProgram(program *PythonProgram, environment_vars map[string]string, extrafiles []*os.File, debug bool, args ...string) (*PythonProcess, []byte, error) {
+	// check KVPairs and interpreter flags before starting anything
+	if err := program.ValidateKVPairs(); err != nil {
+		return nil, nil, err
+	}
+	if err := checkInterpreterFlags(program.InterpreterFlags); err != nil {
+		return nil, nil, err
+	}
+	if program.WorkingDir != "" {
+		info, err := os.Stat(program.WorkingDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid working directory: %v", err)
+		}
+		if !info.IsDir() {
+			return nil, nil, fmt.Errorf("invalid working directory: %s is not a directory", program.WorkingDir)
+		}
+	}
+	// and that the rest of the program data can be sent: once Python has
+	// started, a failure here would leave the bootstrap waiting for it
+	if _, err := json.Marshal(program); err != nil {
+		return nil, nil, fmt.Errorf("error encoding program data: %v", err)
+	}
+
+	// an interpreter that is too old would fail with a SyntaxError in the bootstrap
+	if err := env.checkMinPythonVersion(); err != nil {
+		return nil, nil, err
+	}
+
 	// create the jumpboot package
 	jumpboot_package, err := newPackageFromFS("jumpboot", "jumpboot", "packages/jumpboot", jumpboot_package)
 	if err != nil {
@@ -366,6 +913,7 @@ func (env *PythonEnvironment) NewPythonProcessFromProgram(program *PythonProgram
 
 	// Create the command with the primary bootstrap script
 	cmd := exec.Command(env.PythonPath)
+	cmd.Dir = program.WorkingDir
 
 	// Pass both file descriptors using ExtraFiles
 	// this will return a list of strings with the file descriptors
@@ -378,7 +926,9 @@ func (env *PythonEnvironment) NewPythonProcessFromProgram(program *PythonProgram
 	program.StatusIn, _ = strconv.Atoi(extradescriptors[2])
 	extradescriptors = extradescriptors[3:]
 
-	// At this point, cmd.Args will contain just the python path.  We can now append the "-c" flag and the primary bootstrap script
+	// At this point, cmd.Args will contain just the python path.  We can now append any interpreter flags,
+	// then the "-c" flag and the primary bootstrap script
+	cmd.Args = append(cmd.Args, program.InterpreterFlags...)
 	cmd.Args = append(cmd.Args, "-u", "-c", primaryBootstrapScript)
 
 	// append the count of extra files to the command arguments as a string
@@ -413,21 +963,25 @@ func (env *PythonEnvironment) NewPythonProcessFromProgram(program *PythonProgram
 	// Prepare the program data
 	programData, err := json.Marshal(program)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("error encoding program data: %v", err)
 	}
 
 	// Prepare the status pipe
 	schan := make(chan map[string]interface{}, 1)
 	echan := make(chan *PythonException, 1)
+	startup := &startupRecorder{}
+	logger := &loggerRef{}
+	bootstrapped := make(chan struct{})
+	statusDone := make(chan struct{})
 	go func() {
-		defer status_writer_primary.Close()
+		defer close(statusDone)
 		statusScanner := bufio.NewScanner(status_reader_primary)
 		for statusScanner.Scan() {
 			var status map[string]interface{}
 			text := statusScanner.Text()
 			if err := json.Unmarshal([]byte(text), &status); err != nil {
-				log.Printf("Error decoding status JSON request: %v, data: %s", err, string(text))
-				break
+				logger.get().Errorf("Error decoding status JSON request: %v, data: %s", err, string(text))
+				continue
 			}
 			if status["type"] == "status" {
 				schan <- status
@@ -437,23 +991,44 @@ func (env *PythonEnvironment) NewPythonProcessFromProgram(program *PythonProgram
 			} else if status["type"] == "exception" {
 				exception, err := NewPythonExceptionFromJSON(statusScanner.Bytes())
 				if err != nil {
-					log.Printf("Error decoding Python exception: %v, %s", err, text)
+					logger.get().Errorf("Error decoding Python exception: %v, %s", err, text)
 					continue
 				}
-				log.Printf("Python exception: %s", exception.ToString())
+				logger.get().Warnf("Python exception: %s", exception.ToString())
 				echan <- exception
 				continue
+			} else if status["type"] == "timing" {
+				startup.record(status, logger.get())
+				if _, ok := status["main_start"]; ok {
+					select {
+					case <-bootstrapped:
+					default:
+						close(bootstrapped)
+					}
+				}
 			} else {
-				log.Printf("Unknown status type: %s", text)
+				logger.get().Warnf("Unknown status type: %s", text)
 			}
 		}
 	}()
 
 	// Start the command
+	startup.markLaunch()
 	if err := cmd.Start(); err != nil {
 		return nil, nil, err
 	}
 
+	registerRunningProcess(env.EnvPath, cmd.Process)
+
+	// The child holds its own copies of its pipe ends; close ours so that reads
+	// from Python see EOF once the process exits
+	pipein_writer_primary.Close()
+	pipeout_reader_primary.Close()
+	status_writer_primary.Close()
+
+	stderrTail := &tailBuffer{max: StderrTailSize}
+	stderr := &tailReader{r: stderrPipe, tail: stderrTail}
+
 	// Write the secondary bootstrap script and program data to separate pipes
 	go func() {
 		defer writer_bootstrap.Close()
@@ -463,24 +1038,45 @@ func (env *PythonEnvironment) NewPythonProcessFromProgram(program *PythonProgram
 
 	go func() {
 		defer writer_program.Close()
-		writer_program.Write(programData)
+		if _, err := writer_program.Write(programData); err != nil {
+			logger.get().Errorf("Error writing program data: %v", err)
+		}
 	}()
 
 	pyProcess := &PythonProcess{
 		Cmd:           cmd,
 		Stdin:         stdinPipe,
 		Stdout:        stdoutPipe,
-		Stderr:        stderrPipe,
+		Stderr:        stderr,
 		PipeIn:        pipein_reader_primary,
 		PipeOut:       pipeout_writer_primary,
 		StatusIn:      status_reader_primary,
 		ExceptionChan: echan,
 		StatusChan:    schan,
+		startup:       startup,
+		logger:        logger,
+		stderrTail:    stderrTail,
 	}
 
 	// Set up signal handling
 	setupSignalHandler(pyProcess)
 
+	// Wait for the bootstrap to start the main program. If Python exits
+	// first, the status pipe closes and stderr holds the reason.
+	select {
+	case <-bootstrapped:
+	case <-statusDone:
+		select {
+		case <-bootstrapped:
+		default:
+			// Nobody has read Stderr yet, so all of it is still in the pipe
+			drainStderr(stderr, bootstrapStderrTimeout)
+			pyProcess.Wait()
+			exitCode, _ := pyProcess.ExitCode()
+			return nil, nil, &BootstrapError{ExitCode: exitCode, Stderr: stderrTail.String()}
+		}
+	}
+
 	return pyProcess, programData, nil
 }
 
@@ -555,10 +1151,21 @@ func (env *PythonEnvironment) NewPythonProcessFromString(script string, environm
 	}
 
 	// Start the command
+	launch := time.Now()
 	if err := cmd.Start(); err != nil {
 		return nil, err
 	}
 
+	registerRunningProcess(env.EnvPath, cmd.Process)
+
+	// The child holds its own copies of its pipe ends; close ours so that reads
+	// from Python see EOF once the process exits
+	pipein_writer_primary.Close()
+	pipeout_reader_primary.Close()
+
+	stderrTail := &tailBuffer{max: StderrTailSize}
+	stderr := &tailReader{r: stderrPipe, tail: stderrTail}
+
 	// Write the main script to the pipe
 	go func() {
 		// Close the writer when the function returns
@@ -568,13 +1175,16 @@ func (env *PythonEnvironment) NewPythonProcessFromString(script string, environm
 	}()
 
 	pyProcess := &PythonProcess{
-		Cmd:      cmd,
-		Stdin:    stdinPipe,
-		Stdout:   stdoutPipe,
-		Stderr:   stderrPipe,
-		PipeIn:   pipein_reader_primary,
-		PipeOut:  pipeout_writer_primary,
-		StatusIn: status_reader_primary,
+		Cmd:        cmd,
+		Stdin:      stdinPipe,
+		Stdout:     stdoutPipe,
+		Stderr:     stderr,
+		PipeIn:     pipein_reader_primary,
+		PipeOut:    pipeout_writer_primary,
+		StatusIn:   status_reader_primary,
+		startup:    &startupRecorder{timings: StartupTimings{Launch: launch}},
+		logger:     &loggerRef{},
+		stderrTail: stderrTail,
 	}
 
 	// Set up signal handling
@@ -583,10 +1193,201 @@ func (env *PythonEnvironment) NewPythonProcessFromString(script string, environm
 	return pyProcess, nil
 }
 
+// OutputStream selects one of the Python process's standard output streams.
+type OutputStream int
+
+const (
+	// OutputStdout is the process's standard output.
+	OutputStdout OutputStream = iota
+	// OutputStderr is the process's standard error.
+	OutputStderr
+)
+
+// WaitForOutput reads the chosen output stream line by line until a line
+// matches pattern, and returns that line without its line ending. It is a
+// readiness primitive for programs that signal startup by printing a known
+// line (such as "SERVER READY") rather than using the status pipe.
+//
+// Lines up to and including the match are consumed; output after the match is
+// left unread for other consumers. WaitForOutput returns an error if the
+// stream closes or timeout elapses first. A timeout of zero or less waits
+// indefinitely. After a timeout, the partial line being read is discarded.
+//
+// A positive timeout requires a stream that supports read deadlines so the
+// pending read can be abandoned without leaking a goroutine or consuming
+// later output; this holds for process pipes on Unix but not on Windows,
+// where an error wrapping os.ErrNoDeadline is returned. WaitForOutput cannot
+// be used once the output is forwarded with ForwardOutput, as a QueueProcess
+// does.
+func (pp *PythonProcess) WaitForOutput(pattern *regexp.Regexp, stream OutputStream, timeout time.Duration) (string, error) {
+	if pp == nil {
+		return "", ErrNoLocalProcess
+	}
+	if pp.outputForwarded {
+		return "", fmt.Errorf("output streams are already being forwarded")
+	}
+	var reader io.Reader
+	switch stream {
+	case OutputStdout:
+		reader = pp.Stdout
+	case OutputStderr:
+		reader = pp.Stderr
+	default:
+		return "", fmt.Errorf("unknown output stream: %d", stream)
+	}
+	if reader == nil {
+		return "", fmt.Errorf("output stream is not available")
+	}
+
+	// A timed wait must be able to interrupt the read it leaves behind
+	deadlineReader, hasDeadline := reader.(interface{ SetReadDeadline(time.Time) error })
+	if timeout > 0 {
+		if !hasDeadline {
+			return "", fmt.Errorf("output stream does not support timeouts: %w", os.ErrNoDeadline)
+		}
+		if err := deadlineReader.SetReadDeadline(time.Time{}); err != nil {
+			return "", fmt.Errorf("output stream does not support timeouts: %w", err)
+		}
+	}
+
+	type matchResult struct {
+		line string
+		err  error
+	}
+	results := make(chan matchResult, 1)
+
+	// Read a byte at a time so that nothing past the matching line is consumed
+	go func() {
+		var line []byte
+		buf := make([]byte, 1)
+		for {
+			n, err := reader.Read(buf)
+			if n > 0 {
+				if buf[0] != '\n' {
+					line = append(line, buf[0])
+					continue
+				}
+				text := strings.TrimSuffix(string(line), "\r")
+				line = line[:0]
+				if pattern.MatchString(text) {
+					results <- matchResult{line: text}
+					return
+				}
+			}
+			if err != nil {
+				// A final line without a newline can still match
+				if text := string(line); len(line) > 0 && pattern.MatchString(text) {
+					results <- matchResult{line: text}
+					return
+				}
+				results <- matchResult{err: err}
+				return
+			}
+		}
+	}()
+
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	select {
+	case result := <-results:
+		if result.err != nil {
+			return "", fmt.Errorf("output closed before a line matched %q: %v", pattern, result.err)
+		}
+		return result.line, nil
+	case <-timeoutChan:
+		// Interrupt the pending read and wait for the reader to stop
+		deadlineReader.SetReadDeadline(time.Now())
+		result := <-results
+		deadlineReader.SetReadDeadline(time.Time{})
+		if result.err == nil {
+			// The match arrived as the timeout fired
+			return result.line, nil
+		}
+		return "", fmt.Errorf("timeout waiting for output matching %q", pattern)
+	}
+}
+
+// ProcessIO says where a process's output goes. A nil Stdout or Stderr means
+// os.Stdout or os.Stderr; use io.Discard to drop a stream. If both are the
+// same writer, it must be safe for concurrent use.
+type ProcessIO struct {
+	// Stdout receives what Python writes to standard output.
+	Stdout io.Writer
+
+	// Stderr receives what Python writes to standard error.
+	Stderr io.Writer
+}
+
+// ForwardOutput copies the process's standard output and error to the
+// writers in pio until the process exits. Nothing else may read Stdout or
+// Stderr afterwards. A QueueProcess forwards its output itself; set
+// QueueOptions.IO to choose the writers.
+func (pp *PythonProcess) ForwardOutput(pio ProcessIO) error {
+	if pp == nil {
+		return ErrNoLocalProcess
+	}
+	if pp.outputForwarded {
+		return fmt.Errorf("output streams are already being forwarded")
+	}
+	stdout, stderr := pio.Stdout, pio.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	pp.outputForwarded = true
+	pp.outputDone = make(chan struct{})
+
+	var wg sync.WaitGroup
+	forward := func(w io.Writer, r io.Reader) {
+		defer wg.Done()
+		if r != nil {
+			io.Copy(w, r)
+		}
+	}
+	wg.Add(2)
+	go forward(stdout, pp.Stdout)
+	go forward(stderr, pp.Stderr)
+	go func() {
+		wg.Wait()
+		close(pp.outputDone)
+	}()
+	return nil
+}
+
+// waitForwardedOutput waits up to timeout for the goroutines started by
+// ForwardOutput to copy the rest of the output, which they do once Python
+// exits, and reports whether they finished.
+func (pp *PythonProcess) waitForwardedOutput(timeout time.Duration) bool {
+	if pp.outputDone == nil {
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-pp.outputDone:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
 // Wait blocks until the Python process exits.
 // Returns an error if the process was killed or exited with a non-zero status.
 func (pp *PythonProcess) Wait() error {
+	if pp == nil {
+		return ErrNoLocalProcess
+	}
 	err := pp.Cmd.Wait()
+	pp.recordExit(pp.Cmd)
+	unregisterRunningProcess(pp.Cmd.Process)
+	forgetSignalHandler(pp)
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			if exitErr.ExitCode() == -1 {
@@ -599,16 +1400,43 @@ func (pp *PythonProcess) Wait() error {
 	return nil
 }
 
+// DefaultTerminateGrace is how long Terminate waits for the process to exit
+// after SIGTERM before killing it.
+const DefaultTerminateGrace = 5 * time.Second
+
 // Terminate gracefully stops the Python process by sending SIGTERM.
-// If the process doesn't exit within 5 seconds, it is forcefully killed with SIGKILL.
-// Returns nil if the process wasn't running or has already finished.
+// If the process doesn't exit within DefaultTerminateGrace, it is forcefully
+// killed with SIGKILL. See TerminateWithTimeout.
 func (pp *PythonProcess) Terminate() error {
+	return pp.TerminateWithTimeout(DefaultTerminateGrace)
+}
+
+// TerminateWithTimeout sends SIGTERM and waits up to grace for the process to
+// exit before killing it with SIGKILL. It returns as soon as the process
+// exits. A grace of zero or less kills the process immediately.
+// On Windows, which has no SIGTERM, the process and the processes it started
+// are killed at once with taskkill /T.
+// Returns nil if the process wasn't running or has already finished.
+func (pp *PythonProcess) TerminateWithTimeout(grace time.Duration) error {
+	if pp == nil {
+		return ErrNoLocalProcess
+	}
 	if pp.Cmd.Process == nil {
 		return nil // Process hasn't started or has already finished
 	}
 
+	// A suspended process can't handle SIGTERM until it runs again
+	if pp.suspended.Load() {
+		pp.Resume()
+	}
+
 	// Try to terminate gracefully first
-	err := pp.Cmd.Process.Signal(syscall.SIGTERM)
+	var err error
+	if grace > 0 {
+		err = terminateProcess(pp.Cmd.Process)
+	} else {
+		err = pp.Cmd.Process.Kill()
+	}
 	if err != nil {
 		return err
 	}
@@ -616,32 +1444,140 @@ func (pp *PythonProcess) Terminate() error {
 	// Wait for the process to exit
 	done := make(chan error, 1)
 	go func() {
-		done <- pp.Cmd.Wait()
+		err := pp.Cmd.Wait()
+		pp.recordExit(pp.Cmd)
+		unregisterRunningProcess(pp.Cmd.Process)
+		forgetSignalHandler(pp)
+		done <- err
 	}()
+	if grace <= 0 {
+		<-done // Wait for the process to be killed
+		return nil
+	}
 
-	// Wait for the process to exit or force kill after timeout
+	// Wait for the process to exit or force kill after the grace period
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
 	select {
-	case <-time.After(5 * time.Second):
-		// Force kill if it doesn't exit within 5 seconds
+	case <-timer.C:
 		err = pp.Cmd.Process.Kill()
 		if err != nil {
 			return err
 		}
 		<-done // Wait for the process to be killed
 	case err = <-done:
-		// Process exited before timeout
+		// Process exited before the grace period ended
 	}
 
 	return err
 }
 
+// Suspend pauses the Python process without terminating it. A suspended
+// process uses no CPU while its memory, open files and pipes are preserved;
+// call Resume to continue it. On Unix this sends SIGSTOP; on Windows all of
+// the process's threads are suspended.
+//
+// Calls to a QueueProcess on a suspended process block until it is resumed or
+// the call times out.
+func (pp *PythonProcess) Suspend() error {
+	if pp == nil {
+		return ErrNoLocalProcess
+	}
+	if pp.Cmd.Process == nil {
+		return errors.New("process has not been started")
+	}
+	if pp.suspended.Load() {
+		return nil
+	}
+	if err := suspendProcess(pp.Cmd.Process); err != nil {
+		return fmt.Errorf("error suspending process: %v", err)
+	}
+	pp.suspended.Store(true)
+	return nil
+}
+
+// Resume continues a process paused with Suspend. It does nothing if the
+// process is not suspended.
+func (pp *PythonProcess) Resume() error {
+	if pp == nil {
+		return ErrNoLocalProcess
+	}
+	if pp.Cmd.Process == nil {
+		return errors.New("process has not been started")
+	}
+	if !pp.suspended.Load() {
+		return nil
+	}
+	if err := resumeProcess(pp.Cmd.Process); err != nil {
+		return fmt.Errorf("error resuming process: %v", err)
+	}
+	pp.suspended.Store(false)
+	return nil
+}
+
+// Suspended reports whether the process is paused by Suspend.
+func (pp *PythonProcess) Suspended() bool {
+	return pp != nil && pp.suspended.Load()
+}
+
+// signalProcesses holds the live processes that are terminated when the Go
+// program receives SIGINT or SIGTERM. A single goroutine, started with the
+// first process, watches for the signals on behalf of all of them.
+var (
+	signalProcessesMutex sync.Mutex
+	signalProcesses      = make(map[*PythonProcess]struct{})
+	signalWatcherOnce    sync.Once
+)
+
+// setupSignalHandler registers pp to be terminated on SIGINT or SIGTERM.
+// Processes that have exited without being waited on are pruned first, so the
+// registry stays bounded by the number of live processes.
 func setupSignalHandler(pp *PythonProcess) {
-	signalChan := make(chan os.Signal, 1)
-	setSignalsForChannel(signalChan)
+	signalWatcherOnce.Do(func() {
+		signalChan := make(chan os.Signal, 1)
+		setSignalsForChannel(signalChan)
 
-	go func() {
-		<-signalChan
-		// Terminate the child process when a signal is received
-		pp.Terminate()
-	}()
+		go func() {
+			for range signalChan {
+				terminateSignalProcesses()
+			}
+		}()
+	})
+
+	signalProcessesMutex.Lock()
+	defer signalProcessesMutex.Unlock()
+	for p := range signalProcesses {
+		if !processAlive(p.Cmd.Process) {
+			delete(signalProcesses, p)
+		}
+	}
+	signalProcesses[pp] = struct{}{}
+}
+
+// forgetSignalHandler removes pp from the signal registry once it has exited.
+func forgetSignalHandler(pp *PythonProcess) {
+	signalProcessesMutex.Lock()
+	defer signalProcessesMutex.Unlock()
+	delete(signalProcesses, pp)
+}
+
+// terminateSignalProcesses terminates every registered process, in parallel
+// so that a process that is slow to exit does not delay the others.
+func terminateSignalProcesses() {
+	signalProcessesMutex.Lock()
+	processes := make([]*PythonProcess, 0, len(signalProcesses))
+	for pp := range signalProcesses {
+		processes = append(processes, pp)
+	}
+	signalProcessesMutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, pp := range processes {
+		wg.Add(1)
+		go func(pp *PythonProcess) {
+			defer wg.Done()
+			pp.Terminate()
+		}(pp)
+	}
+	wg.Wait()
 }
@@ -0,0 +1,728 @@
+package jumpboot
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// buildTarGz returns a gzipped tarball holding files, in the given order.
+func buildTarGz(t *testing.T, files [][2]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		hdr := &tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader failed: %v", err)
+		}
+		if _, err := tw.Write([]byte(file[1])); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("closing tar writer failed: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("closing gzip writer failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestNewPackageFromTarGz tests that a tarball with a single top-level
+// directory becomes a package with its modules and subpackages, skipping
+// non-Python files.
+func TestNewPackageFromTarGz(t *testing.T) {
+	archive := buildTarGz(t, [][2]string{
+		{"./mypkg/__init__.py", "VERSION = '1.0'\n"},
+		{"./mypkg/core.py", "def run():\n    pass\n"},
+		{"./mypkg/README.md", "# mypkg\n"},
+		{"./mypkg/sub/__init__.py", ""},
+		{"./mypkg/sub/util.py", "X = 1\n"},
+	})
+
+	pkg, err := NewPackageFromTarGz("mypkg", bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("NewPackageFromTarGz failed: %v", err)
+	}
+	if pkg.Name != "mypkg" || pkg.Path != "mypkg" {
+		t.Errorf("got package %q at %q, want mypkg at mypkg", pkg.Name, pkg.Path)
+	}
+
+	moduleNames := func(p Package) []string {
+		var names []string
+		for _, m := range p.Modules {
+			names = append(names, m.Name+"@"+m.Path)
+		}
+		return names
+	}
+	if got := strings.Join(moduleNames(*pkg), ","); got != "__init__.py@mypkg/__init__.py,core.py@mypkg/core.py" {
+		t.Errorf("unexpected modules: %s", got)
+	}
+	if len(pkg.Packages) != 1 || pkg.Packages[0].Name != "sub" {
+		t.Fatalf("expected one subpackage named sub, got %+v", pkg.Packages)
+	}
+	if got := strings.Join(moduleNames(pkg.Packages[0]), ","); got != "__init__.py@mypkg/sub/__init__.py,util.py@mypkg/sub/util.py" {
+		t.Errorf("unexpected subpackage modules: %s", got)
+	}
+}
+
+// TestNewPackageFromTarGz_Limits tests that oversized entries and archives,
+// and paths escaping the archive root, are rejected.
+func TestNewPackageFromTarGz_Limits(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   [][2]string
+		wantErr string
+	}{
+		{"entry too large", [][2]string{{"big.py", strings.Repeat("x", 101)}}, "exceeding the 100 byte limit"},
+		{"total too large", [][2]string{{"a.py", strings.Repeat("x", 80)}, {"b.py", strings.Repeat("x", 80)}}, "exceed the 150 byte limit"},
+		{"escaping path", [][2]string{{"../evil.py", "x = 1"}}, "invalid path"},
+		{"empty", nil, "contains no files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPackageFromTarGz("pkg", bytes.NewReader(buildTarGz(t, tt.files)), 100, 150)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// hiddenPkgFS holds hiddenpkg, whose api module imports the _internal module
+// and the tools.private subpackage module. all: keeps the underscore files.
+//
+//go:embed all:testdata/hiddenpkg
+var hiddenPkgFS embed.FS
+
+// TestPackageOptionsHidden tests that Include and Exclude paths resolve to
+// the fully-qualified names of nested modules and subpackages.
+func TestPackageOptionsHidden(t *testing.T) {
+	tests := []struct {
+		opts PackageOptions
+		want []string
+	}{
+		{PackageOptions{Exclude: []string{"_internal", "tools.private"}}, []string{"hiddenpkg._internal", "hiddenpkg.tools.private"}},
+		// A hidden subpackage hides its contents without listing them
+		{PackageOptions{Exclude: []string{"tools"}}, []string{"hiddenpkg.tools"}},
+		// Parents of included modules stay importable
+		{PackageOptions{Include: []string{"tools.public"}}, []string{"hiddenpkg._internal", "hiddenpkg.api", "hiddenpkg.tools.private"}},
+		// Exclude takes precedence over Include
+		{PackageOptions{Include: []string{"api", "tools"}, Exclude: []string{"tools.private"}}, []string{"hiddenpkg._internal", "hiddenpkg.tools.private"}},
+		// Paths only cover whole module names
+		{PackageOptions{Exclude: []string{"tool", "ap"}}, nil},
+	}
+	for _, tt := range tests {
+		pkg, err := NewPackageFromFSWithOptions("hiddenpkg", "hiddenpkg", "testdata/hiddenpkg", hiddenPkgFS, tt.opts)
+		if err != nil {
+			t.Fatalf("NewPackageFromFSWithOptions failed: %v", err)
+		}
+		got := append([]string(nil), pkg.Hidden...)
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%+v: Hidden = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+}
+
+// TestPackageOptionsImportGuard tests that hidden modules can't be imported
+// from the main program or a REPL, while the package's own modules can.
+func TestPackageOptionsImportGuard(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	pkg, err := NewPackageFromFSWithOptions("hiddenpkg", "hiddenpkg", "testdata/hiddenpkg", hiddenPkgFS,
+		PackageOptions{Exclude: []string{"_internal", "tools.private"}})
+	if err != nil {
+		t.Fatalf("NewPackageFromFSWithOptions failed: %v", err)
+	}
+
+	script := `
+import importlib
+import hiddenpkg
+print("reveal", hiddenpkg.api.reveal())
+from hiddenpkg.tools import public
+print("public", public.hello())
+for name in ["hiddenpkg._internal", "hiddenpkg.tools.private"]:
+    try:
+        importlib.import_module(name)
+        print("imported", name)
+    except ImportError:
+        print("blocked", name)
+try:
+    from hiddenpkg import _internal
+    print("imported from")
+except ImportError:
+    print("blocked from")
+`
+	program := &PythonProgram{
+		Name:     "guard",
+		Program:  Module{Name: "__main__", Path: "main.py", Source: base64.StdEncoding.EncodeToString([]byte(script))},
+		Packages: []Package{*pkg},
+	}
+	pp, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false)
+	if err != nil {
+		t.Fatalf("NewPythonProcessFromProgram failed: %v", err)
+	}
+	out, _ := io.ReadAll(pp.Stdout)
+	pp.Wait()
+	want := "reveal secret helper\npublic hello\nblocked hiddenpkg._internal\nblocked hiddenpkg.tools.private\nblocked from"
+	if got := strings.TrimSpace(string(out)); got != want {
+		t.Errorf("program output = %q, want %q", got, want)
+	}
+
+	repl, err := env.NewREPLPythonProcess(nil, nil, nil, []Package{*pkg})
+	if err != nil {
+		t.Fatalf("NewREPLPythonProcess failed: %v", err)
+	}
+	defer repl.Close()
+	if out, err := repl.Execute("import hiddenpkg\nprint(hiddenpkg.api.reveal())", true); err != nil || out != "secret helper" {
+		t.Errorf("using the package from the REPL gave %q, %v", out, err)
+	}
+	// ModuleNotFoundError is the ImportError subclass the guard raises
+	for _, code := range []string{"import hiddenpkg._internal", "from hiddenpkg.tools import private"} {
+		if _, err := repl.Execute(code, true); err == nil || !strings.HasPrefix(err.Error(), "ModuleNotFoundError") {
+			t.Errorf("%s from the REPL: got %v, want a ModuleNotFoundError", code, err)
+		}
+	}
+	if out, err := repl.Execute("print(hiddenpkg.api.reveal())", true); err != nil || out != "secret helper" {
+		t.Errorf("the package stopped working after the blocked imports: %q, %v", out, err)
+	}
+}
+
+// TestMemFS tests memFS against the fs.FS contract.
+func TestMemFS(t *testing.T) {
+	files := memFS{
+		"pkg/__init__.py":     []byte(""),
+		"pkg/sub/__init__.py": []byte("x = 1"),
+		"top.py":              []byte("y = 2"),
+	}
+	if err := fstest.TestFS(files, "pkg/__init__.py", "pkg/sub/__init__.py", "top.py"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestWaitForOutput tests matching, a timeout that leaves later output
+// unread, and rejecting timeouts on streams without read deadlines.
+func TestWaitForOutput(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe failed: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	pp := &PythonProcess{Stdout: r}
+
+	w.Write([]byte("loading\r\nSERVER READY on 8080\nnext\n"))
+	line, err := pp.WaitForOutput(regexp.MustCompile(`READY on \d+`), OutputStdout, 5*time.Second)
+	if err != nil || line != "SERVER READY on 8080" {
+		t.Fatalf("expected the ready line, got %q, %v", line, err)
+	}
+
+	// The timed-out wait consumes the pending line but nothing written afterwards
+	if _, err := pp.WaitForOutput(regexp.MustCompile("never"), OutputStdout, 50*time.Millisecond); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	w.Write([]byte("after\n"))
+	line, err = pp.WaitForOutput(regexp.MustCompile(""), OutputStdout, 5*time.Second)
+	if err != nil || line != "after" {
+		t.Errorf("expected the line written after the timeout, got %q, %v", line, err)
+	}
+
+	// Readers without deadlines can only wait indefinitely
+	pp = &PythonProcess{Stdout: io.NopCloser(strings.NewReader("ready\n"))}
+	if _, err := pp.WaitForOutput(regexp.MustCompile("ready"), OutputStdout, time.Second); !errors.Is(err, os.ErrNoDeadline) {
+		t.Errorf("expected os.ErrNoDeadline, got %v", err)
+	}
+	if line, err := pp.WaitForOutput(regexp.MustCompile("ready"), OutputStdout, 0); err != nil || line != "ready" {
+		t.Errorf("expected an untimed wait to match, got %q, %v", line, err)
+	}
+
+	// A QueueProcess already forwards the streams
+	pp = &PythonProcess{Stdout: r, outputForwarded: true}
+	if _, err := pp.WaitForOutput(regexp.MustCompile("ready"), OutputStdout, 0); err == nil {
+		t.Error("expected an error for forwarded output")
+	}
+}
+
+// TestMinPythonVersion tests that launching on a Python older than
+// MinPythonVersion fails with a clear error before Python is started.
+func TestMinPythonVersion(t *testing.T) {
+	env := &PythonEnvironment{PythonVersion: Version{Major: 3, Minor: 6, Patch: 15}}
+	env.PythonPath = "/nonexistent/python3.6"
+	program := &PythonProgram{Name: "old", Program: Module{Name: "__main__"}}
+
+	_, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false)
+	if err == nil || !strings.Contains(err.Error(), "requires Python >= 3.7") {
+		t.Fatalf("expected a minimum version error, got %v", err)
+	}
+	if len(program.Packages) != 0 {
+		t.Errorf("expected the check to run before the program is modified")
+	}
+
+	for _, v := range []Version{{Major: 3, Minor: 7, Patch: -1}, {Major: 3, Minor: 7, Patch: 0}, {Major: 3, Minor: 12, Patch: 1}, {}} {
+		env.PythonVersion = v
+		if err := env.checkMinPythonVersion(); err != nil {
+			t.Errorf("Python %v: unexpected error %v", v, err)
+		}
+	}
+}
+
+// TestSignalRegistry tests that processes share one signal registry that
+// forgets them when they exit and terminates every live one on a signal.
+func TestSignalRegistry(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+
+	registered := func(pp *PythonProcess) bool {
+		signalProcessesMutex.Lock()
+		defer signalProcessesMutex.Unlock()
+		_, ok := signalProcesses[pp]
+		return ok
+	}
+
+	var repls []*REPLPythonProcess
+	for i := 0; i < 3; i++ {
+		repl, err := env.NewREPLPythonProcess(nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("NewREPLPythonProcess failed: %v", err)
+		}
+		defer repl.Close()
+		if !registered(repl.PythonProcess) {
+			t.Fatalf("REPL %d is not registered for signals", i)
+		}
+		repls = append(repls, repl)
+	}
+
+	// Closing one REPL leaves the others running and registered
+	repls[0].Close()
+	if registered(repls[0].PythonProcess) {
+		t.Errorf("expected the closed REPL to be forgotten")
+	}
+	if out, err := repls[1].Execute("print('alive')", true); err != nil || out != "alive" {
+		t.Fatalf("expected the other REPLs to keep running, got %q, %v", out, err)
+	}
+
+	terminateSignalProcesses()
+	for i, repl := range repls[1:] {
+		if registered(repl.PythonProcess) {
+			t.Errorf("REPL %d is still registered after termination", i+1)
+		}
+		if processAlive(repl.Cmd.Process) {
+			t.Errorf("REPL %d is still running after termination", i+1)
+		}
+	}
+}
+
+// TestUnencodableKVPairs tests that a KVPairs value JSON can't encode is
+// reported by NewPythonProcessFromProgram before anything is launched.
+func TestUnencodableKVPairs(t *testing.T) {
+	// The check comes first, so an empty environment is never used
+	env := &PythonEnvironment{}
+	program := &PythonProgram{
+		Name:    "unencodable",
+		Program: Module{Name: "__main__", Path: "main.py"},
+		KVPairs: map[string]interface{}{"port": 8080, "events": make(chan string)},
+	}
+	_, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false)
+	if err == nil || !strings.Contains(err.Error(), `KVPairs["events"]`) {
+		t.Fatalf("error = %v, want one naming the events key", err)
+	}
+	if len(program.Packages) != 0 {
+		t.Error("the program was modified before the KVPairs were checked")
+	}
+}
+
+// TestBootstrapError tests that a program whose embedded package fails to
+// import is reported as a BootstrapError with Python's traceback, instead of
+// a process that fails later.
+func TestBootstrapError(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	program := &PythonProgram{
+		Name: "broken",
+		Program: Module{
+			Name:   "__main__",
+			Path:   "main.py",
+			Source: base64.StdEncoding.EncodeToString([]byte("print('unreachable')")),
+		},
+		Packages: []Package{{
+			Name: "broken",
+			Path: "broken",
+			Modules: []Module{{
+				Name:   "__init__.py",
+				Path:   "broken/__init__.py",
+				Source: base64.StdEncoding.EncodeToString([]byte("import jumpboot_missing_module")),
+			}},
+		}},
+	}
+
+	_, _, err = env.NewPythonProcessFromProgram(program, nil, nil, false)
+	var bootErr *BootstrapError
+	if !errors.As(err, &bootErr) {
+		t.Fatalf("expected a BootstrapError, got %v", err)
+	}
+	if bootErr.ExitCode == 0 {
+		t.Error("expected a non-zero exit code")
+	}
+	if !strings.Contains(bootErr.Stderr, "jumpboot_missing_module") || !strings.Contains(err.Error(), "ModuleNotFoundError") {
+		t.Errorf("expected the traceback in the error, got %v", err)
+	}
+
+	// A working program starts, and its exit code is available after Wait
+	program = &PythonProgram{
+		Name: "exits",
+		Program: Module{
+			Name:   "__main__",
+			Path:   "main.py",
+			Source: base64.StdEncoding.EncodeToString([]byte("import sys\nprint('bye', file=sys.stderr)\nsys.exit(3)")),
+		},
+	}
+	pp, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false)
+	if err != nil {
+		t.Fatalf("NewPythonProcessFromProgram failed: %v", err)
+	}
+	if _, exited := pp.ExitCode(); exited {
+		t.Error("ExitCode reported an exit before Wait")
+	}
+	// Stderr still supports timed waits
+	if line, err := pp.WaitForOutput(regexp.MustCompile("bye"), OutputStderr, 5*time.Second); err != nil || line != "bye" {
+		t.Errorf("WaitForOutput on stderr = %q, %v", line, err)
+	}
+	pp.Wait()
+	if code, exited := pp.ExitCode(); !exited || code != 3 {
+		t.Errorf("ExitCode() = %d, %v; want 3, true", code, exited)
+	}
+	if tail := pp.StderrTail(); !strings.Contains(tail, "bye") {
+		t.Errorf("StderrTail() = %q", tail)
+	}
+}
+
+// TestInterpreterFlags tests that InterpreterFlags reach Python and that
+// flags which would break the bootstrap are rejected.
+func TestInterpreterFlags(t *testing.T) {
+	valid := [][]string{
+		nil,
+		{"-O"},
+		{"-OO", "-B"},
+		{"-X", "utf8", "-Xdev"},
+		{"-W", "error", "-Wignore::DeprecationWarning"},
+		{"--check-hash-based-pycs", "never"},
+	}
+	for _, flags := range valid {
+		if err := checkInterpreterFlags(flags); err != nil {
+			t.Errorf("checkInterpreterFlags(%q) = %v", flags, err)
+		}
+	}
+	invalid := [][]string{
+		{"-c", "print(1)"},
+		{"-Oc"},
+		{"-m", "http.server"},
+		{"-i"},
+		{"-h"},
+		{"--version"},
+		{"script.py"},
+		{"-"},
+		{"-X"},
+	}
+	for _, flags := range invalid {
+		if err := checkInterpreterFlags(flags); err == nil {
+			t.Errorf("checkInterpreterFlags(%q) accepted flags that break the bootstrap", flags)
+		}
+	}
+
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	program := &PythonProgram{
+		Name: "flags",
+		Program: Module{
+			Name:   "__main__",
+			Path:   "main.py",
+			Source: base64.StdEncoding.EncodeToString([]byte("import sys\nprint(sys.flags.optimize, sys.flags.utf8_mode)")),
+		},
+		InterpreterFlags: []string{"-O", "-X", "utf8"},
+	}
+	pp, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false)
+	if err != nil {
+		t.Fatalf("NewPythonProcessFromProgram failed: %v", err)
+	}
+	out, _ := io.ReadAll(pp.Stdout)
+	pp.Wait()
+	if got := strings.TrimSpace(string(out)); got != "1 1" {
+		t.Errorf("sys.flags.optimize, utf8_mode = %q, want \"1 1\"", got)
+	}
+}
+
+// TestWorkingDir tests that Python runs in PythonProgram.WorkingDir and that
+// a missing directory is reported before launch.
+func TestWorkingDir(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), []byte("found"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	program := &PythonProgram{
+		Name: "workdir",
+		Program: Module{
+			Name:   "__main__",
+			Path:   "main.py",
+			Source: base64.StdEncoding.EncodeToString([]byte("print(open('data.txt').read())")),
+		},
+		WorkingDir: dir,
+	}
+	pp, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false)
+	if err != nil {
+		t.Fatalf("NewPythonProcessFromProgram failed: %v", err)
+	}
+	out, _ := io.ReadAll(pp.Stdout)
+	pp.Wait()
+	if got := strings.TrimSpace(string(out)); got != "found" {
+		t.Errorf("reading a relative path gave %q", got)
+	}
+
+	program.WorkingDir = filepath.Join(dir, "missing")
+	if _, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false); err == nil || !strings.Contains(err.Error(), "invalid working directory") {
+		t.Errorf("error = %v, want an invalid working directory error", err)
+	}
+}
+
+// TestMainModuleName tests that a program runs as __main__ by default and
+// under MainModuleName when one is given, without firing __main__ guards.
+func TestMainModuleName(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	script := "print(__name__)\nif __name__ == '__main__':\n    print('guarded')\n"
+	for _, tt := range []struct {
+		mainModuleName string
+		want           string
+	}{
+		{"", "__main__\nguarded\n"},
+		{"myapp", "myapp\n"},
+	} {
+		program := &PythonProgram{
+			Name:           "mainname",
+			Program:        Module{Name: "__main__", Path: "main.py", Source: base64.StdEncoding.EncodeToString([]byte(script))},
+			MainModuleName: tt.mainModuleName,
+		}
+		pp, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false)
+		if err != nil {
+			t.Fatalf("NewPythonProcessFromProgram failed: %v", err)
+		}
+		out, _ := io.ReadAll(pp.Stdout)
+		pp.Wait()
+		if got := strings.ReplaceAll(string(out), "\r\n", "\n"); got != tt.want {
+			t.Errorf("MainModuleName %q: output = %q, want %q", tt.mainModuleName, got, tt.want)
+		}
+	}
+}
+
+// TestTerminateWithTimeout tests that a process ignoring SIGTERM is killed once
+// the grace period ends, and that one that exits is not waited on for longer.
+func TestTerminateWithTimeout(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	start := func(script string) *PythonProcess {
+		t.Helper()
+		program := &PythonProgram{
+			Name:    "terminate",
+			Program: Module{Name: "__main__", Path: "main.py", Source: base64.StdEncoding.EncodeToString([]byte(script))},
+		}
+		pp, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false)
+		if err != nil {
+			t.Fatalf("NewPythonProcessFromProgram failed: %v", err)
+		}
+		t.Cleanup(func() { pp.TerminateWithTimeout(0) })
+		line, err := bufio.NewReader(pp.Stdout).ReadString('\n')
+		if err != nil || line != "ready\n" {
+			t.Fatalf("expected the ready line, got %q, %v", line, err)
+		}
+		return pp
+	}
+
+	stubborn := start("import signal, time\nsignal.signal(signal.SIGTERM, signal.SIG_IGN)\nprint('ready', flush=True)\ntime.sleep(60)")
+	begin := time.Now()
+	if err := stubborn.TerminateWithTimeout(300 * time.Millisecond); err != nil {
+		t.Errorf("TerminateWithTimeout failed: %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 3*time.Second {
+		t.Errorf("killing the process took %v", elapsed)
+	}
+	if _, exited := stubborn.ExitCode(); !exited {
+		t.Error("the process is still running")
+	}
+
+	willing := start("import time\nprint('ready', flush=True)\ntime.sleep(60)")
+	begin = time.Now()
+	willing.TerminateWithTimeout(time.Minute)
+	if elapsed := time.Since(begin); elapsed > 3*time.Second {
+		t.Errorf("a process that exits on SIGTERM took %v to stop", elapsed)
+	}
+}
+
+// TestSuspendResume tests that a suspended process stops producing output and
+// picks up again once resumed, and that Terminate resumes a suspended process
+// so it can handle SIGTERM.
+func TestSuspendResume(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("checks SIGSTOP/SIGCONT delivery")
+	}
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	program := &PythonProgram{
+		Name:    "suspend",
+		Program: Module{Name: "__main__", Path: "main.py", Source: base64.StdEncoding.EncodeToString([]byte("import time\ni = 0\nwhile True:\n    print(i, flush=True)\n    i += 1\n    time.sleep(0.02)"))},
+	}
+	pp, _, err := env.NewPythonProcessFromProgram(program, nil, nil, false)
+	if err != nil {
+		t.Fatalf("NewPythonProcessFromProgram failed: %v", err)
+	}
+	t.Cleanup(func() { pp.TerminateWithTimeout(0) })
+
+	lines := make(chan string, 1024)
+	go func() {
+		scanner := bufio.NewScanner(pp.Stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	waitLine := func(within time.Duration) bool {
+		select {
+		case _, ok := <-lines:
+			return ok
+		case <-time.After(within):
+			return false
+		}
+	}
+	if !waitLine(5 * time.Second) {
+		t.Fatal("no output before suspending")
+	}
+
+	if err := pp.Suspend(); err != nil {
+		t.Fatalf("Suspend failed: %v", err)
+	}
+	if !pp.Suspended() {
+		t.Error("Suspended() = false after Suspend")
+	}
+	// Let lines already in the pipe drain, then expect silence.
+	for drained := 0; drained < 50 && waitLine(200*time.Millisecond); drained++ {
+	}
+	if waitLine(500 * time.Millisecond) {
+		t.Error("got output while suspended")
+	}
+
+	if err := pp.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if pp.Suspended() {
+		t.Error("Suspended() = true after Resume")
+	}
+	if !waitLine(2 * time.Second) {
+		t.Error("no output after resuming")
+	}
+
+	// A suspended process can only act on SIGTERM once Terminate resumes it.
+	if err := pp.Suspend(); err != nil {
+		t.Fatalf("Suspend failed: %v", err)
+	}
+	begin := time.Now()
+	if err := pp.TerminateWithTimeout(10 * time.Second); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			t.Errorf("Terminate failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(begin); elapsed > 3*time.Second {
+		t.Errorf("terminating a suspended process took %v", elapsed)
+	}
+	if pp.Suspended() {
+		t.Error("Suspended() = true after Terminate")
+	}
+	if _, exited := pp.ExitCode(); !exited {
+		t.Error("the process is still running")
+	}
+}
+
+// TestPipesAndExtraFiles tests that a message can be exchanged over the
+// primary pipes and that an extra file is inherited. On Windows this checks
+// that the handles are inherited and can be opened by the bootstrap.
+func TestPipesAndExtraFiles(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	extraReader, extraWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe failed: %v", err)
+	}
+	defer extraReader.Close()
+
+	source := "import jumpboot\n" +
+		"line = jumpboot.Pipe_in.readline()\n" +
+		"jumpboot.Pipe_out.write(line.upper())\n" +
+		"jumpboot.Pipe_out.flush()\n" +
+		"with jumpboot.open_extra_file(0, 'w') as f:\n" +
+		"    f.write('extra')\n"
+	program := &PythonProgram{
+		Name: "pipes",
+		Program: Module{
+			Name:   "__main__",
+			Path:   "main.py",
+			Source: base64.StdEncoding.EncodeToString([]byte(source)),
+		},
+	}
+	pp, _, err := env.NewPythonProcessFromProgram(program, nil, []*os.File{extraWriter}, false)
+	extraWriter.Close()
+	if err != nil {
+		t.Fatalf("NewPythonProcessFromProgram failed: %v", err)
+	}
+	defer pp.Terminate()
+	go io.Copy(io.Discard, pp.Stdout)
+	go io.Copy(io.Discard, pp.Stderr)
+
+	if _, err := pp.PipeOut.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("writing to Python failed: %v", err)
+	}
+	reply, err := bufio.NewReader(pp.PipeIn).ReadString('\n')
+	if err != nil || strings.TrimSpace(reply) != "HELLO" {
+		t.Errorf("reply = %q, %v; want \"HELLO\"", reply, err)
+	}
+	extra, err := io.ReadAll(extraReader)
+	if err != nil || string(extra) != "extra" {
+		t.Errorf("extra file = %q, %v; want \"extra\"", extra, err)
+	}
+	pp.Wait()
+}
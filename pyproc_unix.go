@@ -18,6 +18,11 @@ func setSignalsForChannel(c chan os.Signal) {
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 }
 
+// terminateProcess asks the process to exit with SIGTERM.
+func terminateProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGTERM)
+}
+
 // waitForExit waits for a command to exit and returns an appropriate error.
 func waitForExit(cmd *exec.Cmd) error {
 	err := cmd.Wait()
@@ -46,3 +51,24 @@ func setExtraFiles(cmd *exec.Cmd, extraFiles []*os.File) []string {
 	}
 	return retv
 }
+
+// processAlive reports whether a process is still running. A process that has
+// exited but not yet been waited on is reported as running.
+func processAlive(process *os.Process) bool {
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// suspendProcess stops a process with SIGSTOP.
+func suspendProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGSTOP)
+}
+
+// resumeProcess continues a stopped process with SIGCONT.
+func resumeProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGCONT)
+}
+
+// interruptProcess raises KeyboardInterrupt in a Python process with SIGINT.
+func interruptProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGINT)
+}
@@ -10,6 +10,8 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
@@ -17,6 +19,27 @@ func setSignalsForChannel(c chan os.Signal) {
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 }
 
+// terminateProcess kills the process and every process it started. Windows
+// has no SIGTERM to send a console process in another process group, and
+// killing only Python would leave the processes it started running.
+func terminateProcess(process *os.Process) error {
+	cmd := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(process.Pid))
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	// taskkill fails if the process has already exited
+	if !processAlive(process) {
+		return nil
+	}
+	// Without taskkill, at least kill the process itself
+	if killErr := process.Kill(); killErr != nil {
+		return fmt.Errorf("taskkill failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 func waitForExit(cmd *exec.Cmd) error {
 	err := cmd.Wait()
 	if err != nil {
@@ -31,23 +54,88 @@ func waitForExit(cmd *exec.Cmd) error {
 	return nil
 }
 
-// return the file descriptors as numerical strings
+// setExtraFiles makes the extra files inheritable by the command and returns
+// their handles as numerical strings. A child process inherits a handle with
+// the same value, so the bootstrap (and jumpboot.open_extra_file) can turn
+// each number into a file descriptor with msvcrt.open_osfhandle.
 func setExtraFiles(cmd *exec.Cmd, extraFiles []*os.File) []string {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	// Hide the console window
+	cmd.SysProcAttr.HideWindow = true
+	// Only the handles listed in AdditionalInheritedHandles (and stdio) are
+	// inherited, but they must be inheritable themselves
+	cmd.SysProcAttr.NoInheritHandles = false
+
 	retv := make([]string, len(extraFiles))
-	var handles []syscall.Handle
 	for i, f := range extraFiles {
-		handles = append(handles, syscall.Handle(f.Fd()))
-		retv[i] = fmt.Sprintf("%d", f.Fd())
+		handle := syscall.Handle(f.Fd())
+		// pipes from os.Pipe are inheritable, but files opened with os.Open
+		// or os.Create are not
+		if err := syscall.SetHandleInformation(handle, syscall.HANDLE_FLAG_INHERIT, syscall.HANDLE_FLAG_INHERIT); err != nil {
+			getPackageLogger().Warnf("error making %s inheritable: %v", f.Name(), err)
+		}
+		cmd.SysProcAttr.AdditionalInheritedHandles = append(cmd.SysProcAttr.AdditionalInheritedHandles, handle)
+		retv[i] = strconv.FormatUint(uint64(handle), 10)
+	}
+	return retv
+}
+
+// processAlive reports whether a process is still running.
+func processAlive(process *os.Process) bool {
+	const stillActive = 259
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(process.Pid))
+	if err != nil {
+		return false
 	}
+	defer syscall.CloseHandle(handle)
 
-	// Pass the handle to the child process
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		// Hide the console window
-		HideWindow: true,
-		// Inherit handles
-		NoInheritHandles: false,
-		// Pass the handle to the child process
-		AdditionalInheritedHandles: handles,
+	var exitCode uint32
+	if err := syscall.GetExitCodeProcess(handle, &exitCode); err != nil {
+		return false
 	}
-	return retv
+	return exitCode == stillActive
+}
+
+// ntdll exports NtSuspendProcess and NtResumeProcess, which suspend and resume
+// every thread of a process in one call.
+var (
+	ntdll                = syscall.NewLazyDLL("ntdll.dll")
+	procNtSuspendProcess = ntdll.NewProc("NtSuspendProcess")
+	procNtResumeProcess  = ntdll.NewProc("NtResumeProcess")
+)
+
+// processSuspendResume is the access right needed to suspend or resume a process.
+const processSuspendResume = 0x0800
+
+// callProcessProc opens the process and calls an ntdll function on its handle.
+func callProcessProc(process *os.Process, proc *syscall.LazyProc) error {
+	handle, err := syscall.OpenProcess(processSuspendResume, false, uint32(process.Pid))
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(handle)
+
+	status, _, _ := proc.Call(uintptr(handle))
+	if status != 0 {
+		return fmt.Errorf("%s failed with NTSTATUS 0x%x", proc.Name, status)
+	}
+	return nil
+}
+
+// suspendProcess suspends all threads of a process.
+func suspendProcess(process *os.Process) error {
+	return callProcessProc(process, procNtSuspendProcess)
+}
+
+// resumeProcess resumes all threads of a suspended process.
+func resumeProcess(process *os.Process) error {
+	return callProcessProc(process, procNtResumeProcess)
+}
+
+// interruptProcess is not supported on Windows, where a console control event
+// cannot be delivered to a single child process.
+func interruptProcess(process *os.Process) error {
+	return errors.New("interrupting a process is not supported on Windows")
 }
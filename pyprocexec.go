@@ -2,12 +2,16 @@ package jumpboot
 
 import (
 	"bufio"
+	"bytes"
 	_ "embed"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // ExecOptions specifies a command to send to PythonExecProcess.
@@ -17,6 +21,13 @@ type ExecOptions struct {
 
 	// Command is the Python code to execute (for "exec" type).
 	Command string `json:"code"`
+
+	// Argv replaces sys.argv while the code runs, if set.
+	Argv []string `json:"argv,omitempty"`
+
+	// Filename runs the code as a script with this file name, in a fresh
+	// namespace where __name__ is "__main__", if set.
+	Filename string `json:"filename,omitempty"`
 }
 
 // ExecResult contains the response from PythonExecProcess.
@@ -24,8 +35,18 @@ type ExecResult struct {
 	// ReturnType is "output" for success or "error" for exceptions.
 	ReturnType string `json:"type"`
 
-	// Output contains the result or error message.
+	// Output contains the result or error message. On success it is the
+	// code's stdout and stderr combined in the order they were written.
 	Output string `json:"output"`
+
+	// Stdout is what the code wrote to sys.stdout, including before an exception.
+	Stdout string `json:"stdout"`
+
+	// Stderr is what the code wrote to sys.stderr, such as warnings.
+	Stderr string `json:"stderr"`
+
+	// Exception is the exception the code raised, or nil if it completed.
+	Exception *PythonException `json:"exception,omitempty"`
 }
 
 //go:embed modules/pyprocexec/main.py
@@ -36,11 +57,20 @@ var pythonExecMain string
 // This is simpler than QueueProcess but lacks bidirectional RPC capabilities.
 type PythonExecProcess struct {
 	*PythonProcess
+
+	// reader buffers responses from PipeIn across Exec calls, so bytes read
+	// ahead of one response are not lost to the next
+	reader *bufio.Reader
 }
 
 // NewPythonExecProcess creates a Python process for simple command execution.
 // Commands are sent as JSON and results are received as JSON responses.
-func (env *PythonEnvironment) NewPythonExecProcess(environment_vars map[string]string, extrafiles []*os.File) (*PythonExecProcess, error) {
+//
+// Parameters:
+//   - kvpairs: Key-value data accessible in Python as jumpboot.<key>; may be nil
+//   - environment_vars: Additional environment variables; may be nil
+//   - extrafiles: Additional file handles to pass to Python; may be nil
+func (env *PythonEnvironment) NewPythonExecProcess(kvpairs map[string]interface{}, environment_vars map[string]string, extrafiles []*os.File) (*PythonExecProcess, error) {
 	cwd, _ := os.Getwd()
 	program := &PythonProgram{
 		Name: "PythonExecProcess",
@@ -52,21 +82,148 @@ func (env *PythonEnvironment) NewPythonExecProcess(environment_vars map[string]s
 		},
 		Modules:  []Module{},
 		Packages: []Package{},
+		KVPairs:  kvpairs,
+		// run where Path says the program is
+		WorkingDir: cwd,
 	}
 
-	pyProcess, _, err := env.NewPythonProcessFromProgram(program, environment_vars, nil, false)
+	pyProcess, _, err := env.NewPythonProcessFromProgram(program, environment_vars, extrafiles, false)
 	if err != nil {
 		return nil, err
 	}
 
 	return &PythonExecProcess{
 		PythonProcess: pyProcess,
+		reader:        bufio.NewReader(pyProcess.PipeIn),
 	}, nil
 }
 
 // Exec sends Python code for execution and returns the output.
 // Returns an error if the code raised an exception or communication failed.
 func (p *PythonExecProcess) Exec(code string) (string, error) {
+	if err := p.sendExec(code); err != nil {
+		return "", err
+	}
+	return p.readResult()
+}
+
+// ExecDetailed runs Python code like Exec, but returns the full result, with
+// stdout and stderr kept apart and any exception the code raised in
+// result.Exception. The error is only set if communication failed, so a
+// Python exception is not an error here.
+func (p *PythonExecProcess) ExecDetailed(code string) (ExecResult, error) {
+	if err := p.sendExec(code); err != nil {
+		return ExecResult{}, err
+	}
+	return p.readExecResult()
+}
+
+// ExecFile runs the Python script at path like "python path args...":
+// sys.argv is set to the path followed by args, so scripts using argparse
+// work, and the script runs in a fresh namespace as __main__. The script's
+// output is returned as with Exec; a script that calls sys.exit with a
+// non-zero status returns an error.
+//
+// The file is streamed to Python as it is read rather than loaded into memory
+// first.
+func (p *PythonExecProcess) ExecFile(path string, args []string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening script: %v", err)
+	}
+	defer f.Close()
+
+	header, err := json.Marshal(ExecOptions{
+		ExecType: "exec",
+		Argv:     append([]string{path}, args...),
+		Filename: path,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Send the header without its empty "code" field and closing brace, then
+	// the file as the code
+	header = bytes.Replace(header, []byte(`"code":"",`), nil, 1)
+	w := bufio.NewWriter(p.PipeOut)
+	w.Write(header[:len(header)-1])
+	w.WriteString(`,"code":`)
+	if err := writeJSONString(w, f); err != nil {
+		return "", fmt.Errorf("error sending script: %v", err)
+	}
+	w.WriteString("}\n")
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	return p.readResult()
+}
+
+// writeJSONString copies r to w as a quoted JSON string. Bytes of multi-byte
+// UTF-8 sequences are copied unchanged, so r can be escaped a byte at a time.
+func writeJSONString(w *bufio.Writer, r io.Reader) error {
+	const hex = "0123456789abcdef"
+	br := bufio.NewReader(r)
+	w.WriteByte('"')
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case b == '"' || b == '\\':
+			w.WriteByte('\\')
+			w.WriteByte(b)
+		case b == '\n':
+			w.WriteString(`\n`)
+		case b == '\r':
+			w.WriteString(`\r`)
+		case b == '\t':
+			w.WriteString(`\t`)
+		case b < 0x20:
+			w.WriteString(`\u00`)
+			w.WriteByte(hex[b>>4])
+			w.WriteByte(hex[b&0xf])
+		default:
+			w.WriteByte(b)
+		}
+	}
+	return w.WriteByte('"')
+}
+
+// ExecWithTimeout runs Python code like Exec, but fails if no result has
+// arrived within timeout. The Python code can't be stopped safely once it is
+// running, so on timeout the Python process is terminated and later calls
+// fail.
+func (p *PythonExecProcess) ExecWithTimeout(code string, timeout time.Duration) (string, error) {
+	if err := p.sendExec(code); err != nil {
+		return "", err
+	}
+
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := p.readResult()
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-time.After(timeout):
+		p.Terminate()
+		return "", fmt.Errorf("execution timed out - Python process terminated")
+	}
+}
+
+// sendExec sends an "exec" command for code to the Python process.
+func (p *PythonExecProcess) sendExec(code string) error {
 	e := ExecOptions{
 		ExecType: "exec",
 		Command:  code,
@@ -75,33 +232,44 @@ func (p *PythonExecProcess) Exec(code string) (string, error) {
 	// encode the command to JSON
 	cmd_json, err := json.Marshal(e)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	// send the command to the Python process
 	_, err = p.PipeOut.Write([]byte(string(cmd_json) + "\n"))
+	return err
+}
+
+// readResult reads the response to an "exec" command and returns its output,
+// or its error message as an error.
+func (p *PythonExecProcess) readResult() (string, error) {
+	result, err := p.readExecResult()
 	if err != nil {
 		return "", err
 	}
 
+	if result.ReturnType == "error" {
+		return "", errors.New(result.Output)
+	} else {
+		return result.Output, nil
+	}
+}
+
+// readExecResult reads and decodes the response to an "exec" command.
+func (p *PythonExecProcess) readExecResult() (ExecResult, error) {
 	// read the output from the Python process
-	b, err := bufio.NewReader(p.PipeIn).ReadBytes('\n')
+	b, err := p.reader.ReadBytes('\n')
 	if err != nil {
-		return "", err
+		return ExecResult{}, err
 	}
 
 	// decode the output from JSON
 	var result ExecResult
 	err = json.Unmarshal(b, &result)
 	if err != nil {
-		return "", err
-	}
-
-	if result.ReturnType == "error" {
-		return "", errors.New(result.Output)
-	} else {
-		return result.Output, nil
+		return ExecResult{}, err
 	}
+	return result, nil
 }
 
 // Close sends an exit command to terminate the Python process.
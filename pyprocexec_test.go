@@ -0,0 +1,206 @@
+package jumpboot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestExecProcess starts a PythonExecProcess on the system Python, skipping
+// the test if there is none.
+func newTestExecProcess(t *testing.T) *PythonExecProcess {
+	t.Helper()
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	p, err := env.NewPythonExecProcess(nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewPythonExecProcess failed: %v", err)
+	}
+	t.Cleanup(func() { p.Terminate() })
+	return p
+}
+
+// TestExecProcessKVPairs tests that KVPairs given to NewPythonExecProcess
+// reach Python as jumpboot attributes and through jumpboot.get_kv.
+func TestExecProcessKVPairs(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	p, err := env.NewPythonExecProcess(map[string]interface{}{
+		"name":  "worker",
+		"count": 3,
+		"ratio": 2.0,
+		"flag":  true,
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewPythonExecProcess failed: %v", err)
+	}
+	defer p.Terminate()
+
+	code := `import jumpboot
+print(jumpboot.name, jumpboot.get_kv("count", int), repr(jumpboot.get_kv("ratio", float)), jumpboot.get_kv("missing", default="none"))
+for key, typ in (("name", int), ("flag", int), ("missing", None)):
+    try:
+        jumpboot.get_kv(key, typ)
+    except (TypeError, KeyError) as e:
+        print(type(e).__name__)`
+	out, err := p.Exec(code)
+	if err != nil {
+		t.Fatalf("Exec failed: %v", err)
+	}
+	if want := "worker 3 2.0 none\nTypeError\nTypeError\nKeyError\n"; out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	// Values that can't be encoded are rejected before Python starts
+	_, err = env.NewPythonExecProcess(map[string]interface{}{"callback": func() {}}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), `KVPairs["callback"]`) {
+		t.Errorf("error = %v, want one naming the callback key", err)
+	}
+}
+
+// TestExecWithTimeout tests that ExecWithTimeout returns results in time and
+// gives up on code that never finishes.
+func TestExecWithTimeout(t *testing.T) {
+	p := newTestExecProcess(t)
+
+	out, err := p.ExecWithTimeout("print(6 * 7)", 10*time.Second)
+	if err != nil || out != "42\n" {
+		t.Fatalf("ExecWithTimeout = %q, %v", out, err)
+	}
+
+	start := time.Now()
+	_, err = p.ExecWithTimeout("while True: pass", 500*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 7*time.Second {
+		t.Errorf("timeout took %v", elapsed)
+	}
+}
+
+// TestExecManyCalls is a regression test for responses being lost between Exec
+// calls: every response of a rapid series of calls of varying output length
+// must belong to its own call.
+func TestExecManyCalls(t *testing.T) {
+	p := newTestExecProcess(t)
+
+	for i := 0; i < 200; i++ {
+		n := (i * 37) % 9000 // past bufio's 4096-byte buffer for some calls
+		out, err := p.Exec(fmt.Sprintf("print('%d:' + 'x' * %d)", i, n))
+		if err != nil {
+			t.Fatalf("Exec %d failed: %v", i, err)
+		}
+		if want := fmt.Sprintf("%d:%s\n", i, strings.Repeat("x", n)); out != want {
+			t.Fatalf("Exec %d returned %d bytes starting %q, want %d bytes", i, len(out), out[:min(len(out), 20)], len(want))
+		}
+	}
+}
+
+// TestExecDetailed tests that ExecDetailed keeps stdout and stderr apart and
+// reports exceptions in the result, while Exec still combines the output.
+func TestExecDetailed(t *testing.T) {
+	p := newTestExecProcess(t)
+
+	code := "import sys\nprint('result')\nprint('warning', file=sys.stderr)\nprint('more')"
+	result, err := p.ExecDetailed(code)
+	if err != nil {
+		t.Fatalf("ExecDetailed failed: %v", err)
+	}
+	if result.ReturnType != "output" || result.Stdout != "result\nmore\n" || result.Stderr != "warning\n" || result.Exception != nil {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if out, err := p.Exec(code); err != nil || out != "result\nwarning\nmore\n" {
+		t.Errorf("Exec = %q, %v; want the combined output", out, err)
+	}
+
+	result, err = p.ExecDetailed("print('partial')\ntry:\n    {}['k']\nexcept KeyError as e:\n    raise ValueError('bad', 7) from e")
+	if err != nil {
+		t.Fatalf("ExecDetailed failed: %v", err)
+	}
+	ex := result.Exception
+	if result.ReturnType != "error" || result.Stdout != "partial\n" || ex == nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if ex.Exception != "ValueError" || len(ex.ExceptionArgs) != 2 || !strings.Contains(ex.Traceback, "ValueError") {
+		t.Errorf("unexpected exception %+v", ex)
+	}
+	if ex.Cause == nil || ex.Cause.Exception != "KeyError" {
+		t.Errorf("expected the KeyError cause, got %+v", ex.Cause)
+	}
+	if _, err := p.Exec("1 / 0"); err == nil || err.Error() != "division by zero" {
+		t.Errorf("expected Exec to return the exception message, got %v", err)
+	}
+}
+
+// TestExecFile tests that ExecFile runs a script as __main__ with its own
+// argv and reports its exit status.
+func TestExecFile(t *testing.T) {
+	p := newTestExecProcess(t)
+
+	script := filepath.Join(t.TempDir(), "greet.py")
+	source := `import argparse
+import sys
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("name")
+    parser.add_argument("--shout", action="store_true")
+    args = parser.parse_args()
+    greeting = "hello \"%s\"\té" % args.name
+    print(greeting.upper() if args.shout else greeting)
+    if args.name == "fail":
+        sys.exit(3)
+
+if __name__ == "__main__":
+    main()
+`
+	if err := os.WriteFile(script, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := p.ExecFile(script, []string{"world", "--shout"})
+	if err != nil || out != "HELLO \"WORLD\"\tÉ\n" {
+		t.Fatalf("ExecFile = %q, %v", out, err)
+	}
+	if _, err := p.ExecFile(script, []string{"fail"}); err == nil || !strings.Contains(err.Error(), "status 3") {
+		t.Errorf("expected the exit status as an error, got %v", err)
+	}
+
+	// argv is restored and the process stays usable
+	if out, err := p.Exec("import sys\nprint(len(sys.argv) > 0 and sys.argv[-1] != 'fail')"); err != nil || out != "True\n" {
+		t.Errorf("Exec after ExecFile = %q, %v", out, err)
+	}
+	if _, err := p.ExecFile(filepath.Join(t.TempDir(), "missing.py"), nil); err == nil {
+		t.Errorf("expected an error for a missing script")
+	}
+}
+
+// TestWriteJSONString tests that writeJSONString produces the same string
+// encoding/json decodes.
+func TestWriteJSONString(t *testing.T) {
+	in := "quote \" backslash \\ newline \n tab \t bell \x07 utf8 é世 \U0001F600"
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if err := writeJSONString(w, strings.NewReader(in)); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	var out string
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if out != in {
+		t.Errorf("got %q, want %q", out, in)
+	}
+}
@@ -0,0 +1,137 @@
+package jumpboot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StderrTailSize is the number of bytes of a Python process's most recent
+// standard error output kept for StderrTail and BootstrapError.
+const StderrTailSize = 16 << 10
+
+// bootstrapStderrTimeout bounds the wait for a failed bootstrap's stderr to
+// reach EOF.
+const bootstrapStderrTimeout = 2 * time.Second
+
+// BootstrapError is returned by NewPythonProcessFromProgram when Python exits
+// before the bootstrap has started the main program, for example because an
+// embedded package failed to import.
+type BootstrapError struct {
+	// ExitCode is the process's exit code, or -1 if it was killed by a signal.
+	ExitCode int
+
+	// Stderr is the end of what the process wrote to standard error, which
+	// normally holds the Python traceback.
+	Stderr string
+}
+
+func (e *BootstrapError) Error() string {
+	msg := fmt.Sprintf("python exited with code %d during bootstrap", e.ExitCode)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ":\n" + stderr
+	}
+	return msg
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mutex sync.Mutex
+	data  []byte
+	max   int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.data = append(b.data, p...)
+	if over := len(b.data) - b.max; over > 0 {
+		b.data = append(b.data[:0], b.data[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return string(b.data)
+}
+
+// tailReader is a process's Stderr: it records what is read through it in a
+// tailBuffer.
+type tailReader struct {
+	r    io.ReadCloser
+	tail *tailBuffer
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.tail.Write(p[:n])
+	}
+	return n, err
+}
+
+func (t *tailReader) Close() error {
+	return t.r.Close()
+}
+
+// SetReadDeadline passes the deadline to the pipe so WaitForOutput can time
+// out reads from Stderr.
+func (t *tailReader) SetReadDeadline(deadline time.Time) error {
+	if d, ok := t.r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return d.SetReadDeadline(deadline)
+	}
+	return os.ErrNoDeadline
+}
+
+// drainStderr reads the rest of stderr into its tail, giving up after
+// timeout in case a process Python started still holds the pipe open.
+func drainStderr(stderr io.Reader, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, stderr)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// StderrTail returns the last StderrTailSize bytes the process wrote to
+// standard error. Output is captured as it is read from Stderr, so it is
+// only complete if something reads Stderr, as a QueueProcess does.
+func (pp *PythonProcess) StderrTail() string {
+	if pp == nil || pp.stderrTail == nil {
+		return ""
+	}
+	return pp.stderrTail.String()
+}
+
+// ExitCode returns the process's exit code and true once Wait or Terminate
+// has seen it exit, or (0, false) while it is running. The code is -1 if
+// the process was killed by a signal.
+func (pp *PythonProcess) ExitCode() (int, bool) {
+	if pp == nil {
+		return 0, false
+	}
+	pp.exitMutex.Lock()
+	defer pp.exitMutex.Unlock()
+	return pp.exitCode, pp.exited
+}
+
+// recordExit saves the exit code after cmd.Wait has returned.
+func (pp *PythonProcess) recordExit(cmd *exec.Cmd) {
+	if cmd.ProcessState == nil {
+		return
+	}
+	pp.exitMutex.Lock()
+	defer pp.exitMutex.Unlock()
+	pp.exitCode = cmd.ProcessState.ExitCode()
+	pp.exited = true
+}
@@ -1,12 +1,16 @@
 package jumpboot
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"net"
 	"os"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -65,8 +69,68 @@ type QueueProcess struct {
 	// running indicates whether the message loop is active
 	running bool
 
+	// closing is set once Close or CloseGraceful has begun; new commands from
+	// Python are refused from then on
+	closing bool
+
 	// processingWg tracks in-flight command handlers
 	processingWg sync.WaitGroup
+
+	// orderedQueues holds the FIFO queues of handlers registered with RegisterHandlerOrdered
+	orderedQueues map[string]*orderedQueue
+
+	// asyncResponses holds the response channels of requests sent with SendCommandAsync
+	// until they are collected by AwaitResponse
+	asyncResponses map[string]chan map[string]interface{}
+
+	// subscriptions maps event topics to the channels registered with Subscribe
+	subscriptions map[string][]chan interface{}
+
+	// eventsClosed is set once the message loop exits and subscriptions are closed
+	eventsClosed bool
+
+	// loopDone is closed when the message loop exits
+	loopDone chan struct{}
+
+	// lastActivity is the time the message loop last received a message
+	lastActivity time.Time
+
+	// lastPingRTT is the round-trip time of the last successful Ping
+	lastPingRTT time.Duration
+
+	// idleTimeout is QueueOptions.IdleTimeout; zero disables idle shutdown
+	idleTimeout time.Duration
+
+	// idleSince is the time of the last frame sent to or received from Python
+	idleSince time.Time
+
+	// idleTimer fires idleShutdown once idleTimeout may have passed
+	idleTimer *time.Timer
+
+	// onIdleShutdown is QueueOptions.OnIdleShutdown
+	onIdleShutdown func(*QueueProcess)
+
+	// logger receives the queue's diagnostic messages (see SetLogger)
+	logger loggerRef
+}
+
+// eventBufferSize is the capacity of each Subscribe channel. Events arriving
+// while a subscriber's buffer is full are dropped for that subscriber.
+const eventBufferSize = 64
+
+// orderedQueue serializes the invocations of a handler registered with
+// RegisterHandlerOrdered. Commands are queued in arrival order and drained by
+// at most one goroutine at a time.
+type orderedQueue struct {
+	mutex   sync.Mutex
+	pending []orderedCommand
+	active  bool
+}
+
+// orderedCommand is a command from Python waiting in an orderedQueue.
+type orderedCommand struct {
+	data      interface{}
+	requestID string
 }
 
 // CommandHandler is a function that handles commands received from Python.
@@ -82,6 +146,7 @@ type methodCall struct {
 	data          map[string]interface{}
 	timeout       time.Duration
 	reflectResult bool
+	strict        bool
 }
 
 // RegisterHandler registers a Go function to handle a specific command from Python.
@@ -91,6 +156,54 @@ func (jq *QueueProcess) RegisterHandler(command string, handler CommandHandler)
 	jq.mutex.Lock()
 	defer jq.mutex.Unlock()
 	jq.commandHandlers[command] = handler
+	delete(jq.orderedQueues, command)
+}
+
+// RegisterHandlerOrdered registers a handler like RegisterHandler, but invokes it
+// for one command at a time in the order the commands arrived from Python.
+// Use this for stateful handlers that need strict FIFO processing. Handlers for
+// other commands still run concurrently.
+func (jq *QueueProcess) RegisterHandlerOrdered(command string, handler CommandHandler) {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
+	jq.commandHandlers[command] = handler
+	if jq.orderedQueues == nil {
+		jq.orderedQueues = make(map[string]*orderedQueue)
+	}
+	if _, exists := jq.orderedQueues[command]; !exists {
+		jq.orderedQueues[command] = &orderedQueue{}
+	}
+}
+
+// dispatchOrdered queues a command for an ordered handler and starts a worker
+// to drain the queue if one isn't already running. The caller has already
+// added the command to processingWg.
+func (jq *QueueProcess) dispatchOrdered(queue *orderedQueue, command string, data interface{}, requestID string) {
+	queue.mutex.Lock()
+	queue.pending = append(queue.pending, orderedCommand{data: data, requestID: requestID})
+	if queue.active {
+		queue.mutex.Unlock()
+		return
+	}
+	queue.active = true
+	queue.mutex.Unlock()
+
+	go func() {
+		for {
+			queue.mutex.Lock()
+			if len(queue.pending) == 0 {
+				queue.active = false
+				queue.mutex.Unlock()
+				return
+			}
+			next := queue.pending[0]
+			queue.pending = queue.pending[1:]
+			queue.mutex.Unlock()
+
+			jq.processCommand(command, next.data, next.requestID)
+			jq.processingWg.Done()
+		}
+	}()
 }
 
 // SetDefaultHandler sets a fallback handler for commands without a specific handler.
@@ -124,6 +237,11 @@ type ParameterInfo struct {
 
 	// Type is the type annotation (if available).
 	Type string `json:"type,omitempty"`
+
+	// Kind is the Python parameter kind: "POSITIONAL_ONLY",
+	// "POSITIONAL_OR_KEYWORD", "VAR_POSITIONAL", "KEYWORD_ONLY" or
+	// "VAR_KEYWORD". It is empty if the server doesn't report it.
+	Kind string `json:"kind,omitempty"`
 }
 
 // NewQueueProcess creates a Python process with bidirectional RPC communication.
@@ -131,126 +249,424 @@ type ParameterInfo struct {
 // Parameters:
 //   - program: The PythonProgram to execute (should implement the queue protocol)
 //   - serviceStruct: Optional Go struct whose exported methods become command handlers.
-//     Reflection is used to register each method automatically.
+//     Reflection is used to register each method automatically; see registerService
+//     for the supported method signatures.
 //   - environment_vars: Additional environment variables for the process
 //   - extrafiles: Additional file handles to pass to Python
 //
 // The function starts the message loop automatically and discovers Python methods
-// via introspection. Python stdout/stderr are forwarded to Go's os.Stdout/os.Stderr.
+// via introspection. Python stdout/stderr are forwarded to Go's os.Stdout/os.Stderr;
+// use NewQueueProcessWithOptions with QueueOptions.IO to send them elsewhere.
 func (env *PythonEnvironment) NewQueueProcess(program *PythonProgram, serviceStruct interface{}, environment_vars map[string]string, extrafiles []*os.File) (*QueueProcess, error) {
+	return env.NewQueueProcessWithOptions(program, serviceStruct, environment_vars, extrafiles, QueueOptions{})
+}
+
+// RunOnce starts a QueueProcess for program, calls method with args, and closes
+// the process, returning the call's result. It is a shortcut for one-shot
+// scripts that would otherwise be mostly create/call/close boilerplate.
+//
+// The process is terminated whether or not the call succeeds. A timeout of
+// zero or less waits indefinitely for the call; it does not cover starting
+// Python.
+func (env *PythonEnvironment) RunOnce(program *PythonProgram, method string, args interface{}, timeout time.Duration) (interface{}, error) {
+	queue, err := env.NewQueueProcess(program, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Close terminates the process even if the call panics
+	defer queue.Close()
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return queue.CallContext(ctx, method, args)
+}
+
+// QueueOptions configures optional QueueProcess behavior.
+type QueueOptions struct {
+	// RecordTo, if set, receives a recording of every frame sent to and
+	// received from Python, as JSON lines. The recording can be replayed
+	// without Python using NewReplayTransport and NewQueueProcessOverTransport.
+	RecordTo io.Writer
+
+	// Serializer encodes and decodes messages. Nil uses MsgpackSerializer.
+	// The Python side must use a matching serializer: JSONSerializer is
+	// selected automatically, while a custom codec must be passed to
+	// MessagePackQueueServer(serializer=...) by the program.
+	Serializer Serializer
+
+	// NewTransport creates the transport from the pipes to and from Python.
+	// Nil uses NewMsgpackTransport, whose length-prefixed framing is what the
+	// Python server expects.
+	NewTransport func(reader io.ReadCloser, writer io.WriteCloser) Transport
+
+	// CompressMinSize, if greater than zero, gzip-compresses frames of at
+	// least this many bytes in both directions using CompressingTransport.
+	// The Python server enables its matching transport automatically.
+	CompressMinSize int
+
+	// Logger, if set, receives the queue's diagnostic messages instead of the
+	// package logger. It applies from the start, including method discovery.
+	Logger Logger
+
+	// IO sets where Python's stdout and stderr are copied. The default is
+	// os.Stdout and os.Stderr.
+	IO ProcessIO
+
+	// IdleTimeout, if greater than zero, closes the queue with CloseGraceful
+	// once no frame has been sent to or received from Python for this long,
+	// so an unused worker doesn't hold on to its memory. The queue isn't idle
+	// while a request is waiting for Python's response. Periodic Pings count
+	// as activity and keep the queue open.
+	IdleTimeout time.Duration
+
+	// OnIdleShutdown, if set, is called just before an idle queue is closed;
+	// the queue is still open, so the callback can call Python to save its
+	// state.
+	OnIdleShutdown func(queue *QueueProcess)
+}
+
+// queueSerializerEnvVar tells the Python queue server which serializer to use.
+const queueSerializerEnvVar = "JUMPBOOT_QUEUE_SERIALIZER"
+
+// NewQueueProcessWithOptions is like NewQueueProcess but accepts QueueOptions.
+func (env *PythonEnvironment) NewQueueProcessWithOptions(program *PythonProgram, serviceStruct interface{}, environment_vars map[string]string, extrafiles []*os.File, opts QueueOptions) (*QueueProcess, error) {
+	serializer := opts.Serializer
+	if serializer == nil {
+		serializer = MsgpackSerializer{}
+	}
+	_, useJSON := serializer.(JSONSerializer)
+	if useJSON || opts.CompressMinSize > 0 {
+		vars := make(map[string]string, len(environment_vars)+2)
+		for key, value := range environment_vars {
+			vars[key] = value
+		}
+		if useJSON {
+			vars[queueSerializerEnvVar] = "json"
+		}
+		if opts.CompressMinSize > 0 {
+			vars[queueCompressionEnvVar] = strconv.Itoa(opts.CompressMinSize)
+		}
+		environment_vars = vars
+	}
+
 	pyProcess, _, err := env.NewPythonProcessFromProgram(program, environment_vars, extrafiles, false)
 	if err != nil {
 		return nil, err
 	}
 
-	// Goroutine to read Python's stdout
-	go func() {
-		io.Copy(os.Stdout, pyProcess.Stdout)
-	}()
+	// Forward Python's output; these goroutines are the streams' only readers
+	pyProcess.ForwardOutput(opts.IO)
 
-	// Goroutine to read Python's stderr
-	go func() {
-		io.Copy(os.Stderr, pyProcess.Stderr)
-	}()
+	var transport Transport
+	if opts.NewTransport != nil {
+		transport = opts.NewTransport(pyProcess.PipeIn, pyProcess.PipeOut)
+	} else {
+		transport = NewMsgpackTransport(pyProcess.PipeIn, pyProcess.PipeOut)
+	}
+	if opts.CompressMinSize > 0 {
+		transport = NewCompressingTransport(transport, opts.CompressMinSize)
+	}
+	if opts.RecordTo != nil {
+		transport = NewRecordingTransport(transport, opts.RecordTo)
+	}
 
 	jq := &QueueProcess{
-		PythonProcess: pyProcess,
-		serializer:    MsgpackSerializer{},
-		transport:     NewMsgpackTransport(pyProcess.PipeIn, pyProcess.PipeOut),
-		// reader:          bufio.NewReader(pyProcess.PipeIn),
-		// writer:          bufio.NewWriter(pyProcess.PipeOut),
+		PythonProcess:   pyProcess,
+		serializer:      serializer,
+		transport:       transport,
 		responseMap:     make(map[string]chan map[string]interface{}),
 		nextID:          1,
 		methodCache:     make(map[string]MethodInfo),
 		commandHandlers: map[string]CommandHandler{},
 	}
+	if opts.Logger != nil {
+		jq.SetLogger(opts.Logger)
+	}
 
 	if serviceStruct != nil {
-		// --- Reflect over the serviceStruct ---
-		serviceValue := reflect.ValueOf(serviceStruct)
-		serviceType := serviceValue.Type()
+		jq.registerService(serviceStruct)
+	}
+	if opts.IdleTimeout > 0 {
+		jq.setIdleTimeout(opts.IdleTimeout, opts.OnIdleShutdown)
+	}
 
-		// Iterate over the methods of the struct
-		for i := 0; i < serviceType.NumMethod(); i++ {
-			method := serviceType.Method(i)
+	// Start the message processing (Start launches the single message loop)
+	jq.Start()
 
-			// Check if the method is exported (starts with uppercase)
-			if method.PkgPath != "" { // PkgPath is empty for exported methods
-				continue
+	// Fetch method info from Python
+	err = jq.discoverMethods()
+	if err != nil {
+		// Not fatal, just log it
+		jq.logger.get().Warnf("Failed to discover Python methods: %v", err)
+	}
+
+	return jq, nil
+}
+
+// registerService registers a command handler for each exported method of
+// service, named after the method. Python calls a method with its arguments
+// as a positional list.
+//
+// Methods with value and pointer receivers are both registered; when service
+// is not a pointer, methods are called on a single shared copy of it. The
+// supported signatures are:
+//
+//	func (s *T) M(a A, b B) R                 // fixed parameters
+//	func (s *T) M(a A, rest ...B) R           // variadic final parameter
+//	func (s *T) M(...) error                  // result is None
+//	func (s *T) M(...) (R, error)             // result is the value
+//	func (s *T) M(...) (R1, R2, ..., error)   // result is a list of the values
+//
+// The trailing error is optional. Arguments are converted to the parameter
+// types directly when possible, and otherwise through a JSON round-trip, so
+// structs, maps, and typed slices are accepted.
+//
+// Python may omit trailing arguments that are optional: pointer parameters
+// (which receive nil) and parameters with a default supplied through
+// ServiceDefaults.
+func (jq *QueueProcess) registerService(service interface{}) {
+	serviceValue := reflect.ValueOf(service)
+	if serviceValue.Kind() != reflect.Ptr {
+		// Use an addressable copy so pointer-receiver methods are included
+		ptr := reflect.New(serviceValue.Type())
+		ptr.Elem().Set(serviceValue)
+		serviceValue = ptr
+	}
+	serviceType := serviceValue.Type()
+
+	var defaults map[string][]interface{}
+	if provider, ok := serviceValue.Interface().(ServiceDefaults); ok {
+		defaults = provider.MethodDefaults()
+	}
+
+	// Iterate over the methods of the struct
+	for i := 0; i < serviceType.NumMethod(); i++ {
+		method := serviceType.Method(i)
+
+		// Check if the method is exported (starts with uppercase)
+		if method.PkgPath != "" { // PkgPath is empty for exported methods
+			continue
+		}
+
+		// The defaults provider is not itself a command
+		if defaults != nil && method.Name == "MethodDefaults" {
+			continue
+		}
+
+		jq.RegisterHandler(method.Name, serviceMethodHandler(serviceValue, method, defaults[method.Name]))
+	}
+}
+
+// ServiceDefaults can be implemented by a serviceStruct to give default values
+// for trailing method parameters, so that Python may omit those arguments.
+//
+// MethodDefaults maps a method name to the defaults of its last fixed
+// (non-variadic) parameters, in order. For example, for
+//
+//	func (s *Service) Resize(path string, width int, height int) error
+//
+// the entry "Resize": {640, 480} lets Python call Resize with one, two, or
+// three arguments.
+type ServiceDefaults interface {
+	MethodDefaults() map[string][]interface{}
+}
+
+// serviceMethodHandler returns a CommandHandler that calls method on receiver
+// using reflection. defaults holds the default values of the method's trailing
+// fixed parameters.
+func serviceMethodHandler(receiver reflect.Value, method reflect.Method, defaults []interface{}) CommandHandler {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	methodType := method.Type
+	numParams := methodType.NumIn() - 1 // -1 to exclude the receiver
+	variadic := methodType.IsVariadic()
+
+	// Fixed parameters exclude the variadic slice
+	numFixed := numParams
+	if variadic {
+		numFixed--
+	}
+	if len(defaults) > numFixed {
+		defaults = defaults[len(defaults)-numFixed:]
+	}
+	firstDefault := numFixed - len(defaults)
+
+	// Trailing parameters with a default or a pointer type may be omitted
+	numRequired := numFixed
+	for numRequired > 0 && (numRequired-1 >= firstDefault || methodType.In(numRequired).Kind() == reflect.Ptr) {
+		numRequired--
+	}
+
+	return func(data interface{}, requestID string) (interface{}, error) {
+		// 1. Convert data to []reflect.Value (handling nil)
+		var dataArray []interface{}
+		if data != nil {
+			var ok bool
+			if dataArray, ok = data.([]interface{}); !ok {
+				return nil, fmt.Errorf("invalid data format for method %s, expected array", method.Name)
 			}
+		}
 
-			// Create a CommandHandler function that uses reflection to call the method
-			handler := func(data interface{}, requestID string) (interface{}, error) {
-				// 1. Convert data to []reflect.Value (handling nil)
-				var args []reflect.Value
-				args = append(args, serviceValue) // Add the receiver first
-
-				if data != nil {
-					// Expect data to be an array/slice
-					if dataArray, ok := data.([]interface{}); ok {
-						// Check if the number of arguments matches the method signature
-						if len(dataArray) != method.Type.NumIn()-1 { // -1 to exclude the receiver
-							return nil, fmt.Errorf("incorrect number of arguments for method %s", method.Name)
-						}
-
-						// Process each argument
-						for i, arg := range dataArray {
-							paramType := method.Type.In(i + 1) // +1 to skip the receiver
-							argValue := reflect.ValueOf(arg)
-
-							// Check if the argument can be converted to the parameter type
-							if !argValue.CanConvert(paramType) {
-								return nil, fmt.Errorf("cannot convert argument %d to type %s for method %s", i, paramType, method.Name)
-							}
-
-							// Convert the argument to the correct type
-							convertedValue := argValue.Convert(paramType)
-							args = append(args, convertedValue)
-						}
-					} else {
-						return nil, fmt.Errorf("invalid data format for method %s, expected array", method.Name)
-					}
-				}
+		// Check if the number of arguments matches the method signature
+		if len(dataArray) < numRequired || (!variadic && len(dataArray) > numParams) {
+			return nil, fmt.Errorf("incorrect number of arguments for method %s", method.Name)
+		}
 
-				// 2. Call the method using reflection
-				results := method.Func.Call(args)
+		args := []reflect.Value{receiver} // Add the receiver first
+		for i, arg := range dataArray {
+			var paramType reflect.Type
+			if variadic && i >= numParams-1 {
+				paramType = methodType.In(numParams).Elem() // element type of the variadic slice
+			} else {
+				paramType = methodType.In(i + 1) // +1 to skip the receiver
+			}
 
-				// 3. Check for errors (assume the last result is an error)
-				if len(results) > 0 {
-					if err, ok := results[len(results)-1].Interface().(error); ok && err != nil {
-						return nil, fmt.Errorf("error calling method %s: %w", method.Name, err)
-					}
-				}
+			convertedValue, err := convertArgument(arg, paramType)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert argument %d to type %s for method %s: %v", i, paramType, method.Name, err)
+			}
+			args = append(args, convertedValue)
+		}
 
-				// 4. Return the result (or nil if no result)
-				if len(results) > 1 {
-					return results[0].Interface(), nil
-				}
-				return nil, nil
+		// Fill in omitted trailing parameters
+		for i := len(dataArray); i < numFixed; i++ {
+			paramType := methodType.In(i + 1)
+			if i < firstDefault {
+				args = append(args, reflect.Zero(paramType))
+				continue
 			}
+			defaultValue, err := convertArgument(defaults[i-firstDefault], paramType)
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert default for argument %d to type %s for method %s: %v", i, paramType, method.Name, err)
+			}
+			args = append(args, defaultValue)
+		}
 
-			// Register the handler
-			jq.RegisterHandler(method.Name, handler)
+		// 2. Call the method using reflection
+		results := method.Func.Call(args)
+
+		// 3. Check for errors (a trailing error result is optional)
+		if n := methodType.NumOut(); n > 0 && methodType.Out(n-1) == errorType {
+			if err, ok := results[n-1].Interface().(error); ok && err != nil {
+				return nil, fmt.Errorf("error calling method %s: %w", method.Name, err)
+			}
+			results = results[:n-1]
 		}
+
+		// 4. Return the result: nil, a single value, or a list of values
+		switch len(results) {
+		case 0:
+			return nil, nil
+		case 1:
+			return results[0].Interface(), nil
+		default:
+			values := make([]interface{}, len(results))
+			for i, result := range results {
+				values[i] = result.Interface()
+			}
+			return values, nil
+		}
+	}
+}
+
+// convertArgument converts a decoded argument to paramType, falling back to a
+// JSON round-trip for types that cannot be converted directly.
+func convertArgument(arg interface{}, paramType reflect.Type) (reflect.Value, error) {
+	if arg == nil {
+		return reflect.Zero(paramType), nil
+	}
+
+	argValue := reflect.ValueOf(arg)
+	if argValue.Type().AssignableTo(paramType) {
+		return argValue, nil
+	}
+	if argValue.CanConvert(paramType) {
+		return argValue.Convert(paramType), nil
+	}
+
+	target := reflect.New(paramType)
+	jsonData, err := json.Marshal(arg)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if err := json.Unmarshal(jsonData, target.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return target.Elem(), nil
+}
+
+// NewQueueProcessOverConn creates a QueueProcess that speaks the queue protocol
+// over an already-established network connection instead of local pipes.
+// This allows Go to drive a remote Python worker (for example over TCP or TLS)
+// using the same Call, On().Do().Call(), and RegisterHandler APIs.
+//
+// Parameters:
+//   - conn: The connection to the remote Python MessagePackQueueServer
+//   - serializer: Message encoding; nil uses MsgpackSerializer
+//
+// The returned QueueProcess has no local Python process, so the embedded
+// *PythonProcess is nil: its methods (Wait, Terminate, Suspend, WaitForOutput
+// and so on) return ErrNoLocalProcess, and its fields must not be accessed.
+// Close releases the connection instead of terminating a process. On the Python side, the server can be wired to a socket with:
+//
+//	sock = socket.create_connection((host, port))
+//	server = MyServer(pipe_in=sock.makefile('rb'), pipe_out=sock.makefile('wb'))
+func NewQueueProcessOverConn(conn net.Conn, serializer Serializer) (*QueueProcess, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("connection is nil")
+	}
+	return NewQueueProcessOverTransport(NewMsgpackTransport(conn, nopWriteCloser{conn}), serializer)
+}
+
+// NewQueueProcessOverTransport creates a QueueProcess that speaks the queue
+// protocol over an arbitrary Transport, such as a ReplayTransport for testing
+// without Python. Like NewQueueProcessOverConn, it has no local Python process,
+// and a nil serializer selects MessagePack.
+func NewQueueProcessOverTransport(transport Transport, serializer Serializer) (*QueueProcess, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("transport is nil")
+	}
+	if serializer == nil {
+		serializer = MsgpackSerializer{}
+	}
+
+	jq := &QueueProcess{
+		serializer:      serializer,
+		transport:       transport,
+		responseMap:     make(map[string]chan map[string]interface{}),
+		nextID:          1,
+		methodCache:     make(map[string]MethodInfo),
+		commandHandlers: map[string]CommandHandler{},
 	}
 
 	// Start the message processing
 	jq.Start()
 
-	// Start the message loop
-	go jq.messageLoop()
-
 	// Fetch method info from Python
-	err = jq.discoverMethods()
-	if err != nil {
+	if err := jq.discoverMethods(); err != nil {
 		// Not fatal, just log it
-		fmt.Printf("Warning: Failed to discover Python methods: %v\n", err)
+		jq.logger.get().Warnf("Failed to discover Python methods: %v", err)
 	}
 
 	return jq, nil
 }
 
-// discoverMethods fetches information about exposed Python methods
+// nopWriteCloser wraps a writer whose lifetime is owned by a paired reader,
+// so that closing the transport closes a shared connection only once.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+// Close does nothing; the underlying connection is closed via the reader.
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// discoverMethods fetches information about exposed Python methods and
+// replaces the method cache with it.
 func (jq *QueueProcess) discoverMethods() error {
 	response, err := jq.SendCommand("__get_methods__", nil, 0, true)
 	if err != nil {
@@ -262,43 +678,121 @@ func (jq *QueueProcess) discoverMethods() error {
 		return fmt.Errorf("invalid method information returned")
 	}
 
-	// Parse method info
+	cache := make(map[string]MethodInfo, len(methods))
 	for name, info := range methods {
-		infoMap, ok := info.(map[string]interface{})
-		if !ok {
-			continue
+		methodInfo, err := parseMethodInfo(info)
+		if err != nil {
+			return fmt.Errorf("invalid method information for %s: %v", name, err)
 		}
+		cache[name] = methodInfo
+	}
 
-		// Convert to our structure
-		methodInfo := MethodInfo{
-			Doc: infoMap["doc"].(string),
-		}
+	jq.mutex.Lock()
+	jq.methodCache = cache
+	jq.mutex.Unlock()
+	return nil
+}
 
-		// Parse parameters
-		if params, ok := infoMap["parameters"].([]interface{}); ok {
-			for _, p := range params {
-				param, ok := p.(map[string]interface{})
-				if !ok {
-					continue
-				}
+// parseMethodInfo converts one method's introspection data into a MethodInfo.
+// Absent fields are left empty and parameters without a name are skipped, but
+// every field that is present must have the expected type.
+func parseMethodInfo(info interface{}) (MethodInfo, error) {
+	infoMap, ok := info.(map[string]interface{})
+	if !ok {
+		return MethodInfo{}, fmt.Errorf("expected a map, got %T", info)
+	}
 
-				paramInfo := ParameterInfo{
-					Name:     param["name"].(string),
-					Required: param["required"].(bool),
-				}
+	var methodInfo MethodInfo
+	var err error
+	if methodInfo.Doc, err = optionalString(infoMap, "doc"); err != nil {
+		return MethodInfo{}, err
+	}
 
-				if typeName, ok := param["type"]; ok {
-					paramInfo.Type = typeName.(string)
-				}
+	if ret, ok := infoMap["return"]; ok && ret != nil {
+		retMap, ok := ret.(map[string]interface{})
+		if !ok {
+			return MethodInfo{}, fmt.Errorf("return is %T, not a map", ret)
+		}
+		for key, value := range retMap {
+			text, ok := value.(string)
+			if !ok {
+				return MethodInfo{}, fmt.Errorf("return %s is %T, not a string", key, value)
+			}
+			if methodInfo.Return == nil {
+				methodInfo.Return = make(map[string]string)
+			}
+			methodInfo.Return[key] = text
+		}
+	}
 
-				methodInfo.Parameters = append(methodInfo.Parameters, paramInfo)
+	if params, ok := infoMap["parameters"]; ok && params != nil {
+		paramList, ok := params.([]interface{})
+		if !ok {
+			return MethodInfo{}, fmt.Errorf("parameters is %T, not a list", params)
+		}
+		for i, p := range paramList {
+			paramInfo, err := parseParameterInfo(p)
+			if err != nil {
+				return MethodInfo{}, fmt.Errorf("parameter %d: %v", i, err)
 			}
+			if paramInfo.Name == "" {
+				continue
+			}
+			methodInfo.Parameters = append(methodInfo.Parameters, paramInfo)
+		}
+	}
+
+	return methodInfo, nil
+}
+
+// parseParameterInfo converts one parameter's introspection data into a
+// ParameterInfo. An absent required field means the parameter is optional.
+func parseParameterInfo(p interface{}) (ParameterInfo, error) {
+	param, ok := p.(map[string]interface{})
+	if !ok {
+		return ParameterInfo{}, fmt.Errorf("expected a map, got %T", p)
+	}
+
+	var paramInfo ParameterInfo
+	var err error
+	if paramInfo.Name, err = optionalString(param, "name"); err != nil {
+		return ParameterInfo{}, err
+	}
+	if required, ok := param["required"]; ok && required != nil {
+		if paramInfo.Required, ok = required.(bool); !ok {
+			return ParameterInfo{}, fmt.Errorf("required is %T, not a bool", required)
 		}
+	}
+	if paramInfo.Type, err = optionalString(param, "type"); err != nil {
+		return ParameterInfo{}, err
+	}
+	if paramInfo.Kind, err = optionalString(param, "kind"); err != nil {
+		return ParameterInfo{}, err
+	}
+	return paramInfo, nil
+}
 
-		// Store in the cache
-		jq.methodCache[name] = methodInfo
+// optionalString returns m[key] as a string, or "" if it is missing or nil.
+func optionalString(m map[string]interface{}, key string) (string, error) {
+	value, ok := m[key]
+	if !ok || value == nil {
+		return "", nil
 	}
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s is %T, not a string", key, value)
+	}
+	return text, nil
+}
 
+// RefreshMethods re-runs method discovery, replacing the methods reported by
+// GetMethods and GetMethodInfo. Use it after the Python service registers
+// methods dynamically, since discovery otherwise only runs at startup. The
+// cached methods are left unchanged if discovery fails.
+func (jq *QueueProcess) RefreshMethods() error {
+	if err := jq.discoverMethods(); err != nil {
+		return fmt.Errorf("error discovering Python methods: %v", err)
+	}
 	return nil
 }
 
@@ -310,8 +804,27 @@ func (jq *QueueProcess) discoverMethods() error {
 //   - args: Arguments to pass (typically a map or slice)
 //
 // Returns the result from Python, or an error if the call failed or timed out.
+// Call is equivalent to CallContext with a context.WithTimeout.
 func (jq *QueueProcess) Call(methodName string, timeoutSeconds int, args interface{}) (interface{}, error) {
-	response, err := jq.SendCommand(methodName, args, timeoutSeconds, true)
+	ctx := context.Background()
+	if timeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+		defer cancel()
+	}
+	return jq.CallContext(ctx, methodName, args)
+}
+
+// CallContext invokes a Python method by name and returns the result, giving up
+// when ctx is cancelled or its deadline passes.
+//
+// On cancellation the pending request is abandoned (a late response is
+// discarded) and a best-effort "__cancel__" command carrying the request ID is
+// sent so that Python can abort the work; handlers can check for this with
+// cancelled() from jumpboot.msgpackqueue. A context deadline is also sent to
+// Python as the call's time budget.
+func (jq *QueueProcess) CallContext(ctx context.Context, methodName string, args interface{}) (interface{}, error) {
+	response, err := jq.sendCommandContext(ctx, methodName, args, true)
 	if err != nil {
 		return nil, err
 	}
@@ -337,8 +850,11 @@ func (jq *QueueProcess) Call(methodName string, timeoutSeconds int, args interfa
 }
 
 // GetMethods returns the names of all discovered Python methods.
-// Methods are discovered during NewQueueProcess via introspection.
+// Methods are discovered during NewQueueProcess via introspection, and again
+// by RefreshMethods.
 func (jq *QueueProcess) GetMethods() []string {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
 	var methods []string
 	for name := range jq.methodCache {
 		methods = append(methods, name)
@@ -349,12 +865,16 @@ func (jq *QueueProcess) GetMethods() []string {
 // GetMethodInfo returns metadata about a specific Python method.
 // Returns the MethodInfo and true if found, or an empty MethodInfo and false if not.
 func (jq *QueueProcess) GetMethodInfo(methodName string) (MethodInfo, bool) {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
 	info, ok := jq.methodCache[methodName]
 	return info, ok
 }
 
 // Start begins the message processing loop.
 // This is called automatically by NewQueueProcess; manual calls are idempotent.
+// Exactly one messageLoop reads from the transport, so responses are never
+// consumed by a competing reader.
 func (jq *QueueProcess) Start() {
 	jq.mutex.Lock()
 	if jq.running {
@@ -362,16 +882,25 @@ func (jq *QueueProcess) Start() {
 		return
 	}
 	jq.running = true
+	jq.loopDone = make(chan struct{})
+	jq.lastActivity = time.Now()
+	done := jq.loopDone
 	jq.mutex.Unlock()
 
 	// Start the message processing goroutine
-	go jq.messageLoop()
+	go func() {
+		defer close(done)
+		jq.messageLoop()
+	}()
 }
 
 // messageLoop continuously reads messages from Python and dispatches them.
 // Responses to Go requests are routed via responseMap; commands from Python
 // are handled by registered handlers in separate goroutines.
 func (jq *QueueProcess) messageLoop() {
+	defer jq.closeSubscriptions()
+	defer jq.dropPendingAsyncResponses()
+
 	for {
 		jq.mutex.Lock()
 		running := jq.running
@@ -387,17 +916,48 @@ func (jq *QueueProcess) messageLoop() {
 				// The pipe was closed
 				break
 			}
-			log.Printf("Error reading from Python: %v", err)
+			jq.mutex.Lock()
+			closing := jq.closing
+			jq.mutex.Unlock()
+			if closing {
+				// Close closed the transport under us
+				break
+			}
+			jq.logger.get().Errorf("Error reading from Python: %v", err)
+			if errors.Is(err, ErrMessageTooLarge) {
+				// The rest of the stream can't be framed
+				break
+			}
+			continue
+		}
+
+		jq.mutex.Lock()
+		jq.lastActivity = time.Now()
+		jq.idleSince = jq.lastActivity
+		jq.mutex.Unlock()
+
+		// Raw frames bypass the serializer
+		if isRawFrame(response) {
+			jq.handleRawFrame(response)
 			continue
 		}
 
 		var message map[string]interface{}
-		// if err := json.Unmarshal(response, &message); err != nil {
-		// 	log.Printf("Error decoding JSON message: %v", err)
-		// 	continue
-		// }
-		if err := jq.serializer.Unmarshal(response, &message); err != nil {
-			log.Printf("Error decoding message: %v", err)
+		err = jq.serializer.Unmarshal(response, &message)
+		// The built-in serializers copy what they decode, so the frame's
+		// buffer can be reused
+		switch jq.serializer.(type) {
+		case MsgpackSerializer, JSONSerializer:
+			releaseFrame(jq.transport, response)
+		}
+		if err != nil {
+			jq.logger.get().Errorf("Error decoding message: %v", err)
+			continue
+		}
+
+		// Check if this is a one-way event
+		if topic, ok := message["event"].(string); ok {
+			jq.publishEvent(topic, message["data"])
 			continue
 		}
 
@@ -417,19 +977,107 @@ func (jq *QueueProcess) messageLoop() {
 		data := message["data"]
 		requestID, hasRequestID := message["request_id"].(string)
 		if !hasRequestID {
-			fmt.Printf("Warning: Command without request ID: %v\n", message)
+			jq.logger.get().Warnf("Command without request ID: %v", message)
 		} else {
 			if hasCommand {
-				jq.processingWg.Add(1)
-				go func() {
-					defer jq.processingWg.Done()
-					jq.processCommand(command, data, requestID)
-				}()
+				// Adding to processingWg under the mutex keeps it from racing
+				// with CloseGraceful's wait
+				jq.mutex.Lock()
+				closing := jq.closing
+				queue, ordered := jq.orderedQueues[command]
+				if !closing {
+					jq.processingWg.Add(1)
+				}
+				jq.mutex.Unlock()
+
+				if closing {
+					go jq.respond(requestID, nil, fmt.Errorf("queue process is closing"))
+				} else if ordered {
+					jq.dispatchOrdered(queue, command, data, requestID)
+				} else {
+					go func() {
+						defer jq.processingWg.Done()
+						jq.processCommand(command, data, requestID)
+					}()
+				}
 			}
 		}
 	}
 }
 
+// Subscribe returns a channel that receives the data of each event Python
+// emits on topic (messages of the form {"event": topic, "data": ...}, sent with
+// emit() in jumpboot.msgpackqueue), and a function that cancels the
+// subscription and closes the channel.
+//
+// Events never block the message loop: events with no subscriber are dropped,
+// as are events for a subscriber whose buffer is full. The channel is also
+// closed when the message loop stops.
+func (jq *QueueProcess) Subscribe(topic string) (<-chan interface{}, func()) {
+	ch := make(chan interface{}, eventBufferSize)
+
+	jq.mutex.Lock()
+	if jq.eventsClosed {
+		jq.mutex.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if jq.subscriptions == nil {
+		jq.subscriptions = make(map[string][]chan interface{})
+	}
+	jq.subscriptions[topic] = append(jq.subscriptions[topic], ch)
+	jq.mutex.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			jq.mutex.Lock()
+			defer jq.mutex.Unlock()
+
+			subs := jq.subscriptions[topic]
+			for i, sub := range subs {
+				if sub == ch {
+					jq.subscriptions[topic] = append(subs[:i:i], subs[i+1:]...)
+					close(ch)
+					break
+				}
+			}
+			if len(jq.subscriptions[topic]) == 0 {
+				delete(jq.subscriptions, topic)
+			}
+		})
+	}
+	return ch, unsubscribe
+}
+
+// publishEvent delivers event data to every subscriber of topic without blocking.
+func (jq *QueueProcess) publishEvent(topic string, data interface{}) {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
+
+	for _, ch := range jq.subscriptions[topic] {
+		select {
+		case ch <- data:
+		default:
+			// Subscriber is not keeping up; drop the event
+		}
+	}
+}
+
+// closeSubscriptions closes all subscriber channels once the message loop exits.
+func (jq *QueueProcess) closeSubscriptions() {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
+
+	for _, subs := range jq.subscriptions {
+		for _, ch := range subs {
+			close(ch)
+		}
+	}
+	jq.subscriptions = nil
+	jq.eventsClosed = true
+}
+
 // processCommand dispatches a command from Python to the appropriate handler.
 // If a handler is registered for the command, it's invoked; otherwise the default
 // handler is used. The response is sent back to Python with the matching requestID.
@@ -453,31 +1101,39 @@ func (jq *QueueProcess) processCommand(command string, data interface{}, request
 
 	// Send a response if requestID is present
 	if requestID != "" {
-		responseObj := make(map[string]interface{})
+		jq.respond(requestID, response, err)
+	}
+}
 
-		if err != nil {
-			responseObj["error"] = err.Error()
-		} else {
-			responseObj["result"] = response
-		}
+// respond sends Python the result of the command with the given request ID,
+// or the error if err is not nil.
+func (jq *QueueProcess) respond(requestID string, result interface{}, err error) {
+	responseObj := make(map[string]interface{})
 
-		responseObj["request_id"] = requestID
+	if err != nil {
+		responseObj["error"] = err.Error()
+	} else {
+		responseObj["result"] = result
+	}
 
-		// Send the response
-		jq.mutex.Lock()
-		// responseJSON, _ := json.Marshal(responseObj)
-		response, _ := jq.serializer.Marshal(responseObj)
-		err = jq.transport.Send(response)
-		if err == nil {
-			// err = jq.writer.Flush()
-			err = jq.transport.Flush()
-		}
-		jq.mutex.Unlock()
+	responseObj["request_id"] = requestID
 
+	// Send the response, or an error if the result can't be encoded so
+	// Python isn't left waiting
+	response, err := jq.serializer.Marshal(responseObj)
+	if err != nil {
+		jq.logger.get().Errorf("Error encoding response to Python: %v", err)
+		response, err = jq.serializer.Marshal(map[string]interface{}{
+			"error":      fmt.Sprintf("error encoding result: %v", err),
+			"request_id": requestID,
+		})
 		if err != nil {
-			log.Printf("Error sending response to Python: %v", err)
+			return
 		}
 	}
+	if err := jq.sendFrame(response); err != nil {
+		jq.logger.get().Errorf("Error sending response to Python: %v", err)
+	}
 }
 
 // generateRequestID generates a unique request ID
@@ -489,44 +1145,25 @@ func (jq *QueueProcess) generateRequestID() string {
 	return id
 }
 
-// Read a message from Python
-func (jq *QueueProcess) readMessage() (map[string]interface{}, error) {
-	//line, err := jq.reader.ReadString('\n')
-	line, err := jq.transport.Receive()
-	if err != nil {
-		return nil, err
-	}
-
-	// // Trim any whitespace
-	// line = strings.TrimSpace(line)
-	// if line == "" {
-	// 	return nil, fmt.Errorf("empty line received")
-	// }
-
-	// Parse the JSON
-	var message map[string]interface{}
-	if err := json.Unmarshal([]byte(line), &message); err != nil {
-		return nil, fmt.Errorf("error unmarshaling JSON: %v, raw data: %s", err, line)
-	}
-
-	return message, nil
-}
-
 // Send a message to Python
 func (jq *QueueProcess) sendMessage(message map[string]interface{}) error {
 	msgdata, err := jq.serializer.Marshal(message)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message: %w", err)
 	}
+	return jq.sendFrame(msgdata)
+}
 
+// sendFrame writes an encoded frame to Python.
+func (jq *QueueProcess) sendFrame(frame []byte) error {
 	jq.mutex.Lock()
-	err = jq.transport.Send(msgdata)
+	jq.idleSince = time.Now()
+	err := jq.transport.Send(frame)
 	if err != nil {
 		jq.mutex.Unlock()
 		return fmt.Errorf("failed to write message: %w", err)
 	}
 
-	// err = jq.writer.Flush()
 	err = jq.transport.Flush()
 	jq.mutex.Unlock()
 
@@ -545,8 +1182,29 @@ func (jq *QueueProcess) sendMessage(message map[string]interface{}) error {
 //   - timeoutSeconds: Maximum seconds to wait (0 for unlimited, ignored if not waiting)
 //   - waitForResponse: If true, blocks until Python responds
 //
+// When waiting with a timeout, the remaining time budget is sent to Python in
+// the request's "meta" field so cooperative handlers can stop early (see
+// time_remaining in jumpboot.msgpackqueue).
+//
 // Returns the response map (if waiting) or nil, and any error encountered.
 func (jq *QueueProcess) SendCommand(command string, data interface{}, timeoutSeconds int, waitForResponse bool) (map[string]interface{}, error) {
+	return jq.sendCommand(command, data, time.Duration(timeoutSeconds)*time.Second, waitForResponse)
+}
+
+// sendCommand implements SendCommand with a time.Duration timeout.
+func (jq *QueueProcess) sendCommand(command string, data interface{}, timeout time.Duration, waitForResponse bool) (map[string]interface{}, error) {
+	ctx := context.Background()
+	if waitForResponse && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return jq.sendCommandContext(ctx, command, data, waitForResponse)
+}
+
+// sendCommandContext sends a command and, if waitForResponse is set, waits for
+// the response until ctx is done.
+func (jq *QueueProcess) sendCommandContext(ctx context.Context, command string, data interface{}, waitForResponse bool) (map[string]interface{}, error) {
 	requestID := jq.generateRequestID()
 	request := map[string]interface{}{
 		"command":    command,
@@ -554,6 +1212,17 @@ func (jq *QueueProcess) SendCommand(command string, data interface{}, timeoutSec
 		"request_id": requestID,
 	}
 
+	// Tell Python how long Go will wait so it can abandon work whose result would be discarded
+	if deadline, ok := ctx.Deadline(); ok && waitForResponse {
+		budget := time.Until(deadline)
+		if budget < 0 {
+			budget = 0
+		}
+		request["meta"] = map[string]interface{}{
+			"budget_ms": budget.Milliseconds(),
+		}
+	}
+
 	// If waiting for response, create a channel to receive it
 	var responseChan chan map[string]interface{}
 	if waitForResponse {
@@ -565,52 +1234,440 @@ func (jq *QueueProcess) SendCommand(command string, data interface{}, timeoutSec
 
 	// Send the request
 	if err := jq.sendMessage(request); err != nil {
+		if waitForResponse {
+			jq.mutex.Lock()
+			delete(jq.responseMap, requestID)
+			jq.mutex.Unlock()
+		}
 		return nil, err
 	}
 
 	if !waitForResponse {
 		return nil, nil
 	}
+	return jq.waitForResponse(ctx, command, requestID, responseChan)
+}
+
+// waitForResponse waits for the response to a request until ctx is done, in
+// which case the request is abandoned and Python is asked to cancel it, or the
+// message loop stops, in which case the error wraps io.EOF.
+func (jq *QueueProcess) waitForResponse(ctx context.Context, command string, requestID string, responseChan chan map[string]interface{}) (map[string]interface{}, error) {
+	jq.mutex.Lock()
+	done := jq.loopDone
+	jq.mutex.Unlock()
 
-	if timeoutSeconds <= 0 {
-		response := <-responseChan
+	select {
+	case response := <-responseChan:
 		return response, nil
-	} else {
-		// Wait for response with timeout
+	case <-done:
+		// Prefer a response that arrived just before the loop stopped
 		select {
 		case response := <-responseChan:
 			return response, nil
-		case <-time.After(time.Duration(timeoutSeconds) * time.Second):
-			jq.mutex.Lock()
-			delete(jq.responseMap, requestID)
-			jq.mutex.Unlock()
+		default:
+		}
+		jq.mutex.Lock()
+		delete(jq.responseMap, requestID)
+		jq.mutex.Unlock()
+		return nil, fmt.Errorf("connection closed waiting for response to command %s: %w", command, io.EOF)
+	case <-ctx.Done():
+		// Abandon the request; the channel is buffered so a late response never blocks the loop
+		jq.mutex.Lock()
+		delete(jq.responseMap, requestID)
+		jq.mutex.Unlock()
+
+		// Let Python know it can stop working on this request
+		if err := jq.sendMessage(map[string]interface{}{
+			"command":    "__cancel__",
+			"data":       map[string]interface{}{"request_id": requestID},
+			"request_id": jq.generateRequestID(),
+		}); err != nil {
+			jq.logger.get().Warnf("Error sending cancel for request %s: %v", requestID, err)
+		}
+
+		if ctx.Err() == context.DeadlineExceeded {
 			return nil, fmt.Errorf("timeout waiting for response to command: %s", command)
 		}
+		return nil, fmt.Errorf("command %s cancelled: %w", command, ctx.Err())
+	}
+}
+
+// Ping checks that the Python side is alive and responsive by sending the
+// reserved "__ping__" command, which the Python queue server answers
+// immediately. It returns an error if no reply arrives within timeout (for
+// example because a handler is blocking Python's event loop) or if the
+// connection has closed. A timeout of zero or less waits until a reply
+// arrives or the connection closes.
+//
+// The round-trip time of a successful ping is available from LastPingRTT.
+func (jq *QueueProcess) Ping(timeout time.Duration) error {
+	jq.mutex.Lock()
+	done := jq.loopDone
+	jq.mutex.Unlock()
+	if done == nil {
+		return fmt.Errorf("ping failed: message loop is not running")
+	}
+	select {
+	case <-done:
+		return fmt.Errorf("ping failed: %w", io.EOF)
+	default:
+	}
+
+	requestID := jq.generateRequestID()
+	responseChan := make(chan map[string]interface{}, 1)
+	jq.mutex.Lock()
+	jq.responseMap[requestID] = responseChan
+	jq.mutex.Unlock()
+
+	forget := func() {
+		jq.mutex.Lock()
+		delete(jq.responseMap, requestID)
+		jq.mutex.Unlock()
+	}
+
+	start := time.Now()
+	if err := jq.sendMessage(map[string]interface{}{
+		"command":    "__ping__",
+		"data":       nil,
+		"request_id": requestID,
+	}); err != nil {
+		forget()
+		return fmt.Errorf("ping failed: %w", err)
+	}
+
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	select {
+	case <-responseChan:
+		jq.mutex.Lock()
+		jq.lastPingRTT = time.Since(start)
+		jq.mutex.Unlock()
+		return nil
+	case <-done:
+		forget()
+		return fmt.Errorf("ping failed: %w", io.EOF)
+	case <-timeoutChan:
+		forget()
+		return fmt.Errorf("ping timed out after %v", timeout)
+	}
+}
+
+// LastPingRTT returns the round-trip time of the last successful Ping, or zero
+// if no ping has succeeded.
+func (jq *QueueProcess) LastPingRTT() time.Duration {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
+	return jq.lastPingRTT
+}
+
+// LastActivity returns the time the message loop last received a message from
+// Python. Callers can use it to implement their own idle detection.
+func (jq *QueueProcess) LastActivity() time.Time {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
+	return jq.lastActivity
+}
+
+// SendCommandAsync sends a command to Python without waiting for the response
+// and returns the request ID. The response can be collected later, possibly from
+// another goroutine, with AwaitResponse, or released with DiscardResponse.
+func (jq *QueueProcess) SendCommandAsync(command string, data interface{}) (string, error) {
+	requestID := jq.generateRequestID()
+	request := map[string]interface{}{
+		"command":    command,
+		"data":       data,
+		"request_id": requestID,
+	}
+
+	// Register the response channel before sending so an early response isn't lost
+	responseChan := make(chan map[string]interface{}, 1)
+	jq.mutex.Lock()
+	jq.responseMap[requestID] = responseChan
+	if jq.asyncResponses == nil {
+		jq.asyncResponses = make(map[string]chan map[string]interface{})
+	}
+	jq.asyncResponses[requestID] = responseChan
+	jq.mutex.Unlock()
+
+	if err := jq.sendMessage(request); err != nil {
+		jq.mutex.Lock()
+		delete(jq.responseMap, requestID)
+		delete(jq.asyncResponses, requestID)
+		jq.mutex.Unlock()
+		return "", err
+	}
+
+	return requestID, nil
+}
+
+// AwaitResponse blocks until the response to a request sent with SendCommandAsync
+// arrives and returns it. A timeout of zero or less waits indefinitely.
+// Each request ID can be awaited once; on timeout the request is abandoned.
+// If the message loop stops before the response arrives, the error wraps io.EOF.
+func (jq *QueueProcess) AwaitResponse(requestID string, timeout time.Duration) (map[string]interface{}, error) {
+	jq.mutex.Lock()
+	responseChan, exists := jq.asyncResponses[requestID]
+	delete(jq.asyncResponses, requestID)
+	done := jq.loopDone
+	jq.mutex.Unlock()
+
+	if !exists {
+		// Pending requests are dropped when the loop stops
+		if done != nil {
+			select {
+			case <-done:
+				return nil, fmt.Errorf("no pending request with ID %s: %w", requestID, io.EOF)
+			default:
+			}
+		}
+		return nil, fmt.Errorf("no pending request with ID: %s", requestID)
+	}
+
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	select {
+	case response := <-responseChan:
+		return response, nil
+	case <-done:
+		// Prefer a response that arrived just before the loop stopped
+		select {
+		case response := <-responseChan:
+			return response, nil
+		default:
+		}
+		jq.DiscardResponse(requestID)
+		return nil, fmt.Errorf("connection closed waiting for response to request %s: %w", requestID, io.EOF)
+	case <-timeoutChan:
+		jq.DiscardResponse(requestID)
+		return nil, fmt.Errorf("timeout waiting for response to request: %s", requestID)
 	}
 }
 
+// DiscardResponse releases a request sent with SendCommandAsync whose response
+// will never be awaited. Responses are held until they are collected, so every
+// request ID should be passed to either AwaitResponse or DiscardResponse.
+func (jq *QueueProcess) DiscardResponse(requestID string) {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
+	delete(jq.responseMap, requestID)
+	delete(jq.asyncResponses, requestID)
+}
+
+// dropPendingAsyncResponses forgets the SendCommandAsync requests that have not
+// been answered when the message loop stops, since their responses can no
+// longer arrive. Responses that already arrived can still be collected.
+func (jq *QueueProcess) dropPendingAsyncResponses() {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
+
+	for requestID, ch := range jq.asyncResponses {
+		if len(ch) == 0 {
+			delete(jq.asyncResponses, requestID)
+			delete(jq.responseMap, requestID)
+		}
+	}
+}
+
+// SetLogger sets the Logger for the queue's diagnostic messages, such as
+// undecodable messages and failed responses, and for those of its Python
+// process. Nil reverts to the package logger set with SetLogger.
+func (jq *QueueProcess) SetLogger(l Logger) {
+	jq.logger.set(l)
+	if jq.PythonProcess != nil {
+		jq.PythonProcess.SetLogger(l)
+	}
+}
+
+// closeExitAckTimeout is how long Close waits for Python to acknowledge the
+// "exit" command before terminating the process.
+const closeExitAckTimeout = 500 * time.Millisecond
+
+// closeLoopTimeout is how long closing waits for the message loop to stop
+// once the process is terminated or the connection closed.
+const closeLoopTimeout = time.Second
+
+// queueClosedError completes requests still waiting for a response when the
+// queue closes.
+const queueClosedError = "queue process closed"
+
 // Close stops the message loop and terminates the Python process.
-// It sends an "exit" command to Python (without waiting for response) and
-// then forcefully terminates the process after a brief delay.
+// It refuses new commands from Python, sends an "exit" command and waits
+// briefly for Python to acknowledge it, then terminates the process. Command
+// handlers still running are not waited for; use CloseGraceful for that.
+// Requests still waiting for a response from Python fail with an error.
 func (jq *QueueProcess) Close() error {
+	return jq.close(false, closeExitAckTimeout)
+}
+
+// CloseGraceful is like Close, but first waits up to timeout for running Go
+// command handlers to finish and send their responses. The time left after
+// the handlers finish bounds the wait for Python to acknowledge "exit" and
+// then for the process to exit before it is killed.
+// Handlers that are still running at the timeout are abandoned and can no
+// longer reach Python.
+func (jq *QueueProcess) CloseGraceful(timeout time.Duration) error {
+	return jq.close(true, timeout)
+}
+
+// idleCloseTimeout bounds the CloseGraceful of an idle queue.
+const idleCloseTimeout = 5 * time.Second
+
+// setIdleTimeout arms the idle shutdown described by QueueOptions.IdleTimeout.
+func (jq *QueueProcess) setIdleTimeout(timeout time.Duration, onShutdown func(*QueueProcess)) {
+	jq.mutex.Lock()
+	defer jq.mutex.Unlock()
+	jq.idleTimeout = timeout
+	jq.onIdleShutdown = onShutdown
+	jq.idleSince = time.Now()
+	jq.idleTimer = time.AfterFunc(timeout, jq.idleShutdown)
+}
+
+// idleShutdown runs when the idle timer fires. Sends and receives only record
+// their time, so the timer is re-armed for the rest of the timeout if there
+// was activity since it was set.
+func (jq *QueueProcess) idleShutdown() {
+	jq.mutex.Lock()
+	if !jq.running || jq.closing {
+		jq.mutex.Unlock()
+		return
+	}
+	remaining := jq.idleTimeout - time.Since(jq.idleSince)
+	if len(jq.responseMap) > 0 {
+		// Python is still working on a request
+		remaining = jq.idleTimeout
+	}
+	if remaining > 0 {
+		jq.idleTimer.Reset(remaining)
+		jq.mutex.Unlock()
+		return
+	}
+	onShutdown := jq.onIdleShutdown
+	jq.mutex.Unlock()
+
+	jq.logger.get().Infof("Closing queue after %v without activity", jq.idleTimeout)
+	if onShutdown != nil {
+		onShutdown(jq)
+	}
+	if err := jq.CloseGraceful(idleCloseTimeout); err != nil {
+		jq.logger.get().Warnf("Error closing idle queue: %v", err)
+	}
+}
+
+// close implements Close and CloseGraceful.
+func (jq *QueueProcess) close(waitForHandlers bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
 	// Signal that we're closing
 	jq.mutex.Lock()
-	if !jq.running {
+	if !jq.running || jq.closing {
 		jq.mutex.Unlock()
 		return nil
 	}
+	jq.closing = true
+	done := jq.loopDone
+	if jq.idleTimer != nil {
+		jq.idleTimer.Stop()
+	}
+	jq.mutex.Unlock()
+
+	if waitForHandlers && !jq.waitForHandlers(timeout) {
+		jq.logger.get().Warnf("Command handlers still running after %v; closing anyway", timeout)
+	}
+
+	// Send the exit command and wait for Python to acknowledge it
+	jq.logger.get().Debugf("Sending exit command to Python process")
+	requestID := jq.generateRequestID()
+	ack := make(chan map[string]interface{}, 1)
+	jq.mutex.Lock()
+	jq.responseMap[requestID] = ack
+	jq.mutex.Unlock()
+	err := jq.sendMessage(map[string]interface{}{
+		"command":    "exit",
+		"data":       nil,
+		"request_id": requestID,
+	})
+	acked := false
+	if err == nil {
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-ack:
+			acked = true
+		case <-done:
+		case <-timer.C:
+			jq.logger.get().Debugf("Python did not acknowledge the exit command within %v", timeout)
+		}
+		timer.Stop()
+	}
+
+	// Stop the loop and fail the requests it will never answer
+	jq.mutex.Lock()
 	jq.running = false
+	delete(jq.responseMap, requestID)
+	for id, ch := range jq.responseMap {
+		select {
+		case ch <- map[string]interface{}{"request_id": id, "error": queueClosedError}:
+		default:
+		}
+		delete(jq.responseMap, id)
+	}
 	jq.mutex.Unlock()
 
-	// Send exit command without waiting for a response
-	fmt.Println("Sending exit command to Python process...")
-	jq.SendCommand("exit", nil, 0, false)
+	// Connection-backed queues have no local process to terminate.
+	// CloseGraceful gives the process what is left of its timeout to exit.
+	if jq.PythonProcess == nil {
+		err = jq.transport.Close()
+	} else {
+		// Python exits after acknowledging; copy the last of its output
+		// before Terminate's Wait closes the pipes
+		if acked {
+			jq.PythonProcess.waitForwardedOutput(closeLoopTimeout)
+		}
+		if waitForHandlers {
+			err = jq.PythonProcess.TerminateWithTimeout(time.Until(deadline))
+		} else {
+			err = jq.PythonProcess.Terminate()
+		}
+		if !jq.PythonProcess.waitForwardedOutput(closeLoopTimeout) {
+			jq.logger.get().Warnf("Output forwarding did not stop within %v of closing", closeLoopTimeout)
+		}
+	}
 
-	// Small delay to allow the command to be sent
-	time.Sleep(50 * time.Millisecond)
+	// The loop sees EOF once the process is gone or the connection is closed
+	select {
+	case <-done:
+	case <-time.After(closeLoopTimeout):
+		jq.logger.get().Warnf("Message loop did not stop within %v of closing", closeLoopTimeout)
+	}
+	return err
+}
 
-	// Terminate the process
-	return jq.PythonProcess.Terminate()
+// waitForHandlers waits up to timeout for running command handlers to finish
+// and reports whether they did. If they don't, the goroutine waiting for them
+// exits when the last of them returns.
+func (jq *QueueProcess) waitForHandlers(timeout time.Duration) bool {
+	finished := make(chan struct{})
+	go func() {
+		jq.processingWg.Wait()
+		close(finished)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-finished:
+		return true
+	case <-timer.C:
+		return false
+	}
 }
 
 // Shutdown gracefully stops the QueueProcess by sending a "shutdown" command
@@ -623,7 +1680,12 @@ func (jq *QueueProcess) Shutdown() error {
 		return fmt.Errorf("error during shutdown: %w", err)
 	}
 
-	fmt.Printf("Shutdown response: %v\n", resp)
+	jq.logger.get().Debugf("Shutdown response: %v", resp)
+
+	// Connection-backed queues have no local process to wait for
+	if jq.PythonProcess == nil {
+		return jq.transport.Close()
+	}
 
 	// Wait for Python process to exit
 	return jq.PythonProcess.Wait()
@@ -664,29 +1726,121 @@ func (mc *methodCall) Do(args ...interface{}) *methodCall {
 	return mc
 }
 
+// positionalArgsKey is the key in a call's data under which Args sends
+// positional arguments. The Python queue servers pass them to the method as
+// *args and every other key as a keyword argument.
+const positionalArgsKey = "__args__"
+
+// Args adds positional arguments to the method call, which the Python method
+// receives in order, as if called as method(*args). This suits methods with
+// positional-only or *args parameters. Args can be combined with named
+// arguments from Do, and calling it again appends to the arguments:
+//
+//	.Args(10, 4).Do("scale", 2) // method(10, 4, scale=2)
+func (mc *methodCall) Args(args ...interface{}) *methodCall {
+	existing, _ := mc.data[positionalArgsKey].([]interface{})
+	mc.data[positionalArgsKey] = append(existing, args...)
+	return mc
+}
+
+// Strict checks the call's arguments against the method's discovered
+// MethodInfo before anything is sent: every required parameter must be
+// supplied and every named argument must match a parameter, so a typo is
+// reported as a Go error rather than a Python TypeError. Methods that weren't
+// discovered, such as handlers registered after startup, are called without
+// checking.
+func (mc *methodCall) Strict() *methodCall {
+	mc.strict = true
+	return mc
+}
+
 // WithTimeout sets the maximum duration to wait for the method to complete.
-// A zero timeout means wait indefinitely.
+// A zero timeout means wait indefinitely. The timeout is also sent to Python
+// as the call's time budget.
 func (mc *methodCall) WithTimeout(timeout time.Duration) *methodCall {
 	mc.timeout = timeout
 	return mc
 }
 
 // Call executes the Python method and returns the result.
-// The method is called with arguments added via Do() and the timeout set via WithTimeout().
+// The method is called with arguments added via Args() and Do() and the timeout set via WithTimeout().
 func (mc *methodCall) Call() (interface{}, error) {
-	// ... (Add validation for parameter names and types here, potentially using GetMethodInfo)
-
-	if mc.timeout > 0 {
-		// Use SendCommand with timeout
-		response, err := mc.process.SendCommand(mc.methodName, mc.data, int(mc.timeout.Seconds()), true)
-		return extractResult(response, err)
+	if mc.strict {
+		if info, ok := mc.process.GetMethodInfo(mc.methodName); ok {
+			if err := checkCallArguments(mc.methodName, info, mc.data); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	// Use SendCommand without timeout
-	response, err := mc.process.SendCommand(mc.methodName, mc.data, 0, true)
+	// A zero timeout waits indefinitely; sub-second timeouts are honored
+	response, err := mc.process.sendCommand(mc.methodName, mc.data, mc.timeout, true)
 	return extractResult(response, err)
 }
 
+// checkCallArguments reports the arguments in data that the method described
+// by info can't accept, binding them the way Python would: positional
+// arguments from Args fill the positional parameters in order, then named
+// arguments from Do fill the rest.
+func checkCallArguments(method string, info MethodInfo, data map[string]interface{}) error {
+	var problems []string
+	positional, _ := data[positionalArgsKey].([]interface{})
+
+	supplied := make(map[string]bool)
+	remaining := len(positional)
+	varPositional := false
+	for _, param := range info.Parameters {
+		switch param.Kind {
+		case "VAR_POSITIONAL":
+			varPositional = true
+		case "", "POSITIONAL_ONLY", "POSITIONAL_OR_KEYWORD":
+			if remaining > 0 && !varPositional {
+				supplied[param.Name] = true
+				remaining--
+			}
+		}
+	}
+	if remaining > 0 && !varPositional {
+		problems = append(problems, fmt.Sprintf("takes %d positional arguments but %d were given", len(positional)-remaining, len(positional)))
+	}
+
+	params := make(map[string]ParameterInfo, len(info.Parameters))
+	for _, param := range info.Parameters {
+		params[param.Name] = param
+	}
+	var names []string
+	for name := range data {
+		if name != positionalArgsKey {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		param, ok := params[name]
+		switch {
+		case !ok || param.Kind == "VAR_POSITIONAL" || param.Kind == "VAR_KEYWORD":
+			problems = append(problems, fmt.Sprintf("unknown argument %q", name))
+		case param.Kind == "POSITIONAL_ONLY":
+			problems = append(problems, fmt.Sprintf("argument %q is positional-only; pass it with Args", name))
+		case supplied[name]:
+			problems = append(problems, fmt.Sprintf("got multiple values for argument %q", name))
+		default:
+			supplied[name] = true
+		}
+	}
+
+	for _, param := range info.Parameters {
+		if param.Required && param.Kind != "VAR_POSITIONAL" && param.Kind != "VAR_KEYWORD" && !supplied[param.Name] {
+			problems = append(problems, fmt.Sprintf("missing required argument %q", param.Name))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid arguments for %s: %s", method, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
 // CallReflect executes the Python method and unmarshals the result into target.
 // Target must be a non-nil pointer. For complex types, JSON marshaling/unmarshaling
 // is used to handle nested structures.
@@ -702,6 +1856,42 @@ func (mc *methodCall) CallReflect(target interface{}) error {
 		return err
 	}
 
+	return unmarshalResult(result, target)
+}
+
+// CallTyped calls a Python method and converts the result to T, using the
+// same conversion as CallReflect: values that are not directly assignable
+// (structs, typed slices and maps) are converted through a JSON round-trip.
+// A timeout of zero or less waits indefinitely.
+//
+// Example:
+//
+//	users, err := jumpboot.CallTyped[[]User](queue, "get_users", 5*time.Second, nil)
+func CallTyped[T any](jq *QueueProcess, method string, timeout time.Duration, args interface{}) (T, error) {
+	var value T
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err := jq.CallContext(ctx, method, args)
+	if err != nil {
+		return value, err
+	}
+
+	if err := unmarshalResult(result, &value); err != nil {
+		var zero T
+		return zero, err
+	}
+	return value, nil
+}
+
+// unmarshalResult stores a decoded Python result in the value target points to,
+// converting through JSON when the types are not directly assignable.
+func unmarshalResult(result interface{}, target interface{}) error {
 	targetValue := reflect.ValueOf(target)
 	if targetValue.Kind() != reflect.Ptr || targetValue.IsNil() {
 		return fmt.Errorf("target must be a non-nil pointer")
@@ -709,6 +1899,11 @@ func (mc *methodCall) CallReflect(target interface{}) error {
 
 	targetElem := targetValue.Elem()
 
+	// A None result leaves the target at its zero value
+	if result == nil {
+		return nil
+	}
+
 	// Special handling for slices
 	if targetElem.Kind() == reflect.Slice {
 		resultSlice, ok := result.([]interface{})
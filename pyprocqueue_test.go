@@ -0,0 +1,1114 @@
+package jumpboot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testService struct {
+	calls int
+}
+
+type testPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (s testService) Add(a, b int) int {
+	return a + b
+}
+
+func (s testService) Sum(label string, values ...float64) (string, float64, error) {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return label, total, nil
+}
+
+func (s testService) Norm(p testPoint) (int, error) {
+	return p.X*p.X + p.Y*p.Y, nil
+}
+
+func (s testService) Fail() error {
+	return errors.New("failed")
+}
+
+func (s *testService) Count() int {
+	s.calls++
+	return s.calls
+}
+
+// callService invokes the handler registered for name on a fresh QueueProcess.
+func callService(t *testing.T, service interface{}, name string, args []interface{}) (interface{}, error) {
+	t.Helper()
+	jq := &QueueProcess{commandHandlers: map[string]CommandHandler{}}
+	jq.registerService(service)
+	handler, ok := jq.commandHandlers[name]
+	if !ok {
+		t.Fatalf("method %s was not registered", name)
+	}
+	return handler(args, "py-1")
+}
+
+// TestServiceMethodSignatures tests the method signatures supported by serviceStruct registration.
+func TestServiceMethodSignatures(t *testing.T) {
+	// Values arrive as msgpack decodes them, so small integers are int8
+	result, err := callService(t, testService{}, "Add", []interface{}{int8(2), int8(3)})
+	if err != nil || result != 5 {
+		t.Errorf("Add returned %v, %v; expected 5", result, err)
+	}
+
+	result, err = callService(t, testService{}, "Sum", []interface{}{"total", 1.5, int8(2)})
+	if err != nil || !reflect.DeepEqual(result, []interface{}{"total", 3.5}) {
+		t.Errorf("Sum returned %v, %v; expected [total 3.5]", result, err)
+	}
+
+	result, err = callService(t, testService{}, "Sum", []interface{}{"empty"})
+	if err != nil || !reflect.DeepEqual(result, []interface{}{"empty", 0.0}) {
+		t.Errorf("Sum with no variadic arguments returned %v, %v", result, err)
+	}
+
+	result, err = callService(t, testService{}, "Norm", []interface{}{map[string]interface{}{"x": int8(3), "y": int8(4)}})
+	if err != nil || result != 25 {
+		t.Errorf("Norm returned %v, %v; expected 25", result, err)
+	}
+
+	if _, err := callService(t, testService{}, "Fail", nil); err == nil {
+		t.Error("Fail did not return an error")
+	}
+
+	if _, err := callService(t, testService{}, "Add", []interface{}{int8(1)}); err == nil {
+		t.Error("Add with too few arguments did not return an error")
+	}
+
+	// Pointer-receiver methods are registered even when a value is passed
+	result, err = callService(t, testService{}, "Count", nil)
+	if err != nil || result != 1 {
+		t.Errorf("Count returned %v, %v; expected 1", result, err)
+	}
+}
+
+type testDefaultsService struct{}
+
+func (s testDefaultsService) Resize(path string, width int, height int) string {
+	return fmt.Sprintf("%s %dx%d", path, width, height)
+}
+
+func (s testDefaultsService) Find(name string, limit *int) int {
+	if limit == nil {
+		return -1
+	}
+	return *limit
+}
+
+func (s testDefaultsService) MethodDefaults() map[string][]interface{} {
+	return map[string][]interface{}{"Resize": {640, 480}}
+}
+
+// TestServiceMethodDefaults tests that optional trailing arguments may be omitted.
+func TestServiceMethodDefaults(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want string
+	}{
+		{[]interface{}{"a.png"}, "a.png 640x480"},
+		{[]interface{}{"a.png", int8(100)}, "a.png 100x480"},
+		{[]interface{}{"a.png", int8(100), int8(50)}, "a.png 100x50"},
+	}
+	for _, tt := range tests {
+		result, err := callService(t, testDefaultsService{}, "Resize", tt.args)
+		if err != nil || result != tt.want {
+			t.Errorf("Resize(%v) returned %v, %v; expected %q", tt.args, result, err, tt.want)
+		}
+	}
+
+	if _, err := callService(t, testDefaultsService{}, "Resize", nil); err == nil {
+		t.Error("Resize without its required argument did not return an error")
+	}
+
+	// Trailing pointer parameters are optional and receive nil
+	result, err := callService(t, testDefaultsService{}, "Find", []interface{}{"x"})
+	if err != nil || result != -1 {
+		t.Errorf("Find without limit returned %v, %v; expected -1", result, err)
+	}
+
+	jq := &QueueProcess{commandHandlers: map[string]CommandHandler{}}
+	jq.registerService(testDefaultsService{})
+	if _, ok := jq.commandHandlers["MethodDefaults"]; ok {
+		t.Error("MethodDefaults was registered as a command")
+	}
+}
+
+// fakePeer plays the Python side of a QueueProcess over net.Pipe. It answers
+// method discovery itself and passes every other message to a handler.
+type fakePeer struct {
+	transport  *MsgpackTransport
+	serializer MsgpackSerializer
+	sendMutex  sync.Mutex
+}
+
+// send encodes and sends a message to Go.
+func (p *fakePeer) send(message map[string]interface{}) {
+	data, err := p.serializer.Marshal(message)
+	if err != nil {
+		return
+	}
+	p.sendMutex.Lock()
+	defer p.sendMutex.Unlock()
+	p.transport.Send(data)
+}
+
+// newFakePeerQueue returns a QueueProcess connected to a fake peer that calls
+// handle for each message other than method discovery. The queue is closed
+// when the test ends.
+func newFakePeerQueue(t *testing.T, handle func(peer *fakePeer, msg map[string]interface{})) (*QueueProcess, *fakePeer) {
+	t.Helper()
+	goSide, pySide := net.Pipe()
+	peer := &fakePeer{transport: NewMsgpackTransport(pySide, nopWriteCloser{pySide})}
+
+	go func() {
+		for {
+			frame, err := peer.transport.Receive()
+			if err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if err := peer.serializer.Unmarshal(frame, &msg); err != nil {
+				return
+			}
+			if msg["command"] == "__get_methods__" {
+				peer.send(map[string]interface{}{"request_id": msg["request_id"], "methods": map[string]interface{}{}})
+				continue
+			}
+			if handle != nil {
+				handle(peer, msg)
+			}
+		}
+	}()
+
+	jq, err := NewQueueProcessOverConn(goSide, nil)
+	if err != nil {
+		t.Fatalf("NewQueueProcessOverConn failed: %v", err)
+	}
+	t.Cleanup(func() {
+		jq.Close()
+		pySide.Close()
+	})
+	return jq, peer
+}
+
+// TestConnQueueHasNoLocalProcess tests that the PythonProcess methods promoted
+// to a connection-backed QueueProcess report ErrNoLocalProcess instead of
+// dereferencing the nil embedded process.
+func TestConnQueueHasNoLocalProcess(t *testing.T) {
+	jq, _ := newFakePeerQueue(t, nil)
+
+	calls := map[string]func() error{
+		"Wait":      jq.Wait,
+		"Terminate": jq.Terminate,
+		"Suspend":   jq.Suspend,
+		"Resume":    jq.Resume,
+		"WaitForOutput": func() error {
+			_, err := jq.WaitForOutput(regexp.MustCompile("ready"), OutputStdout, time.Second)
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrNoLocalProcess) {
+			t.Errorf("%s returned %v, want ErrNoLocalProcess", name, err)
+		}
+	}
+
+	if jq.Suspended() {
+		t.Error("Suspended reported true without a process")
+	}
+	if timings := jq.StartupTimings(); timings.Complete {
+		t.Error("StartupTimings reported a complete startup without a process")
+	}
+}
+
+// TestCloseGraceful tests that CloseGraceful refuses new commands from
+// Python, lets a running handler send its response before "exit" is sent, and
+// fails requests Python never answered.
+func TestCloseGraceful(t *testing.T) {
+	msgs := make(chan map[string]interface{}, 16)
+	jq, peer := newFakePeerQueue(t, func(peer *fakePeer, msg map[string]interface{}) {
+		if msg["command"] == "exit" {
+			peer.send(map[string]interface{}{"request_id": msg["request_id"], "result": map[string]interface{}{"status": "exiting"}})
+		}
+		msgs <- msg
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	jq.RegisterHandler("work", func(data interface{}, requestID string) (interface{}, error) {
+		close(started)
+		<-release
+		return "done", nil
+	})
+
+	// A request Python never answers
+	unanswered := make(chan error, 1)
+	go func() {
+		_, err := jq.Call("never", 0, nil)
+		unanswered <- err
+	}()
+	if msg := <-msgs; msg["command"] != "never" {
+		t.Fatalf("unexpected message %v", msg)
+	}
+
+	peer.send(map[string]interface{}{"command": "work", "request_id": "py-1"})
+	<-started
+
+	closed := make(chan error, 1)
+	go func() { closed <- jq.CloseGraceful(5 * time.Second) }()
+	for {
+		jq.mutex.Lock()
+		closing := jq.closing
+		jq.mutex.Unlock()
+		if closing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// A command arriving while closing is refused
+	peer.send(map[string]interface{}{"command": "work", "request_id": "py-2"})
+	if msg := <-msgs; msg["request_id"] != "py-2" || msg["error"] != "queue process is closing" {
+		t.Errorf("response to a command sent while closing = %v", msg)
+	}
+
+	select {
+	case err := <-closed:
+		t.Fatalf("CloseGraceful returned %v while a handler was running", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	if msg := <-msgs; msg["request_id"] != "py-1" || msg["result"] != "done" {
+		t.Errorf("expected the running handler's response first, got %v", msg)
+	}
+	if msg := <-msgs; msg["command"] != "exit" {
+		t.Errorf("expected the exit command, got %v", msg)
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("CloseGraceful failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CloseGraceful did not return")
+	}
+
+	if err := <-unanswered; err == nil || !strings.Contains(err.Error(), "queue process closed") {
+		t.Errorf("unanswered request returned %v", err)
+	}
+	select {
+	case <-jq.loopDone:
+	default:
+		t.Error("the message loop is still running after CloseGraceful")
+	}
+}
+
+// TestAwaitResponseLoopStopped tests that AwaitResponse returns io.EOF instead
+// of blocking forever when the connection closes before the response arrives,
+// and that unanswered async requests are forgotten when the loop stops.
+func TestAwaitResponseLoopStopped(t *testing.T) {
+	received := make(chan string, 2)
+	jq, peer := newFakePeerQueue(t, func(peer *fakePeer, msg map[string]interface{}) {
+		received <- msg["request_id"].(string)
+	})
+
+	// Python answers neither request before the connection closes
+	awaited, err := jq.SendCommandAsync("slow", nil)
+	if err != nil {
+		t.Fatalf("SendCommandAsync failed: %v", err)
+	}
+	unawaited, err := jq.SendCommandAsync("slow", nil)
+	if err != nil {
+		t.Fatalf("SendCommandAsync failed: %v", err)
+	}
+	<-received
+	<-received
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := jq.AwaitResponse(awaited, 0)
+		result <- err
+	}()
+	peer.transport.Close()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("expected io.EOF, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("AwaitResponse did not return after the connection closed")
+	}
+
+	jq.mutex.Lock()
+	pending, waiting := len(jq.asyncResponses), len(jq.responseMap)
+	jq.mutex.Unlock()
+	if pending != 0 || waiting != 0 {
+		t.Errorf("expected no pending requests, got %d async and %d waiting", pending, waiting)
+	}
+	if _, err := jq.AwaitResponse(unawaited, time.Second); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF for a request dropped when the loop stopped, got %v", err)
+	}
+}
+
+// TestDiscardResponse tests that a discarded async request is forgotten and
+// its late response is dropped.
+func TestDiscardResponse(t *testing.T) {
+	received := make(chan string, 1)
+	jq, peer := newFakePeerQueue(t, func(peer *fakePeer, msg map[string]interface{}) {
+		if msg["command"] == "echo" {
+			peer.send(map[string]interface{}{"request_id": msg["request_id"], "result": msg["data"]})
+			return
+		}
+		received <- msg["request_id"].(string)
+	})
+
+	requestID, err := jq.SendCommandAsync("work", nil)
+	if err != nil {
+		t.Fatalf("SendCommandAsync failed: %v", err)
+	}
+	<-received
+	jq.DiscardResponse(requestID)
+
+	// The echo response follows the late one, so the late one has been handled once it returns
+	peer.send(map[string]interface{}{"request_id": requestID, "result": "late"})
+	if _, err := jq.SendCommand("echo", "sync", 5, true); err != nil {
+		t.Fatalf("SendCommand failed: %v", err)
+	}
+
+	jq.mutex.Lock()
+	_, pending := jq.asyncResponses[requestID]
+	_, waiting := jq.responseMap[requestID]
+	jq.mutex.Unlock()
+	if pending || waiting {
+		t.Error("discarded request is still pending")
+	}
+	if _, err := jq.AwaitResponse(requestID, time.Second); err == nil {
+		t.Error("expected an error awaiting a discarded request")
+	}
+}
+
+// TestNewQueueProcessOverEmptyReplay tests that method discovery gives up when
+// the transport reaches EOF instead of blocking forever.
+func TestNewQueueProcessOverEmptyReplay(t *testing.T) {
+	replay, err := NewReplayTransport(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("NewReplayTransport failed: %v", err)
+	}
+
+	created := make(chan error, 1)
+	go func() {
+		jq, err := NewQueueProcessOverTransport(replay, nil)
+		if err == nil {
+			jq.Close()
+		}
+		created <- err
+	}()
+
+	select {
+	case err := <-created:
+		if err != nil {
+			t.Fatalf("NewQueueProcessOverTransport failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("NewQueueProcessOverTransport blocked on an exhausted replay")
+	}
+}
+
+// TestCallContextCancel tests that cancelling a call abandons the request,
+// asks Python to cancel it and drops its late response.
+func TestCallContextCancel(t *testing.T) {
+	slow := make(chan map[string]interface{}, 1)
+	cancelled := make(chan interface{}, 1)
+	jq, peer := newFakePeerQueue(t, func(peer *fakePeer, msg map[string]interface{}) {
+		switch msg["command"] {
+		case "slow":
+			slow <- msg
+		case "__cancel__":
+			cancelled <- msg["data"].(map[string]interface{})["request_id"]
+		case "echo":
+			peer.send(map[string]interface{}{"request_id": msg["request_id"], "result": msg["data"]})
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	result := make(chan error, 1)
+	go func() {
+		_, err := jq.CallContext(ctx, "slow", nil)
+		result <- err
+	}()
+
+	request := <-slow
+	requestID := request["request_id"].(string)
+	meta, _ := request["meta"].(map[string]interface{})
+	if _, ok := meta["budget_ms"]; !ok {
+		t.Errorf("expected the deadline to be sent as a budget, got meta %v", request["meta"])
+	}
+	cancel()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CallContext did not return after cancellation")
+	}
+
+	select {
+	case id := <-cancelled:
+		if id != requestID {
+			t.Errorf("__cancel__ named request %v, want %s", id, requestID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no __cancel__ command was sent")
+	}
+
+	jq.mutex.Lock()
+	_, waiting := jq.responseMap[requestID]
+	jq.mutex.Unlock()
+	if waiting {
+		t.Error("cancelled request is still in the response map")
+	}
+
+	// A late response is dropped and does not disturb the next call
+	peer.send(map[string]interface{}{"request_id": requestID, "result": "late"})
+	got, err := jq.CallContext(context.Background(), "echo", "next")
+	if err != nil || got != "next" {
+		t.Errorf("expected the next call to return %q, got %v, %v", "next", got, err)
+	}
+}
+
+// TestSubscribe tests event delivery, dropping events for a full subscriber,
+// unsubscribing, and closing subscriptions when the message loop stops.
+func TestSubscribe(t *testing.T) {
+	jq, peer := newFakePeerQueue(t, func(peer *fakePeer, msg map[string]interface{}) {
+		if msg["command"] == "echo" {
+			peer.send(map[string]interface{}{"request_id": msg["request_id"], "result": msg["data"]})
+		}
+	})
+	// flush returns once the loop has handled everything the peer sent before it
+	flush := func() {
+		t.Helper()
+		if _, err := jq.SendCommand("echo", nil, 5, true); err != nil {
+			t.Fatalf("SendCommand failed: %v", err)
+		}
+	}
+
+	full, _ := jq.Subscribe("progress")
+	other, unsubscribe := jq.Subscribe("progress")
+	idle, _ := jq.Subscribe("status")
+
+	// Nobody reads full, so the events past its buffer are dropped
+	for i := 0; i < eventBufferSize+10; i++ {
+		peer.send(map[string]interface{}{"event": "progress", "data": i})
+	}
+	flush()
+	if len(full) != eventBufferSize {
+		t.Errorf("expected %d buffered events, got %d", eventBufferSize, len(full))
+	}
+	first := <-full
+	if n := reflect.ValueOf(first).Convert(reflect.TypeOf(0)).Interface(); n != 0 {
+		t.Errorf("expected the first event to be kept, got %v", first)
+	}
+
+	// Unsubscribing closes the channel after its buffered events
+	unsubscribe()
+	unsubscribe()
+	drained := 0
+	for range other {
+		drained++
+	}
+	if drained != eventBufferSize {
+		t.Errorf("expected %d events before close, got %d", eventBufferSize, drained)
+	}
+	peer.send(map[string]interface{}{"event": "progress", "data": "after"})
+	flush()
+
+	// Stopping the loop closes the remaining subscriptions
+	peer.transport.Close()
+	for _, ch := range []<-chan interface{}{full, idle} {
+		timeout := time.After(5 * time.Second)
+	drain:
+		for {
+			select {
+			case _, ok := <-ch:
+				if !ok {
+					break drain
+				}
+			case <-timeout:
+				t.Fatal("subscription was not closed when the loop stopped")
+			}
+		}
+	}
+	late, _ := jq.Subscribe("late")
+	if _, ok := <-late; ok {
+		t.Error("expected a subscription made after the loop stopped to be closed")
+	}
+}
+
+// TestPing tests a successful ping, a ping Python never answers, and that
+// LastActivity advances when messages arrive.
+func TestPing(t *testing.T) {
+	answer := make(chan bool, 1)
+	answer <- true
+	jq, peer := newFakePeerQueue(t, func(peer *fakePeer, msg map[string]interface{}) {
+		if msg["command"] == "__ping__" && <-answer {
+			peer.send(map[string]interface{}{"request_id": msg["request_id"], "result": "pong"})
+		}
+	})
+
+	if err := jq.Ping(5 * time.Second); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if jq.LastPingRTT() <= 0 {
+		t.Errorf("expected a positive round-trip time, got %v", jq.LastPingRTT())
+	}
+	before := jq.LastActivity()
+
+	// A wedged Python leaves the ping unanswered
+	answer <- false
+	err := jq.Ping(50 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a ping timeout, got %v", err)
+	}
+	jq.mutex.Lock()
+	waiting := len(jq.responseMap)
+	jq.mutex.Unlock()
+	if waiting != 0 {
+		t.Errorf("expected the timed-out ping to be forgotten, %d requests waiting", waiting)
+	}
+	if !jq.LastActivity().Equal(before) {
+		t.Error("LastActivity changed without any message from Python")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	peer.send(map[string]interface{}{"event": "heartbeat"})
+	deadline := time.Now().Add(5 * time.Second)
+	for !jq.LastActivity().After(before) {
+		if time.Now().After(deadline) {
+			t.Fatal("LastActivity was not updated by an incoming message")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Pinging a closed connection fails immediately
+	peer.transport.Close()
+	<-jq.loopDone
+	if err := jq.Ping(5 * time.Second); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF after the connection closed, got %v", err)
+	}
+}
+
+// TestBinaryMessages tests that messages in both directions are decoded with
+// the queue's serializer: binary payloads that aren't valid JSON arrive
+// intact, and a handler result the serializer can't encode is answered with
+// an error instead of leaving Python waiting.
+func TestBinaryMessages(t *testing.T) {
+	binary := []byte{0x00, 0xc1, 0xff, 0xfe, '{'}
+	responses := make(chan map[string]interface{}, 2)
+	jq, peer := newFakePeerQueue(t, func(peer *fakePeer, msg map[string]interface{}) {
+		if msg["command"] == "blob" {
+			peer.send(map[string]interface{}{"request_id": msg["request_id"], "result": binary})
+			return
+		}
+		responses <- msg
+	})
+
+	result, err := jq.Call("blob", 5, nil)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if got, ok := result.([]byte); !ok || !bytes.Equal(got, binary) {
+		t.Errorf("result = %#v, want %#v", result, binary)
+	}
+
+	jq.RegisterHandler("reverse", func(data interface{}, requestID string) (interface{}, error) {
+		in := data.([]byte)
+		out := make([]byte, len(in))
+		for i, b := range in {
+			out[len(in)-1-i] = b
+		}
+		return out, nil
+	})
+	jq.RegisterHandler("unencodable", func(data interface{}, requestID string) (interface{}, error) {
+		return make(chan int), nil
+	})
+	peer.send(map[string]interface{}{"command": "reverse", "data": binary, "request_id": "py-1"})
+	peer.send(map[string]interface{}{"command": "unencodable", "request_id": "py-2"})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-responses:
+			switch msg["request_id"] {
+			case "py-1":
+				if got, ok := msg["result"].([]byte); !ok || !bytes.Equal(got, []byte{'{', 0xfe, 0xff, 0xc1, 0x00}) {
+					t.Errorf("reverse result = %#v", msg["result"])
+				}
+			case "py-2":
+				if errMsg, _ := msg["error"].(string); !strings.Contains(errMsg, "error encoding result") {
+					t.Errorf("expected an encoding error response, got %v", msg)
+				}
+			default:
+				t.Errorf("unexpected message %v", msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for responses from Go")
+		}
+	}
+}
+
+// runOnceScript is a minimal queue server for TestRunOnce.
+const runOnceScript = `
+import time
+from jumpboot import MessagePackQueueServer, exposed
+
+class Service(MessagePackQueueServer):
+    @exposed
+    def add(self, a, b):
+        return a + b
+
+    @exposed
+    def fail(self):
+        raise ValueError("bad input")
+
+if __name__ == "__main__":
+    service = Service()
+    while service.running:
+        time.sleep(0.1)
+`
+
+// TestRunOnce tests that RunOnce returns the call's result or error and
+// terminates the process either way.
+func TestRunOnce(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	program := &PythonProgram{
+		Name:    "RunOnce",
+		Path:    "./",
+		Program: *NewModuleFromString("__main__", "runonce.py", runOnceScript),
+	}
+
+	result, err := env.RunOnce(program, "add", map[string]interface{}{"a": 2, "b": 3}, 30*time.Second)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+	if n := reflect.ValueOf(result).Convert(reflect.TypeOf(0)).Interface(); n != 5 {
+		t.Errorf("expected 5, got %v", result)
+	}
+
+	if _, err := env.RunOnce(program, "fail", nil, 30*time.Second); err == nil || !strings.Contains(err.Error(), "bad input") {
+		t.Errorf("expected the Python error, got %v", err)
+	}
+
+	runningProcessesMutex.Lock()
+	remaining := len(runningProcesses[env.EnvPath])
+	runningProcessesMutex.Unlock()
+	if remaining != 0 {
+		t.Errorf("expected no running processes, got %d", remaining)
+	}
+}
+
+// positionalScript is a queue server with positional-only and *args methods
+// for TestMethodCallArgs.
+const positionalScript = `
+import time
+from jumpboot import MessagePackQueueServer
+
+class Service(MessagePackQueueServer):
+    def sub(self, a, b, /, scale=1):
+        return (a - b) * scale
+
+    def total(self, *values):
+        return sum(values)
+
+if __name__ == "__main__":
+    service = Service()
+    while service.running:
+        time.sleep(0.1)
+`
+
+// TestMethodCallArgs tests that Args sends positional arguments that Python
+// receives as *args, alone and combined with keyword arguments from Do.
+func TestMethodCallArgs(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	program := &PythonProgram{
+		Name:    "Positional",
+		Path:    "./",
+		Program: *NewModuleFromString("__main__", "positional.py", positionalScript),
+	}
+	queue, err := env.NewQueueProcess(program, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewQueueProcess failed: %v", err)
+	}
+	defer queue.Close()
+
+	toInt := func(v interface{}) interface{} {
+		return reflect.ValueOf(v).Convert(reflect.TypeOf(0)).Interface()
+	}
+	result, err := queue.On("sub").Args(10, 4).Do("scale", 2).WithTimeout(30 * time.Second).Call()
+	if err != nil || toInt(result) != 12 {
+		t.Errorf("sub(10, 4, scale=2) = %v, %v; want 12", result, err)
+	}
+	result, err = queue.On("total").Args(1, 2).Args(3).WithTimeout(30 * time.Second).Call()
+	if err != nil || toInt(result) != 6 {
+		t.Errorf("total(1, 2, 3) = %v, %v; want 6", result, err)
+	}
+	// Positional-only parameters can't be passed by name
+	if _, err := queue.On("sub").Do("a", 10, "b", 4).WithTimeout(30 * time.Second).Call(); err == nil {
+		t.Error("expected an error passing positional-only parameters by name")
+	}
+
+	info, ok := queue.GetMethodInfo("sub")
+	if !ok || len(info.Parameters) != 3 || info.Parameters[0].Kind != "POSITIONAL_ONLY" || info.Parameters[2].Required {
+		t.Errorf("GetMethodInfo(sub) = %+v, %v", info, ok)
+	}
+	if info, ok := queue.GetMethodInfo("total"); !ok || len(info.Parameters) != 1 || info.Parameters[0].Required {
+		t.Errorf("GetMethodInfo(total) = %+v, %v; want an optional *values", info, ok)
+	}
+	result, err = queue.On("sub").Strict().Args(10, 4).WithTimeout(30 * time.Second).Call()
+	if err != nil || toInt(result) != 6 {
+		t.Errorf("strict sub(10, 4) = %v, %v; want 6", result, err)
+	}
+}
+
+// TestRefreshMethods tests that RefreshMethods picks up a method the Python
+// service registers after startup.
+func TestRefreshMethods(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	program := &PythonProgram{
+		Name: "Refresh",
+		Path: "./",
+		Program: *NewModuleFromString("__main__", "refresh.py", `
+import time, types
+from jumpboot import MessagePackQueueServer
+
+class Service(MessagePackQueueServer):
+    def add_double(self, name):
+        setattr(self, name, types.MethodType(lambda self, x: x * 2, self))
+        self.register_method(name, getattr(self, name))
+        return True
+
+if __name__ == "__main__":
+    service = Service()
+    while service.running:
+        time.sleep(0.1)
+`),
+	}
+	queue, err := env.NewQueueProcess(program, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewQueueProcess failed: %v", err)
+	}
+	defer queue.Close()
+
+	if _, ok := queue.GetMethodInfo("double"); ok {
+		t.Fatal("double was discovered before it was registered")
+	}
+	if _, err := queue.On("add_double").Do("name", "double").WithTimeout(30 * time.Second).Call(); err != nil {
+		t.Fatalf("add_double failed: %v", err)
+	}
+	if err := queue.RefreshMethods(); err != nil {
+		t.Fatalf("RefreshMethods failed: %v", err)
+	}
+	info, ok := queue.GetMethodInfo("double")
+	if !ok || len(info.Parameters) != 1 || info.Parameters[0].Name != "x" || !info.Parameters[0].Required {
+		t.Errorf("GetMethodInfo(double) = %+v, %v; want one required parameter x", info, ok)
+	}
+	if _, ok := queue.GetMethodInfo("add_double"); !ok {
+		t.Error("add_double is missing after RefreshMethods")
+	}
+}
+
+// TestParseMethodInfo tests that malformed introspection data is reported as
+// an error instead of panicking.
+func TestParseMethodInfo(t *testing.T) {
+	info, err := parseMethodInfo(map[string]interface{}{
+		"parameters": []interface{}{
+			map[string]interface{}{"name": "x", "required": true, "type": "<class 'int'>", "kind": "POSITIONAL_OR_KEYWORD"},
+		},
+		"return": map[string]interface{}{"type": "<class 'int'>"},
+	})
+	if err != nil {
+		t.Fatalf("parseMethodInfo failed: %v", err)
+	}
+	want := MethodInfo{
+		Parameters: []ParameterInfo{{Name: "x", Required: true, Type: "<class 'int'>", Kind: "POSITIONAL_OR_KEYWORD"}},
+		Return:     map[string]string{"type": "<class 'int'>"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("parseMethodInfo = %+v; want %+v", info, want)
+	}
+
+	malformed := []interface{}{
+		"not a map",
+		map[string]interface{}{"doc": 42},
+		map[string]interface{}{"return": "int"},
+		map[string]interface{}{"parameters": "x"},
+		map[string]interface{}{"parameters": []interface{}{"x"}},
+		map[string]interface{}{"parameters": []interface{}{map[string]interface{}{"name": 1}}},
+		map[string]interface{}{"parameters": []interface{}{map[string]interface{}{"name": "x", "required": "yes"}}},
+		map[string]interface{}{"parameters": []interface{}{map[string]interface{}{"name": "x", "required": true, "kind": 1}}},
+	}
+	for _, m := range malformed {
+		if _, err := parseMethodInfo(m); err == nil {
+			t.Errorf("parseMethodInfo(%v) succeeded; want an error", m)
+		}
+	}
+}
+
+// TestDiscoverMethodsMissingFields tests that discovery registers a method
+// whose introspection data lacks a doc, parameter types and parameter names.
+func TestDiscoverMethodsMissingFields(t *testing.T) {
+	goSide, pySide := net.Pipe()
+	peer := &fakePeer{transport: NewMsgpackTransport(pySide, nopWriteCloser{pySide})}
+	go func() {
+		for {
+			frame, err := peer.transport.Receive()
+			if err != nil {
+				return
+			}
+			var msg map[string]interface{}
+			if err := peer.serializer.Unmarshal(frame, &msg); err != nil {
+				return
+			}
+			if msg["command"] == "__get_methods__" {
+				peer.send(map[string]interface{}{"request_id": msg["request_id"], "methods": map[string]interface{}{
+					"scale": map[string]interface{}{
+						"parameters": []interface{}{
+							map[string]interface{}{"name": "value", "required": true},
+							map[string]interface{}{"name": "factor"},
+							map[string]interface{}{"required": true},
+						},
+					},
+				}})
+			}
+		}
+	}()
+
+	jq, err := NewQueueProcessOverConn(goSide, nil)
+	if err != nil {
+		t.Fatalf("NewQueueProcessOverConn failed: %v", err)
+	}
+	defer func() {
+		jq.Close()
+		pySide.Close()
+	}()
+
+	info, ok := jq.GetMethodInfo("scale")
+	if !ok {
+		t.Fatalf("scale was not registered; methods: %v", jq.GetMethods())
+	}
+	want := []ParameterInfo{{Name: "value", Required: true}, {Name: "factor"}}
+	if info.Doc != "" || !reflect.DeepEqual(info.Parameters, want) {
+		t.Errorf("GetMethodInfo(scale) = %+v; want no doc and parameters %+v", info, want)
+	}
+}
+
+// TestQueueIdleTimeout tests that an idle queue calls OnIdleShutdown and
+// closes, and that activity and pending requests keep it open.
+func TestQueueIdleTimeout(t *testing.T) {
+	release := make(chan struct{})
+	jq, _ := newFakePeerQueue(t, func(peer *fakePeer, msg map[string]interface{}) {
+		reply := map[string]interface{}{"request_id": msg["request_id"], "result": "ok"}
+		if msg["command"] == "slow" {
+			go func() {
+				<-release
+				peer.send(reply)
+			}()
+			return
+		}
+		peer.send(reply)
+	})
+
+	const timeout = 200 * time.Millisecond
+	var hookCalls atomic.Int32
+	jq.setIdleTimeout(timeout, func(queue *QueueProcess) {
+		// The queue is still usable from the hook
+		if _, err := queue.Call("save", 5, nil); err != nil {
+			t.Errorf("Call from OnIdleShutdown failed: %v", err)
+		}
+		hookCalls.Add(1)
+	})
+
+	// Regular calls keep the queue open past the timeout
+	for i := 0; i < 5; i++ {
+		if _, err := jq.Call("work", 5, nil); err != nil {
+			t.Fatalf("Call %d failed: %v", i, err)
+		}
+		time.Sleep(timeout / 2)
+	}
+
+	// So does a request Python hasn't answered yet
+	go func() {
+		time.Sleep(3 * timeout)
+		close(release)
+	}()
+	if _, err := jq.Call("slow", 5, nil); err != nil {
+		t.Fatalf("slow Call failed: %v", err)
+	}
+	if hookCalls.Load() != 0 {
+		t.Fatal("the queue was closed while it was in use")
+	}
+
+	select {
+	case <-jq.loopDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the idle queue was not closed")
+	}
+	if n := hookCalls.Load(); n != 1 {
+		t.Errorf("OnIdleShutdown was called %d times; want 1", n)
+	}
+	if _, err := jq.Call("work", 1, nil); err == nil {
+		t.Error("Call succeeded after the idle shutdown")
+	}
+}
+
+// TestMethodCallStrict tests that Strict rejects arguments the discovered
+// method can't accept without sending the call.
+func TestMethodCallStrict(t *testing.T) {
+	var calls atomic.Int32
+	jq, _ := newFakePeerQueue(t, func(peer *fakePeer, msg map[string]interface{}) {
+		calls.Add(1)
+		peer.send(map[string]interface{}{"request_id": msg["request_id"], "result": "ok"})
+	})
+	jq.methodCache["resize"] = MethodInfo{
+		Parameters: []ParameterInfo{
+			{Name: "image", Required: true, Kind: "POSITIONAL_OR_KEYWORD"},
+			{Name: "width", Required: true, Kind: "POSITIONAL_OR_KEYWORD"},
+			{Name: "quality", Required: false, Kind: "KEYWORD_ONLY"},
+		},
+	}
+
+	invalid := []struct {
+		name string
+		call *methodCall
+		want string
+	}{
+		{"missing required", jq.On("resize").Strict().Do("image", "a.png"), `missing required argument "width"`},
+		{"unknown argument", jq.On("resize").Strict().Do("image", "a.png", "width", 10, "qualty", 90), `unknown argument "qualty"`},
+		{"too many positional", jq.On("resize").Strict().Args("a.png", 10, 90), "takes 2 positional arguments but 3 were given"},
+		{"multiple values", jq.On("resize").Strict().Args("a.png", 10).Do("width", 20), `got multiple values for argument "width"`},
+	}
+	for _, tc := range invalid {
+		_, err := tc.call.WithTimeout(5 * time.Second).Call()
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: Call() error = %v; want it to contain %q", tc.name, err, tc.want)
+		}
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("invalid strict calls sent %d messages; want 0", n)
+	}
+
+	valid := []*methodCall{
+		jq.On("resize").Strict().Do("image", "a.png", "width", 10, "quality", 90),
+		jq.On("resize").Strict().Args("a.png").Do("width", 10),
+		// Without Strict, the arguments are left for Python to check
+		jq.On("resize").Do("qualty", 90),
+		// Undiscovered methods aren't checked
+		jq.On("other").Strict().Do("anything", 1),
+	}
+	for i, call := range valid {
+		if _, err := call.WithTimeout(5 * time.Second).Call(); err != nil {
+			t.Errorf("call %d failed: %v", i, err)
+		}
+	}
+	if n := calls.Load(); n != int32(len(valid)) {
+		t.Errorf("valid calls sent %d messages; want %d", n, len(valid))
+	}
+}
+
+// lockedBuffer is a bytes.Buffer safe for the output forwarding goroutines.
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// TestQueueProcessIO tests that QueueOptions.IO receives Python's output,
+// including what it prints just before Close.
+func TestQueueProcessIO(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	program := &PythonProgram{
+		Name: "QueueIO",
+		Path: "./",
+		Program: *NewModuleFromString("__main__", "queueio.py", `
+import sys, time
+from jumpboot import MessagePackQueueServer, exposed
+
+class Service(MessagePackQueueServer):
+    @exposed
+    def say(self, text):
+        print("out:", text)
+        print("err:", text, file=sys.stderr)
+        return True
+
+if __name__ == "__main__":
+    service = Service()
+    while service.running:
+        time.sleep(0.1)
+`),
+	}
+
+	var stdout, stderr lockedBuffer
+	queue, err := env.NewQueueProcessWithOptions(program, nil, nil, nil, QueueOptions{IO: ProcessIO{Stdout: &stdout, Stderr: &stderr}})
+	if err != nil {
+		t.Fatalf("NewQueueProcessWithOptions failed: %v", err)
+	}
+	if _, err := queue.Call("say", 10, map[string]interface{}{"text": "hello"}); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if err := queue.Close(); err != nil {
+		t.Logf("Close: %v", err)
+	}
+
+	if !strings.Contains(stdout.String(), "out: hello") {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if !strings.Contains(stderr.String(), "err: hello") || strings.Contains(stderr.String(), "out:") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+	if err := queue.ForwardOutput(ProcessIO{}); err == nil {
+		t.Error("expected an error forwarding already forwarded output")
+	}
+}
@@ -2,8 +2,10 @@ package jumpboot
 
 import (
 	"bufio"
+	"context"
 	_ "embed"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -74,6 +76,8 @@ func (env *PythonEnvironment) NewREPLPythonProcess(kvpairs map[string]interface{
 		Modules:  modules,
 		Packages: packages,
 		KVPairs:  kvpairs,
+		// run where Path says the program is
+		WorkingDir: cwd,
 		// KVPairs:  map[string]interface{}{"SHARED_MEMORY_NAME": name, "SHARED_MEMORY_SIZE": size, "SEMAPHORE_NAME": semaphore_name},
 	}
 
@@ -111,8 +115,6 @@ const WINDELIMITER = "\x01\x02\x03\r\n"
 //
 // Empty lines in the code are normalized and trailing whitespace is trimmed.
 func (rpp *REPLPythonProcess) Execute(code string, combinedOutput bool) (string, error) {
-	iswin := runtime.GOOS == "windows"
-
 	// we need to lock the mutex to prevent multiple goroutines from writing to the Python process at the same time
 	rpp.m.Lock()
 	defer rpp.m.Unlock()
@@ -122,52 +124,206 @@ func (rpp *REPLPythonProcess) Execute(code string, combinedOutput bool) (string,
 		return "", fmt.Errorf("REPL process has been closed")
 	}
 
-	// if we are changing the combined output setting, update the Python process
-	if rpp.combinedOutput != combinedOutput {
-		cc := "__CAPTURE_COMBINED__ ="
-		if combinedOutput {
-			cc += " True" + DELIMITER
-		} else {
-			cc += " False" + DELIMITER
-		}
-		_, err := rpp.PythonProcess.PipeOut.WriteString(cc)
-		if err != nil {
-			return "", err
-		}
-		rpp.combinedOutput = combinedOutput
+	if err := rpp.setCombinedOutput(combinedOutput); err != nil {
+		return "", err
+	}
+
+	return rpp.run(cleanREPLCode(code)+DELIMITER, nil)
+}
+
+// streamPrefix marks a REPL request that ExecuteStream sends.
+const streamPrefix = "__STREAM__\n"
+
+// ExecuteStream runs Python code in the REPL like Execute, but calls onLine
+// with each line of output as soon as Python prints it instead of only
+// returning the output once the code completes. This lets long-running code
+// report its progress:
+//
+//	out, err := repl.ExecuteStream("for i in range(3):\n    print(i); time.sleep(1)", true, func(line string) {
+//		fmt.Println("python:", line)
+//	})
+//
+// Lines are passed without their line ending, and onLine is called from the
+// goroutine that called ExecuteStream. If combinedOutput is false only stdout
+// is streamed. The full output is still returned, as Execute returns it.
+func (rpp *REPLPythonProcess) ExecuteStream(code string, combinedOutput bool, onLine func(line string)) (string, error) {
+	rpp.m.Lock()
+	defer rpp.m.Unlock()
+
+	if rpp.closed {
+		return "", fmt.Errorf("REPL process has been closed")
+	}
+
+	if err := rpp.setCombinedOutput(combinedOutput); err != nil {
+		return "", err
+	}
+
+	if onLine == nil {
+		onLine = func(string) {}
+	}
+	return rpp.run(streamPrefix+cleanREPLCode(code)+DELIMITER, onLine)
+}
+
+// resetCommand is the REPL request that Reset sends.
+const resetCommand = "__RESET__"
+
+// Reset clears the REPL's state without restarting the Python process: the
+// variables, functions and imported names of earlier calls are discarded and
+// later code runs in a fresh namespace. Modules stay loaded in sys.modules, so
+// importing them again is cheap, and KVPairs remain available on the jumpboot
+// module.
+//
+// Returns an error if the REPL is closed or if there's a communication error.
+func (rpp *REPLPythonProcess) Reset() error {
+	rpp.m.Lock()
+	defer rpp.m.Unlock()
+
+	if rpp.closed {
+		return fmt.Errorf("REPL process has been closed")
+	}
+
+	_, err := rpp.run(resetCommand+DELIMITER, nil)
+	return err
+}
+
+// inspectCommand is the REPL request that Inspect sends.
+const inspectCommand = "__INSPECT__"
+
+// VarInfo describes a variable defined in the REPL, as reported by Inspect.
+type VarInfo struct {
+	// Type is the name of the value's type, qualified by its module unless it
+	// is a builtin (e.g., "int" or "numpy.ndarray").
+	Type string `json:"type"`
+
+	// Repr is the value's repr(), cut to 200 characters followed by "...".
+	// It is empty if repr() failed or the value has more than 10000 items.
+	Repr string `json:"repr,omitempty"`
+
+	// Size is the value's size in bytes as reported by sys.getsizeof, which
+	// does not include the objects it refers to (0 if unknown).
+	Size int64 `json:"size,omitempty"`
+}
+
+// Inspect returns the variables defined in the REPL keyed by name, for
+// variable explorers in notebook and IDE integrations. Dunder names and the
+// jumpboot module are left out; imported modules and functions are included.
+//
+// Returns an error if the REPL is closed or if there's a communication error.
+func (rpp *REPLPythonProcess) Inspect() (map[string]VarInfo, error) {
+	rpp.m.Lock()
+	defer rpp.m.Unlock()
+
+	if rpp.closed {
+		return nil, fmt.Errorf("REPL process has been closed")
+	}
+
+	output, err := rpp.run(inspectCommand+DELIMITER, nil)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]VarInfo)
+	if err := json.Unmarshal([]byte(output), &vars); err != nil {
+		return nil, fmt.Errorf("error decoding REPL variables: %v", err)
+	}
+	return vars, nil
+}
+
+// setCombinedOutput updates the Python process if the combined output setting
+// is changing. The caller must hold rpp.m.
+func (rpp *REPLPythonProcess) setCombinedOutput(combinedOutput bool) error {
+	if rpp.combinedOutput == combinedOutput {
+		return nil
+	}
+	cc := "__CAPTURE_COMBINED__ ="
+	if combinedOutput {
+		cc += " True" + DELIMITER
+	} else {
+		cc += " False" + DELIMITER
+	}
+	if _, err := rpp.PythonProcess.PipeOut.WriteString(cc); err != nil {
+		return err
 	}
+	rpp.combinedOutput = combinedOutput
+	return nil
+}
 
+// cleanREPLCode removes empty lines and trailing whitespace from code sent to
+// the interactive console.
+func cleanREPLCode(code string) string {
 	// remove empty lines from the code - account for \r\n line endings on Windows
 	code = strings.ReplaceAll(code, "\r\n", "\n")
 	code = strings.ReplaceAll(code, "\n\n", "\n")
 
 	// trim whitespace from the end of the code
+	return strings.TrimRight(code, " \t\n\r")
+}
+
+// evalPrefix marks a REPL request that EvalExpression sends.
+const evalPrefix = "__EVAL__\n"
+
+// EvalExpression runs Python code in the REPL and, if its last statement is an
+// expression, returns the repr() of that expression's value, as the Python
+// interactive interpreter echoes it. Preceding statements run first, so
+// multi-statement blocks work:
+//
+//	value, _ := repl.EvalExpression("import math\nmath.pi * 2")  // "6.283185307179586"
+//
+// The result is empty if the last statement is not an expression or its value
+// is None. Unlike Execute, output printed by the code is not returned. As in
+// the interactive interpreter, the value is also stored in the variable _.
+//
+// Returns an error if the REPL is closed, if there's a communication error, or
+// if the Python code raised an exception (the error contains the traceback).
+func (rpp *REPLPythonProcess) EvalExpression(code string) (string, error) {
+	rpp.m.Lock()
+	defer rpp.m.Unlock()
+
+	if rpp.closed {
+		return "", fmt.Errorf("REPL process has been closed")
+	}
+
+	// the code is parsed as a whole, so blank lines are kept
+	code = strings.ReplaceAll(code, "\r\n", "\n")
 	code = strings.TrimRight(code, " \t\n\r")
 
-	// append the DELIMITER to the end of the code
-	code += DELIMITER
+	return rpp.run(evalPrefix+code+DELIMITER, nil)
+}
 
-	// write the code to the Python process as a single string
-	_, err := rpp.PythonProcess.PipeOut.WriteString(code)
-	if err != nil {
-		return "", err
+// Interrupt raises KeyboardInterrupt in the code the REPL is running, like
+// pressing Ctrl-C in an interactive interpreter, without losing the
+// interpreter's state. The interrupted Execute or EvalExpression returns the
+// KeyboardInterrupt exception as its error and the REPL stays usable.
+// Interrupt may be called from any goroutine and has no effect while no code
+// is running.
+//
+// Interrupt sends SIGINT to the Python process, so code that catches
+// KeyboardInterrupt or blocks in a call that ignores signals may not stop,
+// and an interrupt sent before the REPL has finished starting terminates it.
+// Interrupt is not supported on Windows and returns an error there.
+func (rpp *REPLPythonProcess) Interrupt() error {
+	if rpp.PythonProcess == nil || rpp.Cmd.Process == nil {
+		return fmt.Errorf("REPL process is not running")
 	}
+	return interruptProcess(rpp.Cmd.Process)
+}
 
-	// we will receive a status or an exception first
-	var exception *PythonException = nil
-	var exerr error = nil
-	select {
-	case <-rpp.StatusChan:
-		// Status received, continue
-	case e := <-rpp.ExceptionChan:
-		exception = e
+// run writes a delimited request to the REPL and returns its output, along
+// with the Python exception it raised, if any. If onLine is set, it is called
+// with each complete line of output as it is read. The caller must hold rpp.m.
+func (rpp *REPLPythonProcess) run(request string, onLine func(line string)) (string, error) {
+	delimiter := DELIMITER
+	if runtime.GOOS == "windows" {
+		delimiter = WINDELIMITER
 	}
 
-	if exception != nil {
-		exerr = exception.Error()
+	// write the code to the Python process as a single string
+	_, err := rpp.PythonProcess.PipeOut.WriteString(request)
+	if err != nil {
+		return "", err
 	}
 
-	// Read the output from Python and process it until we encounter the delimiter
+	// Read the output from Python and process it until we encounter the delimiter.
+	// Python reports the status before writing the delimiter, so it is collected after the output.
 	reader := bufio.NewReader(rpp.PythonProcess.PipeIn)
 	var result strings.Builder
 
@@ -179,22 +335,21 @@ func (rpp *REPLPythonProcess) Execute(code string, combinedOutput bool) (string,
 
 		result.WriteString(line)
 
-		if iswin {
-			// Check if we've received the complete output (marked by the WINDELIMITER)
-			if strings.HasSuffix(result.String(), WINDELIMITER) {
-				// Trim the delimiter and any trailing newline/carriage return from the output
-				output := strings.TrimSuffix(result.String(), WINDELIMITER)
-				output = strings.TrimRight(output, "\n\r")
-				return output, exerr
-			}
-		} else {
-			// Check if we've received the complete output (marked by the delimiter)
-			if strings.HasSuffix(result.String(), DELIMITER) {
-				// Trim the delimiter and any trailing newline/carriage return from the output
-				output := strings.TrimSuffix(result.String(), DELIMITER)
-				output = strings.TrimRight(output, "\n\r")
-				return output, exerr
+		// Check if we've received the complete output (marked by the delimiter)
+		if strings.HasSuffix(result.String(), delimiter) {
+			// Text printed without a final newline shares its line with the delimiter
+			if text := strings.TrimSuffix(line, delimiter); onLine != nil && text != "" {
+				onLine(text)
 			}
+
+			// Trim the delimiter and any trailing newline/carriage return from the output
+			output := strings.TrimSuffix(result.String(), delimiter)
+			output = strings.TrimRight(output, "\n\r")
+			return output, rpp.readStatus()
+		}
+
+		if onLine != nil && strings.HasSuffix(line, "\n") {
+			onLine(strings.TrimRight(line, "\r\n"))
 		}
 
 		if err == io.EOF {
@@ -203,6 +358,17 @@ func (rpp *REPLPythonProcess) Execute(code string, combinedOutput bool) (string,
 	}
 }
 
+// readStatus waits for the status Python reports after running a request and
+// returns the exception it raised, if any.
+func (rpp *REPLPythonProcess) readStatus() error {
+	select {
+	case <-rpp.StatusChan:
+		return nil
+	case e := <-rpp.ExceptionChan:
+		return e.Error()
+	}
+}
+
 // ExecuteWithTimeout runs Python code with a maximum execution time.
 //
 // Parameters:
@@ -300,6 +466,72 @@ func (rpp *REPLPythonProcess) ExecuteWithTimeout(code string, combinedOutput boo
 	}
 }
 
+// replInterruptGrace is how long ExecuteContext waits for an interrupted
+// execution to finish before terminating the Python process.
+var replInterruptGrace = 2 * time.Second
+
+// ExecuteContext runs Python code in the REPL like Execute, but stops waiting
+// when ctx is cancelled or its deadline passes. Unlike ExecuteWithTimeout, it
+// first tries to recover the interpreter: the running code is interrupted with
+// a KeyboardInterrupt (see Interrupt), and only if it has not stopped within a
+// short grace period (or cannot be interrupted, as on Windows) is the Python
+// process terminated and the REPL closed.
+//
+// When ctx ends first, the returned error wraps ctx.Err(), so callers can tell
+// a cancellation from a Python exception with errors.Is:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	out, err := repl.ExecuteContext(ctx, code, true)
+//	if errors.Is(err, context.DeadlineExceeded) {
+//		// the cell was stopped; the REPL is still usable unless it was closed
+//	}
+func (rpp *REPLPythonProcess) ExecuteContext(ctx context.Context, code string, combinedOutput bool) (string, error) {
+	rpp.m.Lock()
+	defer rpp.m.Unlock()
+
+	if rpp.closed {
+		return "", fmt.Errorf("REPL process has been closed")
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if err := rpp.setCombinedOutput(combinedOutput); err != nil {
+		return "", err
+	}
+
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := rpp.run(cleanREPLCode(code)+DELIMITER, nil)
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-ctx.Done():
+	}
+
+	// Try to stop the code without losing the interpreter's state
+	if rpp.Interrupt() == nil {
+		select {
+		case <-done:
+			return "", fmt.Errorf("execution cancelled: %w", ctx.Err())
+		case <-time.After(replInterruptGrace):
+		}
+	}
+
+	// The code did not stop, so the REPL can't be recovered
+	rpp.PythonProcess.Terminate()
+	rpp.closed = true
+	return "", fmt.Errorf("execution cancelled - Python process terminated: %w", ctx.Err())
+}
+
 // Close terminates the Python REPL process and releases resources.
 // After Close, the REPL cannot be reused. Returns an error if already closed.
 func (rpp *REPLPythonProcess) Close() error {
@@ -0,0 +1,275 @@
+package jumpboot
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestREPL starts a REPL on the system Python, skipping the test if there is none.
+func newTestREPL(t *testing.T) *REPLPythonProcess {
+	t.Helper()
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	repl, err := env.NewREPLPythonProcess(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewREPLPythonProcess failed: %v", err)
+	}
+	t.Cleanup(func() { repl.Close() })
+	return repl
+}
+
+// TestEvalExpression tests that EvalExpression returns the repr of the final
+// expression of a block, independently of printed output.
+func TestEvalExpression(t *testing.T) {
+	repl := newTestREPL(t)
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"2 + 2", "4"},
+		{"x = 5\n\nprint('ignored')\nx * 3", "15"},
+		{"y = 1", ""},
+		{"None", ""},
+		{"_ + 1", "16"}, // _ holds the last value that was not None
+		{"'a' * 2", "'aa'"},
+	}
+	for _, tt := range tests {
+		got, err := repl.EvalExpression(tt.code)
+		if err != nil {
+			t.Errorf("EvalExpression(%q) failed: %v", tt.code, err)
+		} else if got != tt.want {
+			t.Errorf("EvalExpression(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+
+	if _, err := repl.EvalExpression("1 / 0"); err == nil || !strings.Contains(err.Error(), "ZeroDivisionError") {
+		t.Errorf("expected a ZeroDivisionError, got %v", err)
+	}
+
+	// State is shared with Execute
+	if out, err := repl.Execute("print(x + y)", true); err != nil || out != "6" {
+		t.Errorf("expected Execute to see the evaluated state, got %q, %v", out, err)
+	}
+}
+
+// TestInterrupt tests that Interrupt stops running code with a
+// KeyboardInterrupt and leaves the REPL and its state usable.
+func TestInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Interrupt is not supported on Windows")
+	}
+	repl := newTestREPL(t)
+
+	if _, err := repl.Execute("import time\nstate = 'kept'", true); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	// Interrupting an idle REPL does nothing
+	if err := repl.Interrupt(); err != nil {
+		t.Fatalf("Interrupt failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if out, err := repl.Execute("print('idle')", true); err != nil || out != "idle" {
+		t.Fatalf("expected the idle REPL to survive an interrupt, got %q, %v", out, err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := repl.Execute("time.sleep(30)", true)
+		result <- err
+	}()
+	time.Sleep(500 * time.Millisecond)
+	if err := repl.Interrupt(); err != nil {
+		t.Fatalf("Interrupt failed: %v", err)
+	}
+
+	select {
+	case err := <-result:
+		if err == nil || !strings.HasPrefix(err.Error(), "KeyboardInterrupt") {
+			t.Errorf("expected a KeyboardInterrupt exception, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Execute was not interrupted")
+	}
+
+	if out, err := repl.Execute("print(state)", true); err != nil || out != "kept" {
+		t.Errorf("expected the REPL to keep its state, got %q, %v", out, err)
+	}
+}
+
+// TestExecuteStream tests that ExecuteStream passes each line to its callback
+// while the code is still running and returns the full output.
+func TestExecuteStream(t *testing.T) {
+	repl := newTestREPL(t)
+
+	var lines []string
+	var firstLine time.Time
+	code := "import time\nprint('one')\nprint()\ntime.sleep(0.5)\nprint('two')\nprint('partial', end='')"
+	out, err := repl.ExecuteStream(code, true, func(line string) {
+		if firstLine.IsZero() {
+			firstLine = time.Now()
+		}
+		lines = append(lines, line)
+	})
+	done := time.Now()
+	if err != nil {
+		t.Fatalf("ExecuteStream failed: %v", err)
+	}
+
+	want := []string{"one", "", "two", "partial"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if out != "one\n\ntwo\npartial" {
+		t.Errorf("output = %q", out)
+	}
+	if done.Sub(firstLine) < 400*time.Millisecond {
+		t.Errorf("first line arrived %v before the code finished, expected it to stream", done.Sub(firstLine))
+	}
+
+	// Exceptions are reported as with Execute, and the traceback is streamed
+	lines = nil
+	if _, err := repl.ExecuteStream("print('before')\nraise ValueError('boom')", true, func(line string) {
+		lines = append(lines, line)
+	}); err == nil || !strings.HasPrefix(err.Error(), "ValueError") {
+		t.Errorf("expected a ValueError, got %v", err)
+	}
+	if len(lines) < 2 || lines[0] != "before" || !strings.Contains(lines[len(lines)-1], "boom") {
+		t.Errorf("unexpected lines for the exception: %q", lines)
+	}
+
+	// The REPL is left in sync for Execute
+	if out, err := repl.Execute("print('after')", true); err != nil || out != "after" {
+		t.Errorf("expected Execute to work after streaming, got %q, %v", out, err)
+	}
+}
+
+// TestReset tests that Reset discards the REPL's variables and imports but
+// keeps the process and the jumpboot KVPairs.
+func TestReset(t *testing.T) {
+	env, err := CreateEnvironmentFromSystem()
+	if err != nil {
+		t.Skipf("no system Python: %v", err)
+	}
+	repl, err := env.NewREPLPythonProcess(map[string]interface{}{"GREETING": "hello"}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("NewREPLPythonProcess failed: %v", err)
+	}
+	defer repl.Close()
+
+	if _, err := repl.Execute("import json\nleftover = 42", true); err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	pid := repl.Cmd.Process.Pid
+
+	if err := repl.Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	out, err := repl.Execute("print('leftover' in globals(), 'json' in globals(), __name__)", true)
+	if err != nil || out != "False False __console__" {
+		t.Errorf("expected a fresh namespace, got %q, %v", out, err)
+	}
+	out, err = repl.Execute("import jumpboot\nprint(jumpboot.GREETING)", true)
+	if err != nil || out != "hello" {
+		t.Errorf("expected KVPairs to survive Reset, got %q, %v", out, err)
+	}
+	if repl.Cmd.Process.Pid != pid {
+		t.Errorf("expected Reset to keep the process")
+	}
+
+	repl.Close()
+	if err := repl.Reset(); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("expected a closed error, got %v", err)
+	}
+}
+
+// TestExecuteContext tests that a cancelled ExecuteContext interrupts the
+// code and keeps the REPL, and terminates it only if the code won't stop.
+func TestExecuteContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Interrupt is not supported on Windows")
+	}
+	repl := newTestREPL(t)
+
+	if out, err := repl.ExecuteContext(context.Background(), "import time\nstate = 'kept'\nprint(state)", true); err != nil || out != "kept" {
+		t.Fatalf("ExecuteContext = %q, %v", out, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := repl.ExecuteContext(ctx, "time.sleep(30)", true)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("cancellation took %v", elapsed)
+	}
+	if out, err := repl.Execute("print(state)", true); err != nil || out != "kept" {
+		t.Fatalf("expected the REPL to survive the cancellation, got %q, %v", out, err)
+	}
+
+	// Code that swallows the interrupt is terminated after the grace period
+	defer func(grace time.Duration) { replInterruptGrace = grace }(replInterruptGrace)
+	replInterruptGrace = 300 * time.Millisecond
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+	stubborn := "while True:\n    try:\n        time.sleep(10)\n    except KeyboardInterrupt:\n        pass\n"
+	if _, err := repl.ExecuteContext(ctx, stubborn, true); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+	if _, err := repl.Execute("print(state)", true); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("expected the REPL to be closed, got %v", err)
+	}
+}
+
+// TestInspect tests that Inspect summarizes the REPL's variables and degrades
+// to type-only entries for values that can't be repr'd.
+func TestInspect(t *testing.T) {
+	repl := newTestREPL(t)
+
+	code := "import os\nn = 42\ntext = 'x' * 500\nbig = list(range(20000))\n" +
+		"class Broken:\n    def __repr__(self):\n        raise RuntimeError('no repr')"
+	for _, c := range []string{code, "broken = Broken()"} {
+		if _, err := repl.Execute(c, true); err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+	}
+
+	vars, err := repl.Inspect()
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if v := vars["n"]; v.Type != "int" || v.Repr != "42" || v.Size <= 0 {
+		t.Errorf("n = %+v", v)
+	}
+	if v := vars["text"]; v.Type != "str" || len(v.Repr) != 203 || !strings.HasSuffix(v.Repr, "...") {
+		t.Errorf("expected a truncated repr for text, got %d characters", len(v.Repr))
+	}
+	if v := vars["big"]; v.Type != "list" || v.Repr != "" {
+		t.Errorf("expected big to be reported by type only, got %+v", v)
+	}
+	if v := vars["broken"]; v.Type != "__console__.Broken" || v.Repr != "" {
+		t.Errorf("broken = %+v", v)
+	}
+	if v := vars["os"]; v.Type != "module" {
+		t.Errorf("os = %+v", v)
+	}
+	for name := range vars {
+		if strings.HasPrefix(name, "__") {
+			t.Errorf("unexpected dunder %s", name)
+		}
+	}
+
+	// The REPL is left in sync
+	if out, err := repl.Execute("print(n)", true); err != nil || out != "42" {
+		t.Errorf("expected Execute to work after Inspect, got %q, %v", out, err)
+	}
+}
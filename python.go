@@ -2,13 +2,19 @@ package jumpboot
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 )
 
 // RunPythonReadCombined executes a Python script and returns combined stdout/stderr.
-// This is a blocking call that waits for the script to complete.
+// This is a blocking call that waits for the script to complete. Use RunPython
+// to get the two streams separately along with the exit code.
 func (env *PythonEnvironment) RunPythonReadCombined(scriptPath string, args ...string) (string, error) {
 	args = append([]string{scriptPath}, args...)
 	cmd := exec.Command(env.PythonPath, args...)
@@ -19,29 +25,120 @@ func (env *PythonEnvironment) RunPythonReadCombined(scriptPath string, args ...s
 	return string(output), nil
 }
 
+// RunOptions specifies how RunPython runs Python.
+type RunOptions struct {
+	// Args are the arguments to the interpreter, usually a script path
+	// followed by the script's arguments.
+	Args []string
+
+	// Env holds additional environment variables for the process.
+	Env map[string]string
+
+	// Dir is the working directory; the current directory if empty.
+	Dir string
+
+	// Stdin is the process's standard input; no input if nil. Unless it is
+	// an *os.File, it is copied to the process through a pipe that is closed
+	// when Stdin returns EOF, so the process sees the end of its input.
+	Stdin io.Reader
+
+	// Timeout kills the process if it runs longer; no limit if zero.
+	Timeout time.Duration
+}
+
+// RunResult is the outcome of RunPython.
+type RunResult struct {
+	// Stdout is everything the process wrote to standard output.
+	Stdout string
+
+	// Stderr is everything the process wrote to standard error.
+	Stderr string
+
+	// ExitCode is the process's exit code, or -1 if it was killed or didn't
+	// start.
+	ExitCode int
+
+	// Duration is how long the process ran.
+	Duration time.Duration
+}
+
+// RunPython runs the environment's Python with opts and waits for it to
+// finish. The result is filled in even when an error is returned; the error is
+// an *exec.ExitError if Python exited with a non-zero code.
+func (env *PythonEnvironment) RunPython(opts RunOptions) (RunResult, error) {
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, env.PythonPath, opts.Args...)
+	// don't wait forever for pipes held open by processes Python started
+	cmd.WaitDelay = time.Second
+	cmd.Dir = opts.Dir
+	cmd.Stdin = opts.Stdin
+	if len(opts.Env) > 0 {
+		cmd.Env = os.Environ()
+		for key, value := range opts.Env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	result := RunResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: -1,
+		Duration: time.Since(start),
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return result, fmt.Errorf("python timed out after %v", opts.Timeout)
+	}
+	return result, err
+}
+
 // RunPythonReadStdout executes a Python script and returns only stdout.
 // This is a blocking call that waits for the script to complete.
+//
+// If the script exits with an error, the output it printed is returned along
+// with an error that includes its stderr.
 func (env *PythonEnvironment) RunPythonReadStdout(scriptPath string, args ...string) (string, error) {
+	return env.runPythonReadStdout(nil, scriptPath, args...)
+}
+
+// RunPythonWithInput executes a Python script with input as its stdin and
+// returns only stdout, like RunPythonReadStdout. The script sees the end of
+// its input after the last byte, so filter-style scripts that read
+// sys.stdin to EOF work as expected.
+func (env *PythonEnvironment) RunPythonWithInput(input []byte, scriptPath string, args ...string) (string, error) {
+	return env.runPythonReadStdout(bytes.NewReader(input), scriptPath, args...)
+}
+
+// runPythonReadStdout runs a script with stdin and returns its stdout for
+// RunPythonReadStdout and RunPythonWithInput.
+func (env *PythonEnvironment) runPythonReadStdout(stdin io.Reader, scriptPath string, args ...string) (string, error) {
 	// put scriptPath at the front of the args
-	retv := ""
-	args = append([]string{scriptPath}, args...)
-	cmd := exec.Command(env.PythonPath, args...)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return "", err
+	result, err := env.RunPython(RunOptions{Args: append([]string{scriptPath}, args...), Stdin: stdin})
+
+	// return the output one line at a time, each ending in a newline
+	var retv strings.Builder
+	for _, line := range strings.SplitAfter(result.Stdout, "\n") {
+		if line != "" {
+			retv.WriteString(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r") + "\n")
+		}
 	}
-	defer stdout.Close()
 
-	// continue to read the output until there is no more
-	// or an error occurs
-	if err := cmd.Start(); err != nil {
-		return "", err
-	}
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		retv += scanner.Text() + "\n"
+	if err != nil {
+		return retv.String(), fmt.Errorf("error running python script: %v, stderr: %s", err, result.Stderr)
 	}
-	return retv, nil
+	return retv.String(), nil
 }
 
 // RunPythonScriptFromFile executes a Python script file, printing stderr to stdout.
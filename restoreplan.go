@@ -0,0 +1,233 @@
+package jumpboot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// RestorePlan lists the packages restoring an environment would install, as
+// resolved by micromamba and pip without installing anything.
+type RestorePlan struct {
+	// Name is the environment name from the specification.
+	Name string
+
+	// Channel is the channel micromamba resolved the conda packages from.
+	Channel string
+
+	// CondaPackages are the conda packages micromamba would install,
+	// including their dependencies and Python itself.
+	CondaPackages []PackageSpec
+
+	// PipPackages are the pip packages pip would install, including their
+	// dependencies.
+	PipPackages []PackageSpec
+}
+
+// RestoreEnvironmentFromJSONFile restores an environment from a JSON
+// specification like CreateEnvironmentFromJSONFileWithOptions. With
+// opts.DryRun it resolves the specification instead, returning the plan and
+// a nil environment, so changes to a specification can be checked quickly
+// (in CI, for example) without building anything.
+//
+// A dry run resolves Python and the conda packages with "micromamba create
+// --dry-run", trying each channel in order, and the pip packages with "pip
+// install --dry-run --report" using the system Python's pip, which must be
+// pip 22.2 or later. Pip resolves for the Python version micromamba chose; if
+// that differs from the system Python's, only wheels are considered, as pip
+// requires. Micromamba is downloaded into rootDir/bin if it isn't there yet,
+// but nothing is installed.
+func RestoreEnvironmentFromJSONFile(filePath string, rootDir string, opts RestoreOptions, progressCallback ProgressCallback) (*PythonEnvironment, *RestorePlan, error) {
+	if !opts.DryRun {
+		env, err := CreateEnvironmentFromJSONFileWithOptions(filePath, rootDir, opts, progressCallback)
+		return env, nil, err
+	}
+
+	spec, err := readRestoreSpec(filePath, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := planRestore(spec, rootDir, progressCallback)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, plan, nil
+}
+
+// planRestore resolves a specification without installing anything.
+func planRestore(spec EnvironmentSpec, rootDir string, progressCallback ProgressCallback) (*RestorePlan, error) {
+	mambaPath, err := micromambaInRoot(rootDir, progressCallback)
+	if err != nil {
+		return nil, err
+	}
+
+	pythonVersion := spec.PythonVersion
+	if pythonVersion == "" {
+		pythonVersion = "3.10"
+	}
+	pythonSpec, _, err := pythonRequirement(pythonVersion)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing requested python version: %v", err)
+	}
+	channels := spec.Channels
+	if len(channels) == 0 {
+		channels = []string{"conda-forge"}
+	}
+	condaSpecs, pipSpecs := restorePackageSpecs(spec)
+
+	if progressCallback != nil {
+		progressCallback("Resolving conda packages...", 0, 100)
+	}
+	plan := &RestorePlan{Name: spec.Name}
+	plan.Channel, plan.CondaPackages, err = planCondaPackages(mambaPath, rootDir, append([]string{pythonSpec}, condaSpecs...), channels)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pipSpecs) > 0 {
+		if progressCallback != nil {
+			progressCallback("Resolving pip packages...", 50, 100)
+		}
+		system, err := CreateEnvironmentFromSystem()
+		if err != nil {
+			return nil, fmt.Errorf("error finding pip for the dry run: %v", err)
+		}
+		if system.PipPath == "" {
+			return nil, fmt.Errorf("error finding pip for the dry run: the system Python has no pip")
+		}
+		if system.PipVersion.Compare(Version{Major: 22, Minor: 2, Patch: -1}) < 0 {
+			return nil, fmt.Errorf("a dry run needs pip 22.2 or later, found pip %s", system.PipVersion.String())
+		}
+
+		// Resolve for the Python the environment would get
+		targetPython := ""
+		for _, pkg := range plan.CondaPackages {
+			if pkg.Name != "python" {
+				continue
+			}
+			if v, err := ParseVersion(pkg.Version); err == nil && (v.Major != system.PythonVersion.Major || v.Minor != system.PythonVersion.Minor) {
+				targetPython = fmt.Sprintf("%d.%d", v.Major, v.Minor)
+			}
+		}
+		plan.PipPackages, err = planPipPackages(system.PipPath, pipSpecs, targetPython)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if progressCallback != nil {
+		progressCallback("Resolved environment", 100, 100)
+	}
+	return plan, nil
+}
+
+// micromambaInRoot returns the micromamba binary in rootDir/bin, downloading
+// it if it isn't there.
+func micromambaInRoot(rootDir string, progressCallback ProgressCallback) (string, error) {
+	binDirectory := filepath.Join(rootDir, "bin")
+	executableName := "micromamba"
+	if runtime.GOOS == "windows" {
+		executableName += ".exe"
+	}
+	if _, err := os.Stat(filepath.Join(binDirectory, executableName)); err == nil {
+		return filepath.Join(binDirectory, executableName), nil
+	}
+	mambaPath, err := ExpectMicromamba(binDirectory, progressCallback)
+	if err != nil {
+		return "", fmt.Errorf("error downloading micromamba: %v", err)
+	}
+	return mambaPath, nil
+}
+
+// planCondaPackages resolves specs with "micromamba create --dry-run" into a
+// prefix that is never created, trying each channel in order. It returns the
+// channel that resolved them and the packages micromamba would link.
+func planCondaPackages(mambaPath string, rootDir string, specs []string, channels []string) (string, []PackageSpec, error) {
+	network := getNetworkConfig()
+	prefix := filepath.Join(os.TempDir(), fmt.Sprintf("jumpboot-dry-run-%d", time.Now().UnixNano()))
+
+	var errs []error
+	for _, channel := range channels {
+		args := []string{"create", "--no-rc", "--dry-run", "--json", "-y", "--prefix", prefix, "-c", channel}
+		args = append(args, specs...)
+		cmd := exec.Command(mambaPath, append(args, network.micromambaArgs()...)...)
+		cmd.Env = append(network.micromambaEnv(), "MAMBA_ROOT_PREFIX="+rootDir)
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			detail := strings.TrimSpace(stderr.String())
+			if detail == "" {
+				detail = strings.TrimSpace(stdout.String())
+			}
+			errs = append(errs, fmt.Errorf("error resolving conda packages from channel %s: %v - %s", channel, err, detail))
+			continue
+		}
+
+		var result struct {
+			Actions struct {
+				Link []condaPackage `json:"LINK"`
+			} `json:"actions"`
+		}
+		if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+			return "", nil, fmt.Errorf("error parsing micromamba dry run JSON output: %v", err)
+		}
+		packages := make([]PackageSpec, 0, len(result.Actions.Link))
+		for _, pkg := range result.Actions.Link {
+			packages = append(packages, PackageSpec{Name: pkg.Name, Version: pkg.Version, Build: pkg.BuildString, Source: "conda", Channel: pkg.Channel})
+		}
+		return channel, packages, nil
+	}
+	return "", nil, errors.Join(errs...)
+}
+
+// planPipPackages resolves specs with "pip install --dry-run --report" and
+// returns the packages pip would install. If pythonVersion is set, pip
+// resolves for that version ("3.11") using wheels only.
+func planPipPackages(pipPath string, specs []string, pythonVersion string) ([]PackageSpec, error) {
+	args := []string{"install", "--dry-run", "--ignore-installed", "--quiet", "--report", "-", "--disable-pip-version-check"}
+	if pythonVersion != "" {
+		args = append(args, "--python-version", pythonVersion, "--only-binary=:all:")
+	}
+	args = append(args, getNetworkConfig().pipArgs()...)
+	for _, pkg := range specs {
+		spec, err := pipPackageArg(pkg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, spec)
+	}
+
+	cmd := exec.Command(pipPath, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, newPipError("error resolving pip packages", err, stderr.String())
+	}
+
+	var report struct {
+		Install []struct {
+			Metadata struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"metadata"`
+		} `json:"install"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		return nil, fmt.Errorf("error parsing pip install report: %v", err)
+	}
+	packages := make([]PackageSpec, 0, len(report.Install))
+	for _, item := range report.Install {
+		packages = append(packages, PackageSpec{Name: item.Metadata.Name, Version: item.Metadata.Version, Source: "pip"})
+	}
+	return packages, nil
+}
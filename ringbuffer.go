@@ -0,0 +1,226 @@
+package jumpboot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// RingBuffer is a single-producer, single-consumer queue of byte messages in
+// a SharedMemory region, for streaming data such as audio or sensor samples
+// between Go and Python without a round trip per message. One process pushes
+// and one process pops; the two sides need no lock, only the shared indices.
+//
+// The region starts with a 64-byte header followed by the data area. All
+// integers are little-endian:
+//
+//	offset  size  field
+//	0       4     magic "JBRB"
+//	4       4     format version (1)
+//	8       8     capacity of the data area in bytes
+//	16      8     write index: total bytes ever pushed
+//	24      8     read index: total bytes ever popped
+//	32      32    reserved (zero)
+//	64      ...   data area
+//
+// Each message is stored at data[index % capacity] as a 4-byte length followed
+// by the payload, wrapping around the end of the data area. The producer
+// copies a message in and then advances the write index; the consumer copies
+// it out and then advances the read index. The buffer holds write - read
+// bytes, so a message fits when 4 + len(payload) <= capacity - (write - read).
+// Both indices are 8-byte aligned so each side can update them with a single
+// store. The Python side is jumpboot.RingBuffer.
+type RingBuffer struct {
+	shm      *SharedMemory
+	data     []byte
+	capacity uint64
+	write    *uint64
+	read     *uint64
+
+	// sem, if set, is released once per pushed message so PopWait can block
+	// until a message arrives
+	sem Semaphore
+}
+
+// RingBufferHeaderSize is the size of the header at the start of a
+// RingBuffer's region.
+const RingBufferHeaderSize = 64
+
+// ringBufferMagic identifies a region holding a RingBuffer.
+var ringBufferMagic = []byte("JBRB")
+
+// ringBufferVersion is the format version written to the header.
+const ringBufferVersion = 1
+
+// ringRecordHeaderSize is the length prefix stored before each message.
+const ringRecordHeaderSize = 4
+
+// NewRingBuffer formats shm as an empty RingBuffer whose data area is the
+// rest of the region after the header, discarding its contents. Only one
+// side calls NewRingBuffer; the other opens the same region with
+// OpenRingBuffer.
+//
+// sem is optional. If set, Push releases it once per message and PopWait
+// acquires it to block until a message is available; both sides must then use
+// the same named semaphore, created with an initial value of 0.
+func NewRingBuffer(shm *SharedMemory, sem Semaphore) (*RingBuffer, error) {
+	if shm.GetSize() <= RingBufferHeaderSize+ringRecordHeaderSize {
+		return nil, fmt.Errorf("shared memory of %d bytes is too small for a ring buffer", shm.GetSize())
+	}
+	header := shm.GetByteSlice(0)[:RingBufferHeaderSize]
+	clear(header)
+	copy(header[0:4], ringBufferMagic)
+	binary.LittleEndian.PutUint32(header[4:8], ringBufferVersion)
+	binary.LittleEndian.PutUint64(header[8:16], uint64(shm.GetSize()-RingBufferHeaderSize))
+	return newRingBuffer(shm, sem), nil
+}
+
+// OpenRingBuffer attaches to a RingBuffer that NewRingBuffer (or the Python
+// side) formatted in shm. sem is the same optional semaphore as for
+// NewRingBuffer.
+func OpenRingBuffer(shm *SharedMemory, sem Semaphore) (*RingBuffer, error) {
+	if shm.GetSize() <= RingBufferHeaderSize {
+		return nil, fmt.Errorf("shared memory of %d bytes is too small for a ring buffer", shm.GetSize())
+	}
+	header := shm.GetByteSlice(0)[:RingBufferHeaderSize]
+	if !bytes.Equal(header[0:4], ringBufferMagic) {
+		return nil, errors.New("shared memory does not hold a ring buffer")
+	}
+	if version := binary.LittleEndian.Uint32(header[4:8]); version != ringBufferVersion {
+		return nil, fmt.Errorf("unsupported ring buffer version %d", version)
+	}
+	if capacity := binary.LittleEndian.Uint64(header[8:16]); capacity != uint64(shm.GetSize()-RingBufferHeaderSize) {
+		return nil, fmt.Errorf("ring buffer capacity %d does not match the shared memory size %d", capacity, shm.GetSize())
+	}
+	return newRingBuffer(shm, sem), nil
+}
+
+// newRingBuffer maps a RingBuffer onto a formatted region.
+func newRingBuffer(shm *SharedMemory, sem Semaphore) *RingBuffer {
+	region := shm.GetByteSlice(0)
+	return &RingBuffer{
+		shm:      shm,
+		data:     region[RingBufferHeaderSize:],
+		capacity: uint64(len(region) - RingBufferHeaderSize),
+		write:    (*uint64)(unsafe.Pointer(&region[16])),
+		read:     (*uint64)(unsafe.Pointer(&region[24])),
+		sem:      sem,
+	}
+}
+
+// Capacity returns the size of the data area in bytes. Each message takes its
+// length plus 4 bytes.
+func (rb *RingBuffer) Capacity() int {
+	return int(rb.capacity)
+}
+
+// Len returns the number of bytes currently queued, including the length
+// prefixes.
+func (rb *RingBuffer) Len() int {
+	return int(atomic.LoadUint64(rb.write) - atomic.LoadUint64(rb.read))
+}
+
+// Push appends a message. It returns false without changing the buffer if
+// there is not enough free space; the caller can retry once the consumer has
+// caught up. It returns an error if the message can never fit.
+// Push must only be called by the producer.
+func (rb *RingBuffer) Push(p []byte) (bool, error) {
+	need := uint64(ringRecordHeaderSize + len(p))
+	if need > rb.capacity || uint64(len(p)) > 0xffffffff {
+		return false, fmt.Errorf("message of %d bytes is larger than the ring buffer", len(p))
+	}
+
+	write := atomic.LoadUint64(rb.write)
+	if need > rb.capacity-(write-atomic.LoadUint64(rb.read)) {
+		return false, nil
+	}
+
+	var length [ringRecordHeaderSize]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(p)))
+	rb.copyIn(write, length[:])
+	rb.copyIn(write+ringRecordHeaderSize, p)
+
+	// Publish the message only once it is complete
+	atomic.StoreUint64(rb.write, write+need)
+
+	if rb.sem != nil {
+		if err := rb.sem.Release(); err != nil {
+			return true, fmt.Errorf("error signaling ring buffer semaphore: %v", err)
+		}
+	}
+	return true, nil
+}
+
+// Pop removes and returns the oldest message. It returns false if the buffer
+// is empty. Pop must only be called by the consumer.
+func (rb *RingBuffer) Pop() ([]byte, bool, error) {
+	read := atomic.LoadUint64(rb.read)
+	available := atomic.LoadUint64(rb.write) - read
+	if available == 0 {
+		return nil, false, nil
+	}
+	if available < ringRecordHeaderSize || available > rb.capacity {
+		return nil, false, fmt.Errorf("corrupt ring buffer: %d bytes queued", available)
+	}
+
+	var length [ringRecordHeaderSize]byte
+	rb.copyOut(length[:], read)
+	size := uint64(binary.LittleEndian.Uint32(length[:]))
+	if ringRecordHeaderSize+size > available {
+		return nil, false, fmt.Errorf("corrupt ring buffer: message of %d bytes with %d bytes queued", size, available)
+	}
+
+	p := make([]byte, size)
+	rb.copyOut(p, read+ringRecordHeaderSize)
+
+	// Free the space only once the message has been copied out
+	atomic.StoreUint64(rb.read, read+ringRecordHeaderSize+size)
+	return p, true, nil
+}
+
+// PopWait removes and returns the oldest message, waiting up to timeout for
+// one to arrive. It returns false if the timeout elapsed first. PopWait
+// requires the ring buffer's semaphore.
+func (rb *RingBuffer) PopWait(timeout time.Duration) ([]byte, bool, error) {
+	if rb.sem == nil {
+		return nil, false, errors.New("PopWait requires a ring buffer semaphore")
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if p, ok, err := rb.Pop(); ok || err != nil {
+			return p, ok, err
+		}
+
+		// The semaphore may count messages Pop already took; those wake us
+		// early and we wait again
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, false, nil
+		}
+		acquired, err := rb.sem.AcquireTimeout(int(remaining.Milliseconds()) + 1)
+		if err != nil {
+			return nil, false, err
+		}
+		if !acquired {
+			return rb.Pop()
+		}
+	}
+}
+
+// copyIn copies p into the data area at index, wrapping around the end.
+func (rb *RingBuffer) copyIn(index uint64, p []byte) {
+	start := index % rb.capacity
+	n := copy(rb.data[start:], p)
+	copy(rb.data, p[n:])
+}
+
+// copyOut fills p from the data area at index, wrapping around the end.
+func (rb *RingBuffer) copyOut(p []byte, index uint64) {
+	start := index % rb.capacity
+	n := copy(p, rb.data[start:])
+	copy(p[n:], rb.data)
+}
@@ -0,0 +1,178 @@
+package jumpboot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingSemaphore is an in-process Semaphore for tests, since named
+// semaphores require CGO on Unix.
+type countingSemaphore struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	count int
+}
+
+func newCountingSemaphore() *countingSemaphore {
+	s := &countingSemaphore{}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *countingSemaphore) Acquire() error {
+	_, err := s.AcquireTimeout(int(time.Hour.Milliseconds()))
+	return err
+}
+
+func (s *countingSemaphore) Release() error {
+	s.mu.Lock()
+	s.count++
+	s.mu.Unlock()
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *countingSemaphore) TryAcquire() (bool, error) {
+	return s.AcquireTimeout(0)
+}
+
+func (s *countingSemaphore) AcquireTimeout(timeoutMs int) (bool, error) {
+	deadline := time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)
+	timer := time.AfterFunc(time.Until(deadline), s.cond.Broadcast)
+	defer timer.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.count == 0 {
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+		s.cond.Wait()
+	}
+	s.count--
+	return true, nil
+}
+
+func (s *countingSemaphore) AcquireContext(ctx context.Context) error {
+	return acquireContext(s, ctx)
+}
+
+func (s *countingSemaphore) Close() error { return nil }
+
+func newTestRingBuffer(t *testing.T, size int, sem Semaphore) (*RingBuffer, *SharedMemory) {
+	t.Helper()
+	name := fmt.Sprintf("/jumpboot_ring_%d_%d", os.Getpid(), time.Now().UnixNano())
+	shm, err := CreateSharedMemory(name, size)
+	if err != nil {
+		t.Fatalf("CreateSharedMemory failed: %v", err)
+	}
+	t.Cleanup(func() { shm.Close() })
+	rb, err := NewRingBuffer(shm, sem)
+	if err != nil {
+		t.Fatalf("NewRingBuffer failed: %v", err)
+	}
+	return rb, shm
+}
+
+// TestRingBuffer tests pushing and popping across the end of the data area,
+// and that a full buffer rejects a message without corrupting the queue.
+func TestRingBuffer(t *testing.T) {
+	rb, shm := newTestRingBuffer(t, RingBufferHeaderSize+64, nil)
+	if rb.Capacity() != 64 {
+		t.Fatalf("Capacity() = %d, want 64", rb.Capacity())
+	}
+	if _, ok, err := rb.Pop(); ok || err != nil {
+		t.Fatalf("Pop on an empty buffer = %v, %v", ok, err)
+	}
+
+	// Messages of 4+13 bytes start at varying offsets, so they wrap
+	for i := 0; i < 50; i++ {
+		msg := []byte(fmt.Sprintf("message %5d", i))
+		if ok, err := rb.Push(msg); !ok || err != nil {
+			t.Fatalf("Push %d = %v, %v", i, ok, err)
+		}
+		got, ok, err := rb.Pop()
+		if !ok || err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("Pop %d = %q, %v, %v", i, got, ok, err)
+		}
+	}
+
+	// Fill the buffer, then check that a message that doesn't fit is refused
+	var pushed [][]byte
+	for i := 0; ; i++ {
+		msg := []byte(fmt.Sprintf("fill %d", i))
+		ok, err := rb.Push(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		pushed = append(pushed, msg)
+	}
+	if rb.Len() > rb.Capacity() {
+		t.Fatalf("Len() = %d exceeds the capacity", rb.Len())
+	}
+
+	// An attached consumer sees the same messages in order
+	consumer, err := OpenRingBuffer(shm, nil)
+	if err != nil {
+		t.Fatalf("OpenRingBuffer failed: %v", err)
+	}
+	for i, want := range pushed {
+		got, ok, err := consumer.Pop()
+		if !ok || err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("Pop %d after refused push = %q, %v, %v; want %q", i, got, ok, err, want)
+		}
+	}
+	if _, ok, _ := consumer.Pop(); ok {
+		t.Error("the refused message was queued")
+	}
+
+	if _, err := rb.Push(make([]byte, 61)); err == nil || !strings.Contains(err.Error(), "larger than the ring buffer") {
+		t.Errorf("expected an error for a message that can never fit, got %v", err)
+	}
+	if ok, err := rb.Push(make([]byte, 60)); !ok || err != nil {
+		t.Errorf("a message filling the whole buffer = %v, %v", ok, err)
+	}
+}
+
+// TestRingBufferPopWait tests that PopWait wakes when the producer pushes
+// and times out when nothing arrives.
+func TestRingBufferPopWait(t *testing.T) {
+	sem := newCountingSemaphore()
+	rb, _ := newTestRingBuffer(t, 4096, sem)
+
+	if _, _, err := (&RingBuffer{}).PopWait(time.Millisecond); err == nil {
+		t.Error("expected PopWait without a semaphore to fail")
+	}
+	start := time.Now()
+	if _, ok, err := rb.PopWait(50 * time.Millisecond); ok || err != nil {
+		t.Fatalf("PopWait on an empty buffer = %v, %v", ok, err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("PopWait returned after %v, before the timeout", elapsed)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		rb.Push([]byte("wake"))
+	}()
+	got, ok, err := rb.PopWait(5 * time.Second)
+	if !ok || err != nil || string(got) != "wake" {
+		t.Fatalf("PopWait = %q, %v, %v", got, ok, err)
+	}
+
+	// A message taken with Pop leaves a count behind; PopWait must still wait
+	rb.Push([]byte("taken"))
+	rb.Pop()
+	if _, ok, err := rb.PopWait(30 * time.Millisecond); ok || err != nil {
+		t.Errorf("PopWait after Pop = %v, %v", ok, err)
+	}
+}
@@ -1,4 +1,4 @@
-import os,sys
+import os,sys,time;sys.__jbt=time.time()
 def o(h,m='r'):
  if sys.platform.startswith('win'):import msvcrt;return os.fdopen(msvcrt.open_osfhandle(h,os.O_RDONLY if m=='r'else os.O_WRONLY),m)
  return os.fdopen(h,m)
@@ -1,5 +1,7 @@
 import os
 import sys
+import ast
+import signal
 import traceback
 import code
 from contextlib import redirect_stdout, redirect_stderr
@@ -7,24 +9,58 @@ import json
 import io
 import jumpboot
 DELIMITER = "\x01\x02\x03\n"  # Custom delimiter with non-visible ASCII characters
+EVAL_PREFIX = "__EVAL__\n"  # Marks a block sent by EvalExpression
+STREAM_PREFIX = "__STREAM__\n"  # Marks a block sent by ExecuteStream
+RESET_COMMAND = "__RESET__"  # Sent by Reset to clear the interpreter's namespace
+INSPECT_COMMAND = "__INSPECT__"  # Sent by Inspect to list the interpreter's variables
+INSPECT_REPR_LIMIT = 200  # Longer reprs are truncated
+INSPECT_MAX_LEN = 10000  # Containers with more items are not repr'd at all
 
 # import debugpy
 # debugpy.listen(("localhost", 5678))
 # debugpy.wait_for_client()
 
+class LineFlushingWriter(io.TextIOBase):
+    """Writes to the output pipe, flushing each complete line so Go sees it immediately"""
+
+    def __init__(self, pipe):
+        self.pipe = pipe
+
+    def writable(self):
+        return True
+
+    def write(self, s):
+        self.pipe.write(s)
+        if "\n" in s:
+            self.pipe.flush()
+        return len(s)
+
+    def flush(self):
+        self.pipe.flush()
+
 class REPLInterpreter(code.InteractiveConsole):
 
     def __init__(self, locals=None):
         super().__init__(locals=locals)
         self.__CAPTURE_COMBINED__ = True   # Flag to capture both stdout and stderr
         self.last_exception = None  # Track the last exception
-    
+        self.executing = False  # Set while user code runs, so only it can be interrupted
+
+    def interrupt(self, signum, frame):
+        """SIGINT handler: interrupt user code, but never the REPL protocol"""
+        if self.executing:
+            raise KeyboardInterrupt
+
     def runcode(self, code):
         """Override runcode to catch exceptions during execution"""
         try:
-            exec(code, self.locals)
+            self.executing = True
+            try:
+                exec(code, self.locals)
+            finally:
+                self.executing = False
             self.last_exception = None
-        except Exception as e:
+        except (Exception, KeyboardInterrupt) as e:
             self.last_exception = {
                 "type": type(e).__name__,
                 "message": str(e),
@@ -32,19 +68,28 @@ class REPLInterpreter(code.InteractiveConsole):
             }
             self.showtraceback()
     
-    def conrun(self, source, filename="<input>", symbol="single"):
+    def conrun(self, source, filename="<input>", symbol="single", stream=False):
         f_status = jumpboot.Status_in
         self.last_exception = None  # Reset exception tracking
         
         try:
             # Use StringIO for capturing stdout and stderr
-            stdout_f = io.StringIO() if self.__CAPTURE_COMBINED__ else None
-            stderr_f = io.StringIO() if self.__CAPTURE_COMBINED__ else None
+            stdout_f = io.StringIO() if self.__CAPTURE_COMBINED__ and not stream else None
+            stderr_f = io.StringIO() if self.__CAPTURE_COMBINED__ and not stream else None
             result = False
 
             # split the source into lines
             lines = source.splitlines()
-            if self.__CAPTURE_COMBINED__:
+            if stream:
+                # Write output to the pipe as it is printed rather than after the block
+                streamer = LineFlushingWriter(global_output_pipe)
+                with redirect_stdout(streamer), redirect_stderr(streamer if self.__CAPTURE_COMBINED__ else sys.stderr):
+                    for line in lines:
+                        result = self.push(line)
+                    if result:
+                        result = self.push('')
+                streamer.flush()
+            elif self.__CAPTURE_COMBINED__:
                 with redirect_stdout(stdout_f), redirect_stderr(stderr_f):
                     for line in lines:
                         result = self.push(line)
@@ -57,12 +102,12 @@ class REPLInterpreter(code.InteractiveConsole):
                     result = self.push('')
 
             # Write the captured stdout to the output_pipe
-            if self.__CAPTURE_COMBINED__:
+            if stdout_f is not None:
                 global_output_pipe.write(stdout_f.getvalue())
                 global_output_pipe.flush()
 
             # Write the captured stderr to the output_pipe
-            if self.__CAPTURE_COMBINED__:
+            if stderr_f is not None:
                 global_output_pipe.write(stderr_f.getvalue())
                 global_output_pipe.flush()
 
@@ -102,12 +147,109 @@ class REPLInterpreter(code.InteractiveConsole):
             f_status.flush()
             return False
 
+    def reset(self):
+        """Replace the execution namespace with a fresh one, as a new console starts with"""
+        self.locals = {"__name__": "__console__", "__doc__": None}
+        self.resetbuffer()
+        self.last_exception = None
+
+        # KVPairs live on the jumpboot module, which stays imported
+        jumpboot.Status_in.write(json.dumps({"type": "status", "message": "ok"}) + "\n")
+        jumpboot.Status_in.flush()
+        return False
+
+    def inspect(self):
+        """Write a JSON summary of the namespace's variables to the output pipe"""
+        summary = {}
+        for name, value in list(self.locals.items()):
+            if (name.startswith("__") and name.endswith("__")) or value is jumpboot:
+                continue
+
+            cls = type(value)
+            if cls.__module__ == "builtins":
+                info = {"type": cls.__qualname__}
+            else:
+                info = {"type": cls.__module__ + "." + cls.__qualname__}
+
+            # Values that can't be measured or repr'd cheaply are reported by type only
+            try:
+                info["size"] = sys.getsizeof(value)
+            except Exception:
+                pass
+            try:
+                if not (hasattr(value, "__len__") and len(value) > INSPECT_MAX_LEN):
+                    text = repr(value)
+                    if len(text) > INSPECT_REPR_LIMIT:
+                        text = text[:INSPECT_REPR_LIMIT] + "..."
+                    info["repr"] = text
+            except Exception:
+                pass
+            summary[name] = info
+
+        global_output_pipe.write(json.dumps(summary))
+        global_output_pipe.flush()
+        jumpboot.Status_in.write(json.dumps({"type": "status", "message": "ok"}) + "\n")
+        jumpboot.Status_in.flush()
+        return False
+
+    def evalrun(self, source, filename="<input>"):
+        """Run a block and write the repr of its final expression, if any"""
+        f_status = jumpboot.Status_in
+        self.last_exception = None
+        value = ""
+
+        try:
+            # split off a trailing expression so it can be evaluated for its value
+            tree = ast.parse(source, filename, "exec")
+            last = None
+            if tree.body and isinstance(tree.body[-1], ast.Expr):
+                last = ast.Expression(tree.body.pop().value)
+
+            # printed output is not part of the result
+            with redirect_stdout(io.StringIO()), redirect_stderr(io.StringIO()):
+                self.executing = True
+                try:
+                    exec(compile(tree, filename, "exec"), self.locals)
+                    if last is not None:
+                        result = eval(compile(last, filename, "eval"), self.locals)
+                finally:
+                    self.executing = False
+                if last is not None and result is not None:
+                    self.locals["_"] = result
+                    value = repr(result)
+        except (Exception, KeyboardInterrupt) as e:
+            self.last_exception = {
+                "type": type(e).__name__,
+                "message": str(e),
+                "traceback": traceback.format_exc()
+            }
+
+        global_output_pipe.write(value)
+        global_output_pipe.flush()
+
+        if self.last_exception:
+            status = {
+                "type": "exception",
+                "exception": self.last_exception["type"],
+                "message": self.last_exception["message"],
+                "traceback": self.last_exception["traceback"],
+            }
+        else:
+            status = {
+                "type": "status",
+                "message": "ok",
+            }
+        f_status.write(json.dumps(status) + "\n")
+        f_status.flush()
+        return False
+
 def run_repl(input_pipe, output_pipe):
     global global_output_pipe
     global_output_pipe = output_pipe
     
     # Initialize the REPL interpreter with stdout and stderr redirection options
     repl = REPLInterpreter()
+    signal.signal(signal.SIGINT, repl.interrupt)
     code_buffer = ""  # Buffer for multiline code input
     gotdelim = False
     # breakpoint()
@@ -136,7 +278,16 @@ def run_repl(input_pipe, output_pipe):
                 continue
 
             # Feed the complete code block to the interpreter
-            more = repl.conrun(code_buffer)
+            if code_buffer.startswith(EVAL_PREFIX):
+                more = repl.evalrun(code_buffer[len(EVAL_PREFIX):])
+            elif code_buffer == RESET_COMMAND:
+                more = repl.reset()
+            elif code_buffer == INSPECT_COMMAND:
+                more = repl.inspect()
+            elif code_buffer.startswith(STREAM_PREFIX):
+                more = repl.conrun(code_buffer[len(STREAM_PREFIX):], stream=True)
+            else:
+                more = repl.conrun(code_buffer)
 
             # Once the block is complete, clear buffer after execution
             code_buffer = ""  # Reset buffer for next input block
@@ -54,14 +54,36 @@ def print_program_structure(modules):
             else:
                 debug_out(f"{name}")
 
+def importing_module_name():
+    # Walk up the stack past importlib and bootstrap frames to find the module requesting the import
+    frame = sys._getframe(1)
+    while frame is not None:
+        name = frame.f_globals.get('__name__', '')
+        if frame.f_globals is not globals() and name != 'importlib' and not name.startswith('importlib.'):
+            return name
+        frame = frame.f_back
+    return ''
+
 class CustomFinder(MetaPathFinder):
-    def __init__(self, modules):
+    def __init__(self, modules, hidden=None):
         self.modules = modules
         self.loaded_modules = {}
+        self.hidden = hidden or set()
+
+    def is_hidden(self, fullname):
+        return any(fullname == h or fullname.startswith(h + '.') for h in self.hidden)
+
+    def import_allowed(self, fullname, importer):
+        # Hidden modules may only be imported from within their own top-level package
+        root = fullname.split('.')[0]
+        return importer == root or importer.startswith(root + '.')
 
     def find_spec(self, fullname, path, target=None):
         debug_out(f"Attempting to find spec for: {fullname}")
         debug_out(f"Search path: {path}")
+
+        if self.is_hidden(fullname) and not self.import_allowed(fullname, importing_module_name()):
+            raise ModuleNotFoundError(f"No module named '{fullname}'", name=fullname)
         
         # Check if it's a module we know about
         if fullname in self.modules:
@@ -167,6 +189,51 @@ class CustomLoader(Loader):
         
         debug_out(f"Finished executing module: {self.fullname}")
 
+def install_import_guard(finder):
+    # Hidden modules already in sys.modules bypass the finder, so also check import statements
+    import builtins
+    original_import = builtins.__import__
+
+    def guarded_import(name, globals=None, locals=None, fromlist=(), level=0):
+        importer = (globals or {}).get('__name__', '')
+        try:
+            if level > 0:
+                absname = importlib.util.resolve_name('.' * level + name, (globals or {}).get('__package__') or '')
+            else:
+                absname = name
+        except (ImportError, ValueError):
+            absname = None
+        if absname:
+            candidates = [absname] + [f"{absname}.{item}" for item in (fromlist or ()) if item != '*']
+            for candidate in candidates:
+                if finder.is_hidden(candidate) and not finder.import_allowed(candidate, importer):
+                    raise ModuleNotFoundError(f"No module named '{candidate}'", name=candidate)
+        return original_import(name, globals, locals, fromlist, level)
+
+    original_import_module = importlib.import_module
+
+    def guarded_import_module(name, package=None):
+        absname = importlib.util.resolve_name(name, package) if name.startswith('.') else name
+        if finder.is_hidden(absname) and not finder.import_allowed(absname, importing_module_name()):
+            raise ModuleNotFoundError(f"No module named '{absname}'", name=absname)
+        return original_import_module(name, package)
+
+    builtins.__import__ = guarded_import
+    importlib.import_module = guarded_import_module
+
+def collect_hidden_modules(program_data):
+    hidden = set()
+
+    def walk(package):
+        for name in package.get('Hidden') or []:
+            hidden.add(name)
+        for sub_package in package.get('Packages') or []:
+            walk(sub_package)
+
+    for package in program_data.get('Packages') or []:
+        walk(package)
+    return hidden
+
 def load_program_data(program_data):
     modules = {}
     
@@ -228,6 +295,9 @@ def load_program_data(program_data):
     return modules
 
 
+# Record when the secondary bootstrap started executing
+timing_secondary_start = time.time()
+
 # Read program data from the second pipe
 fd_program = int(sys.argv[3])
 with sys.__jbo(fd_program, 'r') as f_program:
@@ -253,10 +323,14 @@ debug_out(f"Extra file descriptors: {len(sys.extra_file_descriptors)}")
 sys.argv = ["pyingo.py"] + sys.argv[2 + extra_file_count:]
 
 # Process program data
+timing_program_loaded = time.time()
 modules = load_program_data(program_data)
 
-# Create an instance of CustomFinder
-custom_finder = CustomFinder(modules)
+# Create an instance of CustomFinder, hiding any modules excluded by PackageOptions
+hidden_modules = collect_hidden_modules(program_data)
+custom_finder = CustomFinder(modules, hidden_modules)
+if hidden_modules:
+    install_import_guard(custom_finder)
 
 # Add the custom finder to sys.meta_path
 sys.meta_path.insert(0, custom_finder)
@@ -272,6 +346,8 @@ if modules is not None:
         if '.' not in name and name != main_module_name:
             custom_finder._load_module(name)
 
+timing_modules_loaded = time.time()
+
 # get the "jumpboot" package
 jumpboot_package = importlib.import_module('jumpboot')
 
@@ -285,15 +361,32 @@ if jumpboot_package is not None:
     if 'KVPairs' in program_data and program_data['KVPairs'] is not None:
         for key, value in program_data['KVPairs'].items():
             setattr(jumpboot_package, key, value)
+        # keep them together too, for jumpboot.get_kv
+        setattr(jumpboot_package, "_kvpairs", dict(program_data['KVPairs']))
 
 # Now load and execute the main module
 main_module_info = modules[main_module_name]
 main_source = base64.b64decode(main_module_info['Source']).decode('utf-8')
 
-loader = CustomLoader(main_source, main_module_info['Path'], '__main__', custom_finder)
-spec = importlib.util.spec_from_file_location('__main__', main_module_info['Path'], loader=loader)
+# The program runs as __main__ unless a MainModuleName is given, in which case it
+# is imported under that name and `if __name__ == "__main__"` guards do not fire
+run_as_name = program_data.get('MainModuleName') or '__main__'
+loader = CustomLoader(main_source, main_module_info['Path'], run_as_name, custom_finder)
+spec = importlib.util.spec_from_file_location(run_as_name, main_module_info['Path'], loader=loader)
 main_module = importlib.util.module_from_spec(spec)
-sys.modules['__main__'] = main_module
+sys.modules[run_as_name] = main_module
+
+# Report startup timings to Go before any debugger wait so they reflect real startup cost
+timing_info = {
+    "type": "timing",
+    "primary_start": getattr(sys, '__jbt', timing_secondary_start),
+    "secondary_start": timing_secondary_start,
+    "program_loaded": timing_program_loaded,
+    "modules_loaded": timing_modules_loaded,
+    "main_start": time.time(),
+}
+f_status.write(json.dumps(timing_info) + "\n")
+f_status.flush()
 
 # if program_data has a field 'DebugPort' and it is not None and non-zero, then start the debug server
 # and wait for the client to connect and stop at the breakpoint.
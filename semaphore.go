@@ -1,10 +1,21 @@
 package jumpboot
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSemaphoreNotAvailable is returned by the semaphore functions in builds
+// without CGO on Unix, where named semaphores need sem_open.
+var ErrSemaphoreNotAvailable = errors.New("named semaphores require CGO on this platform; rebuild with CGO_ENABLED=1")
+
 // Semaphore provides cross-process synchronization using named semaphores.
 // It enables coordination between Go and Python processes accessing shared resources.
 //
-// Note: This feature requires CGO and platform-specific implementations:
-//   - Linux/macOS: POSIX named semaphores (sem_open)
+// Note: This feature uses platform-specific implementations:
+//   - Linux/macOS: POSIX named semaphores (sem_open), which require CGO;
+//     without it CreateSemaphore and OpenSemaphore return ErrSemaphoreNotAvailable
 //   - Windows: Kernel semaphore objects
 //
 // Create a semaphore with CreateSemaphore and open an existing one with OpenSemaphore.
@@ -33,7 +44,49 @@ type Semaphore interface {
 	// Returns true if acquired, false if the timeout elapsed.
 	AcquireTimeout(timeoutMs int) (bool, error)
 
+	// AcquireContext blocks until the semaphore can be decremented or ctx is
+	// done. On cancellation it returns ctx.Err() without having acquired.
+	AcquireContext(ctx context.Context) error
+
 	// Close releases resources associated with the semaphore.
 	// The semaphore is only destroyed when all processes have closed it.
 	Close() error
 }
+
+// NewSemaphore creates a named semaphore.
+//
+// Deprecated: Use CreateSemaphore.
+func NewSemaphore(name string, initialValue int) (Semaphore, error) {
+	return CreateSemaphore(name, initialValue)
+}
+
+// acquirePollInterval is the longest single wait in acquireContext, and so the
+// longest it takes to notice a cancelled context.
+const acquirePollInterval = 50 * time.Millisecond
+
+// acquireContext implements AcquireContext with the semaphore's timed wait,
+// waiting in short slices so it can check ctx between them.
+func acquireContext(s Semaphore, ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		wait := acquirePollInterval
+		if deadline, ok := ctx.Deadline(); ok {
+			if remaining := time.Until(deadline); remaining < wait {
+				wait = remaining
+			}
+		}
+		ms := int(wait.Milliseconds())
+		if ms < 1 {
+			ms = 1
+		}
+		acquired, err := s.AcquireTimeout(ms)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows && !cgo
+// +build !windows,!cgo
+
+package jumpboot
+
+// CreateSemaphore returns ErrSemaphoreNotAvailable without CGO.
+func CreateSemaphore(name string, initialValue int) (Semaphore, error) {
+	return nil, ErrSemaphoreNotAvailable
+}
+
+// OpenSemaphore returns ErrSemaphoreNotAvailable without CGO.
+func OpenSemaphore(name string) (Semaphore, error) {
+	return nil, ErrSemaphoreNotAvailable
+}
+
+// RemoveSemaphore returns ErrSemaphoreNotAvailable without CGO.
+func RemoveSemaphore(name string) error {
+	return ErrSemaphoreNotAvailable
+}
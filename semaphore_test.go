@@ -0,0 +1,106 @@
+package jumpboot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestSemaphoreCrossProcess tests that a semaphore created in Go is released
+// and acquired by a Python child through jumpboot.NamedSemaphore.
+func TestSemaphoreCrossProcess(t *testing.T) {
+	name := fmt.Sprintf("/jumpboot_sem_%d", os.Getpid())
+	sem, err := CreateSemaphore(name, 0)
+	if errors.Is(err, ErrSemaphoreNotAvailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("CreateSemaphore failed: %v", err)
+	}
+	defer RemoveSemaphore(name)
+	defer sem.Close()
+
+	if ok, err := sem.TryAcquire(); ok || err != nil {
+		t.Fatalf("TryAcquire on a new semaphore = %v, %v", ok, err)
+	}
+
+	p := newTestExecProcess(t)
+	open := fmt.Sprintf("import jumpboot\nsem = jumpboot.NamedSemaphore(%q)\n", name)
+
+	// Python releases, Go acquires
+	if _, err := p.ExecWithTimeout(open+"sem.release()\nsem.close()", 10*time.Second); err != nil {
+		t.Fatalf("Python release failed: %v", err)
+	}
+	if ok, err := sem.AcquireTimeout(5000); !ok || err != nil {
+		t.Fatalf("AcquireTimeout after the Python release = %v, %v", ok, err)
+	}
+
+	// Go releases, Python acquires
+	sem.Release()
+	sem.Release()
+	out, err := p.ExecWithTimeout(open+"sem.acquire()\nsem.acquire()\nsem.close()\nprint('acquired')", 10*time.Second)
+	if err != nil || out != "acquired\n" {
+		t.Fatalf("Python acquire = %q, %v", out, err)
+	}
+	if ok, err := sem.TryAcquire(); ok || err != nil {
+		t.Errorf("TryAcquire after Python took both = %v, %v", ok, err)
+	}
+
+	other, err := OpenSemaphore(name)
+	if err != nil {
+		t.Fatalf("OpenSemaphore failed: %v", err)
+	}
+	defer other.Close()
+	other.Release()
+	if ok, _ := sem.TryAcquire(); !ok {
+		t.Error("a release through OpenSemaphore was not seen")
+	}
+}
+
+// TestSemaphoreAcquireContext tests that AcquireContext returns once the
+// semaphore is released and gives up without acquiring when ctx is done.
+func TestSemaphoreAcquireContext(t *testing.T) {
+	name := fmt.Sprintf("/jumpboot_semctx_%d", os.Getpid())
+	sem, err := CreateSemaphore(name, 0)
+	if errors.Is(err, ErrSemaphoreNotAvailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("CreateSemaphore failed: %v", err)
+	}
+	defer RemoveSemaphore(name)
+	defer sem.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := sem.AcquireContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("AcquireContext past the deadline = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("AcquireContext gave up after %v", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	if err := sem.AcquireContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("AcquireContext after cancel = %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		sem.Release()
+	}()
+	if err := sem.AcquireContext(context.Background()); err != nil {
+		t.Errorf("AcquireContext with a release = %v", err)
+	}
+	if ok, _ := sem.TryAcquire(); ok {
+		t.Error("a cancelled AcquireContext left a count behind")
+	}
+}
@@ -1,5 +1,5 @@
-//go:build !windows
-// +build !windows
+//go:build !windows && cgo
+// +build !windows,cgo
 
 package jumpboot
 
@@ -87,6 +87,7 @@ int get_errno() {
 */
 import "C"
 import (
+	"context"
 	"fmt"
 	"time"
 	"unsafe"
@@ -97,7 +98,9 @@ type posixSemaphore struct {
 	name string
 }
 
-func NewSemaphore(name string, initialValue int) (Semaphore, error) {
+// CreateSemaphore creates a POSIX named semaphore with the given initial
+// value, or opens it if it already exists. The name should start with "/".
+func CreateSemaphore(name string, initialValue int) (Semaphore, error) {
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
 
@@ -109,6 +112,7 @@ func NewSemaphore(name string, initialValue int) (Semaphore, error) {
 	return &posixSemaphore{sem: sem, name: name}, nil
 }
 
+// OpenSemaphore opens an existing POSIX named semaphore.
 func OpenSemaphore(name string) (Semaphore, error) {
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
@@ -158,6 +162,11 @@ func (s *posixSemaphore) AcquireTimeout(timeoutMs int) (bool, error) {
 	return false, fmt.Errorf("error acquiring semaphore with timeout")
 }
 
+// AcquireContext blocks until the semaphore can be decremented or ctx is done.
+func (s *posixSemaphore) AcquireContext(ctx context.Context) error {
+	return acquireContext(s, ctx)
+}
+
 func (s *posixSemaphore) Close() error {
 	if C.close_semaphore(s.sem) != 0 {
 		return fmt.Errorf("failed to close semaphore")
@@ -165,6 +174,8 @@ func (s *posixSemaphore) Close() error {
 	return nil
 }
 
+// RemoveSemaphore removes a named semaphore. Processes that still have it open
+// can keep using it; it is destroyed once they all close it.
 func RemoveSemaphore(name string) error {
 	cName := C.CString(name)
 	defer C.free(unsafe.Pointer(cName))
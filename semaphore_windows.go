@@ -4,6 +4,7 @@
 package jumpboot
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"unsafe"
@@ -35,7 +36,9 @@ type windowsSemaphore struct {
 	name   string
 }
 
-func NewSemaphore(name string, initialValue int) (Semaphore, error) {
+// CreateSemaphore creates a named kernel semaphore with the given initial
+// value, or opens it if it already exists.
+func CreateSemaphore(name string, initialValue int) (Semaphore, error) {
 	initOnce.Do(initProcs)
 
 	utf16Name, err := windows.UTF16PtrFromString(name)
@@ -57,6 +60,7 @@ func NewSemaphore(name string, initialValue int) (Semaphore, error) {
 	return &windowsSemaphore{handle: windows.Handle(handle), name: name}, nil
 }
 
+// OpenSemaphore opens an existing named kernel semaphore.
 func OpenSemaphore(name string) (Semaphore, error) {
 	initOnce.Do(initProcs)
 
@@ -118,6 +122,11 @@ func (s *windowsSemaphore) AcquireTimeout(timeoutMs int) (bool, error) {
 	}
 }
 
+// AcquireContext blocks until the semaphore can be decremented or ctx is done.
+func (s *windowsSemaphore) AcquireContext(ctx context.Context) error {
+	return acquireContext(s, ctx)
+}
+
 func (s *windowsSemaphore) Close() error {
 	ret, _, err := procCloseHandle.Call(uintptr(s.handle))
 	if ret == 0 {
@@ -125,3 +134,9 @@ func (s *windowsSemaphore) Close() error {
 	}
 	return nil
 }
+
+// RemoveSemaphore is a no-op on Windows, where a named semaphore is destroyed
+// when its last handle is closed.
+func RemoveSemaphore(name string) error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package jumpboot
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+)
+
+// uint64At returns a pointer to the 8-byte word at offset, checking that it is
+// inside the region and 8-byte aligned so atomic access can't tear.
+func (o *SharedMemory) uint64At(offset int) (*uint64, error) {
+	if o.m == nil {
+		return nil, errors.New("shared memory is closed")
+	}
+	if offset < 0 || offset > o.m.size-8 {
+		return nil, fmt.Errorf("offset %d is outside %d bytes of shared memory", offset, o.m.size)
+	}
+	ptr := unsafe.Add(o.GetPtr(), offset)
+	if offset%8 != 0 || uintptr(ptr)%8 != 0 {
+		return nil, fmt.Errorf("offset %d is not 8-byte aligned", offset)
+	}
+	return (*uint64)(ptr), nil
+}
+
+// AtomicAddUint64 atomically adds delta to the uint64 at offset and returns
+// the new value. The offset must be 8-byte aligned. To subtract x, add
+// ^uint64(x-1).
+//
+// This is only atomic with respect to other processes that also update the
+// word atomically: other Go processes using these methods, or C code using
+// atomic instructions. Python has no atomic read-modify-write on shared
+// memory, so if Python also changes the word, guard every update on both sides
+// with a shared lock such as a named semaphore.
+func (o *SharedMemory) AtomicAddUint64(offset int, delta uint64) (uint64, error) {
+	p, err := o.uint64At(offset)
+	if err != nil {
+		return 0, err
+	}
+	return atomic.AddUint64(p, delta), nil
+}
+
+// AtomicLoadUint64 atomically reads the uint64 at offset. The offset must be
+// 8-byte aligned.
+func (o *SharedMemory) AtomicLoadUint64(offset int) (uint64, error) {
+	p, err := o.uint64At(offset)
+	if err != nil {
+		return 0, err
+	}
+	return atomic.LoadUint64(p), nil
+}
+
+// AtomicStoreUint64 atomically writes v to the uint64 at offset. The offset
+// must be 8-byte aligned.
+func (o *SharedMemory) AtomicStoreUint64(offset int, v uint64) error {
+	p, err := o.uint64At(offset)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint64(p, v)
+	return nil
+}
@@ -1,8 +1,10 @@
 package jumpboot
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"unsafe"
 )
@@ -14,8 +16,9 @@ import (
 // Shared memory is created with CreateSharedMemory and opened with OpenSharedMemory.
 // Both processes must use the same name and agree on the size.
 //
-// Note: This feature requires CGO and platform-specific implementations:
-//   - Linux/macOS: POSIX shared memory (shm_open, mmap)
+// Note: This feature uses platform-specific implementations:
+//   - Linux/macOS: POSIX shared memory (shm_open, mmap) with CGO, or an mmap'd
+//     file in /dev/shm (Linux) or the temp directory (macOS) without CGO
 //   - Windows: Named file mappings
 //
 // Example:
@@ -73,6 +76,35 @@ func OpenSharedMemory(name string, size int) (*SharedMemory, error) {
 	return &SharedMemory{m, 0, name}, nil
 }
 
+// Resize grows the shared memory region to newSize bytes, preserving its
+// contents and the current position. Slices obtained from GetPtr,
+// GetTypedSlice or the Get*Slice methods before a Resize refer to the old
+// mapping and must not be used afterwards.
+//
+// The new size is carried by the shared memory itself, so other processes see
+// it once they close and reopen the region by name (Python's
+// multiprocessing.shared_memory.SharedMemory(name) reads the size of the
+// region it opens). How they are told to reopen is up to the application,
+// for example a QueueProcess call. Other processes keep using the old region
+// until then:
+//   - Linux, and macOS without CGO: the region grows in place, and other
+//     processes' mappings stay valid for the old size.
+//   - macOS with CGO: the region is recreated, so only its creator can resize it.
+//   - Windows: the mapping is recreated, which fails while another process
+//     still has it open.
+func (o *SharedMemory) Resize(newSize int) error {
+	if o.m == nil {
+		return fmt.Errorf("shared memory is closed")
+	}
+	if newSize < o.m.size {
+		return fmt.Errorf("shared memory can only grow: %d is smaller than %d", newSize, o.m.size)
+	}
+	if newSize == o.m.size {
+		return nil
+	}
+	return o.m.resize(newSize)
+}
+
 // Close unmaps and releases the shared memory region.
 // The underlying memory is only destroyed when all processes have closed it.
 func (o *SharedMemory) Close() (err error) {
@@ -86,9 +118,9 @@ func (o *SharedMemory) Close() (err error) {
 }
 
 // Read reads up to len(p) bytes from shared memory at the current position.
-// Implements io.Reader.
+// Implements io.Reader; at the end of the region it returns 0, io.EOF.
 func (o *SharedMemory) Read(p []byte) (n int, err error) {
-	n, err = o.ReadAt(p, o.pos)
+	n, err = o.m.readAt(p, o.pos)
 	if err != nil {
 		return 0, err
 	}
@@ -97,27 +129,40 @@ func (o *SharedMemory) Read(p []byte) (n int, err error) {
 }
 
 // ReadAt reads len(p) bytes from shared memory starting at offset off.
-// Implements io.ReaderAt.
+// Implements io.ReaderAt: if fewer than len(p) bytes remain, it returns the
+// bytes read and io.EOF.
 func (o *SharedMemory) ReadAt(p []byte, off int64) (n int, err error) {
-	return o.m.readAt(p, off)
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	n, err = o.m.readAt(p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
 }
 
 // Seek sets the position for the next Read or Write.
 // Implements io.Seeker with io.SeekStart, io.SeekCurrent, and io.SeekEnd.
+// The position may be anywhere from 0 to the size of the region; at the size,
+// Read returns io.EOF.
 func (o *SharedMemory) Seek(offset int64, whence int) (int64, error) {
+	var base int64
 	switch whence {
 	case io.SeekStart:
-		offset += int64(0)
 	case io.SeekCurrent:
-		offset += o.pos
+		base = o.pos
 	case io.SeekEnd:
-		offset += int64(o.m.size)
+		base = int64(o.m.size)
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
 	}
-	if offset < 0 || offset >= int64(o.m.size) {
-		return 0, fmt.Errorf("invalid offset")
+	pos := base + offset
+	if offset > 0 && pos < base || pos < 0 || pos > int64(o.m.size) {
+		return 0, fmt.Errorf("invalid offset %d for %d bytes of shared memory", offset, o.m.size)
 	}
-	o.pos = offset
-	return offset, nil
+	o.pos = pos
+	return pos, nil
 }
 
 // Write writes len(p) bytes to shared memory at the current position.
@@ -134,9 +179,69 @@ func (o *SharedMemory) Write(p []byte) (n int, err error) {
 // WriteAt writes len(p) bytes to shared memory starting at offset off.
 // Implements io.WriterAt.
 func (o *SharedMemory) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
 	return o.m.writeAt(p, off)
 }
 
+// MessageHeaderSize is the size of the length header WriteMessage writes
+// before each message.
+const MessageHeaderSize = 4
+
+// WriteMessage stores p as a discrete message at the start of the shared
+// memory: a 4-byte big-endian length followed by the payload, so the reader
+// knows how many bytes are valid. The payload is written before the header.
+// It returns an error if the message does not fit. The current position used
+// by Read and Write is not changed.
+//
+// Python reads the message with:
+//
+//	size = struct.unpack(">I", shm.buf[:4])[0]
+//	data = bytes(shm.buf[4:4 + size])
+func (o *SharedMemory) WriteMessage(p []byte) error {
+	if o.m == nil {
+		return fmt.Errorf("shared memory is closed")
+	}
+	if int64(len(p)) > int64(o.m.size)-MessageHeaderSize || uint64(len(p)) > math.MaxUint32 {
+		return fmt.Errorf("message of %d bytes does not fit in %d bytes of shared memory", len(p), o.m.size)
+	}
+	if len(p) > 0 {
+		if _, err := o.m.writeAt(p, MessageHeaderSize); err != nil {
+			return err
+		}
+	}
+	var header [MessageHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(p)))
+	_, err := o.m.writeAt(header[:], 0)
+	return err
+}
+
+// ReadMessage returns a copy of the message stored at the start of the shared
+// memory by WriteMessage (or by Python in the same format). It returns an
+// error if the header declares more bytes than the region holds, which
+// usually means no message has been written there.
+func (o *SharedMemory) ReadMessage() ([]byte, error) {
+	if o.m == nil {
+		return nil, fmt.Errorf("shared memory is closed")
+	}
+	var header [MessageHeaderSize]byte
+	if _, err := o.m.readAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	size := int64(binary.BigEndian.Uint32(header[:]))
+	if size > int64(o.m.size)-MessageHeaderSize {
+		return nil, fmt.Errorf("invalid message length %d for %d bytes of shared memory", size, o.m.size)
+	}
+	p := make([]byte, size)
+	if size > 0 {
+		if _, err := o.m.readAt(p, MessageHeaderSize); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
 // GetTypedSlice returns a typed slice view of shared memory starting at offset.
 // The slice provides zero-copy access to the underlying memory.
 // Changes to the slice are immediately visible in shared memory.
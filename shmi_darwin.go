@@ -5,6 +5,7 @@ package jumpboot
 
 /*
 #include <sys/mman.h>
+#include <stdlib.h>
 #include <sys/types.h>
 #include <sys/stat.h>
 #include <fcntl.h>
@@ -130,6 +131,35 @@ func (o *shmi) close() error {
 	return nil
 }
 
+// resize shared memory to a larger size, preserving its contents. macOS
+// can't change the size of a POSIX segment once it is set, so the segment is
+// recreated under the same name and the contents are copied over. Processes
+// that still map the old segment keep seeing it until they reopen the name.
+func (o *shmi) resize(size int) error {
+	if !o.parent {
+		return fmt.Errorf("resize: only the creator can resize shared memory on macOS")
+	}
+
+	cname := C.CString(o.name)
+	defer C.free(unsafe.Pointer(cname))
+	fd := C._create_shm(cname, C.int(size))
+	if fd < 0 {
+		return fmt.Errorf("resize")
+	}
+	v := C.Map(fd, C.int(size))
+	if v == nil {
+		C.Close(fd, nil, C.int(size))
+		return fmt.Errorf("resize")
+	}
+
+	copy(unsafe.Slice((*byte)(v), size), unsafe.Slice((*byte)(o.v), o.size))
+	C.Close(o.fd, o.v, C.int(o.size))
+	o.fd = fd
+	o.v = v
+	o.size = size
+	return nil
+}
+
 func (o *shmi) readAt(p []byte, off int64) (n int, err error) {
 	if off >= int64(o.size) {
 		return 0, io.EOF
@@ -67,6 +67,20 @@ void Close(int fd, void* p, int size) {
 void Delete(const char* name) {
 	shm_unlink(name);
 }
+
+// Resize grows the segment to size and maps it again. The old mapping is
+// only released once the new one exists.
+void* Resize(int fd, void* p, int oldSize, int size) {
+	if (ftruncate(fd, size) != 0) {
+		return NULL;
+	}
+	void* np = Map(fd, size);
+	if (np == NULL) {
+		return NULL;
+	}
+	munmap(p, oldSize);
+	return np;
+}
 */
 import "C"
 
@@ -140,6 +154,17 @@ func (o *shmi) close() error {
 	return nil
 }
 
+// resize shared memory to a larger size, preserving its contents.
+func (o *shmi) resize(size int) error {
+	v, err := C.Resize(o.fd, o.v, C.int(o.size), C.int(size))
+	if v == nil {
+		return fmt.Errorf("resize: %v", err)
+	}
+	o.v = v
+	o.size = size
+	return nil
+}
+
 // read shared memory. return read size.
 func (o *shmi) readAt(p []byte, off int64) (n int, err error) {
 	if off >= int64(o.size) {
@@ -4,18 +4,36 @@ package jumpboot
 
 import (
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"unsafe"
+
+	"golang.org/x/sys/unix"
 )
 
 // ErrSharedMemoryNotAvailable is returned when shared memory operations are
-// attempted but CGO is disabled. Shared memory requires CGO on Unix platforms.
+// attempted but no implementation is available. Builds without CGO now fall
+// back to memory-mapped files, so this is kept for compatibility.
 var ErrSharedMemoryNotAvailable = errors.New("shared memory requires CGO on this platform; rebuild with CGO_ENABLED=1")
 
-// shmi is a stub implementation for when CGO is disabled.
-// All operations return ErrSharedMemoryNotAvailable.
+// shmi is the pure-Go implementation used when CGO is disabled. Instead of
+// shm_open, it maps a regular file with mmap.
+//
+// On Linux the file lives in /dev/shm, which is where shm_open places its
+// segments, so Python's SharedMemory opens the same memory by name. On macOS
+// POSIX segments are not visible in the filesystem, so the file is created in
+// the temp directory and Python must map that file (see shmPath) instead of
+// opening the segment by name.
 type shmi struct {
-	size int
+	path   string
+	file   *os.File
+	data   []byte
+	size   int
+	parent bool
 }
 
 func (o *shmi) getSize() int {
@@ -23,25 +41,112 @@ func (o *shmi) getSize() int {
 }
 
 func (o *shmi) getPtr() unsafe.Pointer {
-	return nil
+	if len(o.data) == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&o.data[0])
+}
+
+// shmPath returns the file backing the shared memory with the given name.
+func shmPath(name string) string {
+	name = strings.TrimLeft(name, "/")
+	if runtime.GOOS == "linux" {
+		if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+			return filepath.Join("/dev/shm", name)
+		}
+	}
+	return filepath.Join(os.TempDir(), "jumpboot-shm-"+name)
 }
 
+// create shared memory. return shmi object.
 func create(name string, size int) (*shmi, error) {
-	return nil, ErrSharedMemoryNotAvailable
+	path := shmPath(name)
+
+	// Clean up stale segments before creating a new one, as shm_unlink does
+	os.Remove(path)
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0660)
+	if err != nil {
+		return nil, fmt.Errorf("create: %v", err)
+	}
+	if err := file.Truncate(int64(size)); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("create: %v", err)
+	}
+
+	data, err := unix.Mmap(int(file.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("create: %v", err)
+	}
+
+	return &shmi{path, file, data, size, true}, nil
 }
 
+// open shared memory. return shmi object.
 func open(name string, size int) (*shmi, error) {
-	return nil, ErrSharedMemoryNotAvailable
+	path := shmPath(name)
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open: %v", err)
+	}
+
+	data, err := unix.Mmap(int(file.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		// The opener should NOT delete the memory on a map failure.
+		file.Close()
+		return nil, fmt.Errorf("open: %v", err)
+	}
+
+	return &shmi{path, file, data, size, false}, nil
 }
 
 func (o *shmi) close() error {
-	return ErrSharedMemoryNotAvailable
+	var err error
+	if o.data != nil {
+		err = unix.Munmap(o.data)
+		o.data = nil
+	}
+	if o.file != nil {
+		o.file.Close()
+		o.file = nil
+	}
+	if o.parent {
+		os.Remove(o.path)
+	}
+	return err
+}
+
+// resize shared memory to a larger size, preserving its contents.
+func (o *shmi) resize(size int) error {
+	if err := o.file.Truncate(int64(size)); err != nil {
+		return fmt.Errorf("resize: %v", err)
+	}
+	data, err := unix.Mmap(int(o.file.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("resize: %v", err)
+	}
+	unix.Munmap(o.data)
+	o.data = data
+	o.size = size
+	return nil
 }
 
+// read shared memory. return read size.
 func (o *shmi) readAt(p []byte, off int64) (n int, err error) {
-	return 0, io.EOF
+	if off >= int64(o.size) {
+		return 0, io.EOF
+	}
+	return copy(p, o.data[off:]), nil
 }
 
+// write shared memory. return write size.
 func (o *shmi) writeAt(p []byte, off int64) (n int, err error) {
-	return 0, io.EOF
+	if off >= int64(o.size) {
+		return 0, io.EOF
+	}
+	return copy(o.data[off:], p), nil
 }
@@ -0,0 +1,281 @@
+package jumpboot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestSharedMemoryResize tests that Resize grows a region in place, keeping
+// its contents and position, and that reopening the name sees the new size.
+func TestSharedMemoryResize(t *testing.T) {
+	name := fmt.Sprintf("/jumpboot_resize_%d", os.Getpid())
+	shm, err := CreateSharedMemory(name, 4096)
+	if err != nil {
+		t.Fatalf("CreateSharedMemory failed: %v", err)
+	}
+	defer shm.Close()
+
+	if _, err := shm.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := shm.Resize(1024); err == nil || !strings.Contains(err.Error(), "only grow") {
+		t.Errorf("expected shrinking to fail, got %v", err)
+	}
+	if err := shm.Resize(3 * 4096); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	if shm.GetSize() != 3*4096 {
+		t.Errorf("size = %d, want %d", shm.GetSize(), 3*4096)
+	}
+
+	// The position carries on after the existing contents
+	if _, err := shm.Write([]byte(" world")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shm.WriteAt([]byte("end"), 3*4096-3); err != nil {
+		t.Fatalf("WriteAt past the old size failed: %v", err)
+	}
+
+	other, err := OpenSharedMemory(name, 3*4096)
+	if err != nil {
+		t.Fatalf("OpenSharedMemory failed: %v", err)
+	}
+	defer other.Close()
+	buf := make([]byte, 11)
+	if _, err := other.ReadAt(buf, 0); err != nil || string(buf) != "hello world" {
+		t.Errorf("reopened region holds %q, %v", buf, err)
+	}
+	if tail := other.GetByteSlice(3*4096 - 3); !bytes.Equal(tail, []byte("end")) {
+		t.Errorf("reopened region ends with %q", tail)
+	}
+	if _, err := other.ReadAt(buf, 3*4096); err != io.EOF {
+		t.Errorf("expected EOF past the new size, got %v", err)
+	}
+}
+
+// TestSharedMemoryMessages tests that WriteMessage and ReadMessage exchange
+// length-prefixed messages and reject lengths that don't fit.
+func TestSharedMemoryMessages(t *testing.T) {
+	name := fmt.Sprintf("/jumpboot_messages_%d", os.Getpid())
+	shm, err := CreateSharedMemory(name, 64)
+	if err != nil {
+		t.Fatalf("CreateSharedMemory failed: %v", err)
+	}
+	defer shm.Close()
+
+	for _, msg := range []string{"a longer first message", "short", ""} {
+		if err := shm.WriteMessage([]byte(msg)); err != nil {
+			t.Fatalf("WriteMessage(%q) failed: %v", msg, err)
+		}
+		got, err := shm.ReadMessage()
+		if err != nil || string(got) != msg {
+			t.Errorf("ReadMessage = %q, %v; want %q", got, err, msg)
+		}
+	}
+
+	// The largest message fills the region after the header
+	if err := shm.WriteMessage(make([]byte, 64-MessageHeaderSize)); err != nil {
+		t.Errorf("expected a full-size message to fit: %v", err)
+	}
+	if err := shm.WriteMessage(make([]byte, 64-MessageHeaderSize+1)); err == nil {
+		t.Errorf("expected an oversized message to fail")
+	}
+
+	// A corrupt header is rejected rather than read past the region
+	if _, err := shm.WriteAt([]byte{0, 0, 1, 0}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := shm.ReadMessage(); err == nil || !strings.Contains(err.Error(), "invalid message length") {
+		t.Errorf("expected an invalid length error, got %v", err)
+	}
+
+	// Raw access is unaffected
+	if pos, err := shm.Seek(0, io.SeekCurrent); err != nil || pos != 0 {
+		t.Errorf("expected the position to stay at 0, got %d, %v", pos, err)
+	}
+}
+
+// TestSharedMemoryArrayHeader tests that an array header round-trips its
+// dtype and shape, that the data is aligned after it, and that invalid
+// headers are rejected.
+func TestSharedMemoryArrayHeader(t *testing.T) {
+	name := fmt.Sprintf("/jumpboot_array_%d", os.Getpid())
+	shm, err := CreateSharedMemory(name, 4096)
+	if err != nil {
+		t.Fatalf("CreateSharedMemory failed: %v", err)
+	}
+	defer shm.Close()
+
+	if _, _, _, err := shm.ReadArrayHeader(); err == nil {
+		t.Error("expected an error reading a region without a header")
+	}
+	if _, err := shm.WriteArrayHeader("float16", []int{2}); err == nil {
+		t.Error("expected an error for an unsupported dtype")
+	}
+	if _, err := shm.WriteArrayHeader("float64", []int{100, 100}); err == nil {
+		t.Error("expected an error for an array larger than the region")
+	}
+
+	offset, err := shm.WriteArrayHeader("float32", []int{2, 3, 4})
+	if err != nil {
+		t.Fatalf("WriteArrayHeader failed: %v", err)
+	}
+	if offset%ArrayDataAlignment != 0 {
+		t.Errorf("data offset %d is not aligned", offset)
+	}
+	dtype, shape, dataOffset, err := shm.ReadArrayHeader()
+	if err != nil || dtype != "float32" || fmt.Sprint(shape) != "[2 3 4]" || dataOffset != offset {
+		t.Fatalf("ReadArrayHeader = %q, %v, %d, %v", dtype, shape, dataOffset, err)
+	}
+
+	data, err := shm.ArrayData()
+	if err != nil || len(data) != 2*3*4*4 {
+		t.Fatalf("ArrayData = %d bytes, %v", len(data), err)
+	}
+	values, shape, err := GetArraySlice[float32](shm)
+	if err != nil || len(values) != 24 || len(shape) != 3 {
+		t.Fatalf("GetArraySlice = %d values, %v, %v", len(values), shape, err)
+	}
+	values[23] = 1.5
+	if got := shm.GetFloat32Slice(offset)[23]; got != 1.5 {
+		t.Errorf("GetArraySlice is not a view of the data: %v", got)
+	}
+	if _, _, err := GetArraySlice[int64](shm); err == nil {
+		t.Error("expected an error for a mismatched element type")
+	}
+
+	// Bytes are written as NumPy's uint8, not its int8 "byte"
+	if _, err := shm.WriteArrayHeader("byte", []int{16}); err != nil {
+		t.Fatal(err)
+	}
+	if dtype, _, _, _ := shm.ReadArrayHeader(); dtype != "uint8" {
+		t.Errorf("byte array has dtype %q", dtype)
+	}
+
+	// A header claiming more data than the region holds is rejected
+	shm.WriteArrayHeader("uint8", []int{16})
+	binary.LittleEndian.PutUint64(shm.GetByteSlice(32), 1<<20)
+	if _, _, _, err := shm.ReadArrayHeader(); err == nil {
+		t.Error("expected an error for an oversized shape")
+	}
+}
+
+// TestSharedMemorySeek tests that Seek accepts every position from 0 to the
+// size of the region, that reads at the end return io.EOF, and that io.Copy
+// works on a region.
+func TestSharedMemorySeek(t *testing.T) {
+	name := fmt.Sprintf("/jumpboot_seek_%d", os.Getpid())
+	shm, err := CreateSharedMemory(name, 16)
+	if err != nil {
+		t.Fatalf("CreateSharedMemory failed: %v", err)
+	}
+	defer shm.Close()
+
+	if pos, err := shm.Seek(0, io.SeekEnd); err != nil || pos != 16 {
+		t.Fatalf("Seek(0, SeekEnd) = %d, %v", pos, err)
+	}
+	buf := make([]byte, 4)
+	if n, err := shm.Read(buf); n != 0 || err != io.EOF {
+		t.Errorf("Read at the end = %d, %v; want 0, EOF", n, err)
+	}
+
+	if pos, err := shm.Seek(-4, io.SeekEnd); err != nil || pos != 12 {
+		t.Fatalf("Seek(-4, SeekEnd) = %d, %v", pos, err)
+	}
+	if pos, err := shm.Seek(4, io.SeekCurrent); err != nil || pos != 16 {
+		t.Errorf("Seek(4, SeekCurrent) to the end = %d, %v", pos, err)
+	}
+	if _, err := shm.Seek(1, io.SeekCurrent); err == nil {
+		t.Error("expected seeking past the end to fail")
+	}
+	if _, err := shm.Seek(math.MaxInt64, io.SeekCurrent); err == nil {
+		t.Error("expected an overflowing SeekCurrent to fail")
+	}
+	if _, err := shm.Seek(-1, io.SeekStart); err == nil {
+		t.Error("expected a negative position to fail")
+	}
+	if _, err := shm.Seek(0, 3); err == nil {
+		t.Error("expected an invalid whence to fail")
+	}
+	if pos, _ := shm.Seek(0, io.SeekCurrent); pos != 16 {
+		t.Errorf("failed seeks moved the position to %d", pos)
+	}
+
+	// ReadAt across the end returns what is there and io.EOF
+	copy(shm.GetByteSlice(0), "0123456789abcdef")
+	if n, err := shm.ReadAt(buf, 14); n != 2 || err != io.EOF || string(buf[:n]) != "ef" {
+		t.Errorf("ReadAt(14) = %d %q, %v", n, buf[:n], err)
+	}
+	if n, err := shm.ReadAt(buf, 16); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt(16) = %d, %v", n, err)
+	}
+	if _, err := shm.ReadAt(buf, -1); err == nil {
+		t.Error("expected ReadAt with a negative offset to fail")
+	}
+
+	shm.Seek(0, io.SeekStart)
+	var out bytes.Buffer
+	if n, err := io.Copy(&out, shm); err != nil || n != 16 || out.String() != "0123456789abcdef" {
+		t.Errorf("io.Copy = %d %q, %v", n, out.String(), err)
+	}
+}
+
+// TestSharedMemoryAtomic tests that atomic adds through two mappings of the
+// same region don't lose updates, and that misaligned and out-of-range
+// offsets are rejected.
+func TestSharedMemoryAtomic(t *testing.T) {
+	name := fmt.Sprintf("/jumpboot_atomic_%d", os.Getpid())
+	shm, err := CreateSharedMemory(name, 64)
+	if err != nil {
+		t.Fatalf("CreateSharedMemory failed: %v", err)
+	}
+	defer shm.Close()
+	other, err := OpenSharedMemory(name, 64)
+	if err != nil {
+		t.Fatalf("OpenSharedMemory failed: %v", err)
+	}
+	defer other.Close()
+
+	if err := shm.AtomicStoreUint64(8, 100); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for _, region := range []*SharedMemory{shm, other} {
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(region *SharedMemory) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					region.AtomicAddUint64(8, 1)
+				}
+			}(region)
+		}
+	}
+	wg.Wait()
+	if v, err := other.AtomicLoadUint64(8); err != nil || v != 8100 {
+		t.Errorf("AtomicLoadUint64 = %d, %v; want 8100", v, err)
+	}
+	if v, _ := shm.AtomicAddUint64(8, ^uint64(0)); v != 8099 {
+		t.Errorf("subtracting 1 gave %d", v)
+	}
+
+	if _, err := shm.AtomicAddUint64(4, 1); err == nil || !strings.Contains(err.Error(), "aligned") {
+		t.Errorf("expected a misaligned offset to fail, got %v", err)
+	}
+	if _, err := shm.AtomicLoadUint64(64); err == nil {
+		t.Error("expected an offset past the end to fail")
+	}
+	if err := shm.AtomicStoreUint64(-8, 1); err == nil {
+		t.Error("expected a negative offset to fail")
+	}
+	if _, err := shm.AtomicLoadUint64(56); err != nil {
+		t.Errorf("the last word of the region failed: %v", err)
+	}
+}
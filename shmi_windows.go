@@ -3,13 +3,17 @@
 package jumpboot
 
 import (
+	"errors"
 	"io"
 	"os"
 	"syscall"
 	"unsafe"
+
+	"golang.org/x/sys/windows"
 )
 
 type shmi struct {
+	name string
 	h    syscall.Handle
 	v    uintptr
 	size int
@@ -44,7 +48,7 @@ func create(name string, size int) (*shmi, error) {
 		return nil, os.NewSyscallError("MapViewOfFile", err)
 	}
 
-	return &shmi{h, v, size}, nil
+	return &shmi{name, h, v, size}, nil
 }
 
 // open is called by a "client". It opens an *existing* file mapping object
@@ -69,7 +73,7 @@ func open(name string, size int) (*shmi, error) {
 		return nil, os.NewSyscallError("MapViewOfFile", err)
 	}
 
-	return &shmi{h, v, size}, nil
+	return &shmi{name, h, v, size}, nil
 }
 
 func (o *shmi) close() error {
@@ -84,6 +88,43 @@ func (o *shmi) close() error {
 	return nil
 }
 
+// resize shared memory to a larger size, preserving its contents. The size
+// of a file mapping is fixed, so the mapping is recreated under the same name
+// and the contents are copied over. This only works once every other process
+// has closed the mapping; otherwise the name still refers to the old one.
+func (o *shmi) resize(size int) error {
+	key, err := syscall.UTF16PtrFromString(o.name)
+	if err != nil {
+		return err
+	}
+
+	data := make([]byte, o.size)
+	copyPtr2Slice(o.v, data, 0, o.size)
+	syscall.UnmapViewOfFile(o.v)
+	syscall.CloseHandle(o.h)
+
+	h, err := windows.CreateFileMapping(windows.InvalidHandle, nil, windows.PAGE_READWRITE, 0, uint32(size), key)
+	resized := err == nil
+	if err != nil && err != windows.ERROR_ALREADY_EXISTS {
+		return os.NewSyscallError("CreateFileMapping", err)
+	}
+	v, err := windows.MapViewOfFile(h, windows.FILE_MAP_WRITE, 0, 0, 0)
+	if err != nil {
+		windows.CloseHandle(h)
+		return os.NewSyscallError("MapViewOfFile", err)
+	}
+	o.h = syscall.Handle(h)
+	o.v = v
+	if !resized {
+		// Another process kept the old mapping alive, so we reopened it unchanged
+		return errors.New("resize: the shared memory is still open in another process")
+	}
+
+	o.size = size
+	copySlice2Ptr(data, o.v, 0, o.size)
+	return nil
+}
+
 // read shared memory. return read size.
 func (o *shmi) readAt(p []byte, off int64) (n int, err error) {
 	if off >= int64(o.size) {
@@ -1,11 +1,170 @@
 package jumpboot
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"unsafe"
 )
 
+// Array headers make a SharedMemory region self-describing, so Python can
+// rebuild a NumPy array from it without both sides hardcoding the layout. The
+// header is at offset 0 and all integers are little-endian:
+//
+//	offset  size     field
+//	0       4        magic "JBNA"
+//	4       4        rank (number of dimensions)
+//	8       16       dtype name, NUL-padded (e.g. "float32")
+//	24      8        data offset from the start of the region
+//	32      8*rank   dimensions
+//
+// The data starts at the next multiple of ArrayDataAlignment after the
+// dimensions and holds the elements in C (row-major) order. jumpboot's Python
+// SharedMemory class reads the header with as_numpy().
+
+// ArrayDataAlignment is the alignment of the data written after an array header.
+const ArrayDataAlignment = 64
+
+// arrayHeaderMagic identifies a region holding an array header.
+var arrayHeaderMagic = []byte("JBNA")
+
+// arrayHeaderFixedSize is the size of the header before the dimensions.
+const arrayHeaderFixedSize = 32
+
+// arrayDTypeSize is the size of the dtype name field.
+const arrayDTypeSize = 16
+
+// arrayDataOffset returns where the data starts for an array of the given rank.
+func arrayDataOffset(rank int) int {
+	end := arrayHeaderFixedSize + 8*rank
+	return (end + ArrayDataAlignment - 1) / ArrayDataAlignment * ArrayDataAlignment
+}
+
+// WriteArrayHeader writes a header describing an array of dtype with the given
+// shape to the start of the shared memory, and returns the offset at which the
+// data starts. It returns an error if the dtype is not one GetDTypeSize knows
+// or if the header and data do not fit in the region.
+func (o *SharedMemory) WriteArrayHeader(dtype string, shape []int) (int, error) {
+	if o.m == nil {
+		return 0, errors.New("shared memory is closed")
+	}
+	elemSize, ok := dtypeSize(dtype)
+	if !ok {
+		return 0, fmt.Errorf("unsupported dtype: %s", dtype)
+	}
+	if dtype == "byte" {
+		// NumPy's "byte" is int8
+		dtype = "uint8"
+	}
+	count := 1
+	for _, dim := range shape {
+		if dim < 0 {
+			return 0, fmt.Errorf("invalid array shape %v", shape)
+		}
+		count *= dim
+	}
+	offset := arrayDataOffset(len(shape))
+	if offset+count*elemSize > o.m.size {
+		return 0, fmt.Errorf("%s array of shape %v does not fit in %d bytes of shared memory", dtype, shape, o.m.size)
+	}
+
+	header := make([]byte, arrayHeaderFixedSize+8*len(shape))
+	copy(header[0:4], arrayHeaderMagic)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(shape)))
+	copy(header[8:8+arrayDTypeSize], dtype)
+	binary.LittleEndian.PutUint64(header[24:32], uint64(offset))
+	for i, dim := range shape {
+		binary.LittleEndian.PutUint64(header[arrayHeaderFixedSize+8*i:], uint64(dim))
+	}
+	if _, err := o.m.writeAt(header, 0); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// ReadArrayHeader reads the header written by WriteArrayHeader (or by Python)
+// and returns the array's dtype, its shape and the offset of its data. It
+// returns an error if the region does not start with a valid header.
+func (o *SharedMemory) ReadArrayHeader() (dtype string, shape []int, dataOffset int, err error) {
+	if o.m == nil {
+		return "", nil, 0, errors.New("shared memory is closed")
+	}
+	if o.m.size < arrayHeaderFixedSize {
+		return "", nil, 0, errors.New("shared memory does not hold an array header")
+	}
+	header := make([]byte, arrayHeaderFixedSize)
+	if _, err := o.m.readAt(header, 0); err != nil {
+		return "", nil, 0, err
+	}
+	if !bytes.Equal(header[0:4], arrayHeaderMagic) {
+		return "", nil, 0, errors.New("shared memory does not hold an array header")
+	}
+	rank := int(binary.LittleEndian.Uint32(header[4:8]))
+	dtype = string(bytes.TrimRight(header[8:8+arrayDTypeSize], "\x00"))
+	elemSize, ok := dtypeSize(dtype)
+	if !ok {
+		return "", nil, 0, fmt.Errorf("unsupported dtype in array header: %q", dtype)
+	}
+	if arrayHeaderFixedSize+8*rank > o.m.size {
+		return "", nil, 0, fmt.Errorf("invalid array rank %d for %d bytes of shared memory", rank, o.m.size)
+	}
+	dataOffset = int(binary.LittleEndian.Uint64(header[24:32]))
+
+	dims := make([]byte, 8*rank)
+	if _, err := o.m.readAt(dims, arrayHeaderFixedSize); err != nil {
+		return "", nil, 0, err
+	}
+	shape = make([]int, rank)
+	size := uint64(elemSize)
+	for i := range shape {
+		dim := binary.LittleEndian.Uint64(dims[8*i:])
+		size *= dim
+		if dim > uint64(o.m.size) || size > uint64(o.m.size) {
+			return "", nil, 0, fmt.Errorf("array of shape %v does not fit in %d bytes of shared memory", shape[:i], o.m.size)
+		}
+		shape[i] = int(dim)
+	}
+	if dataOffset < arrayHeaderFixedSize+8*rank || uint64(dataOffset)+size > uint64(o.m.size) {
+		return "", nil, 0, fmt.Errorf("invalid array data offset %d for %d bytes of shared memory", dataOffset, o.m.size)
+	}
+	return dtype, shape, dataOffset, nil
+}
+
+// ArrayData returns a zero-copy view of the array data described by the
+// region's array header, sized to exactly the array's elements.
+//
+// Warning: The returned slice is only valid while the SharedMemory is open.
+func (o *SharedMemory) ArrayData() ([]byte, error) {
+	dtype, shape, offset, err := o.ReadArrayHeader()
+	if err != nil {
+		return nil, err
+	}
+	size := GetDTypeSize(dtype)
+	for _, dim := range shape {
+		size *= dim
+	}
+	return o.GetByteSlice(offset)[:size], nil
+}
+
+// GetArraySlice returns a zero-copy typed view of the array data described by
+// the region's array header, together with its shape. It returns an error if
+// the header's dtype is not the dtype of T.
+func GetArraySlice[T any](shm *SharedMemory) ([]T, []int, error) {
+	dtype, shape, offset, err := shm.ReadArrayHeader()
+	if err != nil {
+		return nil, nil, err
+	}
+	if want := GetDType[T](); dtype != want {
+		return nil, nil, fmt.Errorf("array has dtype %s, not %s", dtype, want)
+	}
+	count := 1
+	for _, dim := range shape {
+		count *= dim
+	}
+	return GetTypedSlice[T](shm, offset)[:count], shape, nil
+}
+
 // CreateSharedNumPyArray creates shared memory with metadata for NumPy array interop.
 // The shared memory includes a header with shape, dtype, and endianness information
 // that Python can use to create a corresponding NumPy array view.
@@ -94,30 +253,40 @@ func GetDType[T any]() string {
 // GetDTypeSize returns the size in bytes for a NumPy dtype string.
 // Panics if the dtype is not recognized.
 func GetDTypeSize(dtype string) int {
+	size, ok := dtypeSize(dtype)
+	if !ok {
+		panic(fmt.Sprintf("Unsupported dtype: %s", dtype))
+	}
+	return size
+}
+
+// dtypeSize returns the size in bytes for a NumPy dtype string, and false if
+// the dtype is not recognized.
+func dtypeSize(dtype string) (int, bool) {
 	switch dtype {
 	case "float32":
-		return 4
+		return 4, true
 	case "float64":
-		return 8
+		return 8, true
 	case "int32":
-		return 4
+		return 4, true
 	case "int64":
-		return 8
+		return 8, true
 	case "uint32":
-		return 4
+		return 4, true
 	case "uint64":
-		return 8
+		return 8, true
 	case "complex64":
-		return 8
+		return 8, true
 	case "complex128":
-		return 16
+		return 16, true
 	case "bool":
-		return 1
+		return 1, true
 	case "int8", "uint8", "byte":
-		return 1
+		return 1, true
 	case "int16", "uint16":
-		return 2
+		return 2, true
 	default:
-		panic(fmt.Sprintf("Unsupported dtype: %s", dtype))
+		return 0, false
 	}
 }
@@ -0,0 +1,2 @@
+def secret():
+    return "secret"
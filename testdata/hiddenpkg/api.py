@@ -0,0 +1,6 @@
+from ._internal import secret
+from hiddenpkg.tools import private
+
+
+def reveal():
+    return secret() + " " + private.helper()
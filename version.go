@@ -2,7 +2,11 @@ package jumpboot
 
 import (
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // Version represents a semantic version with major, minor, and patch components.
@@ -16,35 +20,63 @@ type Version struct {
 
 	// Patch is the patch version number (-1 if not specified).
 	Patch int
+
+	// Prerelease is the suffix after the release numbers, exactly as written,
+	// such as "rc1", "b2", ".dev0", ".post1" or "+local" (empty for a final
+	// release). Compare orders it by PEP 440.
+	Prerelease string
 }
 
+// versionRegex matches the release numbers at the start of a version string.
+var versionRegex = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// prereleaseRegex matches a PEP 440 suffix: a pre-release (aN, bN, rcN and
+// their spellings), a post-release, a dev release and a local version label.
+// Semver-style suffixes such as "-beta" or "-rc.1" match too.
+var prereleaseRegex = regexp.MustCompile(`^(?:[-_.]?(a|alpha|b|beta|c|rc|pre|preview)[-_.]?(\d*))?(?:[-_.]?(post|rev|r)[-_.]?(\d*)|-(\d+))?(?:[-_.]?(dev)[-_.]?(\d*))?(?:\+([a-z0-9._-]*))?$`)
+
 // ParseVersion parses a version string into a Version struct.
-// Accepts formats: "X.Y.Z", "X.Y", or "X". Any trailing text is ignored.
+// Accepts formats: "X.Y.Z", "X.Y", or "X", optionally followed by a PEP 440
+// pre-release, post-release, dev or local suffix, which is kept in Prerelease.
+// Any other trailing text is ignored.
 //
 // Examples:
-//   - "3.10.5" -> {3, 10, 5}
-//   - "3.10" -> {3, 10, -1}
-//   - "3" -> {3, -1, -1}
-//   - "2.1.0-beta" -> {2, 1, 0}
+//   - "3.10.5" -> {3, 10, 5, ""}
+//   - "3.10" -> {3, 10, -1, ""}
+//   - "3" -> {3, -1, -1, ""}
+//   - "3.12.0rc1" -> {3, 12, 0, "rc1"}
+//   - "2.1.0-beta" -> {2, 1, 0, "-beta"}
 func ParseVersion(versionStr string) (Version, error) {
+	versionStr = strings.TrimSpace(versionStr)
+	match := versionRegex.FindStringSubmatch(versionStr)
+	if match == nil {
+		return Version{}, fmt.Errorf("error parsing version: %q is not a version", versionStr)
+	}
 	version := Version{
 		Minor: -1,
 		Patch: -1,
 	}
-	_, err := fmt.Sscanf(versionStr, "%d.%d.%d", &version.Major, &version.Minor, &version.Patch)
-	if err != nil {
-		// If the version string is not in the format "X.Y.Z", try parsing it as "X.Y"
-		_, err = fmt.Sscanf(versionStr, "%d.%d", &version.Major, &version.Minor)
-		if err != nil {
-			// If the version string is not in the format "X.Y", try parsing it as "X"
-			_, err = fmt.Sscanf(versionStr, "%d", &version.Major)
-			if err != nil {
-				return Version{}, fmt.Errorf("error parsing version: %v", err)
-			}
+	var err error
+	if version.Major, err = strconv.Atoi(match[1]); err != nil {
+		return Version{}, fmt.Errorf("error parsing version: %v", err)
+	}
+	if match[2] != "" {
+		if version.Minor, err = strconv.Atoi(match[2]); err != nil {
+			return Version{}, fmt.Errorf("error parsing version: %v", err)
+		}
+	}
+	if match[3] != "" {
+		if version.Patch, err = strconv.Atoi(match[3]); err != nil {
+			return Version{}, fmt.Errorf("error parsing version: %v", err)
 		}
 	}
-	if version.Major < 0 || version.Minor < -1 || version.Patch < -1 {
-		return Version{}, fmt.Errorf("invalid version: %s", versionStr)
+
+	suffix := versionStr[len(match[0]):]
+	if i := strings.IndexFunc(suffix, unicode.IsSpace); i >= 0 {
+		suffix = suffix[:i]
+	}
+	if suffix != "" && prereleaseRegex.MatchString(strings.ToLower(suffix)) {
+		version.Prerelease = suffix
 	}
 	return version, nil
 }
@@ -62,19 +94,44 @@ func ParsePythonVersion(versionStr string) (Version, error) {
 }
 
 // ParsePipVersion parses output from "pip --version" (e.g., "pip 23.0 from ...").
+// It uses the first token after "pip" that parses as a version, so it skips
+// warning lines printed before the version line and extra text such as
+// "(python 3.11)" after it.
 func ParsePipVersion(versionStr string) (Version, error) {
-	parts := strings.Split(versionStr, " ")
-	if len(parts) < 2 {
-		return Version{}, fmt.Errorf("invalid version string: %s", versionStr)
+	for _, line := range strings.Split(versionStr, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || isPipWarning(fields[0]) {
+			continue
+		}
+		for i := 0; i+1 < len(fields); i++ {
+			if !strings.HasPrefix(fields[i], "pip") {
+				continue
+			}
+			if version, err := parseWholeVersion(fields[i+1]); err == nil {
+				return version, nil
+			}
+		}
 	}
-	if !strings.HasPrefix(parts[0], "pip") {
-		return Version{}, fmt.Errorf("invalid version string: %s", versionStr)
+	return Version{}, fmt.Errorf("invalid version string: %s", versionStr)
+}
+
+// isPipWarning reports whether a line starting with field is a message pip
+// or a wrapper printed rather than the version line, such as
+// "WARNING: pip is being invoked by an old script wrapper".
+func isPipWarning(field string) bool {
+	switch strings.ToUpper(strings.TrimSuffix(field, ":")) {
+	case "WARNING", "DEPRECATION", "NOTICE", "ERROR":
+		return strings.HasSuffix(field, ":")
 	}
-	return ParseVersion(parts[1])
+	return false
 }
 
 // Compare returns -1 if v < other, 0 if v == other, or 1 if v > other.
-// Comparison is done component by component (major, then minor, then patch).
+// Comparison is done component by component (major, then minor, then patch),
+// then by Prerelease following PEP 440: dev releases come first, then alpha,
+// beta and release candidates, then the final release, then post-releases
+// (e.g. 3.12.0.dev1 < 3.12.0a1 < 3.12.0rc1 < 3.12.0 < 3.12.0.post1). A local
+// label ("+build") sorts after the same version without one.
 func (v *Version) Compare(other Version) int {
 	if v.Major > other.Major {
 		return 1
@@ -94,19 +151,77 @@ func (v *Version) Compare(other Version) int {
 	if v.Patch < other.Patch {
 		return -1
 	}
-	return 0
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// prereleaseKey is a suffix broken into the parts PEP 440 orders by.
+type prereleaseKey struct {
+	phase int // -4 dev only, -3 alpha, -2 beta, -1 release candidate, 0 final
+	pre   int
+	post  int // -1 if not a post-release
+	dev   int // math.MaxInt if not a dev release
+	local string
+}
+
+// parsePrerelease breaks a suffix accepted by ParseVersion into its key.
+func parsePrerelease(s string) prereleaseKey {
+	key := prereleaseKey{post: -1, dev: math.MaxInt}
+	match := prereleaseRegex.FindStringSubmatch(strings.ToLower(s))
+	if match == nil {
+		return key
+	}
+	switch match[1] {
+	case "a", "alpha":
+		key.phase = -3
+	case "b", "beta":
+		key.phase = -2
+	case "c", "rc", "pre", "preview":
+		key.phase = -1
+	}
+	key.pre, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		key.post, _ = strconv.Atoi(match[4])
+	} else if match[5] != "" {
+		key.post, _ = strconv.Atoi(match[5])
+	}
+	if match[6] != "" {
+		key.dev, _ = strconv.Atoi(match[7])
+		if key.phase == 0 && key.post == -1 {
+			// A dev release of the final version comes before its pre-releases
+			key.phase = -4
+		}
+	}
+	key.local = match[8]
+	return key
+}
+
+// comparePrerelease orders two Prerelease suffixes by PEP 440.
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	ka, kb := parsePrerelease(a), parsePrerelease(b)
+	for _, c := range [][2]int{{ka.phase, kb.phase}, {ka.pre, kb.pre}, {ka.post, kb.post}, {ka.dev, kb.dev}} {
+		if c[0] != c[1] {
+			if c[0] < c[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(ka.local, kb.local)
 }
 
 // String returns the version as a string, omitting unspecified components.
-// Examples: "3.10.5", "3.10", "3"
+// Examples: "3.10.5", "3.10", "3", "3.12.0rc1"
 func (v *Version) String() string {
 	if v.Patch != -1 {
-		return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+		return fmt.Sprintf("%d.%d.%d%s", v.Major, v.Minor, v.Patch, v.Prerelease)
 	}
 	if v.Minor != -1 {
-		return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+		return fmt.Sprintf("%d.%d%s", v.Major, v.Minor, v.Prerelease)
 	}
-	return fmt.Sprintf("%d", v.Major)
+	return fmt.Sprintf("%d%s", v.Major, v.Prerelease)
 }
 
 // MinorString returns the version as "major.minor" (e.g., "3.10").
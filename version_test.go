@@ -0,0 +1,116 @@
+package jumpboot
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Version
+	}{
+		{"3.10.5", Version{Major: 3, Minor: 10, Patch: 5}},
+		{"3.10", Version{Major: 3, Minor: 10, Patch: -1}},
+		{"3", Version{Major: 3, Minor: -1, Patch: -1}},
+		{"3.12.0rc1", Version{Major: 3, Minor: 12, Patch: 0, Prerelease: "rc1"}},
+		{"3.13.0a1+", Version{Major: 3, Minor: 13, Patch: 0, Prerelease: "a1+"}},
+		{"2.1.0-beta", Version{Major: 2, Minor: 1, Patch: 0, Prerelease: "-beta"}},
+		{"1.26.0.dev0", Version{Major: 1, Minor: 26, Patch: 0, Prerelease: ".dev0"}},
+		{"24.0.post1", Version{Major: 24, Minor: 0, Patch: -1, Prerelease: ".post1"}},
+		{"1.2.3+cu121", Version{Major: 1, Minor: 2, Patch: 3, Prerelease: "+cu121"}},
+		{" 1.5.8\n", Version{Major: 1, Minor: 5, Patch: 8}},
+		{"23.0 from /usr/lib", Version{Major: 23, Minor: 0, Patch: -1}},
+		{"1.2.3-foo", Version{Major: 1, Minor: 2, Patch: 3}},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %+v, %v; want %+v", tt.input, got, err, tt.want)
+		}
+	}
+	if _, err := ParseVersion("python"); err == nil {
+		t.Error("expected an error for a string without a version")
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	for _, s := range []string{"3.10.5", "3.10", "3", "3.12.0rc1", "2.1.0-beta", "1.26.0.dev0", "24.0.post1", "1.2.3+cu121"} {
+		v, err := ParseVersion(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := v.String(); got != s {
+			t.Errorf("ParseVersion(%q).String() = %q", s, got)
+		}
+	}
+}
+
+func TestVersionComparePrerelease(t *testing.T) {
+	// Each version is lower than the next
+	ordered := []string{
+		"3.11.9",
+		"3.12.0.dev1",
+		"3.12.0.dev2",
+		"3.12.0a1.dev1",
+		"3.12.0a1",
+		"3.12.0a2",
+		"3.12.0b1",
+		"3.12.0rc1",
+		"3.12.0rc2",
+		"3.12.0",
+		"3.12.0+local",
+		"3.12.0.post1.dev1",
+		"3.12.0.post1",
+		"3.12.1.dev0",
+		"3.12.1",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := ParseVersion(ordered[i])
+			b, _ := ParseVersion(ordered[j])
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := a.Compare(b); got != want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	// Different spellings of the same pre-release are equal
+	a, _ := ParseVersion("2.1.0-beta")
+	b, _ := ParseVersion("2.1.0b0")
+	if a.Compare(b) != 0 {
+		t.Errorf("2.1.0-beta and 2.1.0b0 compare as %d", a.Compare(b))
+	}
+}
+
+func TestParsePipVersion(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"pip 23.0 from /usr/lib/python3/dist-packages/pip (python 3.11)", "23.0"},
+		{"pip 24.0 from /home/user/env/lib/python3.11/site-packages/pip (python 3.11)\n", "24.0"},
+		{"pip 24.1.2 from C:\\Program Files\\Python312\\Lib\\site-packages\\pip (python 3.12)\r\n", "24.1.2"},
+		{"WARNING: pip is being invoked by an old script wrapper. This will fail in a future version of pip.\n" +
+			"Please see https://github.com/pypa/pip/issues/5599 for advice on fixing the underlying issue.\n" +
+			"To avoid this problem you can invoke Python with '-m pip' instead of running pip directly.\n" +
+			"pip 21.3.1 from /usr/lib/python3/dist-packages/pip (python 3.10)\n", "21.3.1"},
+		{"DEPRECATION: pip 23 is deprecated\npip 23.3.2 from /opt/pip (python 3.9)", "23.3.2"},
+		{"pip3 22.0.2 from /usr/lib/python3/dist-packages/pip (python 3.10)", "22.0.2"},
+		{"pip 24.3.dev0 from /src/pip (python 3.13)", "24.3.dev0"},
+	}
+	for _, tt := range tests {
+		got, err := ParsePipVersion(tt.output)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("ParsePipVersion(%q) = %v, %v; want %s", tt.output, got.String(), err, tt.want)
+		}
+	}
+	for _, output := range []string{"", "python 3.11.7", "WARNING: pip 23.0 is old\n", "pip from /usr/lib"} {
+		if v, err := ParsePipVersion(output); err == nil {
+			t.Errorf("ParsePipVersion(%q) = %v, want an error", output, v.String())
+		}
+	}
+}